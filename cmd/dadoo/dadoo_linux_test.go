@@ -17,6 +17,7 @@ import (
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/guardian/rundmc/cgroups"
 	"code.cloudfoundry.org/guardian/rundmc/goci"
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
 	"code.cloudfoundry.org/lager/lagertest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -195,6 +196,22 @@ var _ = Describe("Dadoo", func() {
 					Expect(ioutil.ReadFile(filepath.Join(processDir, "exitcode"))).To(Equal([]byte("24")))
 				})
 
+				It("should record the exit status of the container process in the container dir", func() {
+					sess := runDadoo(specs.Process{
+						Args:        []string{"/bin/sh", "-c", "exit 24"},
+						Cwd:         "/",
+						ConsoleSize: &specs.Box{},
+					})
+					openIOPipes()
+					Eventually(sess).Should(gexec.Exit(24))
+
+					exitStatus, err := runrunc.ReadExitStatus(processDir)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(exitStatus.Code).To(Equal(24))
+					Expect(exitStatus.Reason).To(Equal(runrunc.ExitReasonExited))
+					Expect(exitStatus.Time).To(BeTemporally("~", time.Now(), 10*time.Second))
+				})
+
 				It("if the process is signalled the exitcode should be 128 + the signal number", func() {
 					if mode == "run" {
 						Skip("you can't kill PID 1, even in a PID namespace")
@@ -210,6 +227,70 @@ var _ = Describe("Dadoo", func() {
 
 					Eventually(filepath.Join(processDir, "exitcode")).Should(BeAnExistingFile())
 					Expect(ioutil.ReadFile(filepath.Join(processDir, "exitcode"))).To(Equal([]byte("137")))
+
+					exitStatus, err := runrunc.ReadExitStatus(processDir)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(exitStatus.Reason).To(Equal(runrunc.ExitReasonSignaled))
+					Expect(exitStatus.Signal).To(Equal("killed"))
+				})
+
+				Context("when the process has a timeout", func() {
+					BeforeEach(func() {
+						Expect(runrunc.WriteProcessTimeout(processDir, runrunc.ProcessTimeout{
+							Timeout:     500 * time.Millisecond,
+							GracePeriod: 500 * time.Millisecond,
+						})).To(Succeed())
+					})
+
+					It("kills the process once its grace period has expired and records the timed out exit code", func() {
+						sess := runDadoo(specs.Process{
+							Args:        []string{"/bin/sh", "-c", "trap '' TERM; sleep 60 & wait"},
+							Cwd:         "/",
+							ConsoleSize: &specs.Box{},
+						})
+						openIOPipes()
+						Eventually(sess, "5s").Should(gexec.Exit(runrunc.TimedOutExitCode))
+						Expect(sess).To(gbytes.Say("timed out after 500ms"))
+
+						Eventually(filepath.Join(processDir, "exitcode")).Should(BeAnExistingFile())
+						Expect(ioutil.ReadFile(filepath.Join(processDir, "exitcode"))).To(Equal([]byte("124")))
+
+						exitStatus, err := runrunc.ReadExitStatus(processDir)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(exitStatus.Reason).To(Equal(runrunc.ExitReasonTimedOut))
+					})
+				})
+
+				Context("when the output of the process is captured", func() {
+					BeforeEach(func() {
+						Expect(runrunc.WriteProcessLogConfig(processDir, runrunc.ProcessLogConfig{
+							MaxSize:  1024,
+							MaxFiles: 1,
+						})).To(Succeed())
+					})
+
+					It("writes the output to the log as well as the stdout and stderr pipes", func() {
+						sess := runDadoo(specs.Process{
+							Args:        []string{"/bin/sh", "-c", "echo some-output; echo some-error >&2"},
+							Cwd:         "/",
+							ConsoleSize: &specs.Box{},
+						})
+
+						_, err := os.OpenFile(stdinPipe, os.O_WRONLY, 0600)
+						Expect(err).NotTo(HaveOccurred())
+						stdout, err := os.OpenFile(stdoutPipe, os.O_RDONLY, 0600)
+						Expect(err).NotTo(HaveOccurred())
+						stderr, err := os.OpenFile(stderrPipe, os.O_RDONLY, 0600)
+						Expect(err).NotTo(HaveOccurred())
+
+						Eventually(gbytes.BufferReader(stdout)).Should(gbytes.Say("some-output"))
+						Eventually(gbytes.BufferReader(stderr)).Should(gbytes.Say("some-error"))
+						Eventually(sess).Should(gexec.Exit(0))
+
+						for stream, output := range map[string]string{"stdout": "some-output\n", "stderr": "some-error\n"} {
+							Expect(ioutil.ReadFile(filepath.Join(processDir, stream+"-00000000000000000000.log"))).To(Equal([]byte(output)))
+						}
+					})
 				})
 
 				It("should open the exit pipe and close it when it exits", func() {
@@ -12,13 +12,17 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
 
 	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/rundmc/cgroups"
 	"code.cloudfoundry.org/guardian/rundmc/execrunner/dadoo"
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
 
 	"github.com/eapache/go-resiliency/retrier"
 	"github.com/opencontainers/runc/libcontainer/system"
@@ -38,6 +42,7 @@ func main() {
 func run() int {
 	tty := flag.Bool("tty", false, "tty requested")
 	socketDirPath := flag.String("socket-dir-path", "", "path to a dir in which to store console sockets")
+	cgroupsMountpoint := flag.String("cgroups-mountpoint", cgroups.DefaultMountpoint, "path under which the cgroup hierarchies are mounted")
 	flag.Parse()
 
 	runMode := flag.Args()[0] // exec or run
@@ -70,8 +75,27 @@ func run() int {
 		return 2
 	}
 
+	logConfig, err := runrunc.ReadProcessLogConfig(processStateDir)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+
+	var stdout, stderr io.Writer = stdoutW, stderrW
+	if logConfig != nil {
+		stdoutLog := dadoo.NewLogWriter(processStateDir, "stdout", *logConfig)
+		stderrLog := dadoo.NewLogWriter(processStateDir, "stderr", *logConfig)
+		defer closeFile(stdoutLog, stderrLog)
+
+		// the output is captured before it is passed on, so that the log is
+		// complete even while nothing is reading from the fifos
+		stdout = io.MultiWriter(stdoutLog, stdoutW)
+		stderr = io.MultiWriter(stderrLog, stderrW)
+	}
+
 	ioWg := &sync.WaitGroup{}
 	var runcExecCmd *exec.Cmd
+	var outputPipes []io.Closer
 	if *tty {
 		winsz, err := openFile(filepath.Join(processStateDir, "winsz"), os.O_RDWR)
 		defer closeFile(winsz)
@@ -83,13 +107,31 @@ func run() int {
 		if len(*socketDirPath) > MaxSocketDirPathLength {
 			return logAndExit(fmt.Sprintf("value for --socket-dir-path cannot exceed %d characters in length", MaxSocketDirPathLength))
 		}
-		ttySocketPath := setupTTYSocket(stdinR, stdoutW, winsz, pidFilePath, *socketDirPath, ioWg)
+		ttySocketPath := setupTTYSocket(stdinR, stdout, winsz, pidFilePath, *socketDirPath, ioWg)
 		runcExecCmd = dadoo.BuildRuncCommand(runtime, runMode, processStateDir, containerId, ttySocketPath, logFile)
 	} else {
 		runcExecCmd = dadoo.BuildRuncCommand(runtime, runMode, processStateDir, containerId, "", logFile)
 		runcExecCmd.Stdin = stdinR
 		runcExecCmd.Stdout = stdoutW
 		runcExecCmd.Stderr = stderrW
+
+		if logConfig != nil {
+			stdoutPipe, err := copyOutput(stdout, ioWg)
+			if err != nil {
+				fmt.Println(err)
+				return 2
+			}
+
+			stderrPipe, err := copyOutput(stderr, ioWg)
+			if err != nil {
+				fmt.Println(err)
+				return 2
+			}
+
+			runcExecCmd.Stdout = stdoutPipe
+			runcExecCmd.Stderr = stderrPipe
+			outputPipes = append(outputPipes, stdoutPipe, stderrPipe)
+		}
 	}
 
 	// we need to be the subreaper so we can wait on the detached container process
@@ -100,6 +142,10 @@ func run() int {
 		return 2
 	}
 
+	// only the process holds the write ends now, so the copies finish when it
+	// and any children it passed them to have exited
+	closeFile(outputPipes...)
+
 	runcExitStatus := awaitRuncExit(runcExecCmd.Process)
 	logFD.Close() // No more logs from runc so close fd
 
@@ -112,7 +158,91 @@ func run() int {
 	containerPid, err := parsePid(pidFilePath)
 	check(err)
 
-	return waitForContainerToExit(processStateDir, containerPid, signals, ioWg)
+	timeout, err := runrunc.ReadProcessTimeout(processStateDir)
+	check(err)
+
+	timedOut := func() bool { return false }
+	if timeout != nil {
+		timedOut = enforceTimeout(containerPid, *timeout)
+	}
+
+	oomKilled := dadoo.WatchOOMKills(*cgroupsMountpoint, containerPid)
+
+	return waitForContainerToExit(processStateDir, containerPid, signals, ioWg, timedOut, oomKilled)
+}
+
+// enforceTimeout sends the process SIGTERM once its timeout has expired, and
+// SIGKILLs it and its descendants if it is still running after the grace
+// period. The returned func reports whether the timeout has expired.
+func enforceTimeout(pid int, timeout runrunc.ProcessTimeout) func() bool {
+	var expired int32
+
+	go func() {
+		time.Sleep(timeout.Timeout)
+		atomic.StoreInt32(&expired, 1)
+
+		fmt.Printf("process with PID %d timed out after %s\n", pid, timeout.Timeout)
+		syscall.Kill(pid, syscall.SIGTERM)
+
+		time.Sleep(timeout.GracePeriod)
+		fmt.Printf("killing process with PID %d after grace period of %s\n", pid, timeout.GracePeriod)
+		killProcessTree(pid)
+	}()
+
+	return func() bool {
+		return atomic.LoadInt32(&expired) == 1
+	}
+}
+
+// copyOutput returns the write end of a pipe whose contents are copied to w
+// until it has been closed by every process holding it
+func copyOutput(w io.Writer, ioWg *sync.WaitGroup) (*os.File, error) {
+	r, pipeW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	ioWg.Add(1)
+	go func() {
+		defer ioWg.Done()
+		defer r.Close()
+		io.Copy(w, r)
+	}()
+
+	return pipeW, nil
+}
+
+// killProcessTree stops the process so that it cannot fork any more children,
+// then kills its descendants before it, since they would be reparented to the
+// container's init, rather than dadoo, once it exits
+func killProcessTree(pid int) {
+	syscall.Kill(pid, syscall.SIGSTOP)
+
+	for _, child := range childPids(pid) {
+		killProcessTree(child)
+	}
+
+	syscall.Kill(pid, syscall.SIGKILL)
+}
+
+func childPids(pid int) []int {
+	childrenFiles, _ := filepath.Glob(fmt.Sprintf("/proc/%d/task/*/children", pid))
+
+	var children []int
+	for _, childrenFile := range childrenFiles {
+		contents, err := ioutil.ReadFile(childrenFile)
+		if err != nil {
+			continue
+		}
+
+		for _, field := range strings.Fields(string(contents)) {
+			if child, err := strconv.Atoi(field); err == nil {
+				children = append(children, child)
+			}
+		}
+	}
+
+	return children
 }
 
 func awaitRuncExit(runcProc *os.Process) int {
@@ -170,7 +300,7 @@ func openStdioKeepAlivePipes(processStateDir string) (io.ReadCloser, io.ReadClos
 	return keepStdoutAlive, keepStderrAlive, nil
 }
 
-func waitForContainerToExit(processStateDir string, containerPid int, signals chan os.Signal, ioWg *sync.WaitGroup) (exitCode int) {
+func waitForContainerToExit(processStateDir string, containerPid int, signals chan os.Signal, ioWg *sync.WaitGroup, timedOut, oomKilled func() bool) (exitCode int) {
 	for range signals {
 		for {
 			var status syscall.WaitStatus
@@ -181,13 +311,31 @@ func waitForContainerToExit(processStateDir string, containerPid int, signals ch
 			}
 
 			if wpid == containerPid {
-				exitCode = status.ExitStatus()
+				exitStatus := runrunc.ExitStatus{
+					Code:   status.ExitStatus(),
+					Time:   time.Now(),
+					Reason: runrunc.ExitReasonExited,
+				}
 				if status.Signaled() {
-					exitCode = 128 + int(status.Signal())
+					exitStatus.Code = 128 + int(status.Signal())
+					exitStatus.Reason = runrunc.ExitReasonSignaled
+					exitStatus.Signal = status.Signal().String()
+
+					if status.Signal() == syscall.SIGKILL && oomKilled() {
+						exitStatus.Reason = runrunc.ExitReasonOOMKilled
+					}
+				}
+				if timedOut() {
+					exitStatus.Code = runrunc.TimedOutExitCode
+					exitStatus.Reason = runrunc.ExitReasonTimedOut
 				}
+				exitCode = exitStatus.Code
 
 				ioWg.Wait() // wait for full output to be collected
 
+				// the exit status is recorded before the exitcode, whose
+				// presence tells gdn that the process has finished
+				check(runrunc.WriteExitStatus(processStateDir, exitStatus))
 				check(ioutil.WriteFile(filepath.Join(processStateDir, "exitcode"), []byte(strconv.Itoa(exitCode)), 0600))
 				return exitCode
 			}
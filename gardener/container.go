@@ -18,6 +18,7 @@ type container struct {
 	volumizer       Volumizer
 	networker       Networker
 	propertyManager PropertyManager
+	stopGracePeriod time.Duration
 }
 
 func (c *container) Handle() string {
@@ -33,7 +34,7 @@ func (c *container) Attach(processID string, io garden.ProcessIO) (garden.Proces
 }
 
 func (c *container) Stop(kill bool) error {
-	return c.containerizer.Stop(c.logger, c.handle, kill)
+	return c.containerizer.Stop(c.logger, c.handle, StopSpec{Kill: kill, GracePeriod: c.stopGracePeriod})
 }
 
 func (c *container) Info() (garden.ContainerInfo, error) {
@@ -74,6 +75,7 @@ func (c *container) Info() (garden.ContainerInfo, error) {
 		Events:        actualContainerSpec.Events,
 		Properties:    properties,
 		MappedPorts:   mappedPorts,
+		ProcessIDs:    actualContainerSpec.ProcessIDs,
 	}, nil
 }
 
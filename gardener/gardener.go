@@ -28,8 +28,24 @@ const ContainerIPKey = "garden.network.container-ip"
 const BridgeIPKey = "garden.network.host-ip"
 const ExternalIPKey = "garden.network.external-ip"
 const MappedPortsKey = "garden.network.mapped-ports"
+
+// EgressIPKey is the host address to which traffic leaving a container is
+// source NATed. A container may request one of the operator's egress IPs by
+// setting this property; the address actually used is recorded under it.
+const EgressIPKey = "garden.network.egress-ip"
+
+// NetworkAttachmentsKey is a comma-separated list of the operator-defined
+// networks on which a container should be given additional interfaces. The
+// address and interface of each attachment are recorded under
+// NetworkAttachmentPropertyPrefix + <network name>.
+const NetworkAttachmentsKey = "garden.network.attachments"
+const NetworkAttachmentPropertyPrefix = "garden.network.attachment."
 const GraceTimeKey = "garden.grace-time"
 
+// RuntimeClassKey names the operator-defined runtime class with which a
+// container should be run, rather than the default runtime
+const RuntimeClassKey = "garden.runtime-class"
+
 const VolumizerSession = "volumizer"
 
 type SysInfoProvider interface {
@@ -46,12 +62,14 @@ type Containerizer interface {
 
 	Run(log lager.Logger, handle string, spec garden.ProcessSpec, io garden.ProcessIO) (garden.Process, error)
 	Attach(log lager.Logger, handle string, processGUID string, io garden.ProcessIO) (garden.Process, error)
-	Stop(log lager.Logger, handle string, kill bool) error
+	Stop(log lager.Logger, handle string, spec StopSpec) error
 	Destroy(log lager.Logger, handle string) error
 	RemoveBundle(log lager.Logger, handle string) error
 
 	Info(log lager.Logger, handle string) (ActualContainerSpec, error)
 	Metrics(log lager.Logger, handle string) (ActualContainerMetrics, error)
+
+	WatchEvents(log lager.Logger, handle string)
 }
 
 type Networker interface {
@@ -124,8 +142,14 @@ type DesiredContainerSpec struct {
 	// Container is privileged
 	Privileged bool
 
+	// Container shares the host's network namespace
+	HostNetwork bool
+
 	Limits garden.Limits
 
+	// The runtime class with which to run the container, if not the default
+	RuntimeClass string
+
 	BaseConfig specs.Spec
 }
 
@@ -160,6 +184,16 @@ type ActualContainerMetrics struct {
 	Memory garden.ContainerMemoryStat
 }
 
+// StopSpec describes how the processes in a container should be stopped
+type StopSpec struct {
+	// Kill the processes straight away, rather than sending them SIGTERM first
+	Kill bool
+
+	// How long to wait for the processes to exit after signalling them before
+	// killing them, defaulting to 10 seconds
+	GracePeriod time.Duration
+}
+
 // Gardener orchestrates other components to implement the Garden API
 type Gardener struct {
 	// SysInfoProvider returns total memory and total disk
@@ -191,6 +225,16 @@ type Gardener struct {
 	Restorer Restorer
 
 	AllowPrivilgedContainers bool
+
+	// AllowHostNetworkContainers permits privileged containers to share the
+	// host's network namespace
+	AllowHostNetworkContainers bool
+
+	// StopGracePeriod is how long processes are given to exit after being
+	// signalled when a container is stopped or destroyed without choosing a
+	// grace period. When zero, stopping uses the stopper's default and
+	// destroying kills the processes without signalling them first.
+	StopGracePeriod time.Duration
 }
 
 // Create creates a container by combining the results of networker.Network,
@@ -211,6 +255,16 @@ func (g *Gardener) Create(spec garden.ContainerSpec) (ctr garden.Container, err
 		return nil, errors.New("privileged container creation is disabled")
 	}
 
+	if spec.Network == NetworkModeHost {
+		if !g.AllowHostNetworkContainers {
+			return nil, errors.New("host network mode is disabled")
+		}
+
+		if !spec.Privileged {
+			return nil, errors.New("host network mode requires a privileged container")
+		}
+	}
+
 	knownHandles, err := g.Containerizer.Handles()
 	if err != nil {
 		return nil, err
@@ -253,13 +307,15 @@ func (g *Gardener) Create(spec garden.ContainerSpec) (ctr garden.Container, err
 	}
 
 	desiredSpec := DesiredContainerSpec{
-		Handle:     spec.Handle,
-		Hostname:   spec.Handle,
-		Privileged: spec.Privileged,
-		Env:        spec.Env,
-		BindMounts: spec.BindMounts,
-		Limits:     spec.Limits,
-		BaseConfig: runtimeSpec,
+		Handle:       spec.Handle,
+		Hostname:     spec.Handle,
+		Privileged:   spec.Privileged,
+		HostNetwork:  spec.Network == NetworkModeHost,
+		Env:          spec.Env,
+		BindMounts:   spec.BindMounts,
+		Limits:       spec.Limits,
+		RuntimeClass: spec.Properties[RuntimeClassKey],
+		BaseConfig:   runtimeSpec,
 	}
 	if err := g.Containerizer.Create(log, desiredSpec); err != nil {
 		return nil, err
@@ -316,6 +372,7 @@ func (g *Gardener) lookup(handle string) garden.Container {
 		volumizer:       g.Volumizer,
 		networker:       g.Networker,
 		propertyManager: g.PropertyManager,
+		stopGracePeriod: g.StopGracePeriod,
 	}
 }
 
@@ -334,6 +391,14 @@ func (g *Gardener) Destroy(handle string) error {
 		return garden.ContainerNotFoundError{Handle: handle}
 	}
 
+	if g.StopGracePeriod > 0 {
+		// the container is destroyed regardless, which kills anything left
+		// running
+		if err := g.Containerizer.Stop(log, handle, StopSpec{GracePeriod: g.StopGracePeriod}); err != nil {
+			log.Info("stop-failed-destroying-anyway", lager.Data{"error": err.Error()})
+		}
+	}
+
 	return g.destroy(log, handle)
 }
 
@@ -510,7 +575,10 @@ func (g *Gardener) Start() error {
 		return err
 	}
 
+	cleanedUp := map[string]bool{}
 	for _, handle := range g.Restorer.Restore(log, handles) {
+		cleanedUp[handle] = true
+
 		destroyLog := log.Session("clean-up-container", lager.Data{"handle": handle})
 		destroyLog.Info("start")
 
@@ -522,5 +590,15 @@ func (g *Gardener) Start() error {
 		destroyLog.Info("cleaned-up")
 	}
 
+	// nothing has been watching the surviving containers for events since
+	// the previous gdn stopped
+	for _, handle := range handles {
+		if cleanedUp[handle] {
+			continue
+		}
+
+		g.Containerizer.WatchEvents(log, handle)
+	}
+
 	return nil
 }
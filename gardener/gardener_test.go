@@ -333,6 +333,17 @@ var _ = Describe("Gardener", func() {
 			Expect(spec.Env).To(Equal([]string{"FOO=bar"}))
 		})
 
+		It("passes the requested runtime class to containerizer", func() {
+			_, err := gdnr.Create(garden.ContainerSpec{Properties: garden.Properties{
+				gardener.RuntimeClassKey: "sandboxed",
+			}})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(containerizer.CreateCallCount()).To(Equal(1))
+			_, spec := containerizer.CreateArgsForCall(0)
+			Expect(spec.RuntimeClass).To(Equal("sandboxed"))
+		})
+
 		Context("when passed a handle that already exists", func() {
 			var (
 				containerSpec garden.ContainerSpec
@@ -462,6 +473,43 @@ var _ = Describe("Gardener", func() {
 			Expect(c).To(Equal(d))
 		})
 
+		Context("when the host network is requested", func() {
+			It("returns an error when host networking is not permitted", func() {
+				gdnr.AllowPrivilgedContainers = true
+				_, err := gdnr.Create(garden.ContainerSpec{Network: "host", Privileged: true})
+				Expect(err).To(MatchError("host network mode is disabled"))
+				Expect(volumizer.CreateCallCount()).To(Equal(0))
+			})
+
+			Context("when host networking is permitted", func() {
+				BeforeEach(func() {
+					gdnr.AllowHostNetworkContainers = true
+					gdnr.AllowPrivilgedContainers = true
+				})
+
+				It("returns an error for unprivileged containers", func() {
+					_, err := gdnr.Create(garden.ContainerSpec{Network: "host"})
+					Expect(err).To(MatchError("host network mode requires a privileged container"))
+				})
+
+				It("asks the containerizer to share the host network", func() {
+					_, err := gdnr.Create(garden.ContainerSpec{Network: "host", Privileged: true})
+					Expect(err).NotTo(HaveOccurred())
+
+					_, spec := containerizer.CreateArgsForCall(0)
+					Expect(spec.HostNetwork).To(BeTrue())
+				})
+			})
+		})
+
+		It("does not share the host network by default", func() {
+			_, err := gdnr.Create(garden.ContainerSpec{})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, spec := containerizer.CreateArgsForCall(0)
+			Expect(spec.HostNetwork).To(BeFalse())
+		})
+
 		Context("when creating privileged containers is not permitted, and a privileged container is requested", func() {
 			It("returns an error", func() {
 				_, err := gdnr.Create(garden.ContainerSpec{Privileged: true})
@@ -695,6 +743,14 @@ var _ = Describe("Gardener", func() {
 			Expect(handle).To(Equal("container2"))
 		})
 
+		It("should watch for events in the restored containers", func() {
+			restorer.RestoreReturns([]string{"container2"})
+			Expect(gdnr.Start()).To(Succeed())
+			Expect(containerizer.WatchEventsCallCount()).To(Equal(1))
+			_, handle := containerizer.WatchEventsArgsForCall(0)
+			Expect(handle).To(Equal("container1"))
+		})
+
 		It("should return the error when it failes to get a list of handles", func() {
 			containerizer.HandlesReturns([]string{}, errors.New("banana"))
 			Expect(gdnr.Start()).To(MatchError("banana"))
@@ -773,9 +829,24 @@ var _ = Describe("Gardener", func() {
 			Expect(container.Stop(true)).To(Succeed())
 			Expect(containerizer.StopCallCount()).To(Equal(1))
 
-			_, handle, kill := containerizer.StopArgsForCall(0)
+			_, handle, spec := containerizer.StopArgsForCall(0)
 			Expect(handle).To(Equal("banana"))
-			Expect(kill).To(Equal(true))
+			Expect(spec).To(Equal(gardener.StopSpec{Kill: true}))
+		})
+
+		Context("when a stop grace period is configured", func() {
+			BeforeEach(func() {
+				gdnr.StopGracePeriod = 45 * time.Second
+			})
+
+			It("stops the processes with it", func() {
+				container, err := gdnr.Lookup("banana")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(container.Stop(false)).To(Succeed())
+				_, _, spec := containerizer.StopArgsForCall(0)
+				Expect(spec).To(Equal(gardener.StopSpec{GracePeriod: 45 * time.Second}))
+			})
 		})
 	})
 
@@ -792,6 +863,38 @@ var _ = Describe("Gardener", func() {
 			Expect(handle).To(Equal("some-handle"))
 		})
 
+		It("does not stop the processes first", func() {
+			Expect(gdnr.Destroy("some-handle")).To(Succeed())
+			Expect(containerizer.StopCallCount()).To(Equal(0))
+		})
+
+		Context("when a stop grace period is configured", func() {
+			BeforeEach(func() {
+				gdnr.StopGracePeriod = 45 * time.Second
+			})
+
+			It("stops the processes with it before destroying the container", func() {
+				containerizer.DestroyStub = func(lager.Logger, string) error {
+					Expect(containerizer.StopCallCount()).To(Equal(1))
+					return nil
+				}
+
+				Expect(gdnr.Destroy("some-handle")).To(Succeed())
+
+				_, handle, spec := containerizer.StopArgsForCall(0)
+				Expect(handle).To(Equal("some-handle"))
+				Expect(spec).To(Equal(gardener.StopSpec{GracePeriod: 45 * time.Second}))
+				Expect(containerizer.DestroyCallCount()).To(Equal(1))
+			})
+
+			It("destroys the container even when stopping the processes fails", func() {
+				containerizer.StopReturns(errors.New("not-running"))
+
+				Expect(gdnr.Destroy("some-handle")).To(Succeed())
+				Expect(containerizer.DestroyCallCount()).To(Equal(1))
+			})
+		})
+
 		It("asks the networker to destroy the container network", func() {
 			gdnr.Destroy("some-handle")
 			Expect(networker.DestroyCallCount()).To(Equal(1))
@@ -1087,6 +1190,17 @@ var _ = Describe("Gardener", func() {
 			Expect(info.ContainerPath).To(Equal("/foo/bar/baz"))
 		})
 
+		It("returns the IDs of the container's processes", func() {
+			containerizer.InfoReturns(gardener.ActualContainerSpec{
+				ProcessIDs: []string{"process-1", "process-2"},
+			}, nil)
+
+			info, err := container.Info()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(info.ProcessIDs).To(Equal([]string{"process-1", "process-2"}))
+		})
+
 		Context("when getting the ActualContainerSpec fails", func() {
 			It("return the error", func() {
 				containerizer.InfoReturns(gardener.ActualContainerSpec{}, errors.New("info-error"))
@@ -94,19 +94,6 @@ type FakeContainerizer struct {
 		result1 garden.Process
 		result2 error
 	}
-	StopStub        func(log lager.Logger, handle string, kill bool) error
-	stopMutex       sync.RWMutex
-	stopArgsForCall []struct {
-		log    lager.Logger
-		handle string
-		kill   bool
-	}
-	stopReturns struct {
-		result1 error
-	}
-	stopReturnsOnCall map[int]struct {
-		result1 error
-	}
 	DestroyStub        func(log lager.Logger, handle string) error
 	destroyMutex       sync.RWMutex
 	destroyArgsForCall []struct {
@@ -159,6 +146,25 @@ type FakeContainerizer struct {
 		result1 gardener.ActualContainerMetrics
 		result2 error
 	}
+	StopStub        func(log lager.Logger, handle string, spec gardener.StopSpec) error
+	stopMutex       sync.RWMutex
+	stopArgsForCall []struct {
+		log    lager.Logger
+		handle string
+		spec   gardener.StopSpec
+	}
+	stopReturns struct {
+		result1 error
+	}
+	stopReturnsOnCall map[int]struct {
+		result1 error
+	}
+	WatchEventsStub        func(log lager.Logger, handle string)
+	watchEventsMutex       sync.RWMutex
+	watchEventsArgsForCall []struct {
+		log    lager.Logger
+		handle string
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -466,56 +472,6 @@ func (fake *FakeContainerizer) AttachReturnsOnCall(i int, result1 garden.Process
 	}{result1, result2}
 }
 
-func (fake *FakeContainerizer) Stop(log lager.Logger, handle string, kill bool) error {
-	fake.stopMutex.Lock()
-	ret, specificReturn := fake.stopReturnsOnCall[len(fake.stopArgsForCall)]
-	fake.stopArgsForCall = append(fake.stopArgsForCall, struct {
-		log    lager.Logger
-		handle string
-		kill   bool
-	}{log, handle, kill})
-	fake.recordInvocation("Stop", []interface{}{log, handle, kill})
-	fake.stopMutex.Unlock()
-	if fake.StopStub != nil {
-		return fake.StopStub(log, handle, kill)
-	}
-	if specificReturn {
-		return ret.result1
-	}
-	return fake.stopReturns.result1
-}
-
-func (fake *FakeContainerizer) StopCallCount() int {
-	fake.stopMutex.RLock()
-	defer fake.stopMutex.RUnlock()
-	return len(fake.stopArgsForCall)
-}
-
-func (fake *FakeContainerizer) StopArgsForCall(i int) (lager.Logger, string, bool) {
-	fake.stopMutex.RLock()
-	defer fake.stopMutex.RUnlock()
-	return fake.stopArgsForCall[i].log, fake.stopArgsForCall[i].handle, fake.stopArgsForCall[i].kill
-}
-
-func (fake *FakeContainerizer) StopReturns(result1 error) {
-	fake.StopStub = nil
-	fake.stopReturns = struct {
-		result1 error
-	}{result1}
-}
-
-func (fake *FakeContainerizer) StopReturnsOnCall(i int, result1 error) {
-	fake.StopStub = nil
-	if fake.stopReturnsOnCall == nil {
-		fake.stopReturnsOnCall = make(map[int]struct {
-			result1 error
-		})
-	}
-	fake.stopReturnsOnCall[i] = struct {
-		result1 error
-	}{result1}
-}
-
 func (fake *FakeContainerizer) Destroy(log lager.Logger, handle string) error {
 	fake.destroyMutex.Lock()
 	ret, specificReturn := fake.destroyReturnsOnCall[len(fake.destroyArgsForCall)]
@@ -718,6 +674,81 @@ func (fake *FakeContainerizer) MetricsReturnsOnCall(i int, result1 gardener.Actu
 	}{result1, result2}
 }
 
+func (fake *FakeContainerizer) Stop(log lager.Logger, handle string, spec gardener.StopSpec) error {
+	fake.stopMutex.Lock()
+	ret, specificReturn := fake.stopReturnsOnCall[len(fake.stopArgsForCall)]
+	fake.stopArgsForCall = append(fake.stopArgsForCall, struct {
+		log    lager.Logger
+		handle string
+		spec   gardener.StopSpec
+	}{log, handle, spec})
+	fake.recordInvocation("Stop", []interface{}{log, handle, spec})
+	fake.stopMutex.Unlock()
+	if fake.StopStub != nil {
+		return fake.StopStub(log, handle, spec)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.stopReturns.result1
+}
+
+func (fake *FakeContainerizer) StopCallCount() int {
+	fake.stopMutex.RLock()
+	defer fake.stopMutex.RUnlock()
+	return len(fake.stopArgsForCall)
+}
+
+func (fake *FakeContainerizer) StopArgsForCall(i int) (lager.Logger, string, gardener.StopSpec) {
+	fake.stopMutex.RLock()
+	defer fake.stopMutex.RUnlock()
+	return fake.stopArgsForCall[i].log, fake.stopArgsForCall[i].handle, fake.stopArgsForCall[i].spec
+}
+
+func (fake *FakeContainerizer) StopReturns(result1 error) {
+	fake.StopStub = nil
+	fake.stopReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerizer) StopReturnsOnCall(i int, result1 error) {
+	fake.StopStub = nil
+	if fake.stopReturnsOnCall == nil {
+		fake.stopReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.stopReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerizer) WatchEvents(log lager.Logger, handle string) {
+	fake.watchEventsMutex.Lock()
+	fake.watchEventsArgsForCall = append(fake.watchEventsArgsForCall, struct {
+		log    lager.Logger
+		handle string
+	}{log, handle})
+	fake.recordInvocation("WatchEvents", []interface{}{log, handle})
+	fake.watchEventsMutex.Unlock()
+	if fake.WatchEventsStub != nil {
+		fake.WatchEventsStub(log, handle)
+	}
+}
+
+func (fake *FakeContainerizer) WatchEventsCallCount() int {
+	fake.watchEventsMutex.RLock()
+	defer fake.watchEventsMutex.RUnlock()
+	return len(fake.watchEventsArgsForCall)
+}
+
+func (fake *FakeContainerizer) WatchEventsArgsForCall(i int) (lager.Logger, string) {
+	fake.watchEventsMutex.RLock()
+	defer fake.watchEventsMutex.RUnlock()
+	return fake.watchEventsArgsForCall[i].log, fake.watchEventsArgsForCall[i].handle
+}
+
 func (fake *FakeContainerizer) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -733,8 +764,6 @@ func (fake *FakeContainerizer) Invocations() map[string][][]interface{} {
 	defer fake.runMutex.RUnlock()
 	fake.attachMutex.RLock()
 	defer fake.attachMutex.RUnlock()
-	fake.stopMutex.RLock()
-	defer fake.stopMutex.RUnlock()
 	fake.destroyMutex.RLock()
 	defer fake.destroyMutex.RUnlock()
 	fake.removeBundleMutex.RLock()
@@ -743,6 +772,10 @@ func (fake *FakeContainerizer) Invocations() map[string][][]interface{} {
 	defer fake.infoMutex.RUnlock()
 	fake.metricsMutex.RLock()
 	defer fake.metricsMutex.RUnlock()
+	fake.stopMutex.RLock()
+	defer fake.stopMutex.RUnlock()
+	fake.watchEventsMutex.RLock()
+	defer fake.watchEventsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,120 @@
+package gardener
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+// NetworkModeKey records how a container is networked, when it is not
+// networked by the Networker
+const NetworkModeKey = "garden.network.mode"
+
+const (
+	// NetworkModeNone gives the container a fresh network namespace with only
+	// a loopback interface
+	NetworkModeNone = "none"
+
+	// NetworkModeHost shares the host's network namespace with the container.
+	// It is only available to privileged containers, and only when enabled by
+	// the operator.
+	NetworkModeHost = "host"
+)
+
+func isUnmanagedNetworkMode(spec string) bool {
+	return spec == NetworkModeNone || spec == NetworkModeHost
+}
+
+type modeNetworker struct {
+	Networker
+
+	propertyManager PropertyManager
+	hostIP          string
+}
+
+// NewModeNetworker returns a Networker which handles containers requesting the
+// "none" or "host" network modes itself, and delegates all other containers
+// to the given networker. Containers in these modes do not consume subnets,
+// ports or firewall rules.
+func NewModeNetworker(networker Networker, propertyManager PropertyManager, hostIP string) Networker {
+	return &modeNetworker{
+		Networker:       networker,
+		propertyManager: propertyManager,
+		hostIP:          hostIP,
+	}
+}
+
+func (n *modeNetworker) Network(log lager.Logger, spec garden.ContainerSpec, pid int) error {
+	if !isUnmanagedNetworkMode(spec.Network) {
+		return n.Networker.Network(log, spec, pid)
+	}
+
+	log.Info("skipping-network", lager.Data{"handle": spec.Handle, "mode": spec.Network})
+
+	n.propertyManager.Set(spec.Handle, NetworkModeKey, spec.Network)
+	if spec.Network == NetworkModeHost {
+		n.propertyManager.Set(spec.Handle, ContainerIPKey, n.hostIP)
+		n.propertyManager.Set(spec.Handle, ExternalIPKey, n.hostIP)
+	}
+
+	return nil
+}
+
+func (n *modeNetworker) Destroy(log lager.Logger, handle string) error {
+	if n.unmanaged(handle) {
+		return nil
+	}
+
+	return n.Networker.Destroy(log, handle)
+}
+
+func (n *modeNetworker) NetIn(log lager.Logger, handle string, hostPort, containerPort uint32) (uint32, uint32, error) {
+	if mode, ok := n.mode(handle); ok {
+		return 0, 0, fmt.Errorf("net-in is not supported in %s network mode", mode)
+	}
+
+	return n.Networker.NetIn(log, handle, hostPort, containerPort)
+}
+
+func (n *modeNetworker) NetOut(log lager.Logger, handle string, rule garden.NetOutRule) error {
+	if mode, ok := n.mode(handle); ok {
+		return fmt.Errorf("net-out is not supported in %s network mode", mode)
+	}
+
+	return n.Networker.NetOut(log, handle, rule)
+}
+
+func (n *modeNetworker) BulkNetOut(log lager.Logger, handle string, rules []garden.NetOutRule) error {
+	if mode, ok := n.mode(handle); ok {
+		if len(rules) == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("net-out is not supported in %s network mode", mode)
+	}
+
+	return n.Networker.BulkNetOut(log, handle, rules)
+}
+
+func (n *modeNetworker) Restore(log lager.Logger, handle string) error {
+	if n.unmanaged(handle) {
+		return nil
+	}
+
+	return n.Networker.Restore(log, handle)
+}
+
+func (n *modeNetworker) mode(handle string) (string, bool) {
+	mode, ok := n.propertyManager.Get(handle, NetworkModeKey)
+	if !ok || !isUnmanagedNetworkMode(mode) {
+		return "", false
+	}
+
+	return mode, true
+}
+
+func (n *modeNetworker) unmanaged(handle string) bool {
+	_, ok := n.mode(handle)
+	return ok
+}
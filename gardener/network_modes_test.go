@@ -0,0 +1,134 @@
+package gardener_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/gardener"
+	fakes "code.cloudfoundry.org/guardian/gardener/gardenerfakes"
+	"code.cloudfoundry.org/guardian/properties"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ModeNetworker", func() {
+	var (
+		delegate        *fakes.FakeNetworker
+		propertyManager *properties.Manager
+		networker       gardener.Networker
+		logger          lager.Logger
+	)
+
+	BeforeEach(func() {
+		delegate = new(fakes.FakeNetworker)
+		propertyManager = properties.NewManager()
+		networker = gardener.NewModeNetworker(delegate, propertyManager, "10.0.0.5")
+		logger = lagertest.NewTestLogger("test")
+	})
+
+	Describe("Network", func() {
+		It("delegates containers with any other network spec", func() {
+			spec := garden.ContainerSpec{Handle: "some-handle", Network: "10.1.2.0/30"}
+			Expect(networker.Network(logger, spec, 42)).To(Succeed())
+
+			Expect(delegate.NetworkCallCount()).To(Equal(1))
+			_, actualSpec, pid := delegate.NetworkArgsForCall(0)
+			Expect(actualSpec).To(Equal(spec))
+			Expect(pid).To(Equal(42))
+		})
+
+		Context("when the container requests no network", func() {
+			It("does not network the container", func() {
+				Expect(networker.Network(logger, garden.ContainerSpec{Handle: "some-handle", Network: "none"}, 42)).To(Succeed())
+				Expect(delegate.NetworkCallCount()).To(Equal(0))
+			})
+
+			It("records the network mode, and no addresses", func() {
+				Expect(networker.Network(logger, garden.ContainerSpec{Handle: "some-handle", Network: "none"}, 42)).To(Succeed())
+
+				props, err := propertyManager.All("some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(props).To(Equal(garden.Properties{gardener.NetworkModeKey: "none"}))
+			})
+		})
+
+		Context("when the container requests the host network", func() {
+			It("does not network the container", func() {
+				Expect(networker.Network(logger, garden.ContainerSpec{Handle: "some-handle", Network: "host"}, 42)).To(Succeed())
+				Expect(delegate.NetworkCallCount()).To(Equal(0))
+			})
+
+			It("records the host's address as the container's address", func() {
+				Expect(networker.Network(logger, garden.ContainerSpec{Handle: "some-handle", Network: "host"}, 42)).To(Succeed())
+
+				props, err := propertyManager.All("some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(props).To(Equal(garden.Properties{
+					gardener.NetworkModeKey: "host",
+					gardener.ContainerIPKey: "10.0.0.5",
+					gardener.ExternalIPKey:  "10.0.0.5",
+				}))
+			})
+		})
+	})
+
+	Context("when the container was created with a network mode", func() {
+		BeforeEach(func() {
+			Expect(networker.Network(logger, garden.ContainerSpec{Handle: "some-handle", Network: "none"}, 42)).To(Succeed())
+		})
+
+		It("does not destroy its network", func() {
+			Expect(networker.Destroy(logger, "some-handle")).To(Succeed())
+			Expect(delegate.DestroyCallCount()).To(Equal(0))
+		})
+
+		It("does not restore its network", func() {
+			Expect(networker.Restore(logger, "some-handle")).To(Succeed())
+			Expect(delegate.RestoreCallCount()).To(Equal(0))
+		})
+
+		It("rejects NetIn", func() {
+			_, _, err := networker.NetIn(logger, "some-handle", 8080, 8080)
+			Expect(err).To(MatchError("net-in is not supported in none network mode"))
+			Expect(delegate.NetInCallCount()).To(Equal(0))
+		})
+
+		It("rejects NetOut", func() {
+			Expect(networker.NetOut(logger, "some-handle", garden.NetOutRule{})).To(MatchError("net-out is not supported in none network mode"))
+			Expect(delegate.NetOutCallCount()).To(Equal(0))
+		})
+
+		It("accepts BulkNetOut with no rules", func() {
+			Expect(networker.BulkNetOut(logger, "some-handle", nil)).To(Succeed())
+			Expect(networker.BulkNetOut(logger, "some-handle", []garden.NetOutRule{{}})).To(MatchError("net-out is not supported in none network mode"))
+			Expect(delegate.BulkNetOutCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the container is networked by the delegate", func() {
+		It("delegates Destroy", func() {
+			delegate.DestroyReturns(errors.New("boom"))
+			Expect(networker.Destroy(logger, "other-handle")).To(MatchError("boom"))
+		})
+
+		It("delegates Restore", func() {
+			Expect(networker.Restore(logger, "other-handle")).To(Succeed())
+			Expect(delegate.RestoreCallCount()).To(Equal(1))
+		})
+
+		It("delegates NetIn", func() {
+			delegate.NetInReturns(1, 2, nil)
+			hostPort, containerPort, err := networker.NetIn(logger, "other-handle", 0, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hostPort).To(BeEquivalentTo(1))
+			Expect(containerPort).To(BeEquivalentTo(2))
+		})
+
+		It("delegates Capacity", func() {
+			delegate.CapacityReturns(12)
+			Expect(networker.Capacity()).To(BeEquivalentTo(12))
+		})
+	})
+})
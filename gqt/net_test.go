@@ -362,6 +362,40 @@ var _ = Describe("Networking", func() {
 			Eventually(func() *gexec.Session { return sendRequest(externalIP, actualHostPort).Wait("10s") }, "10s").
 				Should(gbytes.Say(fmt.Sprintf("%d", actualContainerPort)))
 		})
+
+		It("allows the container to reach its own mapped port on the external IP", func() {
+			hostPort, containerPort, err := container.NetIn(0, 8080)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(listenInContainer(container, containerPort)).To(Succeed())
+
+			Eventually(func() error {
+				return checkConnection(container, externalIP(container), int(hostPort))
+			}, "10s", "1s").Should(Succeed())
+		})
+
+		Context("when another container in a different subnet maps a port", func() {
+			var otherContainer garden.Container
+
+			JustBeforeEach(func() {
+				var err error
+				otherContainer, err = client.Create(garden.ContainerSpec{})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				Expect(client.Destroy(otherContainer.Handle())).To(Succeed())
+			})
+
+			It("allows the container to reach it on the external IP", func() {
+				hostPort, containerPort, err := otherContainer.NetIn(0, 8080)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(listenInContainer(otherContainer, containerPort)).To(Succeed())
+
+				Eventually(func() error {
+					return checkConnection(container, externalIP(otherContainer), int(hostPort))
+				}, "10s", "1s").Should(Succeed())
+			})
+		})
 	})
 
 	Describe("--deny-network flag", func() {
@@ -808,23 +842,24 @@ var _ = Describe("Networking", func() {
 				})
 			})
 
-			Context("when mtu is above max allowed value", func() {
+			Context("when the container's network spec overrides the mtu", func() {
 				BeforeEach(func() {
-					config.MTU = intptr(1501)
+					config.MTU = intptr(1234)
+					containerNetwork = containerNetwork + ",mtu=1300"
 				})
 
 				Describe("container's network interface", func() {
-					It("has the correct MTU size", func() {
+					It("has the container's MTU size", func() {
 						stdout := containerIfconfig(container)
-						Expect(stdout).To(ContainSubstring(" MTU:1500 "))
+						Expect(stdout).To(ContainSubstring(" MTU:1300 "))
 					})
 				})
 
 				Describe("hosts's network interface for a container", func() {
-					It("has the correct MTU size", func() {
+					It("has the container's MTU size", func() {
 						out, err := exec.Command("ifconfig", hostIfName(container)).Output()
 						Expect(err).ToNot(HaveOccurred())
-						Expect(out).To(ContainSubstring(" MTU:1500 "))
+						Expect(out).To(ContainSubstring(" MTU:1300 "))
 					})
 				})
 			})
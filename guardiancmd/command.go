@@ -23,6 +23,7 @@ import (
 	kawasakifactory "code.cloudfoundry.org/guardian/kawasaki/factory"
 	"code.cloudfoundry.org/guardian/kawasaki/iptables"
 	"code.cloudfoundry.org/guardian/kawasaki/mtu"
+	"code.cloudfoundry.org/guardian/kawasaki/nflog"
 	"code.cloudfoundry.org/guardian/kawasaki/ports"
 	"code.cloudfoundry.org/guardian/kawasaki/subnets"
 	"code.cloudfoundry.org/guardian/logging"
@@ -122,8 +123,9 @@ type GardenFactory interface {
 	CommandRunner() commandrunner.CommandRunner
 	WireVolumizer(logger lager.Logger) gardener.Volumizer
 	WireCgroupsStarter(logger lager.Logger) gardener.Starter
-	WireExecRunner(runMode string) runrunc.ExecRunner
+	WireExecRunner(runMode, runtimePath string) runrunc.ExecRunner
 	WireRootfsFileCreator() rundmc.RootfsFileCreator
+	WireOOMCounter() rundmc.OOMCounter
 }
 
 // These are the maximum capabilities a non-root user gets whether privileged or unprivileged
@@ -131,6 +133,10 @@ type GardenFactory interface {
 // plus CAP_SYS_ADMIN.
 var nonRootMaxCaps = append(unprivilegedMaxCaps, "CAP_SYS_ADMIN")
 
+// processReapInterval is how often the directories of processes which have
+// outlived --process-retention are removed
+const processReapInterval = 10 * time.Minute
+
 var PrivilegedContainerNamespaces = []specs.LinuxNamespace{
 	goci.NetworkNamespace, goci.PIDNamespace, goci.UTSNamespace, goci.IPCNamespace, goci.MountNamespace,
 }
@@ -191,6 +197,7 @@ type ServerCommand struct {
 		ConsoleSocketsPath         string `long:"console-sockets-path" description:"Path in which to store temporary sockets"`
 		CleanupProcessDirsOnWait   bool   `long:"cleanup-process-dirs-on-wait" description:"Clean up proccess dirs on first invocation of wait"`
 		DisablePrivilgedContainers bool   `long:"disable-privileged-containers" description:"Disable creation of privileged containers"`
+		AllowHostNetworkContainers bool   `long:"allow-host-network-containers" description:"Allow privileged containers to share the host's network namespace by passing 'host' as their network spec"`
 
 		UIDMapStart  uint32 `long:"uid-map-start"  default:"1" description:"The lowest numerical subordinate user ID the user is allowed to map"`
 		UIDMapLength uint32 `long:"uid-map-length" description:"The number of numerical subordinate user IDs the user is allowed to map"`
@@ -201,6 +208,8 @@ type ServerCommand struct {
 		DefaultGraceTime           time.Duration `long:"default-grace-time" description:"Default time after which idle containers should expire."`
 		DestroyContainersOnStartup bool          `long:"destroy-containers-on-startup" description:"Clean up all the existing containers on startup."`
 		ApparmorProfile            string        `long:"apparmor" description:"Apparmor profile to use for unprivileged container processes"`
+		StopGracePeriod            time.Duration `long:"stop-grace-period" description:"Time for which the processes of a container are given to exit after being signalled when it is stopped or destroyed, before they are killed. When 0, stopping waits 10s and destroying kills the processes straight away."`
+		ProcessRetention           time.Duration `long:"process-retention" default:"24h" description:"Time for which the exit status of a process is kept after it exits, so that it can be attached to, before its process directory is removed. Set to 0 to keep process directories until the container is destroyed."`
 	} `group:"Container Lifecycle"`
 
 	Bin struct {
@@ -211,11 +220,14 @@ type ServerCommand struct {
 		IPTables        FileFlag `long:"iptables-bin"  default:"/sbin/iptables" description:"path to the iptables binary"`
 		IPTablesRestore FileFlag `long:"iptables-restore-bin"  default:"/sbin/iptables-restore" description:"path to the iptables-restore binary"`
 		Init            FileFlag `long:"init-bin"       description:"Path execute as pid 1 inside each container."`
+		Conntrack       FileFlag `long:"conntrack-bin"  description:"Path to the 'conntrack' binary, used to flush a container's connection tracking entries when it is destroyed. Defaults to 'conntrack' on the PATH."`
 	} `group:"Binary Tools"`
 
 	Runtime struct {
 		Plugin          string   `long:"runtime-plugin"       default:"runc" description:"Path to the runtime plugin binary."`
 		PluginExtraArgs []string `long:"runtime-plugin-extra-arg" description:"Extra argument to pass to the runtime plugin. Can be specified multiple times."`
+
+		Classes FileFlag `long:"runtime-classes-config" description:"Path to a JSON file defining named runtime classes, each with its own runtime binary, extra arguments and state directory. Containers select a class with the garden.runtime-class property, and otherwise use the runtime plugin."`
 	} `group:"Runtime"`
 
 	Graph struct {
@@ -253,10 +265,27 @@ type ServerCommand struct {
 		PortPoolSize           uint32 `long:"port-pool-size"  default:"4534"  description:"Size of the port pool used for mapped container ports."`
 		PortPoolPropertiesPath string `long:"port-pool-properties-path" description:"Path in which to store port pool properties."`
 
-		Mtu int `long:"mtu" description:"MTU size for container network interfaces. Defaults to the MTU of the interface used for outbound access by the host. Max allowed value is 1500."`
+		AdditionalExternalIPs []ExternalPortRangeFlag `long:"additional-external-ip" description:"Additional IP address to use to reach containers' mapped ports, with its own range of ports, as <ip>:<first port>-<last port>. Each container is given the external IP with the most free ports. Can be specified multiple times."`
+
+		EgressIPs []IPFlag `long:"egress-ip" description:"Host IP address to which a container's outbound traffic may be source NATed, selected with the garden.network.egress-ip property. Can be specified multiple times."`
+
+		Mtu int `long:"mtu" description:"MTU size for container network interfaces. Defaults to the MTU of the interface used for outbound access by the host, which is also the maximum, or when that interface cannot be found (e.g. the external IP is behind NAT) this value is the maximum. Containers may override it with an 'mtu=<size>' option in their network spec."`
+
+		MaxConnections int `long:"max-container-connections" description:"Maximum number of connections each container may have open at once, beyond which new connections are rejected. Containers may override it with a 'max-conns=<n>' option in their network spec. Defaults to no limit."`
+		ConnectionRate int `long:"container-connection-rate" description:"Maximum number of new connections per second each container may open, beyond which new connections are rejected. Containers may override it with a 'conn-rate=<n>' option in their network spec. Defaults to no limit."`
+
+		IPTablesDriftCheckInterval time.Duration `long:"iptables-drift-check-interval" description:"Interval on which to compare containers' iptables rules with those applied by the server, re-applying any which are missing. Only the main network of containers created by this version is checked. Defaults to 0 (disabled)."`
+
+		EgressLogNFLogGroup uint16 `long:"egress-log-nflog-group" description:"NFLOG group to which logged net-out rules send new connections, which are then recorded as structured entries naming the container, addresses, ports and protocol. Defaults to logging through the kernel log."`
+		EgressLogFile       string `long:"egress-log-file"        description:"Path to a file to which structured egress entries are appended as lines of JSON. Defaults to the server log. Requires --egress-log-nflog-group."`
+
+		NetworkPools FileFlag `long:"network-pools-config" description:"Path to a JSON file defining named network pools, each with its own range, deny networks, MTU, host access and egress IP, attached directly to a parent interface with macvlan or ipvlan, or joined to other hosts' pools over a VXLAN overlay. Containers select a pool with a network spec of 'pool:<name>'."`
+
+		AttachmentNetworks FileFlag `long:"network-attachments-config" description:"Path to a JSON file defining additional networks which containers may request interfaces on via the garden.network.attachments property."`
 
 		Plugin          FileFlag `long:"network-plugin"           description:"Path to network plugin binary."`
 		PluginExtraArgs []string `long:"network-plugin-extra-arg" description:"Extra argument to pass to the network plugin. Can be specified multiple times."`
+		PluginChained   bool     `long:"network-plugin-chained"   description:"Run the network plugin after the built-in bridge networking has been set up, rather than instead of it. The built-in networking then owns the container's DNS configuration and its 'kawasaki.' properties."`
 	} `group:"Container Networking"`
 
 	Limits struct {
@@ -392,12 +421,32 @@ func (cmd *ServerCommand) Run(signals <-chan os.Signal, ready chan<- struct{}) e
 		return err
 	}
 
-	networker, iptablesStarter, err := cmd.wireNetworker(logger, factory, propManager, portPool)
+	maxMtu, err := cmd.maxContainerMtu(logger)
+	if err != nil {
+		logger.Error("failed-to-determine-max-mtu", err)
+		return err
+	}
+
+	networkPoolSpecs, networkPools, err := cmd.wireNetworkPools(maxMtu)
+	if err != nil {
+		logger.Error("failed-to-wire-network-pools", err)
+		return err
+	}
+
+	networkLocks := kawasaki.NewHandleLocks()
+	networker, networkStarters, err := cmd.wireNetworker(logger, factory, propManager, portPool, maxMtu, networkPoolSpecs, networkPools, networkLocks)
 	if err != nil {
 		logger.Error("failed-to-wire-networker", err)
 		return err
 	}
 
+	hostIP, err := defaultExternalIP(cmd.Network.ExternalIP)
+	if err != nil {
+		logger.Error("failed-to-determine-host-ip", err)
+		return err
+	}
+	networker = gardener.NewModeNetworker(networker, propManager, hostIP.String())
+
 	restorer := gardener.NewRestorer(networker)
 	if cmd.Containers.DestroyContainersOnStartup {
 		restorer = &gardener.NoopRestorer{}
@@ -409,19 +458,25 @@ func (cmd *ServerCommand) Run(signals <-chan os.Signal, ready chan<- struct{}) e
 	if !cmd.Server.SkipSetup {
 		starters = append(starters, factory.WireCgroupsStarter(logger))
 	}
-	if cmd.Network.Plugin.Path() == "" {
-		starters = append(starters, iptablesStarter)
-	}
+	starters = append(starters, networkStarters...)
 
 	var bulkStarter gardener.BulkStarter = gardener.NewBulkStarter(starters)
 
+	runtimeClasses, err := cmd.wireRuntimeClasses()
+	if err != nil {
+		logger.Error("failed-to-wire-runtime-classes", err)
+		return err
+	}
+
+	containerizer := cmd.wireContainerizer(logger, factory, propManager, volumizer, runtimeClasses)
+
 	backend := &gardener.Gardener{
 		UidGenerator:    wireUIDGenerator(),
 		BulkStarter:     bulkStarter,
 		SysInfoProvider: sysinfo.NewResourcesProvider(cmd.Containers.Dir),
 		Networker:       networker,
 		Volumizer:       volumizer,
-		Containerizer:   cmd.wireContainerizer(logger, factory, propManager, volumizer),
+		Containerizer:   containerizer,
 		PropertyManager: propManager,
 		MaxContainers:   cmd.Limits.MaxContainers,
 		Restorer:        restorer,
@@ -430,6 +485,10 @@ func (cmd *ServerCommand) Run(signals <-chan os.Signal, ready chan<- struct{}) e
 		// whether or not gdn is running as root.
 		AllowPrivilgedContainers: !cmd.Containers.DisablePrivilgedContainers,
 
+		AllowHostNetworkContainers: cmd.Containers.AllowHostNetworkContainers,
+
+		StopGracePeriod: cmd.Containers.StopGracePeriod,
+
 		Logger: logger,
 	}
 
@@ -456,6 +515,31 @@ func (cmd *ServerCommand) Run(signals <-chan os.Signal, ready chan<- struct{}) e
 		"depotDirs":     metricsProvider.DepotDirs,
 	}
 
+	for name, pool := range networkPools {
+		debugServerMetrics["networkPoolCapacity."+name] = pool.Capacity
+		debugServerMetrics["networkPoolFree."+name] = pool.Free
+	}
+
+	var driftDetector *kawasaki.DriftDetector
+	if cmd.Network.IPTablesDriftCheckInterval > 0 && (cmd.Network.Plugin.Path() == "" || cmd.Network.PluginChained) {
+		driftDetector = cmd.wireDriftDetector(factory, containerizer, propManager, networkLocks)
+		debugServerMetrics["iptablesDriftedContainers"] = driftDetector.DriftedContainers
+		debugServerMetrics["iptablesRepairedContainers"] = driftDetector.RepairedContainers
+		debugServerMetrics["iptablesFailedDriftChecks"] = driftDetector.FailedChecks
+	}
+
+	var egressLogger *nflog.EgressLogger
+	if cmd.Network.EgressLogNFLogGroup != 0 {
+		var closeEgressLog func()
+		egressLogger, closeEgressLog, err = cmd.wireEgressLogger(logger, containerizer, propManager)
+		if err != nil {
+			logger.Error("wiring-egress-logger", err)
+			return err
+		}
+		defer closeEgressLog()
+		debugServerMetrics["egressLogDroppedPackets"] = egressLogger.DroppedPackets
+	}
+
 	periodicMetronMetrics := map[string]func() int{
 		"DepotDirs": metricsProvider.DepotDirs,
 	}
@@ -477,6 +561,14 @@ func (cmd *ServerCommand) Run(signals <-chan os.Signal, ready chan<- struct{}) e
 		logger.Error("starting-guardian-backend", err)
 		return err
 	}
+
+	// ports recorded for containers which did not survive the restart would
+	// otherwise never be released
+	if handles, err := containerizer.Handles(); err != nil {
+		logger.Error("pruning-port-pool", err)
+	} else {
+		portPool.Prune(handles)
+	}
 	if err := gardenServer.SetupBomberman(); err != nil {
 		logger.Error("setting-up-bomberman", err)
 		return err
@@ -485,6 +577,21 @@ func (cmd *ServerCommand) Run(signals <-chan os.Signal, ready chan<- struct{}) e
 		return err
 	}
 
+	stopDriftDetector := make(chan struct{})
+	if driftDetector != nil {
+		go driftDetector.Run(logger, clock.NewClock(), cmd.Network.IPTablesDriftCheckInterval, stopDriftDetector)
+	}
+
+	if egressLogger != nil {
+		go egressLogger.Run()
+	}
+
+	stopProcessReaper := make(chan struct{})
+	if cmd.Containers.ProcessRetention > 0 {
+		processReaper := runrunc.NewProcessReaper(cmd.Containers.Dir, cmd.Containers.ProcessRetention, clock.NewClock())
+		go processReaper.Run(logger, processReapInterval, stopProcessReaper)
+	}
+
 	close(ready)
 
 	logger.Info("started", lager.Data{
@@ -494,6 +601,8 @@ func (cmd *ServerCommand) Run(signals <-chan os.Signal, ready chan<- struct{}) e
 
 	<-signals
 
+	close(stopDriftDetector)
+	close(stopProcessReaper)
 	gardenServer.Stop()
 
 	cmd.saveProperties(logger, cmd.Containers.PropertiesPath, propManager)
@@ -583,11 +692,21 @@ func (cmd *ServerCommand) wirePortPool(logger lager.Logger) (*ports.PortPool, er
 		}
 	}
 
-	portPool, err := ports.NewPool(
-		cmd.Network.PortPoolStart,
-		cmd.Network.PortPoolSize,
-		portPoolState,
-	)
+	externalIP, err := defaultExternalIP(cmd.Network.ExternalIP)
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := []ports.Range{{
+		IP:    externalIP,
+		Start: cmd.Network.PortPoolStart,
+		Size:  cmd.Network.PortPoolSize,
+	}}
+	for _, additional := range cmd.Network.AdditionalExternalIPs {
+		ranges = append(ranges, additional.Range())
+	}
+
+	portPool, err := ports.NewPool(ranges, portPoolState)
 	if err != nil {
 		return nil, fmt.Errorf("invalid pool range: %s", err)
 	}
@@ -606,7 +725,58 @@ func extractIPs(ipflags []IPFlag) []net.IP {
 	return ips
 }
 
-func (cmd *ServerCommand) wireNetworker(log lager.Logger, factory GardenFactory, propManager kawasaki.ConfigStore, portPool *ports.PortPool) (gardener.Networker, gardener.Starter, error) {
+func (cmd *ServerCommand) wireNetworkPools(maxMtu int) ([]kawasaki.NetworkPoolSpec, map[string]kawasaki.NetworkPool, error) {
+	if cmd.Network.NetworkPools.Path() == "" {
+		return nil, nil, nil
+	}
+
+	specs, err := kawasaki.LoadNetworkPools(cmd.Network.NetworkPools.Path())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pools, err := kawasaki.NewNetworkPools(specs, maxMtu, cmd.Network.Pool.CIDR())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for name, pool := range pools {
+		if pool.IsDirect() {
+			pool.Configurer = kawasakifactory.NewDirectConfigurer(cmd.Containers.Dir, pool.Spec.Mode, pool.Spec.ParentInterface)
+			pools[name] = pool
+		}
+	}
+
+	return specs, pools, nil
+}
+
+// maxContainerMtu returns the MTU of the host interface with the external IP,
+// which container interfaces may not exceed. When the external IP is not a
+// local address (e.g. behind NAT) the configured MTU is trusted as the maximum.
+func (cmd *ServerCommand) maxContainerMtu(log lager.Logger) (int, error) {
+	externalIP, err := defaultExternalIP(cmd.Network.ExternalIP)
+	if err != nil {
+		return 0, err
+	}
+
+	hostMtu, err := mtu.MTU(externalIP.String())
+	if err != nil {
+		if cmd.Network.Mtu == 0 {
+			return 0, err
+		}
+
+		log.Info("host-interface-not-found-using-configured-mtu-as-max", lager.Data{
+			"external-ip": externalIP.String(),
+			"mtu":         cmd.Network.Mtu,
+			"error":       err.Error(),
+		})
+		return cmd.Network.Mtu, nil
+	}
+
+	return hostMtu, nil
+}
+
+func (cmd *ServerCommand) wireNetworker(log lager.Logger, factory GardenFactory, propManager kawasaki.ConfigStore, portPool *ports.PortPool, maxMtu int, poolSpecs []kawasaki.NetworkPoolSpec, pools map[string]kawasaki.NetworkPool, locks *kawasaki.HandleLocks) (gardener.Networker, []gardener.Starter, error) {
 	externalIP, err := defaultExternalIP(cmd.Network.ExternalIP)
 	if err != nil {
 		return nil, nil, err
@@ -615,19 +785,26 @@ func (cmd *ServerCommand) wireNetworker(log lager.Logger, factory GardenFactory,
 	dnsServers := extractIPs(cmd.Network.DNSServers)
 	additionalDNSServers := extractIPs(cmd.Network.AdditionalDNSServers)
 
+	var externalNetworker netplugin.ExternalNetworker
 	if cmd.Network.Plugin.Path() != "" {
 		resolvConfigurer := factory.WireResolvConfigurer()
-		externalNetworker := netplugin.New(
+		externalNetworker = netplugin.New(
+			log,
 			factory.CommandRunner(),
 			propManager,
 			externalIP,
 			dnsServers,
 			additionalDNSServers,
 			resolvConfigurer,
+			cmd.Containers.Dir,
 			cmd.Network.Plugin.Path(),
 			cmd.Network.PluginExtraArgs,
+			cmd.Network.PluginChained,
 		)
-		return externalNetworker, externalNetworker, nil
+
+		if !cmd.Network.PluginChained {
+			return externalNetworker, []gardener.Starter{externalNetworker}, nil
+		}
 	}
 
 	var denyNetworksList []string
@@ -646,26 +823,140 @@ func (cmd *ServerCommand) wireNetworker(log lager.Logger, factory GardenFactory,
 	ipTablesStarter := iptables.NewStarter(nonLoggingIPTables, cmd.Network.AllowHostAccess, interfacePrefix, denyNetworksList, cmd.Containers.DestroyContainersOnStartup, log)
 	ruleTranslator := iptables.NewRuleTranslator()
 
+	var poolRules []iptables.PoolRules
+	for _, spec := range poolSpecs {
+		if pools[spec.Name].IsDirect() {
+			continue
+		}
+
+		poolRules = append(poolRules, iptables.PoolRules{
+			Name:            spec.Name,
+			Network:         spec.CIDR,
+			DenyNetworks:    spec.DenyNetworks,
+			AllowHostAccess: spec.AllowHostAccess,
+		})
+	}
+	poolStarter := iptables.NewPoolStarter(nonLoggingIPTables, poolRules, log)
+	if err := poolStarter.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	iptablesStarters := []gardener.Starter{
+		ipTablesStarter,
+		poolStarter,
+	}
+
 	containerMtu := cmd.Network.Mtu
 	if containerMtu == 0 {
-		containerMtu, err = mtu.MTU(externalIP.String())
+		containerMtu = maxMtu
+	}
+
+	if containerMtu > maxMtu {
+		return nil, nil, fmt.Errorf("mtu %d exceeds the mtu of the host interface, %d", containerMtu, maxMtu)
+	}
+
+	if cmd.Network.MaxConnections < 0 || cmd.Network.ConnectionRate < 0 {
+		return nil, nil, errors.New("container connection limits must not be negative")
+	}
+
+	var conntrackFlusher kawasaki.ConntrackFlusher = kawasaki.NoopConntrackFlusher{}
+	conntrackPath := cmd.Bin.Conntrack.Path()
+	if conntrackPath == "" {
+		conntrackPath, _ = exec.LookPath("conntrack")
+	}
+	if conntrackPath != "" {
+		conntrackFlusher = iptables.NewConntrackFlusher(conntrackPath, iptRunner)
+	} else {
+		log.Info("conntrack-not-found-connection-tracking-entries-will-not-be-flushed")
+	}
+
+	var attachmentNetworks []kawasaki.AttachmentNetworkSpec
+	if cmd.Network.AttachmentNetworks.Path() != "" {
+		attachmentNetworks, err = kawasaki.LoadAttachmentNetworks(cmd.Network.AttachmentNetworks.Path())
 		if err != nil {
 			return nil, nil, err
 		}
 	}
 
+	// attachment networks may not overlap the ranges of containers' main
+	// networks
+	reserved := []*net.IPNet{cmd.Network.Pool.CIDR()}
+	for _, pool := range pools {
+		reserved = append(reserved, pool.Network)
+	}
+
+	firewallOpener := iptables.NewFirewallOpener(ruleTranslator, ipTables)
+	attacher, err := kawasakifactory.NewDefaultAttacher(ipTables, firewallOpener, attachmentNetworks, idGenerator, interfacePrefix, chainPrefix, containerMtu, cmd.Network.EgressLogNFLogGroup, propManager, reserved...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for name, pool := range pools {
+		if pool.IsVXLAN() {
+			vtepName := fmt.Sprintf("%svx%d", interfacePrefix, pool.Spec.VNI)
+			pool.Configurer = kawasakifactory.NewVXLANConfigurer(ipTables, cmd.Network.EgressLogNFLogGroup, cmd.Containers.Dir, conntrackFlusher, vtepName, pool)
+			pools[name] = pool
+		}
+	}
+
 	networker := kawasaki.New(
 		kawasaki.SpecParserFunc(kawasaki.ParseSpec),
 		subnets.NewPool(cmd.Network.Pool.CIDR()),
-		kawasaki.NewConfigCreator(idGenerator, interfacePrefix, chainPrefix, externalIP, dnsServers, additionalDNSServers, cmd.Network.AdditionalHostEntries, containerMtu),
+		pools,
+		kawasaki.NewConfigCreator(idGenerator, interfacePrefix, chainPrefix, externalIP, dnsServers, additionalDNSServers, cmd.Network.AdditionalHostEntries, containerMtu, kawasaki.ConnectionLimits{
+			MaxConnections: cmd.Network.MaxConnections,
+			Rate:           cmd.Network.ConnectionRate,
+		}),
 		propManager,
-		kawasakifactory.NewDefaultConfigurer(ipTables, cmd.Containers.Dir),
+		kawasakifactory.NewDefaultConfigurer(ipTables, cmd.Network.EgressLogNFLogGroup, cmd.Containers.Dir, conntrackFlusher),
 		portPool,
 		iptables.NewPortForwarder(ipTables),
-		iptables.NewFirewallOpener(ruleTranslator, ipTables),
+		firewallOpener,
+		attacher,
+		extractIPs(cmd.Network.EgressIPs),
+		maxMtu,
+		locks,
 	)
 
-	return networker, ipTablesStarter, nil
+	if externalNetworker != nil {
+		return netplugin.NewCompositeNetworker(networker, externalNetworker), append(iptablesStarters, externalNetworker), nil
+	}
+
+	return networker, iptablesStarters, nil
+}
+
+func (cmd *ServerCommand) wireDriftDetector(factory GardenFactory, handles kawasaki.HandleLister, propManager kawasaki.ConfigStore, locks *kawasaki.HandleLocks) *kawasaki.DriftDetector {
+	chainPrefix := fmt.Sprintf("w-%s-", cmd.Server.Tag)
+	ipTables := iptables.New(cmd.Bin.IPTables.Path(), cmd.Bin.IPTablesRestore.Path(), factory.CommandRunner(), &locksmithpkg.FileSystem{}, chainPrefix)
+
+	return kawasaki.NewDriftDetector(handles, propManager, iptables.NewChainReconciler(ipTables, iptables.NewRuleTranslator(), cmd.Network.EgressLogNFLogGroup), locks)
+}
+
+func (cmd *ServerCommand) wireEgressLogger(logger lager.Logger, handles kawasaki.HandleLister, propManager kawasaki.ConfigStore) (*nflog.EgressLogger, func(), error) {
+	socket, err := nflog.Open(cmd.Network.EgressLogNFLogGroup)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sink := nflog.NewLagerSink(logger)
+	closeFn := func() { socket.Close() }
+
+	if cmd.Network.EgressLogFile != "" {
+		file, err := os.OpenFile(cmd.Network.EgressLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			socket.Close()
+			return nil, nil, fmt.Errorf("opening egress log file: %s", err)
+		}
+
+		sink = nflog.NewJSONSink(file)
+		closeFn = func() {
+			socket.Close()
+			file.Close()
+		}
+	}
+
+	resolver := kawasaki.NewInstanceResolver(handles, propManager, clock.NewClock())
+	return nflog.NewEgressLogger(socket, resolver, sink, clock.NewClock(), logger), closeFn, nil
 }
 
 func (cmd *ServerCommand) wireImagePlugin(commandRunner commandrunner.CommandRunner, uid, gid int) gardener.Volumizer {
@@ -703,7 +994,7 @@ func (cmd *ServerCommand) wireImagePlugin(commandRunner commandrunner.CommandRun
 }
 
 func (cmd *ServerCommand) wireContainerizer(log lager.Logger, factory GardenFactory,
-	properties gardener.PropertyManager, volumizer peas.Volumizer) *rundmc.Containerizer {
+	properties gardener.PropertyManager, volumizer peas.Volumizer, runtimeClasses []rundmc.RuntimeClassSpec) *rundmc.Containerizer {
 
 	// TODO centralize knowledge of garden -> runc capability schema translation
 	baseProcess := specs.Process{
@@ -780,7 +1071,9 @@ func (cmd *ServerCommand) wireContainerizer(log lager.Logger, factory GardenFact
 			CpuQuotaPerShare: cmd.Limits.CPUQuotaPerShare,
 			TCPMemoryLimit:   int64(cmd.Limits.TCPMemoryLimit),
 			BlockIOWeight:    cmd.Limits.DefaultBlockIOWeight,
-		})
+			UnifiedCgroups:   cgroupsUnified(),
+		},
+		bundlerules.RuntimeClass{})
 
 	template := &rundmc.BundleTemplate{Rules: bundleRules}
 	peaTemplate := &rundmc.BundleTemplate{Rules: peaBundleRules}
@@ -796,26 +1089,6 @@ func (cmd *ServerCommand) wireContainerizer(log lager.Logger, factory GardenFact
 	runcRunner := runrunc.NewLogRunner(cmdRunner, runrunc.LogDir(os.TempDir()).GenerateLogFile)
 	runcBinary := goci.RuncBinary{Path: cmd.Runtime.Plugin}
 
-	runcrunner := runrunc.New(
-		cmdRunner,
-		runcRunner,
-		runcBinary,
-		cmd.Bin.Dadoo.Path(),
-		cmd.Runtime.Plugin,
-		createCmd(),
-		createCmdExtraArgs(),
-		cmd.Runtime.PluginExtraArgs,
-		bndlLoader,
-		processBuilder,
-		factory.WireMkdirer(),
-		runrunc.LookupFunc(runrunc.LookupUser),
-		factory.WireExecRunner("exec"),
-		wireUIDGenerator(),
-	)
-
-	eventStore := rundmc.NewEventStore(properties)
-	stateStore := rundmc.NewStateStore(properties)
-
 	runcRoot := filepath.Join("/", "run", "runc")
 	if os.Geteuid() != 0 {
 		runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
@@ -824,11 +1097,56 @@ func (cmd *ServerCommand) wireContainerizer(log lager.Logger, factory GardenFact
 		}
 	}
 
+	// the state and stats of containers are read from the runtime's state
+	// directory where possible, rather than by forking the runtime
+	wireRuntime := func(runtimePath string, runtimeExtraArgs []string, stateDir string) *runrunc.RunRunc {
+		return runrunc.New(
+			cmdRunner,
+			runcRunner,
+			goci.RuncBinary{Path: runtimePath},
+			cmd.Bin.Dadoo.Path(),
+			runtimePath,
+			createCmd(),
+			createCmdExtraArgs(),
+			runtimeExtraArgs,
+			bndlLoader,
+			processBuilder,
+			factory.WireMkdirer(),
+			runrunc.LookupFunc(runrunc.LookupUser),
+			factory.WireExecRunner("exec", runtimePath),
+			wireUIDGenerator(),
+			stateDir,
+		)
+	}
+
+	classRuntimes := map[string]rundmc.OCIRuntime{}
+	for _, class := range runtimeClasses {
+		classRuntimes[class.Name] = wireRuntime(class.Path, class.ExtraArgs, class.StateDir)
+	}
+
+	runtime := rundmc.NewRuntimeClasses(depot, bndlLoader, wireRuntime(cmd.Runtime.Plugin, cmd.Runtime.PluginExtraArgs, runcRoot), classRuntimes)
+
+	eventStore := rundmc.NewEventStore(properties)
+	stateStore := rundmc.NewStateStore(properties)
+
+	stateStores := []string{runcRoot}
+	for _, class := range runtimeClasses {
+		if class.StateDir != "" {
+			stateStores = append(stateStores, class.StateDir)
+		}
+	}
+
 	pidFileReader := wirePidfileReader()
 	privilegeChecker := &privchecker.PrivilegeChecker{BundleLoader: bndlLoader}
 
 	runcDeleter := runrunc.NewDeleter(runcRunner, runcBinary)
 
+	peaExecRunner := factory.WireExecRunner("run", cmd.Runtime.Plugin)
+	classPeaExecRunners := map[string]runrunc.ExecRunner{rundmc.DefaultRuntimeClass: peaExecRunner}
+	for _, class := range runtimeClasses {
+		classPeaExecRunners[class.Name] = factory.WireExecRunner("run", class.Path)
+	}
+
 	peaCreator := &peas.PeaCreator{
 		Volumizer:              volumizer,
 		PidGetter:              pidFileReader,
@@ -837,13 +1155,23 @@ func (cmd *ServerCommand) wireContainerizer(log lager.Logger, factory GardenFact
 		BundleGenerator:        peaTemplate,
 		ProcessBuilder:         processBuilder,
 		BundleSaver:            bundleSaver,
-		ExecRunner:             factory.WireExecRunner("run"),
 		RuncDeleter:            runcDeleter,
+		BundleLoader:           bndlLoader,
+		ExecRunner:             peaExecRunner,
+		ClassExecRunners:       classPeaExecRunners,
 	}
 
 	nstar := rundmc.NewNstarRunner(cmd.Bin.NSTar.Path(), cmd.Bin.Tar.Path(), cmdRunner)
-	stopper := stopper.New(stopper.NewRuncStateCgroupPathResolver(runcRoot), nil, retrier.New(retrier.ConstantBackoff(10, 1*time.Second), nil))
-	return rundmc.New(depot, runcrunner, bndlLoader, nstar, stopper, eventStore, stateStore, factory.WireRootfsFileCreator(), peaCreator)
+	stopper := stopper.New(stopper.NewRuncStateCgroupPathResolver(stateStores...), nil, retrier.New(retrier.ConstantBackoff(10, 1*time.Second), nil), clock.NewClock())
+	return rundmc.New(depot, runtime, bndlLoader, nstar, stopper, eventStore, stateStore, factory.WireRootfsFileCreator(), peaCreator, factory.WireOOMCounter())
+}
+
+func (cmd *ServerCommand) wireRuntimeClasses() ([]rundmc.RuntimeClassSpec, error) {
+	if cmd.Runtime.Classes.Path() == "" {
+		return nil, nil
+	}
+
+	return rundmc.LoadRuntimeClasses(cmd.Runtime.Classes.Path())
 }
 
 func wirePidfileReader() *pidreader.PidFileReader {
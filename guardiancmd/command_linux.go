@@ -89,14 +89,15 @@ func (NoopMkdirer) MkdirAs(rootFSPathFile string, uid, gid int, mode os.FileMode
 	return nil
 }
 
-func (f *LinuxFactory) WireExecRunner(runMode string) runrunc.ExecRunner {
+func (f *LinuxFactory) WireExecRunner(runMode, runtimePath string) runrunc.ExecRunner {
 	return dadoo.NewExecRunner(
 		f.config.Bin.Dadoo.Path(),
-		f.config.Runtime.Plugin,
+		runtimePath,
 		f.signallerFactory,
 		f.commandRunner,
 		f.config.Containers.CleanupProcessDirsOnWait,
 		runMode,
+		cgroupsMountpoint(f.config.Server.Tag),
 	)
 }
 
@@ -109,15 +110,23 @@ func (cmd *SetupCommand) WireCgroupsStarter(logger lager.Logger) gardener.Starte
 }
 
 func createCgroupsStarter(logger lager.Logger, tag string, chowner cgroups.Chowner) gardener.Starter {
-	cgroupsMountpoint := "/sys/fs/cgroup"
 	gardenCgroup := "garden"
 	if tag != "" {
-		cgroupsMountpoint = filepath.Join(os.TempDir(), fmt.Sprintf("cgroups-%s", tag))
 		gardenCgroup = fmt.Sprintf("%s-%s", gardenCgroup, tag)
 	}
 
 	return cgroups.NewStarter(logger, mustOpen("/proc/cgroups"), mustOpen("/proc/self/cgroup"),
-		cgroupsMountpoint, gardenCgroup, allowedDevices, linux_command_runner.New(), chowner)
+		cgroupsMountpoint(tag), gardenCgroup, allowedDevices, linux_command_runner.New(), chowner)
+}
+
+// cgroupsMountpoint returns where gdn mounts the cgroup hierarchies: tagged
+// servers mount their own, so as not to interfere with each other
+func cgroupsMountpoint(tag string) string {
+	if tag == "" {
+		return cgroups.DefaultMountpoint
+	}
+
+	return filepath.Join(os.TempDir(), fmt.Sprintf("cgroups-%s", tag))
 }
 
 func (f *LinuxFactory) WireResolvConfigurer() kawasaki.DnsResolvConfigurer {
@@ -133,6 +142,13 @@ func (f *LinuxFactory) WireRootfsFileCreator() rundmc.RootfsFileCreator {
 	return preparerootfs.SymlinkRefusingFileCreator{}
 }
 
+func (f *LinuxFactory) WireOOMCounter() rundmc.OOMCounter {
+	mountpoint := cgroupsMountpoint(f.config.Server.Tag)
+	return rundmc.OOMCounterFunc(func(pid int) (uint64, error) {
+		return cgroups.OOMKills(mountpoint, pid)
+	})
+}
+
 func defaultBindMounts(binInitPath string) []specs.Mount {
 	devptsGid := 0
 	if runningAsRoot() {
@@ -169,6 +185,10 @@ func bindMountPoints() []string {
 	return []string{"/etc/hosts", "/etc/resolv.conf"}
 }
 
+func cgroupsUnified() bool {
+	return cgroups.IsUnified("/sys/fs/cgroup")
+}
+
 func mustGetMaxValidUID() int {
 	return idmapper.MustGetMaxValidUID()
 }
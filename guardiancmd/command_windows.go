@@ -1,6 +1,7 @@
 package guardiancmd
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -57,9 +58,9 @@ func (f *WindowsFactory) WireVolumizer(logger lager.Logger) gardener.Volumizer {
 	return gardener.NoopVolumizer{}
 }
 
-func (f *WindowsFactory) WireExecRunner(runMode string) runrunc.ExecRunner {
+func (f *WindowsFactory) WireExecRunner(runMode, runtimePath string) runrunc.ExecRunner {
 	return &execrunner.DirectExecRunner{
-		RuntimePath:   f.config.Runtime.Plugin,
+		RuntimePath:   runtimePath,
 		CommandRunner: f.commandRunner,
 		RunMode:       runMode,
 	}
@@ -75,6 +76,12 @@ func (noopRootfsFileCreator) CreateFiles(rootFSPath string, pathsToCreate ...str
 	return nil
 }
 
+func (f *WindowsFactory) WireOOMCounter() rundmc.OOMCounter {
+	return rundmc.OOMCounterFunc(func(int) (uint64, error) {
+		return 0, errors.New("OOM kills are not counted on this platform")
+	})
+}
+
 func (f *WindowsFactory) CommandRunner() commandrunner.CommandRunner {
 	return f.commandRunner
 }
@@ -119,6 +126,10 @@ func bindMountPoints() []string {
 	return nil
 }
 
+func cgroupsUnified() bool {
+	return false
+}
+
 func mustGetMaxValidUID() int {
 	return -1
 }
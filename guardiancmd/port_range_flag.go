@@ -0,0 +1,59 @@
+package guardiancmd
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/guardian/kawasaki/ports"
+)
+
+// ExternalPortRangeFlag is an external IP with the range of ports which may
+// be mapped on it, given as <ip>:<first port>-<last port>
+type ExternalPortRangeFlag struct {
+	ip    net.IP
+	first uint32
+	last  uint32
+}
+
+func (f *ExternalPortRangeFlag) UnmarshalFlag(value string) error {
+	host, portRange, err := net.SplitHostPort(value)
+	if err != nil {
+		return fmt.Errorf("invalid external port range: '%s'", value)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("invalid IP: '%s'", host)
+	}
+
+	bounds := strings.SplitN(portRange, "-", 2)
+	if len(bounds) != 2 {
+		return fmt.Errorf("invalid port range: '%s'", portRange)
+	}
+
+	first, err := strconv.ParseUint(bounds[0], 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port range: '%s'", portRange)
+	}
+
+	last, err := strconv.ParseUint(bounds[1], 10, 16)
+	if err != nil || last < first {
+		return fmt.Errorf("invalid port range: '%s'", portRange)
+	}
+
+	f.ip = ip
+	f.first = uint32(first)
+	f.last = uint32(last)
+
+	return nil
+}
+
+func (f ExternalPortRangeFlag) Range() ports.Range {
+	return ports.Range{
+		IP:    f.ip,
+		Start: f.first,
+		Size:  f.last - f.first + 1,
+	}
+}
@@ -0,0 +1,328 @@
+package kawasaki
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/gardener"
+	"code.cloudfoundry.org/guardian/kawasaki/subnets"
+	"code.cloudfoundry.org/lager"
+)
+
+const attachmentsKey = "kawasaki.attachments"
+const attachmentConfigKeyPrefix = "kawasaki.attachment."
+
+// AttachmentNetworkSpec describes an operator-defined network on which
+// containers may request an additional interface. Every attachment network has
+// its own bridge and its own pool of addresses taken from CIDR.
+type AttachmentNetworkSpec struct {
+	Name string `json:"name"`
+	CIDR string `json:"cidr"`
+	Mtu  int    `json:"mtu,omitempty"`
+
+	// DefaultNetOut rules are opened on every attachment to this network
+	DefaultNetOut []garden.NetOutRule `json:"default_netout_rules,omitempty"`
+}
+
+func LoadAttachmentNetworks(path string) ([]AttachmentNetworkSpec, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening attachment networks config: %s", err)
+	}
+	defer file.Close()
+
+	var specs []AttachmentNetworkSpec
+	if err := json.NewDecoder(file).Decode(&specs); err != nil {
+		return nil, fmt.Errorf("parsing attachment networks config: %s", err)
+	}
+
+	return specs, nil
+}
+
+//go:generate counterfeiter . Attacher
+
+type Attacher interface {
+	Attach(log lager.Logger, handle string, networks []string, pid int) error
+	Detach(log lager.Logger, handle string) error
+	Restore(log lager.Logger, handle string) error
+}
+
+type attachmentNetwork struct {
+	spec   AttachmentNetworkSpec
+	subnet *net.IPNet
+	pool   subnets.Pool
+}
+
+type attacher struct {
+	networks             map[string]*attachmentNetwork
+	idGenerator          IDGenerator
+	interfacePrefix      string
+	chainPrefix          string
+	mtu                  int
+	configStore          ConfigStore
+	hostConfigurer       HostConfigurer
+	containerConfigurer  ContainerConfigurer
+	instanceChainCreator InstanceChainCreator
+	firewallOpener       FirewallOpener
+}
+
+// NewAttacher creates an Attacher for the given networks. Networks may not
+// overlap each other or any of the reserved networks, the ranges from which
+// containers' main networks are allocated. As bridges are named after the
+// network address of their subnet, this also keeps an attachment network's
+// bridge from sharing a name with any other bridge.
+func NewAttacher(
+	specs []AttachmentNetworkSpec,
+	idGenerator IDGenerator,
+	interfacePrefix, chainPrefix string,
+	mtu int,
+	configStore ConfigStore,
+	hostConfigurer HostConfigurer,
+	containerConfigurer ContainerConfigurer,
+	instanceChainCreator InstanceChainCreator,
+	firewallOpener FirewallOpener,
+	reserved ...*net.IPNet,
+) (*attacher, error) {
+	networks := map[string]*attachmentNetwork{}
+	taken := append([]*net.IPNet{}, reserved...)
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("attachment network with cidr '%s' has no name", spec.CIDR)
+		}
+
+		if _, exists := networks[spec.Name]; exists {
+			return nil, fmt.Errorf("attachment network '%s' is defined more than once", spec.Name)
+		}
+
+		_, subnet, err := net.ParseCIDR(spec.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("attachment network '%s': %s", spec.Name, err)
+		}
+
+		for _, t := range taken {
+			if t.Contains(subnet.IP) || subnet.Contains(t.IP) {
+				return nil, fmt.Errorf("attachment network '%s' overlaps %s", spec.Name, t)
+			}
+		}
+		taken = append(taken, subnet)
+
+		networks[spec.Name] = &attachmentNetwork{
+			spec:   spec,
+			subnet: subnet,
+			pool:   subnets.NewPool(subnet),
+		}
+	}
+
+	return &attacher{
+		networks:             networks,
+		idGenerator:          idGenerator,
+		interfacePrefix:      interfacePrefix,
+		chainPrefix:          chainPrefix,
+		mtu:                  mtu,
+		configStore:          configStore,
+		hostConfigurer:       hostConfigurer,
+		containerConfigurer:  containerConfigurer,
+		instanceChainCreator: instanceChainCreator,
+		firewallOpener:       firewallOpener,
+	}, nil
+}
+
+// Attach adds an interface on each of the named networks to the container
+// with the given pid. The whole network is shared by all containers attached
+// to it, so every attachment is given an address from the network's CIDR.
+func (a *attacher) Attach(log lager.Logger, handle string, networks []string, pid int) error {
+	log = log.Session("attach", lager.Data{"handle": handle, "networks": networks})
+
+	log.Info("started")
+	defer log.Info("finished")
+
+	for _, name := range networks {
+		if _, ok := a.networks[name]; !ok {
+			return fmt.Errorf("unknown attachment network: %s", name)
+		}
+	}
+
+	for _, name := range networks {
+		network := a.networks[name]
+
+		subnet, ip, err := network.pool.Acquire(log, wholeNetworkSelector{network.subnet}, subnets.DynamicIPSelector)
+		if err != nil {
+			log.Error("acquire-failed", err, lager.Data{"network": name})
+			return fmt.Errorf("attachment network %s: %s", name, err)
+		}
+
+		cfg := a.networkConfig(handle, name, subnet, ip)
+		if err := a.save(handle, cfg); err != nil {
+			return err
+		}
+
+		if err := a.hostConfigurer.Apply(log, cfg, pid); err != nil {
+			return err
+		}
+
+		if err := a.instanceChainCreator.Create(log, handle, cfg.IPTableInstance, cfg.BridgeName, cfg.ContainerIP, cfg.Subnet, nil, nil, ConnectionLimits{}); err != nil {
+			return err
+		}
+
+		if err := a.containerConfigurer.Apply(log, cfg, pid); err != nil {
+			return err
+		}
+
+		if err := a.firewallOpener.BulkOpen(log, cfg.IPTableInstance, handle, network.spec.DefaultNetOut); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *attacher) Detach(log lager.Logger, handle string) error {
+	log = log.Session("detach", lager.Data{"handle": handle})
+
+	for _, cfg := range a.load(log, handle) {
+		network, ok := a.networks[cfg.Attachment]
+		if !ok {
+			log.Info("attachment-network-no-longer-defined", lager.Data{"network": cfg.Attachment})
+			continue
+		}
+
+		if err := a.instanceChainCreator.Destroy(log, cfg.IPTableInstance); err != nil {
+			return err
+		}
+
+		if err := network.pool.Release(cfg.Subnet, cfg.ContainerIP); err != nil && err != subnets.ErrReleasedUnallocatedSubnet {
+			log.Error("release-failed", err, lager.Data{"network": cfg.Attachment})
+			return err
+		}
+
+		if err := network.pool.RunIfFree(cfg.Subnet, func() error {
+			if err := a.instanceChainCreator.DestroySubnet(log, cfg.Subnet); err != nil {
+				return err
+			}
+
+			return a.hostConfigurer.Destroy(cfg)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *attacher) Restore(log lager.Logger, handle string) error {
+	log = log.Session("restore-attachments", lager.Data{"handle": handle})
+
+	for _, cfg := range a.load(log, handle) {
+		network, ok := a.networks[cfg.Attachment]
+		if !ok {
+			return fmt.Errorf("attachment network no longer defined: %s", cfg.Attachment)
+		}
+
+		if err := network.pool.Remove(cfg.Subnet, cfg.ContainerIP); err != nil {
+			return fmt.Errorf("attachment network %s removing %s: %v", cfg.Attachment, handle, err)
+		}
+	}
+
+	return nil
+}
+
+// wholeNetworkSelector always selects the entire attachment network, which,
+// unlike a StaticSubnetSelector, is allowed to be shared between containers
+// and to be the pool's dynamic range.
+type wholeNetworkSelector struct {
+	subnet *net.IPNet
+}
+
+func (s wholeNetworkSelector) SelectSubnet(_ *net.IPNet, _ []*net.IPNet) (*net.IPNet, error) {
+	return s.subnet, nil
+}
+
+func (a *attacher) networkConfig(handle, name string, subnet *net.IPNet, ip net.IP) NetworkConfig {
+	network := a.networks[name]
+
+	mtu := a.mtu
+	if network.spec.Mtu != 0 {
+		mtu = network.spec.Mtu
+	}
+
+	id := a.idGenerator.Generate()
+	return NetworkConfig{
+		ContainerHandle: handle,
+		Attachment:      name,
+		HostIntf:        fmt.Sprintf("%s%s-0", a.interfacePrefix, id),
+		ContainerIntf:   fmt.Sprintf("%s%s-1", a.interfacePrefix, id),
+		BridgeName:      bridgeName(a.interfacePrefix, subnet),
+		IPTablePrefix:   a.chainPrefix,
+		IPTableInstance: id,
+		ContainerIP:     ip,
+		BridgeIP:        subnets.GatewayIP(subnet),
+		Subnet:          subnet,
+		Mtu:             mtu,
+	}
+}
+
+func (a *attacher) save(handle string, cfg NetworkConfig) error {
+	cfgJSON, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	if existing, ok := a.configStore.Get(handle, attachmentsKey); ok && existing != "" {
+		names = strings.Split(existing, ",")
+	}
+
+	a.configStore.Set(handle, attachmentConfigKeyPrefix+cfg.Attachment, string(cfgJSON))
+	a.configStore.Set(handle, attachmentsKey, strings.Join(append(names, cfg.Attachment), ","))
+
+	propertyPrefix := gardener.NetworkAttachmentPropertyPrefix + cfg.Attachment
+	a.configStore.Set(handle, propertyPrefix+".container-ip", cfg.ContainerIP.String())
+	a.configStore.Set(handle, propertyPrefix+".host-ip", cfg.BridgeIP.String())
+	a.configStore.Set(handle, propertyPrefix+".interface", cfg.ContainerIntf)
+
+	return nil
+}
+
+func (a *attacher) load(log lager.Logger, handle string) []NetworkConfig {
+	names, ok := a.configStore.Get(handle, attachmentsKey)
+	if !ok || names == "" {
+		return nil
+	}
+
+	var cfgs []NetworkConfig
+	for _, name := range strings.Split(names, ",") {
+		cfgJSON, ok := a.configStore.Get(handle, attachmentConfigKeyPrefix+name)
+		if !ok {
+			log.Info("attachment-config-missing", lager.Data{"network": name})
+			continue
+		}
+
+		var cfg NetworkConfig
+		if err := json.Unmarshal([]byte(cfgJSON), &cfg); err != nil {
+			log.Error("attachment-config-invalid", err, lager.Data{"network": name})
+			continue
+		}
+
+		cfgs = append(cfgs, cfg)
+	}
+
+	return cfgs
+}
+
+// AttachmentNames returns the attachment networks requested in the given
+// container properties
+func AttachmentNames(properties garden.Properties) []string {
+	var names []string
+	for _, name := range strings.Split(properties[gardener.NetworkAttachmentsKey], ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,258 @@
+package kawasaki_test
+
+import (
+	"errors"
+	"net"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/kawasaki"
+	fakes "code.cloudfoundry.org/guardian/kawasaki/kawasakifakes"
+	"code.cloudfoundry.org/guardian/properties"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Attacher", func() {
+	var (
+		specs                    []kawasaki.AttachmentNetworkSpec
+		fakeIDGenerator          *fakes.FakeIDGenerator
+		fakeHostConfigurer       *fakes.FakeHostConfigurer
+		fakeContainerConfigurer  *fakes.FakeContainerConfigurer
+		fakeInstanceChainCreator *fakes.FakeInstanceChainCreator
+		fakeFirewallOpener       *fakes.FakeFirewallOpener
+		propertyManager          *properties.Manager
+		attacher                 kawasaki.Attacher
+		logger                   lager.Logger
+	)
+
+	BeforeEach(func() {
+		specs = []kawasaki.AttachmentNetworkSpec{
+			{Name: "data", CIDR: "10.20.0.0/24"},
+			{
+				Name: "mgmt",
+				CIDR: "10.30.0.0/24",
+				Mtu:  9000,
+				DefaultNetOut: []garden.NetOutRule{
+					{Protocol: garden.ProtocolTCP},
+				},
+			},
+		}
+
+		fakeIDGenerator = new(fakes.FakeIDGenerator)
+		fakeIDGenerator.GenerateReturnsOnCall(0, "id-one")
+		fakeIDGenerator.GenerateReturnsOnCall(1, "id-two")
+
+		fakeHostConfigurer = new(fakes.FakeHostConfigurer)
+		fakeContainerConfigurer = new(fakes.FakeContainerConfigurer)
+		fakeInstanceChainCreator = new(fakes.FakeInstanceChainCreator)
+		fakeFirewallOpener = new(fakes.FakeFirewallOpener)
+		propertyManager = properties.NewManager()
+		logger = lagertest.NewTestLogger("test")
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		attacher, err = kawasaki.NewAttacher(
+			specs,
+			fakeIDGenerator,
+			"w-", "w--",
+			1500,
+			propertyManager,
+			fakeHostConfigurer,
+			fakeContainerConfigurer,
+			fakeInstanceChainCreator,
+			fakeFirewallOpener,
+		)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("NewAttacher", func() {
+		It("rejects networks with an invalid CIDR", func() {
+			_, err := kawasaki.NewAttacher([]kawasaki.AttachmentNetworkSpec{{Name: "bad", CIDR: "banana"}}, fakeIDGenerator, "w-", "w--", 1500, propertyManager, fakeHostConfigurer, fakeContainerConfigurer, fakeInstanceChainCreator, fakeFirewallOpener)
+			Expect(err).To(MatchError(ContainSubstring("attachment network 'bad'")))
+		})
+
+		It("rejects networks defined more than once", func() {
+			_, err := kawasaki.NewAttacher([]kawasaki.AttachmentNetworkSpec{
+				{Name: "data", CIDR: "10.20.0.0/24"},
+				{Name: "data", CIDR: "10.40.0.0/24"},
+			}, fakeIDGenerator, "w-", "w--", 1500, propertyManager, fakeHostConfigurer, fakeContainerConfigurer, fakeInstanceChainCreator, fakeFirewallOpener)
+			Expect(err).To(MatchError("attachment network 'data' is defined more than once"))
+		})
+
+		It("rejects networks which overlap each other", func() {
+			_, err := kawasaki.NewAttacher([]kawasaki.AttachmentNetworkSpec{
+				{Name: "data", CIDR: "10.20.0.0/16"},
+				{Name: "mgmt", CIDR: "10.20.1.0/24"},
+			}, fakeIDGenerator, "w-", "w--", 1500, propertyManager, fakeHostConfigurer, fakeContainerConfigurer, fakeInstanceChainCreator, fakeFirewallOpener)
+			Expect(err).To(MatchError("attachment network 'mgmt' overlaps 10.20.0.0/16"))
+		})
+
+		It("rejects networks which overlap a reserved network", func() {
+			_, reserved, err := net.ParseCIDR("10.254.0.0/22")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = kawasaki.NewAttacher([]kawasaki.AttachmentNetworkSpec{
+				{Name: "data", CIDR: "10.254.1.0/24"},
+			}, fakeIDGenerator, "w-", "w--", 1500, propertyManager, fakeHostConfigurer, fakeContainerConfigurer, fakeInstanceChainCreator, fakeFirewallOpener, reserved)
+			Expect(err).To(MatchError("attachment network 'data' overlaps 10.254.0.0/22"))
+		})
+	})
+
+	Describe("Attach", func() {
+		It("returns an error when a network is not defined", func() {
+			Expect(attacher.Attach(logger, "some-handle", []string{"data", "nope"}, 42)).To(MatchError("unknown attachment network: nope"))
+			Expect(fakeHostConfigurer.ApplyCallCount()).To(Equal(0))
+		})
+
+		It("configures an interface on each network", func() {
+			Expect(attacher.Attach(logger, "some-handle", []string{"data", "mgmt"}, 42)).To(Succeed())
+
+			Expect(fakeHostConfigurer.ApplyCallCount()).To(Equal(2))
+			Expect(fakeContainerConfigurer.ApplyCallCount()).To(Equal(2))
+
+			_, cfg, pid := fakeContainerConfigurer.ApplyArgsForCall(0)
+			Expect(pid).To(Equal(42))
+			Expect(cfg.Attachment).To(Equal("data"))
+			Expect(cfg.HostIntf).To(Equal("w-id-one-0"))
+			Expect(cfg.ContainerIntf).To(Equal("w-id-one-1"))
+			Expect(cfg.BridgeName).To(Equal("w-brdg-0a140000"))
+			Expect(cfg.BridgeIP.String()).To(Equal("10.20.0.1"))
+			Expect(cfg.Subnet.String()).To(Equal("10.20.0.0/24"))
+			Expect(cfg.Mtu).To(Equal(1500))
+		})
+
+		It("uses the MTU of the network when one is configured", func() {
+			Expect(attacher.Attach(logger, "some-handle", []string{"mgmt"}, 42)).To(Succeed())
+
+			_, cfg, _ := fakeHostConfigurer.ApplyArgsForCall(0)
+			Expect(cfg.Mtu).To(Equal(9000))
+		})
+
+		It("creates an instance chain for each attachment", func() {
+			Expect(attacher.Attach(logger, "some-handle", []string{"data"}, 42)).To(Succeed())
+
+			Expect(fakeInstanceChainCreator.CreateCallCount()).To(Equal(1))
+			_, handle, instanceChain, bridgeName, _, subnet, _, _, _ := fakeInstanceChainCreator.CreateArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(instanceChain).To(Equal("id-one"))
+			Expect(bridgeName).To(Equal("w-brdg-0a140000"))
+			Expect(subnet.String()).To(Equal("10.20.0.0/24"))
+		})
+
+		It("opens the default net out rules of the network", func() {
+			Expect(attacher.Attach(logger, "some-handle", []string{"mgmt"}, 42)).To(Succeed())
+
+			Expect(fakeFirewallOpener.BulkOpenCallCount()).To(Equal(1))
+			_, instance, handle, rules := fakeFirewallOpener.BulkOpenArgsForCall(0)
+			Expect(instance).To(Equal("id-one"))
+			Expect(handle).To(Equal("some-handle"))
+			Expect(rules).To(Equal([]garden.NetOutRule{{Protocol: garden.ProtocolTCP}}))
+		})
+
+		It("records the attachments in the container properties", func() {
+			Expect(attacher.Attach(logger, "some-handle", []string{"data", "mgmt"}, 42)).To(Succeed())
+
+			_, cfg, _ := fakeContainerConfigurer.ApplyArgsForCall(1)
+
+			props, err := propertyManager.All("some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(props).To(HaveKeyWithValue("garden.network.attachment.mgmt.container-ip", cfg.ContainerIP.String()))
+			Expect(props).To(HaveKeyWithValue("garden.network.attachment.mgmt.host-ip", "10.30.0.1"))
+			Expect(props).To(HaveKeyWithValue("garden.network.attachment.mgmt.interface", "w-id-two-1"))
+		})
+
+		It("gives each container on a network a different address", func() {
+			Expect(attacher.Attach(logger, "handle-one", []string{"data"}, 42)).To(Succeed())
+			Expect(attacher.Attach(logger, "handle-two", []string{"data"}, 43)).To(Succeed())
+
+			_, first, _ := fakeContainerConfigurer.ApplyArgsForCall(0)
+			_, second, _ := fakeContainerConfigurer.ApplyArgsForCall(1)
+			Expect(first.ContainerIP).NotTo(Equal(second.ContainerIP))
+			Expect(first.BridgeName).To(Equal(second.BridgeName))
+		})
+
+		Context("when configuring the host fails", func() {
+			BeforeEach(func() {
+				fakeHostConfigurer.ApplyReturns(errors.New("boom"))
+			})
+
+			It("returns the error", func() {
+				Expect(attacher.Attach(logger, "some-handle", []string{"data"}, 42)).To(MatchError("boom"))
+			})
+		})
+	})
+
+	Describe("Detach", func() {
+		JustBeforeEach(func() {
+			Expect(attacher.Attach(logger, "some-handle", []string{"data"}, 42)).To(Succeed())
+		})
+
+		It("destroys the instance chain", func() {
+			Expect(attacher.Detach(logger, "some-handle")).To(Succeed())
+
+			Expect(fakeInstanceChainCreator.DestroyCallCount()).To(Equal(1))
+			_, instanceChain := fakeInstanceChainCreator.DestroyArgsForCall(0)
+			Expect(instanceChain).To(Equal("id-one"))
+		})
+
+		It("destroys the bridge when no other container is attached", func() {
+			Expect(attacher.Detach(logger, "some-handle")).To(Succeed())
+
+			Expect(fakeHostConfigurer.DestroyCallCount()).To(Equal(1))
+			cfg := fakeHostConfigurer.DestroyArgsForCall(0)
+			Expect(cfg.BridgeName).To(Equal("w-brdg-0a140000"))
+		})
+
+		It("destroys the subnet's rules when no other container is attached", func() {
+			Expect(attacher.Detach(logger, "some-handle")).To(Succeed())
+
+			Expect(fakeInstanceChainCreator.DestroySubnetCallCount()).To(Equal(1))
+			_, network := fakeInstanceChainCreator.DestroySubnetArgsForCall(0)
+			Expect(network.String()).To(Equal("10.20.0.0/24"))
+		})
+
+		It("keeps the bridge while other containers are attached", func() {
+			Expect(attacher.Attach(logger, "other-handle", []string{"data"}, 43)).To(Succeed())
+			Expect(attacher.Detach(logger, "some-handle")).To(Succeed())
+
+			Expect(fakeHostConfigurer.DestroyCallCount()).To(Equal(0))
+			Expect(fakeInstanceChainCreator.DestroySubnetCallCount()).To(Equal(0))
+		})
+
+		It("does nothing for containers without attachments", func() {
+			Expect(attacher.Detach(logger, "other-handle")).To(Succeed())
+			Expect(fakeInstanceChainCreator.DestroyCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("Restore", func() {
+		It("marks the attachment addresses as in use", func() {
+			Expect(attacher.Attach(logger, "some-handle", []string{"data"}, 42)).To(Succeed())
+			_, attached, _ := fakeContainerConfigurer.ApplyArgsForCall(0)
+
+			restored, err := kawasaki.NewAttacher(specs, fakeIDGenerator, "w-", "w--", 1500, propertyManager, fakeHostConfigurer, fakeContainerConfigurer, fakeInstanceChainCreator, fakeFirewallOpener)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restored.Restore(logger, "some-handle")).To(Succeed())
+
+			Expect(restored.Attach(logger, "other-handle", []string{"data"}, 43)).To(Succeed())
+			_, cfg, _ := fakeContainerConfigurer.ApplyArgsForCall(1)
+			Expect(cfg.ContainerIP).NotTo(Equal(attached.ContainerIP))
+		})
+	})
+})
+
+var _ = Describe("AttachmentNames", func() {
+	It("parses the comma separated list of networks", func() {
+		Expect(kawasaki.AttachmentNames(garden.Properties{
+			"garden.network.attachments": " data, ,mgmt",
+		})).To(Equal([]string{"data", "mgmt"}))
+	})
+
+	It("returns nothing when no networks are requested", func() {
+		Expect(kawasaki.AttachmentNames(garden.Properties{})).To(BeEmpty())
+	})
+})
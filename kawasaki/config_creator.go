@@ -12,7 +12,7 @@ import (
 const (
 	maxInterfacePrefixLen = 2
 	maxChainPrefixLen     = 16
-	maxAllowedMtuSize     = 1500
+	minMtuSize            = 68
 )
 
 //go:generate counterfeiter . IDGenerator
@@ -23,6 +23,9 @@ type IDGenerator interface {
 
 type NetworkConfig struct {
 	ContainerHandle       string
+	Attachment            string
+	Pool                  string
+	Mode                  string
 	HostIntf              string
 	ContainerIntf         string
 	IPTablePrefix         string
@@ -31,14 +34,32 @@ type NetworkConfig struct {
 	BridgeIP              net.IP
 	ContainerIP           net.IP
 	ExternalIP            net.IP
+	EgressIP              net.IP
 	Subnet                *net.IPNet
 	Mtu                   int
+	ConnectionLimits      ConnectionLimits
 	PluginNameservers     []net.IP
 	OperatorNameservers   []net.IP
 	AdditionalNameservers []net.IP
 	AdditionalHostEntries []string
 }
 
+// ConnectionLimits cap the connections a container may make. A zero value
+// means no limit.
+type ConnectionLimits struct {
+	// MaxConnections is the number of connections a container may have open
+	// at once
+	MaxConnections int
+
+	// Rate is the number of new connections a container may open per second
+	Rate int
+}
+
+// IsSet returns true if either limit is set
+func (l ConnectionLimits) IsSet() bool {
+	return l.MaxConnections != 0 || l.Rate != 0
+}
+
 type Creator struct {
 	idGenerator           IDGenerator
 	interfacePrefix       string
@@ -48,9 +69,10 @@ type Creator struct {
 	additionalNameservers []net.IP
 	additionalHostEntries []string
 	mtu                   int
+	connectionLimits      ConnectionLimits
 }
 
-func NewConfigCreator(idGenerator IDGenerator, interfacePrefix, chainPrefix string, externalIP net.IP, operatorNameservers, additionalNameservers []net.IP, additionalHostEntries []string, mtu int) *Creator {
+func NewConfigCreator(idGenerator IDGenerator, interfacePrefix, chainPrefix string, externalIP net.IP, operatorNameservers, additionalNameservers []net.IP, additionalHostEntries []string, mtu int, connectionLimits ConnectionLimits) *Creator {
 	if len(interfacePrefix) > maxInterfacePrefixLen {
 		panic("interface prefix is too long")
 	}
@@ -67,7 +89,8 @@ func NewConfigCreator(idGenerator IDGenerator, interfacePrefix, chainPrefix stri
 		operatorNameservers:   operatorNameservers,
 		additionalNameservers: additionalNameservers,
 		additionalHostEntries: additionalHostEntries,
-		mtu: min(mtu, maxAllowedMtuSize),
+		mtu:                   mtu,
+		connectionLimits:      connectionLimits,
 	}
 }
 
@@ -78,7 +101,7 @@ func (c *Creator) Create(log lager.Logger, handle string, subnet *net.IPNet, ip
 		HostIntf:        fmt.Sprintf("%s%s-0", c.interfacePrefix, id),
 		ContainerIntf:   fmt.Sprintf("%s%s-1", c.interfacePrefix, id),
 
-		BridgeName: fmt.Sprintf("%s%s%s", c.interfacePrefix, "brdg-", hex.EncodeToString(subnet.IP)),
+		BridgeName: bridgeName(c.interfacePrefix, subnet),
 
 		IPTablePrefix:         c.chainPrefix,
 		IPTableInstance:       id,
@@ -87,15 +110,13 @@ func (c *Creator) Create(log lager.Logger, handle string, subnet *net.IPNet, ip
 		ExternalIP:            c.externalIP,
 		Subnet:                subnet,
 		Mtu:                   c.mtu,
+		ConnectionLimits:      c.connectionLimits,
 		OperatorNameservers:   c.operatorNameservers,
 		AdditionalNameservers: c.additionalNameservers,
 		AdditionalHostEntries: c.additionalHostEntries,
 	}, nil
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
+func bridgeName(interfacePrefix string, subnet *net.IPNet) string {
+	return fmt.Sprintf("%s%s%s", interfacePrefix, "brdg-", hex.EncodeToString(subnet.IP))
 }
@@ -23,6 +23,7 @@ var _ = Describe("ConfigCreator", func() {
 		logger                lager.Logger
 		idGenerator           *fakes.FakeIDGenerator
 		mtu                   int
+		connectionLimits      kawasaki.ConnectionLimits
 	)
 
 	BeforeEach(func() {
@@ -47,33 +48,34 @@ var _ = Describe("ConfigCreator", func() {
 		idGenerator = &fakes.FakeIDGenerator{}
 
 		mtu = 1234
+		connectionLimits = kawasaki.ConnectionLimits{MaxConnections: 100, Rate: 10}
 	})
 
 	JustBeforeEach(func() {
-		creator = kawasaki.NewConfigCreator(idGenerator, "w1", "0123456789abcdef", externalIP, operatorNameservers, additionalNameservers, additionalHostEntries, mtu)
+		creator = kawasaki.NewConfigCreator(idGenerator, "w1", "0123456789abcdef", externalIP, operatorNameservers, additionalNameservers, additionalHostEntries, mtu, connectionLimits)
 	})
 
 	It("panics if the interface prefix is longer than 2 characters", func() {
 		Expect(func() {
-			kawasaki.NewConfigCreator(idGenerator, "too-long", "wc", externalIP, operatorNameservers, additionalNameservers, additionalHostEntries, mtu)
+			kawasaki.NewConfigCreator(idGenerator, "too-long", "wc", externalIP, operatorNameservers, additionalNameservers, additionalHostEntries, mtu, connectionLimits)
 		}).To(Panic())
 	})
 
 	It("panics if the chain prefix is longer than 16 characters", func() {
 		Expect(func() {
-			kawasaki.NewConfigCreator(idGenerator, "w1", "0123456789abcdefg", externalIP, operatorNameservers, additionalNameservers, additionalHostEntries, mtu)
+			kawasaki.NewConfigCreator(idGenerator, "w1", "0123456789abcdefg", externalIP, operatorNameservers, additionalNameservers, additionalHostEntries, mtu, connectionLimits)
 		}).To(Panic())
 	})
 
-	Context("when mtu is greater than max allowed value", func() {
+	Context("when mtu is greater than 1500", func() {
 		BeforeEach(func() {
-			mtu = 6789
+			mtu = 9000
 		})
 
-		It("sets mtu to max allowed value", func() {
+		It("keeps the jumbo frame mtu", func() {
 			config, err := creator.Create(logger, "banana", subnet, ip)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(config.Mtu).To(Equal(1500))
+			Expect(config.Mtu).To(Equal(9000))
 		})
 	})
 
@@ -158,6 +160,13 @@ var _ = Describe("ConfigCreator", func() {
 		Expect(config.Mtu).To(Equal(1234))
 	})
 
+	It("assigns the default connection limits", func() {
+		config, err := creator.Create(logger, "banana", subnet, ip)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(config.ConnectionLimits).To(Equal(kawasaki.ConnectionLimits{MaxConnections: 100, Rate: 10}))
+	})
+
 	It("assigns the DNS servers", func() {
 		config, err := creator.Create(logger, "banana", subnet, ip)
 		Expect(err).NotTo(HaveOccurred())
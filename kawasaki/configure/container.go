@@ -25,6 +25,7 @@ func init() {
 	reexec.Register("configure-container-netns", func() {
 		var netNsPath, containerIntf, containerIPStr, bridgeIPStr, subnetStr string
 		var mtu int
+		var defaultGW bool
 
 		flag.StringVar(&netNsPath, "netNsPath", "", "netNsPath")
 		flag.StringVar(&containerIntf, "containerIntf", "", "containerIntf")
@@ -32,6 +33,7 @@ func init() {
 		flag.StringVar(&bridgeIPStr, "bridgeIP", "", "bridgeIP")
 		flag.StringVar(&subnetStr, "subnet", "", "subnet")
 		flag.IntVar(&mtu, "mtu", 0, "mtu")
+		flag.BoolVar(&defaultGW, "defaultGW", true, "defaultGW")
 		flag.Parse()
 
 		fd, err := os.Open(netNsPath)
@@ -69,8 +71,10 @@ func init() {
 				panic(err)
 			}
 
-			if err := link.AddDefaultGW(intf, bridgeIP); err != nil {
-				panic(err)
+			if defaultGW {
+				if err := link.AddDefaultGW(intf, bridgeIP); err != nil {
+					panic(err)
+				}
 			}
 
 			if err := link.SetMTU(intf, mtu); err != nil {
@@ -108,6 +112,8 @@ func (c *Container) Apply(log lager.Logger, cfg kawasaki.NetworkConfig, pid int)
 		"-bridgeIP", cfg.BridgeIP.String(),
 		"-subnet", cfg.Subnet.String(),
 		"-mtu", strconv.FormatInt(int64(cfg.Mtu), 10),
+		// only the primary interface routes the container's default traffic
+		"-defaultGW="+strconv.FormatBool(cfg.Attachment == ""),
 	)
 
 	errBuf := bytes.NewBuffer([]byte{})
@@ -0,0 +1,84 @@
+package configure
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"code.cloudfoundry.org/guardian/kawasaki"
+	"code.cloudfoundry.org/lager"
+)
+
+// Direct configures the host side of a container attached directly to a
+// parent interface with macvlan or ipvlan, rather than through a bridge
+type Direct struct {
+	Mode            string
+	ParentInterface string
+
+	Creator interface {
+		Create(mode, parentName, name string) (*net.Interface, error)
+		Destroy(name string) error
+	}
+
+	Link interface {
+		SetMTU(intf *net.Interface, mtu int) error
+		SetNs(intf *net.Interface, fd int) error
+	}
+
+	FileOpener interface {
+		Open(path string) (*os.File, error)
+	}
+}
+
+func (c *Direct) Apply(logger lager.Logger, config kawasaki.NetworkConfig, pid int) error {
+	log := logger.Session("configure-direct", lager.Data{
+		"mode":           c.Mode,
+		"parent":         c.ParentInterface,
+		"containerIface": config.ContainerIntf,
+		"mtu":            config.Mtu,
+		"pid":            pid,
+	})
+
+	log.Debug("create")
+	container, err := c.Creator.Create(c.Mode, c.ParentInterface, config.ContainerIntf)
+	if err != nil {
+		log.Error("create", err)
+		return err
+	}
+
+	log.Debug("set-mtu")
+	if err := c.Link.SetMTU(container, config.Mtu); err != nil {
+		log.Error("set-mtu", err)
+		c.destroy(log, container)
+		return &MTUError{err, container, config.Mtu}
+	}
+
+	netns, err := c.FileOpener.Open(fmt.Sprintf("/proc/%d/ns/net", pid))
+	if err != nil {
+		c.destroy(log, container)
+		return err
+	}
+	defer netns.Close()
+
+	if err := c.Link.SetNs(container, int(netns.Fd())); err != nil {
+		log.Error("set-ns", err)
+		c.destroy(log, container)
+		return &SetNsFailedError{err, container, netns}
+	}
+
+	return nil
+}
+
+// destroy removes an interface which never made it in to the container, as
+// it would otherwise be left on the host until the next restart
+func (c *Direct) destroy(log lager.Logger, container *net.Interface) {
+	if err := c.Creator.Destroy(container.Name); err != nil {
+		log.Error("destroy-interface", err)
+	}
+}
+
+// Destroy is a no-op: the interface is removed along with the container's
+// network namespace and there is no shared bridge to clean up
+func (c *Direct) Destroy(config kawasaki.NetworkConfig) error {
+	return nil
+}
@@ -0,0 +1,139 @@
+package configure_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+
+	"code.cloudfoundry.org/guardian/kawasaki"
+	"code.cloudfoundry.org/guardian/kawasaki/configure"
+	"code.cloudfoundry.org/guardian/kawasaki/devices/fakedevices"
+	"code.cloudfoundry.org/guardian/kawasaki/netns"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Direct", func() {
+	var (
+		creator        *fakedevices.FakeDirectLinkCreator
+		linkConfigurer *fakedevices.FakeLink
+		netnsFD        *os.File
+
+		configurer *configure.Direct
+
+		logger lager.Logger
+		config kawasaki.NetworkConfig
+	)
+
+	BeforeEach(func() {
+		creator = &fakedevices.FakeDirectLinkCreator{}
+		creator.CreateReturns.Interface = &net.Interface{Name: "the-container"}
+		linkConfigurer = &fakedevices.FakeLink{AddIPReturns: make(map[string]error)}
+
+		var err error
+		netnsFD, err = ioutil.TempFile("", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		configurer = &configure.Direct{
+			Mode:            "macvlan",
+			ParentInterface: "eth1",
+			Creator:         creator,
+			Link:            linkConfigurer,
+			FileOpener: netns.Opener(func(path string) (*os.File, error) {
+				return netnsFD, nil
+			}),
+		}
+
+		logger = lagertest.NewTestLogger("test")
+		config = kawasaki.NetworkConfig{ContainerIntf: "container", Mtu: 1400}
+	})
+
+	AfterEach(func() {
+		Expect(os.Remove(netnsFD.Name())).To(Succeed())
+	})
+
+	Describe("Apply", func() {
+		It("creates an interface on the parent interface", func() {
+			Expect(configurer.Apply(logger, config, 42)).To(Succeed())
+
+			Expect(creator.CreateCalledWith.Mode).To(Equal("macvlan"))
+			Expect(creator.CreateCalledWith.ParentName).To(Equal("eth1"))
+			Expect(creator.CreateCalledWith.Name).To(Equal("container"))
+		})
+
+		It("sets the mtu of the interface", func() {
+			Expect(configurer.Apply(logger, config, 42)).To(Succeed())
+
+			Expect(linkConfigurer.SetMTUCalledWith.Interface).To(Equal(creator.CreateReturns.Interface))
+			Expect(linkConfigurer.SetMTUCalledWith.MTU).To(Equal(1400))
+		})
+
+		It("moves the interface in to the container's namespace", func() {
+			expectedNetNsFd := int(netnsFD.Fd())
+
+			Expect(configurer.Apply(logger, config, 42)).To(Succeed())
+			Expect(linkConfigurer.SetNsCalledWith.Interface).To(Equal(creator.CreateReturns.Interface))
+			Expect(linkConfigurer.SetNsCalledWith.Fd).To(Equal(expectedNetNsFd))
+		})
+
+		Context("when creating the interface fails", func() {
+			It("returns the error", func() {
+				creator.CreateReturns.Err = errors.New("no parent")
+				Expect(configurer.Apply(logger, config, 42)).To(MatchError("no parent"))
+			})
+		})
+
+		Context("when setting the mtu fails", func() {
+			It("returns a wrapped error", func() {
+				linkConfigurer.SetMTUReturns = errors.New("o no")
+				err := configurer.Apply(logger, config, 42)
+				Expect(err).To(MatchError(&configure.MTUError{Cause: linkConfigurer.SetMTUReturns, Intf: creator.CreateReturns.Interface, MTU: 1400}))
+			})
+
+			It("removes the interface", func() {
+				linkConfigurer.SetMTUReturns = errors.New("o no")
+				Expect(configurer.Apply(logger, config, 42)).NotTo(Succeed())
+				Expect(creator.DestroyCalledWith).To(Equal([]string{"the-container"}))
+			})
+		})
+
+		Context("when moving the interface in to the container's namespace fails", func() {
+			BeforeEach(func() {
+				linkConfigurer.SetNsReturns = errors.New("o no")
+			})
+
+			It("returns a wrapped error", func() {
+				err := configurer.Apply(logger, config, 42)
+				Expect(err).To(BeAssignableToTypeOf(&configure.SetNsFailedError{}))
+			})
+
+			It("removes the interface", func() {
+				Expect(configurer.Apply(logger, config, 42)).NotTo(Succeed())
+				Expect(creator.DestroyCalledWith).To(Equal([]string{"the-container"}))
+			})
+
+			Context("and removing the interface also fails", func() {
+				It("returns the original error", func() {
+					creator.DestroyReturns = errors.New("still busy")
+					err := configurer.Apply(logger, config, 42)
+					Expect(err).To(BeAssignableToTypeOf(&configure.SetNsFailedError{}))
+				})
+			})
+		})
+
+		It("does not remove the interface when it succeeds", func() {
+			Expect(configurer.Apply(logger, config, 42)).To(Succeed())
+			Expect(creator.DestroyCalledWith).To(BeEmpty())
+		})
+	})
+
+	Describe("Destroy", func() {
+		It("succeeds without doing anything", func() {
+			Expect(configurer.Destroy(config)).To(Succeed())
+		})
+	})
+})
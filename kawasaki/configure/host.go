@@ -52,7 +52,7 @@ func (c *Host) Apply(logger lager.Logger, config kawasaki.NetworkConfig, pid int
 
 	cLog.Debug("configuring")
 
-	if bridge, err = c.configureBridgeIntf(cLog, config.BridgeName, config.BridgeIP, config.Subnet); err != nil {
+	if bridge, err = c.configureBridgeIntf(cLog, config.BridgeName, config.BridgeIP, config.Subnet, config.Mtu); err != nil {
 		return err
 	}
 
@@ -82,7 +82,7 @@ func (c *Host) Destroy(config kawasaki.NetworkConfig) error {
 	return c.Bridge.Destroy(config.BridgeName)
 }
 
-func (c *Host) configureBridgeIntf(log lager.Logger, name string, ip net.IP, subnet *net.IPNet) (*net.Interface, error) {
+func (c *Host) configureBridgeIntf(log lager.Logger, name string, ip net.IP, subnet *net.IPNet, mtu int) (*net.Interface, error) {
 	log = log.Session("bridge-interface")
 
 	log.Debug("find")
@@ -95,6 +95,16 @@ func (c *Host) configureBridgeIntf(log lager.Logger, name string, ip net.IP, sub
 		}
 	}
 
+	// traffic between the container and the host is routed through the
+	// bridge, so it must carry frames as large as the container's
+	if bridge.MTU < mtu {
+		log.Debug("set-mtu")
+		if err = c.Link.SetMTU(bridge, mtu); err != nil {
+			log.Error("set-mtu", err)
+			return nil, &MTUError{err, bridge, mtu}
+		}
+	}
+
 	log.Debug("bring-up")
 	if err = c.Link.SetUp(bridge); err != nil {
 		log.Error("bring-up", err)
@@ -64,7 +64,7 @@ var _ = Describe("Host", func() {
 				return netnsFD, nil
 			}
 
-			existingBridge = &net.Interface{Name: "bridge"}
+			existingBridge = &net.Interface{Name: "bridge", MTU: 1500}
 		})
 
 		JustBeforeEach(func() {
@@ -119,6 +119,41 @@ var _ = Describe("Host", func() {
 				Expect(linkConfigurer.SetMTUCalledWith.MTU).To(Equal(123))
 			})
 
+			Context("when the mtu is larger than the bridge's", func() {
+				BeforeEach(func() {
+					config.HostIntf = "host"
+					config.BridgeName = "bridge"
+					config.ContainerIntf = "container"
+					config.Mtu = 9000
+				})
+
+				It("raises the bridge's mtu before setting the host interface's", func() {
+					Expect(configurer.Apply(logger, config, 42)).To(Succeed())
+
+					Expect(linkConfigurer.SetMTUCalls).To(Equal([]fakedevices.InterfaceMTU{
+						{Interface: existingBridge, MTU: 9000},
+						{Interface: vethCreator.CreateReturns.Host, MTU: 9000},
+					}))
+				})
+
+				Context("when raising the bridge's mtu fails", func() {
+					It("returns a wrapped error", func() {
+						linkConfigurer.SetMTUReturns = errors.New("o no")
+						err := configurer.Apply(logger, config, 42)
+						Expect(err).To(MatchError(&configure.MTUError{Cause: linkConfigurer.SetMTUReturns, Intf: existingBridge, MTU: 9000}))
+					})
+				})
+			})
+
+			It("does not change the mtu of a bridge which is already large enough", func() {
+				config.BridgeName = "bridge"
+				config.Mtu = 1500
+				Expect(configurer.Apply(logger, config, 42)).To(Succeed())
+
+				Expect(linkConfigurer.SetMTUCalls).To(HaveLen(1))
+				Expect(linkConfigurer.SetMTUCalls[0].Interface).To(Equal(vethCreator.CreateReturns.Host))
+			})
+
 			Context("When setting the mtu fails", func() {
 				It("returns a wrapped error", func() {
 					config.HostIntf = "host"
@@ -172,6 +207,8 @@ var _ = Describe("Host", func() {
 			Describe("adding the host to the bridge", func() {
 				Context("when the bridge interface does not exist", func() {
 					It("creates the bridge", func() {
+						bridger.CreateReturns.Interface = &net.Interface{Name: "created"}
+
 						config.BridgeName = "banana-bridge"
 						Expect(configurer.Apply(logger, config, 42)).To(Succeed())
 						Expect(bridger.CreateCalledWith.Name).To(Equal("banana-bridge"))
@@ -0,0 +1,114 @@
+package configure
+
+import (
+	"net"
+
+	"code.cloudfoundry.org/guardian/kawasaki"
+	"code.cloudfoundry.org/lager"
+)
+
+// Overlay configures the host side of a container in a VXLAN pool. The
+// container is connected to the pool's bridge as in bridge mode, and the
+// bridge is joined to the pool's VNI by a VXLAN device which floods to each
+// of the remote VTEPs.
+type Overlay struct {
+	Host kawasaki.HostConfigurer
+
+	Device          string
+	VNI             int
+	LocalIP         net.IP
+	Port            int
+	ParentInterface string
+
+	Peers interface {
+		List() ([]net.IP, error)
+	}
+
+	VXLAN interface {
+		Create(name string, vni int, localIP net.IP, port int, parentName string) (*net.Interface, error)
+		SetPeers(name string, peers []net.IP) error
+		Destroy(name string) error
+	}
+
+	Link interface {
+		SetUp(intf *net.Interface) error
+		SetMTU(intf *net.Interface, mtu int) error
+		InterfaceByName(name string) (*net.Interface, bool, error)
+	}
+
+	Bridge interface {
+		Add(bridge, slave *net.Interface) error
+	}
+}
+
+func (c *Overlay) Apply(logger lager.Logger, config kawasaki.NetworkConfig, pid int) error {
+	if err := c.Host.Apply(logger, config, pid); err != nil {
+		return err
+	}
+
+	log := logger.Session("configure-overlay", lager.Data{
+		"device":     c.Device,
+		"vni":        c.VNI,
+		"bridgeName": config.BridgeName,
+		"mtu":        config.Mtu,
+	})
+
+	log.Debug("create")
+	vtep, err := c.VXLAN.Create(c.Device, c.VNI, c.LocalIP, c.Port, c.ParentInterface)
+	if err != nil {
+		log.Error("create", err)
+		return err
+	}
+
+	// the bridge can only carry frames as large as its smallest port allows
+	if vtep.MTU < config.Mtu {
+		log.Debug("set-mtu")
+		if err := c.Link.SetMTU(vtep, config.Mtu); err != nil {
+			log.Error("set-mtu", err)
+			return &MTUError{err, vtep, config.Mtu}
+		}
+	}
+
+	bridge, bridgeExists, err := c.Link.InterfaceByName(config.BridgeName)
+	if err != nil || !bridgeExists {
+		return &FindLinkError{err, "bridge", config.BridgeName}
+	}
+
+	log.Debug("add-to-bridge")
+	if err := c.Bridge.Add(bridge, vtep); err != nil {
+		log.Error("add-to-bridge", err)
+		return &AddToBridgeError{err, bridge, vtep}
+	}
+
+	log.Debug("bring-up")
+	if err := c.Link.SetUp(vtep); err != nil {
+		log.Error("bring-up", err)
+		return &LinkUpError{err, vtep, "vxlan"}
+	}
+
+	// peers are refreshed whenever a container joins, picking up any
+	// changes to the peers file
+	peers, err := c.Peers.List()
+	if err != nil {
+		log.Error("list-peers", err)
+		return err
+	}
+
+	log.Debug("set-peers", lager.Data{"peers": peers})
+	if err := c.VXLAN.SetPeers(c.Device, peers); err != nil {
+		log.Error("set-peers", err)
+		return err
+	}
+
+	return nil
+}
+
+// Destroy removes the VXLAN device along with the pool's bridge, once the
+// last container on this host has left the pool
+func (c *Overlay) Destroy(config kawasaki.NetworkConfig) error {
+	if err := c.VXLAN.Destroy(c.Device); err != nil {
+		return err
+	}
+
+	return c.Host.Destroy(config)
+}
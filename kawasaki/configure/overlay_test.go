@@ -0,0 +1,176 @@
+package configure_test
+
+import (
+	"errors"
+	"net"
+
+	"code.cloudfoundry.org/guardian/kawasaki"
+	"code.cloudfoundry.org/guardian/kawasaki/configure"
+	"code.cloudfoundry.org/guardian/kawasaki/devices/fakedevices"
+	"code.cloudfoundry.org/guardian/kawasaki/kawasakifakes"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Overlay", func() {
+	var (
+		host           *kawasakifakes.FakeHostConfigurer
+		vxlan          *fakedevices.FakeVXLAN
+		linkConfigurer *fakedevices.FakeLink
+		bridger        *fakedevices.FakeBridge
+		bridge         *net.Interface
+		vtep           *net.Interface
+
+		configurer *configure.Overlay
+
+		logger lager.Logger
+		config kawasaki.NetworkConfig
+	)
+
+	BeforeEach(func() {
+		host = new(kawasakifakes.FakeHostConfigurer)
+
+		vtep = &net.Interface{Name: "wvx42", MTU: 1500}
+		vxlan = &fakedevices.FakeVXLAN{}
+		vxlan.CreateReturns.Interface = vtep
+
+		bridge = &net.Interface{Name: "the-bridge"}
+		linkConfigurer = &fakedevices.FakeLink{
+			InterfaceByNameFunc: func(name string) (*net.Interface, bool, error) {
+				if name == "the-bridge" {
+					return bridge, true, nil
+				}
+
+				return nil, false, nil
+			},
+		}
+		bridger = &fakedevices.FakeBridge{}
+
+		configurer = &configure.Overlay{
+			Host:            host,
+			Device:          "wvx42",
+			VNI:             42,
+			LocalIP:         net.ParseIP("192.168.0.1"),
+			Port:            4789,
+			ParentInterface: "eth1",
+			Peers:           kawasaki.VXLANPeers{Static: []net.IP{net.ParseIP("192.168.0.2")}},
+			VXLAN:           vxlan,
+			Link:            linkConfigurer,
+			Bridge:          bridger,
+		}
+
+		logger = lagertest.NewTestLogger("test")
+		config = kawasaki.NetworkConfig{BridgeName: "the-bridge", Mtu: 1450}
+	})
+
+	Describe("Apply", func() {
+		It("configures the container's host side as in bridge mode", func() {
+			Expect(configurer.Apply(logger, config, 42)).To(Succeed())
+
+			Expect(host.ApplyCallCount()).To(Equal(1))
+			_, appliedConfig, pid := host.ApplyArgsForCall(0)
+			Expect(appliedConfig).To(Equal(config))
+			Expect(pid).To(Equal(42))
+		})
+
+		It("creates the vxlan device", func() {
+			Expect(configurer.Apply(logger, config, 42)).To(Succeed())
+
+			Expect(vxlan.CreateCalledWith.Name).To(Equal("wvx42"))
+			Expect(vxlan.CreateCalledWith.VNI).To(Equal(42))
+			Expect(vxlan.CreateCalledWith.LocalIP).To(Equal(net.ParseIP("192.168.0.1")))
+			Expect(vxlan.CreateCalledWith.Port).To(Equal(4789))
+			Expect(vxlan.CreateCalledWith.ParentName).To(Equal("eth1"))
+		})
+
+		It("adds the vxlan device to the bridge and brings it up", func() {
+			Expect(configurer.Apply(logger, config, 42)).To(Succeed())
+
+			Expect(bridger.AddCalledWith.Bridge).To(Equal(bridge))
+			Expect(bridger.AddCalledWith.Slave).To(Equal(vtep))
+			Expect(linkConfigurer.SetUpCalledWith).To(ContainElement(vtep))
+		})
+
+		It("floods to the peers", func() {
+			Expect(configurer.Apply(logger, config, 42)).To(Succeed())
+
+			Expect(vxlan.SetPeersCalledWith.Name).To(Equal("wvx42"))
+			Expect(vxlan.SetPeersCalledWith.Peers).To(Equal([]net.IP{net.ParseIP("192.168.0.2")}))
+		})
+
+		Context("when the vxlan device's mtu is smaller than the container's", func() {
+			BeforeEach(func() {
+				vtep.MTU = 1400
+			})
+
+			It("raises it to the container's", func() {
+				Expect(configurer.Apply(logger, config, 42)).To(Succeed())
+
+				Expect(linkConfigurer.SetMTUCalledWith.Interface).To(Equal(vtep))
+				Expect(linkConfigurer.SetMTUCalledWith.MTU).To(Equal(1450))
+			})
+		})
+
+		It("does not lower the vxlan device's mtu", func() {
+			Expect(configurer.Apply(logger, config, 42)).To(Succeed())
+			Expect(linkConfigurer.SetMTUCalls).To(BeEmpty())
+		})
+
+		Context("when configuring the host side fails", func() {
+			It("returns the error without creating the vxlan device", func() {
+				host.ApplyReturns(errors.New("no bridge"))
+
+				Expect(configurer.Apply(logger, config, 42)).To(MatchError("no bridge"))
+				Expect(vxlan.CreateCalledWith.Name).To(BeEmpty())
+			})
+		})
+
+		Context("when creating the vxlan device fails", func() {
+			It("returns the error", func() {
+				vxlan.CreateReturns.Err = errors.New("no vxlan")
+				Expect(configurer.Apply(logger, config, 42)).To(MatchError("no vxlan"))
+			})
+		})
+
+		Context("when the bridge cannot be found", func() {
+			It("returns a wrapped error", func() {
+				config.BridgeName = "another-bridge"
+				Expect(configurer.Apply(logger, config, 42)).To(MatchError(&configure.FindLinkError{Role: "bridge", Name: "another-bridge"}))
+			})
+		})
+
+		Context("when the peers cannot be listed", func() {
+			It("returns the error", func() {
+				configurer.Peers = kawasaki.VXLANPeers{File: "/does/not/exist"}
+				Expect(configurer.Apply(logger, config, 42)).To(MatchError(ContainSubstring("opening vxlan peers file")))
+			})
+		})
+
+		Context("when setting the peers fails", func() {
+			It("returns the error", func() {
+				vxlan.SetPeersReturns = errors.New("no peers")
+				Expect(configurer.Apply(logger, config, 42)).To(MatchError("no peers"))
+			})
+		})
+	})
+
+	Describe("Destroy", func() {
+		It("destroys the vxlan device and the bridge", func() {
+			Expect(configurer.Destroy(config)).To(Succeed())
+
+			Expect(vxlan.DestroyCalledWith).To(Equal([]string{"wvx42"}))
+			Expect(host.DestroyCallCount()).To(Equal(1))
+			Expect(host.DestroyArgsForCall(0)).To(Equal(config))
+		})
+
+		Context("when destroying the vxlan device fails", func() {
+			It("returns the error", func() {
+				vxlan.DestroyReturns = errors.New("busy")
+				Expect(configurer.Destroy(config)).To(MatchError("busy"))
+			})
+		})
+	})
+})
@@ -18,6 +18,7 @@ type configurer struct {
 	hostConfigurer       HostConfigurer
 	containerConfigurer  ContainerConfigurer
 	instanceChainCreator InstanceChainCreator
+	conntrackFlusher     ConntrackFlusher
 	fileOpener           netns.Opener
 }
 
@@ -29,8 +30,14 @@ type HostConfigurer interface {
 
 //go:generate counterfeiter . InstanceChainCreator
 type InstanceChainCreator interface {
-	Create(logger lager.Logger, handle, instanceChain, bridgeName string, ip net.IP, network *net.IPNet) error
+	Create(logger lager.Logger, handle, instanceChain, bridgeName string, ip net.IP, network *net.IPNet, externalIP, egressIP net.IP, limits ConnectionLimits) error
 	Destroy(logger lager.Logger, instanceChain string) error
+	DestroySubnet(logger lager.Logger, network *net.IPNet) error
+}
+
+//go:generate counterfeiter . ConntrackFlusher
+type ConntrackFlusher interface {
+	Flush(logger lager.Logger, ip net.IP) error
 }
 
 //go:generate counterfeiter . ContainerConfigurer
@@ -43,12 +50,13 @@ type DnsResolvConfigurer interface {
 	Configure(log lager.Logger, cfg NetworkConfig, pid int) error
 }
 
-func NewConfigurer(resolvConfigurer DnsResolvConfigurer, hostConfigurer HostConfigurer, containerConfigurer ContainerConfigurer, instanceChainCreator InstanceChainCreator) *configurer {
+func NewConfigurer(resolvConfigurer DnsResolvConfigurer, hostConfigurer HostConfigurer, containerConfigurer ContainerConfigurer, instanceChainCreator InstanceChainCreator, conntrackFlusher ConntrackFlusher) *configurer {
 	return &configurer{
 		dnsResolvConfigurer:  resolvConfigurer,
 		hostConfigurer:       hostConfigurer,
 		containerConfigurer:  containerConfigurer,
 		instanceChainCreator: instanceChainCreator,
+		conntrackFlusher:     conntrackFlusher,
 	}
 }
 
@@ -61,17 +69,59 @@ func (c *configurer) Apply(log lager.Logger, cfg NetworkConfig, pid int) error {
 		return err
 	}
 
-	if err := c.instanceChainCreator.Create(log, cfg.ContainerHandle, cfg.IPTableInstance, cfg.BridgeName, cfg.ContainerIP, cfg.Subnet); err != nil {
+	if err := c.instanceChainCreator.Create(log, cfg.ContainerHandle, cfg.IPTableInstance, cfg.BridgeName, cfg.ContainerIP, cfg.Subnet, cfg.ExternalIP, cfg.EgressIP, cfg.ConnectionLimits); err != nil {
 		return err
 	}
 
 	return c.containerConfigurer.Apply(log, cfg, pid)
 }
 
+// DestroyBridge is called once the last container in a subnet is destroyed,
+// and removes the subnet's rules along with its bridge
 func (c *configurer) DestroyBridge(log lager.Logger, cfg NetworkConfig) error {
+	if err := c.instanceChainCreator.DestroySubnet(log, cfg.Subnet); err != nil {
+		return err
+	}
+
 	return c.hostConfigurer.Destroy(cfg)
 }
 
+// NoopInstanceChainCreator is used where traffic bypasses the host's
+// iptables, so there are no per-container chains to manage
+type NoopInstanceChainCreator struct{}
+
+func (NoopInstanceChainCreator) Create(logger lager.Logger, handle, instanceChain, bridgeName string, ip net.IP, network *net.IPNet, externalIP, egressIP net.IP, limits ConnectionLimits) error {
+	return nil
+}
+
+func (NoopInstanceChainCreator) Destroy(logger lager.Logger, instanceChain string) error {
+	return nil
+}
+
+func (NoopInstanceChainCreator) DestroySubnet(logger lager.Logger, network *net.IPNet) error {
+	return nil
+}
+
+// NoopConntrackFlusher is used where connections are not tracked by the host,
+// or where there is no conntrack binary to flush them with
+type NoopConntrackFlusher struct{}
+
+func (NoopConntrackFlusher) Flush(logger lager.Logger, ip net.IP) error {
+	return nil
+}
+
+// DestroyIPTablesRules removes the container's chains and then flushes its
+// connection tracking entries, so that connections which were allowed or
+// counted against its limits do not outlive it. Failing to flush is logged
+// rather than returned, as the entries will eventually expire.
 func (c *configurer) DestroyIPTablesRules(log lager.Logger, cfg NetworkConfig) error {
-	return c.instanceChainCreator.Destroy(log, cfg.IPTableInstance)
+	if err := c.instanceChainCreator.Destroy(log, cfg.IPTableInstance); err != nil {
+		return err
+	}
+
+	if err := c.conntrackFlusher.Flush(log, cfg.ContainerIP); err != nil {
+		log.Error("flush-conntrack-failed", err)
+	}
+
+	return nil
 }
@@ -21,6 +21,7 @@ var _ = Describe("Configurer", func() {
 		fakeHostConfigurer       *fakes.FakeHostConfigurer
 		fakeContainerConfigurer  *fakes.FakeContainerConfigurer
 		fakeInstanceChainCreator *fakes.FakeInstanceChainCreator
+		fakeConntrackFlusher     *fakes.FakeConntrackFlusher
 
 		netnsFD *os.File
 
@@ -35,12 +36,13 @@ var _ = Describe("Configurer", func() {
 		fakeHostConfigurer = new(fakes.FakeHostConfigurer)
 		fakeContainerConfigurer = new(fakes.FakeContainerConfigurer)
 		fakeInstanceChainCreator = new(fakes.FakeInstanceChainCreator)
+		fakeConntrackFlusher = new(fakes.FakeConntrackFlusher)
 
 		var err error
 		netnsFD, err = ioutil.TempFile("", "")
 		Expect(err).NotTo(HaveOccurred())
 
-		configurer = kawasaki.NewConfigurer(fakeDnsResolvConfigurer, fakeHostConfigurer, fakeContainerConfigurer, fakeInstanceChainCreator)
+		configurer = kawasaki.NewConfigurer(fakeDnsResolvConfigurer, fakeHostConfigurer, fakeContainerConfigurer, fakeInstanceChainCreator, fakeConntrackFlusher)
 
 		logger = lagertest.NewTestLogger("test")
 	})
@@ -107,16 +109,25 @@ var _ = Describe("Configurer", func() {
 				ContainerIP:     net.ParseIP("1.2.3.4"),
 				ContainerHandle: "some-handle",
 				Subnet:          subnet,
+				ExternalIP:      net.ParseIP("9.8.7.6"),
+				EgressIP:        net.ParseIP("5.6.7.8"),
+				ConnectionLimits: kawasaki.ConnectionLimits{
+					MaxConnections: 100,
+					Rate:           10,
+				},
 			}
 
 			Expect(configurer.Apply(logger, cfg, 42)).To(Succeed())
 			Expect(fakeInstanceChainCreator.CreateCallCount()).To(Equal(1))
-			_, handle, instanceChain, bridgeName, ip, subnet := fakeInstanceChainCreator.CreateArgsForCall(0)
+			_, handle, instanceChain, bridgeName, ip, subnet, externalIP, egressIP, limits := fakeInstanceChainCreator.CreateArgsForCall(0)
 			Expect(handle).To(Equal("some-handle"))
 			Expect(instanceChain).To(Equal("instance"))
 			Expect(bridgeName).To(Equal("the-bridge-name"))
 			Expect(ip).To(Equal(net.ParseIP("1.2.3.4")))
 			Expect(subnet).To(Equal(subnet))
+			Expect(externalIP).To(Equal(net.ParseIP("9.8.7.6")))
+			Expect(egressIP).To(Equal(net.ParseIP("5.6.7.8")))
+			Expect(limits).To(Equal(kawasaki.ConnectionLimits{MaxConnections: 100, Rate: 10}))
 		})
 
 		Context("when applying IPTables configuration fails", func() {
@@ -161,6 +172,26 @@ var _ = Describe("Configurer", func() {
 			Expect(fakeHostConfigurer.DestroyArgsForCall(0)).To(Equal(cfg))
 		})
 
+		It("should destroy the subnet's rules", func() {
+			_, subnet, _ := net.ParseCIDR("10.0.0.0/30")
+			Expect(configurer.DestroyBridge(logger, kawasaki.NetworkConfig{Subnet: subnet})).To(Succeed())
+
+			Expect(fakeInstanceChainCreator.DestroySubnetCallCount()).To(Equal(1))
+			_, network := fakeInstanceChainCreator.DestroySubnetArgsForCall(0)
+			Expect(network).To(Equal(subnet))
+		})
+
+		Context("when it fails to destroy the subnet's rules", func() {
+			BeforeEach(func() {
+				fakeInstanceChainCreator.DestroySubnetReturns(errors.New("iptables-error"))
+			})
+
+			It("should return the error without destroying the host configuration", func() {
+				Expect(configurer.DestroyBridge(logger, kawasaki.NetworkConfig{})).To(MatchError("iptables-error"))
+				Expect(fakeHostConfigurer.DestroyCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when it fails to destroy the host configuration", func() {
 			It("should return the error", func() {
 				fakeHostConfigurer.DestroyReturns(errors.New("spiderman-error"))
@@ -193,6 +224,30 @@ var _ = Describe("Configurer", func() {
 				cfg := kawasaki.NetworkConfig{}
 				Expect(configurer.DestroyIPTablesRules(logger, cfg)).To(MatchError(ContainSubstring("ananas is the best")))
 			})
+
+			It("does not flush the container's connections", func() {
+				Expect(configurer.DestroyIPTablesRules(logger, kawasaki.NetworkConfig{})).NotTo(Succeed())
+				Expect(fakeConntrackFlusher.FlushCallCount()).To(Equal(0))
+			})
+		})
+
+		It("flushes the container's connection tracking entries", func() {
+			cfg := kawasaki.NetworkConfig{
+				IPTableInstance: "sausages",
+				ContainerIP:     net.ParseIP("1.2.3.4"),
+			}
+			Expect(configurer.DestroyIPTablesRules(logger, cfg)).To(Succeed())
+
+			Expect(fakeConntrackFlusher.FlushCallCount()).To(Equal(1))
+			_, ip := fakeConntrackFlusher.FlushArgsForCall(0)
+			Expect(ip).To(Equal(net.ParseIP("1.2.3.4")))
+		})
+
+		Context("when flushing the connection tracking entries fails", func() {
+			It("still succeeds, as the entries will expire", func() {
+				fakeConntrackFlusher.FlushReturns(errors.New("no conntrack"))
+				Expect(configurer.DestroyIPTablesRules(logger, kawasaki.NetworkConfig{})).To(Succeed())
+			})
 		})
 	})
 })
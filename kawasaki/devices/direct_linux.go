@@ -0,0 +1,61 @@
+package devices
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink"
+)
+
+type DirectLinkCreator struct{}
+
+// Create adds a macvlan or ipvlan interface with the given name on top of the
+// parent interface. Containers attached this way share the parent's layer 2
+// network and bypass the host's bridge and NAT entirely.
+func (DirectLinkCreator) Create(mode, parentName, name string) (*net.Interface, error) {
+	netlinkMu.Lock()
+	defer netlinkMu.Unlock()
+
+	parent, err := netlink.LinkByName(parentName)
+	if err != nil {
+		return nil, fmt.Errorf("devices: look up parent interface %s: %v", parentName, err)
+	}
+
+	attrs := netlink.LinkAttrs{Name: name, ParentIndex: parent.Attrs().Index}
+
+	var link netlink.Link
+	switch mode {
+	case "macvlan":
+		link = &netlink.Macvlan{LinkAttrs: attrs, Mode: netlink.MACVLAN_MODE_BRIDGE}
+	case "ipvlan":
+		link = &netlink.IPVlan{LinkAttrs: attrs, Mode: netlink.IPVLAN_MODE_L2}
+	default:
+		return nil, fmt.Errorf("devices: unknown direct link mode: %s", mode)
+	}
+
+	if err := netlink.LinkAdd(link); err != nil {
+		return nil, fmt.Errorf("devices: create %s interface: %v", mode, err)
+	}
+
+	intf, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("devices: look up created %s interface: %v", mode, err)
+	}
+
+	return intf, nil
+}
+
+// Destroy removes the interface with the given name if it is still in the
+// host's namespace, such as when it could not be moved in to a container
+func (DirectLinkCreator) Destroy(name string) error {
+	netlinkMu.Lock()
+	defer netlinkMu.Unlock()
+
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		// already gone
+		return nil
+	}
+
+	return netlink.LinkDel(link)
+}
@@ -27,6 +27,11 @@ type InterfaceIPAndSubnet struct {
 	Subnet    *net.IPNet
 }
 
+type InterfaceMTU struct {
+	Interface *net.Interface
+	MTU       int
+}
+
 type FakeLink struct {
 	AddIPCalledWith        []InterfaceIPAndSubnet
 	SetUpCalledWith        []*net.Interface
@@ -39,6 +44,7 @@ type FakeLink struct {
 		Interface *net.Interface
 		MTU       int
 	}
+	SetMTUCalls []InterfaceMTU
 
 	SetNsCalledWith struct {
 		Interface *net.Interface
@@ -78,6 +84,7 @@ func (f *FakeLink) SetUp(intf *net.Interface) error {
 func (f *FakeLink) SetMTU(intf *net.Interface, mtu int) error {
 	f.SetMTUCalledWith.Interface = intf
 	f.SetMTUCalledWith.MTU = mtu
+	f.SetMTUCalls = append(f.SetMTUCalls, InterfaceMTU{intf, mtu})
 	return f.SetMTUReturns
 }
 
@@ -146,3 +153,78 @@ func (f *FakeBridge) Destroy(bridge string) error {
 	f.DestroyCalledWith = append(f.DestroyCalledWith, bridge)
 	return f.DestroyReturns
 }
+
+type FakeDirectLinkCreator struct {
+	CreateCalledWith struct {
+		Mode, ParentName, Name string
+	}
+
+	CreateReturns struct {
+		Interface *net.Interface
+		Err       error
+	}
+
+	DestroyCalledWith []string
+
+	DestroyReturns error
+}
+
+func (f *FakeDirectLinkCreator) Create(mode, parentName, name string) (*net.Interface, error) {
+	f.CreateCalledWith.Mode = mode
+	f.CreateCalledWith.ParentName = parentName
+	f.CreateCalledWith.Name = name
+
+	return f.CreateReturns.Interface, f.CreateReturns.Err
+}
+
+func (f *FakeDirectLinkCreator) Destroy(name string) error {
+	f.DestroyCalledWith = append(f.DestroyCalledWith, name)
+	return f.DestroyReturns
+}
+
+type FakeVXLAN struct {
+	CreateCalledWith struct {
+		Name       string
+		VNI        int
+		LocalIP    net.IP
+		Port       int
+		ParentName string
+	}
+
+	CreateReturns struct {
+		Interface *net.Interface
+		Err       error
+	}
+
+	SetPeersCalledWith struct {
+		Name  string
+		Peers []net.IP
+	}
+
+	SetPeersReturns error
+
+	DestroyCalledWith []string
+
+	DestroyReturns error
+}
+
+func (f *FakeVXLAN) Create(name string, vni int, localIP net.IP, port int, parentName string) (*net.Interface, error) {
+	f.CreateCalledWith.Name = name
+	f.CreateCalledWith.VNI = vni
+	f.CreateCalledWith.LocalIP = localIP
+	f.CreateCalledWith.Port = port
+	f.CreateCalledWith.ParentName = parentName
+
+	return f.CreateReturns.Interface, f.CreateReturns.Err
+}
+
+func (f *FakeVXLAN) SetPeers(name string, peers []net.IP) error {
+	f.SetPeersCalledWith.Name = name
+	f.SetPeersCalledWith.Peers = peers
+	return f.SetPeersReturns
+}
+
+func (f *FakeVXLAN) Destroy(name string) error {
+	f.DestroyCalledWith = append(f.DestroyCalledWith, name)
+	return f.DestroyReturns
+}
@@ -0,0 +1,131 @@
+package devices
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+// the all-zeros MAC address in a VXLAN forwarding entry floods broadcast and
+// unknown unicast frames to the entry's remote VTEP
+var floodMAC = net.HardwareAddr{0, 0, 0, 0, 0, 0}
+
+type VXLAN struct{}
+
+// Create adds a VXLAN device sending the frames of the given VNI to remote
+// VTEPs from localIP and port, optionally over the parent interface. Remote
+// MAC addresses are learned from received frames. If the device already
+// exists, returns the existing interface.
+func (VXLAN) Create(name string, vni int, localIP net.IP, port int, parentName string) (*net.Interface, error) {
+	netlinkMu.Lock()
+	defer netlinkMu.Unlock()
+
+	if intf, _ := net.InterfaceByName(name); intf != nil {
+		return intf, nil
+	}
+
+	link := &netlink.Vxlan{
+		LinkAttrs: netlink.LinkAttrs{Name: name},
+		VxlanId:   vni,
+		SrcAddr:   localIP,
+		Port:      port,
+		Learning:  true,
+	}
+
+	if parentName != "" {
+		parent, err := netlink.LinkByName(parentName)
+		if err != nil {
+			return nil, fmt.Errorf("devices: look up parent interface %s: %v", parentName, err)
+		}
+
+		link.VtepDevIndex = parent.Attrs().Index
+	}
+
+	if err := netlink.LinkAdd(link); err != nil && err.Error() != "file exists" {
+		return nil, fmt.Errorf("devices: create vxlan interface: %v", err)
+	}
+
+	intf, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("devices: look up created vxlan interface: %v", err)
+	}
+
+	return intf, nil
+}
+
+// SetPeers replaces the remote VTEPs to which the VXLAN device floods frames
+// with the given peers
+func (VXLAN) SetPeers(name string, peers []net.IP) error {
+	netlinkMu.Lock()
+	defer netlinkMu.Unlock()
+
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("devices: look up vxlan interface %s: %v", name, err)
+	}
+
+	entries, err := netlink.NeighList(link.Attrs().Index, syscall.AF_BRIDGE)
+	if err != nil {
+		return fmt.Errorf("devices: list vxlan peers: %v", err)
+	}
+
+	wanted := map[string]bool{}
+	for _, peer := range peers {
+		wanted[peer.String()] = true
+	}
+
+	for _, entry := range entries {
+		if entry.IP == nil || entry.HardwareAddr.String() != floodMAC.String() {
+			continue
+		}
+
+		if wanted[entry.IP.String()] {
+			delete(wanted, entry.IP.String())
+			continue
+		}
+
+		if err := netlink.NeighDel(&entry); err != nil {
+			return fmt.Errorf("devices: remove vxlan peer %s: %v", entry.IP, err)
+		}
+	}
+
+	for _, peer := range peers {
+		if !wanted[peer.String()] {
+			continue
+		}
+
+		if err := netlink.NeighAppend(floodEntry(link, peer)); err != nil {
+			return fmt.Errorf("devices: add vxlan peer %s: %v", peer, err)
+		}
+
+		delete(wanted, peer.String())
+	}
+
+	return nil
+}
+
+func (VXLAN) Destroy(name string) error {
+	netlinkMu.Lock()
+	defer netlinkMu.Unlock()
+
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		// already gone
+		return nil
+	}
+
+	return netlink.LinkDel(link)
+}
+
+func floodEntry(link netlink.Link, peer net.IP) *netlink.Neigh {
+	return &netlink.Neigh{
+		LinkIndex:    link.Attrs().Index,
+		Family:       syscall.AF_BRIDGE,
+		State:        netlink.NUD_PERMANENT,
+		Flags:        netlink.NTF_SELF,
+		IP:           peer,
+		HardwareAddr: floodMAC,
+	}
+}
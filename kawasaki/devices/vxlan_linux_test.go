@@ -0,0 +1,116 @@
+package devices_test
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"code.cloudfoundry.org/guardian/kawasaki/devices"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("VXLAN Management", func() {
+	var (
+		v    devices.VXLAN
+		name string
+	)
+
+	BeforeEach(func() {
+		name = fmt.Sprintf("gdn-test-vx-%d", GinkgoParallelNode())
+	})
+
+	AfterEach(func() {
+		cleanup(name)
+	})
+
+	peers := func() []string {
+		link, err := netlink.LinkByName(name)
+		Expect(err).NotTo(HaveOccurred())
+
+		entries, err := netlink.NeighList(link.Attrs().Index, syscall.AF_BRIDGE)
+		Expect(err).NotTo(HaveOccurred())
+
+		var ips []string
+		for _, entry := range entries {
+			if entry.IP != nil && entry.HardwareAddr.String() == "00:00:00:00:00:00" {
+				ips = append(ips, entry.IP.String())
+			}
+		}
+
+		return ips
+	}
+
+	Describe("Create", func() {
+		It("creates a vxlan device with the given VNI and port", func() {
+			intf, err := v.Create(name, 42, net.ParseIP("127.0.0.1"), 4789, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(intf.Name).To(Equal(name))
+
+			link, err := netlink.LinkByName(name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(link).To(BeAssignableToTypeOf(&netlink.Vxlan{}))
+			Expect(link.(*netlink.Vxlan).VxlanId).To(Equal(42))
+			Expect(link.(*netlink.Vxlan).Port).To(Equal(4789))
+		})
+
+		Context("when the device already exists", func() {
+			It("returns the existing interface", func() {
+				existing, err := v.Create(name, 42, nil, 4789, "")
+				Expect(err).NotTo(HaveOccurred())
+
+				intf, err := v.Create(name, 42, nil, 4789, "")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(intf.Index).To(Equal(existing.Index))
+			})
+		})
+
+		Context("when the parent interface does not exist", func() {
+			It("returns an error", func() {
+				_, err := v.Create(name, 42, nil, 4789, "gdn-not-there")
+				Expect(err).To(MatchError(ContainSubstring("look up parent interface gdn-not-there")))
+			})
+		})
+	})
+
+	Describe("SetPeers", func() {
+		BeforeEach(func() {
+			_, err := v.Create(name, 42, nil, 4789, "")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("floods to each of the peers", func() {
+			Expect(v.SetPeers(name, []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.3")})).To(Succeed())
+			Expect(peers()).To(ConsistOf("10.0.0.2", "10.0.0.3"))
+		})
+
+		It("removes peers which are no longer listed", func() {
+			Expect(v.SetPeers(name, []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.3")})).To(Succeed())
+			Expect(v.SetPeers(name, []net.IP{net.ParseIP("10.0.0.3"), net.ParseIP("10.0.0.4")})).To(Succeed())
+			Expect(peers()).To(ConsistOf("10.0.0.3", "10.0.0.4"))
+		})
+
+		Context("when the device does not exist", func() {
+			It("returns an error", func() {
+				Expect(v.SetPeers("gdn-not-there", nil)).To(MatchError(ContainSubstring("look up vxlan interface gdn-not-there")))
+			})
+		})
+	})
+
+	Describe("Destroy", func() {
+		It("removes the device", func() {
+			_, err := v.Create(name, 42, nil, 4789, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(v.Destroy(name)).To(Succeed())
+
+			_, err = net.InterfaceByName(name)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("succeeds when the device does not exist", func() {
+			Expect(v.Destroy(name)).To(Succeed())
+		})
+	})
+})
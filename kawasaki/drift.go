@@ -0,0 +1,197 @@
+package kawasaki
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/gardener"
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-golang/clock"
+)
+
+// ChainDrift describes how a container's live iptables rules differ from the
+// rules kawasaki applied to it
+type ChainDrift struct {
+	Missing    []string
+	Unexpected []string
+	Repaired   bool
+}
+
+func (d ChainDrift) Drifted() bool {
+	return len(d.Missing) > 0 || len(d.Unexpected) > 0
+}
+
+//go:generate counterfeiter . ChainReconciler
+
+type ChainReconciler interface {
+	Reconcile(log lager.Logger, handle string, cfg NetworkConfig, netOut []garden.NetOutRule, portMappings []garden.PortMapping) (ChainDrift, error)
+}
+
+//go:generate counterfeiter . HandleLister
+
+type HandleLister interface {
+	Handles() ([]string, error)
+}
+
+// DriftDetector compares the iptables rules of every container with the
+// rules recorded in the ConfigStore, and re-applies any which are missing. It
+// shares the networker's HandleLocks, so that it never repairs a container
+// from rules which are being changed or removed.
+//
+// Only a container's main network is checked: the chains of its attachment
+// networks are not compared. Containers created before their net-out rules
+// were recorded in the ConfigStore have nothing to compare against, so they
+// are skipped until they are recreated.
+type DriftDetector struct {
+	handles     HandleLister
+	configStore ConfigStore
+	reconciler  ChainReconciler
+	locks       *HandleLocks
+
+	mu                 sync.Mutex
+	driftedContainers  int
+	repairedContainers int
+	failedChecks       int
+}
+
+func NewDriftDetector(handles HandleLister, configStore ConfigStore, reconciler ChainReconciler, locks *HandleLocks) *DriftDetector {
+	return &DriftDetector{
+		handles:     handles,
+		configStore: configStore,
+		reconciler:  reconciler,
+		locks:       locks,
+	}
+}
+
+// Check reconciles the firewall of every fully networked container. Containers
+// in direct network modes, and containers not networked by kawasaki, have no
+// rules to check and are skipped.
+func (d *DriftDetector) Check(log lager.Logger) (map[string]ChainDrift, error) {
+	log = log.Session("check-iptables-drift")
+	log.Info("started")
+	defer log.Info("finished")
+
+	handles, err := d.handles.Handles()
+	if err != nil {
+		log.Error("listing-handles-failed", err)
+		return nil, err
+	}
+
+	drifts := map[string]ChainDrift{}
+	failed := 0
+	for _, handle := range handles {
+		drift, checked, err := d.check(log, handle)
+		if err != nil {
+			log.Error("reconcile-failed", err, lager.Data{"handle": handle})
+			failed++
+			continue
+		}
+
+		if checked && drift.Drifted() {
+			log.Info("drift-detected", lager.Data{
+				"handle":     handle,
+				"missing":    drift.Missing,
+				"unexpected": drift.Unexpected,
+				"repaired":   drift.Repaired,
+			})
+			drifts[handle] = drift
+		}
+	}
+
+	d.record(drifts, failed)
+
+	return drifts, nil
+}
+
+func (d *DriftDetector) check(log lager.Logger, handle string) (ChainDrift, bool, error) {
+	// the expected rules are read, and repaired, while no net-out or destroy
+	// can change them
+	d.locks.Lock(handle)
+	defer d.locks.Unlock(handle)
+
+	rulesJson, ok := d.configStore.Get(handle, netOutRulesKey)
+	if !ok || rulesJson == "" {
+		// the container is still being networked, is being destroyed, was
+		// created before net-out rules were recorded, or is not networked by
+		// kawasaki at all
+		log.Debug("skipped-no-recorded-rules", lager.Data{"handle": handle})
+		return ChainDrift{}, false, nil
+	}
+
+	cfg, err := load(d.configStore, handle)
+	if err != nil {
+		return ChainDrift{}, false, nil
+	}
+
+	if isDirectMode(cfg.Mode) {
+		return ChainDrift{}, false, nil
+	}
+
+	rules, err := netOutRules(d.configStore, handle)
+	if err != nil {
+		return ChainDrift{}, false, err
+	}
+
+	var mappings portMappingList
+	if mappingsJson, ok := d.configStore.Get(handle, gardener.MappedPortsKey); ok {
+		mappings, err = portsFromJson(mappingsJson)
+		if err != nil {
+			return ChainDrift{}, false, err
+		}
+	}
+
+	drift, err := d.reconciler.Reconcile(log, handle, cfg, rules, mappings)
+	return drift, true, err
+}
+
+func (d *DriftDetector) record(drifts map[string]ChainDrift, failed int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.driftedContainers = len(drifts)
+	d.failedChecks += failed
+	for _, drift := range drifts {
+		if drift.Repaired {
+			d.repairedContainers++
+		}
+	}
+}
+
+// Run checks for drift every interval until stop is closed
+func (d *DriftDetector) Run(log lager.Logger, clk clock.Clock, interval time.Duration, stop <-chan struct{}) {
+	ticker := clk.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			d.Check(log)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// DriftedContainers returns the number of containers which had drifted at
+// the last check
+func (d *DriftDetector) DriftedContainers() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.driftedContainers
+}
+
+// RepairedContainers returns the number of times a drifted container's
+// rules have been re-applied
+func (d *DriftDetector) RepairedContainers() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.repairedContainers
+}
+
+// FailedChecks returns the number of containers which could not be checked
+func (d *DriftDetector) FailedChecks() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.failedChecks
+}
@@ -0,0 +1,191 @@
+package kawasaki_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/gardener"
+	"code.cloudfoundry.org/guardian/kawasaki"
+	fakes "code.cloudfoundry.org/guardian/kawasaki/kawasakifakes"
+	"code.cloudfoundry.org/guardian/properties"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DriftDetector", func() {
+	var (
+		fakeHandleLister *fakes.FakeHandleLister
+		fakeReconciler   *fakes.FakeChainReconciler
+		propertyManager  *properties.Manager
+		locks            *kawasaki.HandleLocks
+		detector         *kawasaki.DriftDetector
+		logger           lager.Logger
+	)
+
+	networked := func(handle string) {
+		for name, value := range map[string]string{
+			"kawasaki.host-interface":      "w-host",
+			"kawasaki.container-interface": "w-cont",
+			"kawasaki.bridge-interface":    "w-brdg",
+			gardener.BridgeIPKey:           "10.0.0.1",
+			gardener.ContainerIPKey:        "10.0.0.2",
+			gardener.ExternalIPKey:         "1.2.3.4",
+			"kawasaki.subnet":              "10.0.0.0/30",
+			"kawasaki.iptable-prefix":      "w--",
+			"kawasaki.iptable-inst":        handle + "-inst",
+			"kawasaki.mtu":                 "1500",
+			"kawasaki.dns-servers":         "",
+			"kawasaki.host-entries":        "",
+			"kawasaki.net-out-rules":       `[{"protocol":1}]`,
+			gardener.MappedPortsKey:        `[{"HostPort":60000,"ContainerPort":8080}]`,
+		} {
+			propertyManager.Set(handle, name, value)
+		}
+	}
+
+	BeforeEach(func() {
+		fakeHandleLister = new(fakes.FakeHandleLister)
+		fakeReconciler = new(fakes.FakeChainReconciler)
+		propertyManager = properties.NewManager()
+		logger = lagertest.NewTestLogger("test")
+
+		networked("some-handle")
+		fakeHandleLister.HandlesReturns([]string{"some-handle"}, nil)
+
+		locks = kawasaki.NewHandleLocks()
+		detector = kawasaki.NewDriftDetector(fakeHandleLister, propertyManager, fakeReconciler, locks)
+	})
+
+	It("reconciles each container with the rules recorded for it", func() {
+		_, err := detector.Check(logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeReconciler.ReconcileCallCount()).To(Equal(1))
+		_, handle, cfg, netOut, portMappings := fakeReconciler.ReconcileArgsForCall(0)
+		Expect(handle).To(Equal("some-handle"))
+		Expect(cfg.IPTableInstance).To(Equal("some-handle-inst"))
+		Expect(cfg.ContainerIP.String()).To(Equal("10.0.0.2"))
+		Expect(netOut).To(Equal([]garden.NetOutRule{{Protocol: garden.ProtocolTCP}}))
+		Expect(portMappings).To(Equal([]garden.PortMapping{{HostPort: 60000, ContainerPort: 8080}}))
+	})
+
+	It("skips containers which are not fully networked", func() {
+		propertyManager.Set("some-handle", "kawasaki.net-out-rules", "")
+		fakeHandleLister.HandlesReturns([]string{"some-handle", "host-network-handle"}, nil)
+
+		_, err := detector.Check(logger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeReconciler.ReconcileCallCount()).To(Equal(0))
+	})
+
+	Context("when the container's networking is being changed", func() {
+		It("waits, and reconciles with the rules recorded once the change is done", func() {
+			locks.Lock("some-handle")
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+
+				_, err := detector.Check(logger)
+				Expect(err).NotTo(HaveOccurred())
+			}()
+
+			Consistently(fakeReconciler.ReconcileCallCount).Should(Equal(0))
+
+			propertyManager.Set("some-handle", "kawasaki.net-out-rules", `[{"protocol":2}]`)
+			locks.Unlock("some-handle")
+			Eventually(done).Should(BeClosed())
+
+			Expect(fakeReconciler.ReconcileCallCount()).To(Equal(1))
+			_, _, _, netOut, _ := fakeReconciler.ReconcileArgsForCall(0)
+			Expect(netOut).To(Equal([]garden.NetOutRule{{Protocol: garden.ProtocolUDP}}))
+		})
+
+		It("does not repair a container which was destroyed meanwhile", func() {
+			locks.Lock("some-handle")
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+
+				_, err := detector.Check(logger)
+				Expect(err).NotTo(HaveOccurred())
+			}()
+
+			propertyManager.Set("some-handle", "kawasaki.net-out-rules", "")
+			locks.Unlock("some-handle")
+			Eventually(done).Should(BeClosed())
+
+			Expect(fakeReconciler.ReconcileCallCount()).To(Equal(0))
+		})
+	})
+
+	It("skips containers attached directly to a network", func() {
+		propertyManager.Set("some-handle", "kawasaki.mode", "macvlan")
+
+		_, err := detector.Check(logger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeReconciler.ReconcileCallCount()).To(Equal(0))
+	})
+
+	Context("when a container has drifted", func() {
+		BeforeEach(func() {
+			networked("other-handle")
+			fakeHandleLister.HandlesReturns([]string{"some-handle", "other-handle"}, nil)
+
+			fakeReconciler.ReconcileStub = func(_ lager.Logger, handle string, _ kawasaki.NetworkConfig, _ []garden.NetOutRule, _ []garden.PortMapping) (kawasaki.ChainDrift, error) {
+				if handle == "other-handle" {
+					return kawasaki.ChainDrift{Missing: []string{"some rule"}, Repaired: true}, nil
+				}
+
+				return kawasaki.ChainDrift{}, nil
+			}
+		})
+
+		It("reports the drift", func() {
+			drifts, err := detector.Check(logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drifts).To(Equal(map[string]kawasaki.ChainDrift{
+				"other-handle": {Missing: []string{"some rule"}, Repaired: true},
+			}))
+		})
+
+		It("counts drifted and repaired containers", func() {
+			_, err := detector.Check(logger)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = detector.Check(logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(detector.DriftedContainers()).To(Equal(1))
+			Expect(detector.RepairedContainers()).To(Equal(2))
+		})
+	})
+
+	Context("when reconciling a container fails", func() {
+		BeforeEach(func() {
+			fakeReconciler.ReconcileReturns(kawasaki.ChainDrift{}, errors.New("boom"))
+		})
+
+		It("counts the failure and carries on", func() {
+			_, err := detector.Check(logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(detector.FailedChecks()).To(Equal(1))
+		})
+	})
+
+	Context("when listing containers fails", func() {
+		BeforeEach(func() {
+			fakeHandleLister.HandlesReturns(nil, errors.New("boom"))
+		})
+
+		It("returns the error", func() {
+			_, err := detector.Check(logger)
+			Expect(err).To(MatchError("boom"))
+		})
+	})
+})
@@ -1,6 +1,7 @@
 package factory
 
 import (
+	"net"
 	"os"
 
 	"code.cloudfoundry.org/guardian/kawasaki"
@@ -11,7 +12,7 @@ import (
 	"code.cloudfoundry.org/guardian/kawasaki/netns"
 )
 
-func NewDefaultConfigurer(ipt *iptables.IPTablesController, depotDir string) kawasaki.Configurer {
+func NewDefaultConfigurer(ipt *iptables.IPTablesController, nflogGroup uint16, depotDir string, conntrackFlusher kawasaki.ConntrackFlusher) kawasaki.Configurer {
 	resolvConfigurer := &kawasaki.ResolvConfigurer{
 		HostsFileCompiler: &dns.HostsFileCompiler{},
 		ResolvCompiler:    &dns.ResolvCompiler{},
@@ -34,6 +35,107 @@ func NewDefaultConfigurer(ipt *iptables.IPTablesController, depotDir string) kaw
 		resolvConfigurer,
 		hostConfigurer,
 		containerConfigurer,
-		iptables.NewInstanceChainCreator(ipt),
+		iptables.NewInstanceChainCreator(ipt, nflogGroup),
+		conntrackFlusher,
+	)
+}
+
+// NewDirectConfigurer configures containers attached directly to a parent
+// interface with macvlan or ipvlan. There are no bridges or iptables chains.
+func NewDirectConfigurer(depotDir, mode, parentInterface string) kawasaki.Configurer {
+	resolvConfigurer := &kawasaki.ResolvConfigurer{
+		HostsFileCompiler: &dns.HostsFileCompiler{},
+		ResolvCompiler:    &dns.ResolvCompiler{},
+		DepotDir:          depotDir,
+		ResolvFilePath:    "/etc/resolv.conf",
+	}
+
+	hostConfigurer := &configure.Direct{
+		Mode:            mode,
+		ParentInterface: parentInterface,
+		Creator:         &devices.DirectLinkCreator{},
+		Link:            &devices.Link{},
+		FileOpener:      netns.Opener(os.Open),
+	}
+
+	containerConfigurer := &configure.Container{
+		FileOpener: netns.Opener(os.Open),
+	}
+
+	return kawasaki.NewConfigurer(
+		resolvConfigurer,
+		hostConfigurer,
+		containerConfigurer,
+		kawasaki.NoopInstanceChainCreator{},
+		kawasaki.NoopConntrackFlusher{},
+	)
+}
+
+// NewVXLANConfigurer configures containers in a VXLAN pool. Containers are
+// bridged and firewalled as with the default configurer, and the bridge is
+// joined to the overlay through the named VXLAN device.
+func NewVXLANConfigurer(ipt *iptables.IPTablesController, nflogGroup uint16, depotDir string, conntrackFlusher kawasaki.ConntrackFlusher, device string, pool kawasaki.NetworkPool) kawasaki.Configurer {
+	resolvConfigurer := &kawasaki.ResolvConfigurer{
+		HostsFileCompiler: &dns.HostsFileCompiler{},
+		ResolvCompiler:    &dns.ResolvCompiler{},
+		DepotDir:          depotDir,
+		ResolvFilePath:    "/etc/resolv.conf",
+	}
+
+	hostConfigurer := &configure.Overlay{
+		Host: &configure.Host{
+			Veth:       &devices.VethCreator{},
+			Link:       &devices.Link{},
+			Bridge:     &devices.Bridge{},
+			FileOpener: netns.Opener(os.Open),
+		},
+		Device:          device,
+		VNI:             pool.Spec.VNI,
+		LocalIP:         net.ParseIP(pool.Spec.VTEPLocalIP),
+		Port:            pool.Spec.VTEPPort,
+		ParentInterface: pool.Spec.ParentInterface,
+		Peers:           pool.Peers,
+		VXLAN:           &devices.VXLAN{},
+		Link:            &devices.Link{},
+		Bridge:          &devices.Bridge{},
+	}
+
+	containerConfigurer := &configure.Container{
+		FileOpener: netns.Opener(os.Open),
+	}
+
+	return kawasaki.NewConfigurer(
+		resolvConfigurer,
+		hostConfigurer,
+		containerConfigurer,
+		iptables.NewInstanceChainCreator(ipt, nflogGroup),
+		conntrackFlusher,
+	)
+}
+
+func NewDefaultAttacher(ipt *iptables.IPTablesController, firewallOpener kawasaki.FirewallOpener, specs []kawasaki.AttachmentNetworkSpec, idGenerator kawasaki.IDGenerator, interfacePrefix, chainPrefix string, mtu int, nflogGroup uint16, configStore kawasaki.ConfigStore, reserved ...*net.IPNet) (kawasaki.Attacher, error) {
+	hostConfigurer := &configure.Host{
+		Veth:       &devices.VethCreator{},
+		Link:       &devices.Link{},
+		Bridge:     &devices.Bridge{},
+		FileOpener: netns.Opener(os.Open),
+	}
+
+	containerConfigurer := &configure.Container{
+		FileOpener: netns.Opener(os.Open),
+	}
+
+	return kawasaki.NewAttacher(
+		specs,
+		idGenerator,
+		interfacePrefix,
+		chainPrefix,
+		mtu,
+		configStore,
+		hostConfigurer,
+		containerConfigurer,
+		iptables.NewInstanceChainCreator(ipt, nflogGroup),
+		firewallOpener,
+		reserved...,
 	)
 }
@@ -3,10 +3,24 @@
 package factory
 
 import (
+	"net"
+
 	"code.cloudfoundry.org/guardian/kawasaki"
 	"code.cloudfoundry.org/guardian/kawasaki/iptables"
 )
 
-func NewDefaultConfigurer(ipt *iptables.IPTablesController, depotDir string) kawasaki.Configurer {
+func NewDefaultConfigurer(ipt *iptables.IPTablesController, nflogGroup uint16, depotDir string, conntrackFlusher kawasaki.ConntrackFlusher) kawasaki.Configurer {
+	panic("not supported on this platform")
+}
+
+func NewDirectConfigurer(depotDir, mode, parentInterface string) kawasaki.Configurer {
+	panic("not supported on this platform")
+}
+
+func NewVXLANConfigurer(ipt *iptables.IPTablesController, nflogGroup uint16, depotDir string, conntrackFlusher kawasaki.ConntrackFlusher, device string, pool kawasaki.NetworkPool) kawasaki.Configurer {
+	panic("not supported on this platform")
+}
+
+func NewDefaultAttacher(ipt *iptables.IPTablesController, firewallOpener kawasaki.FirewallOpener, specs []kawasaki.AttachmentNetworkSpec, idGenerator kawasaki.IDGenerator, interfacePrefix, chainPrefix string, mtu int, nflogGroup uint16, configStore kawasaki.ConfigStore, reserved ...*net.IPNet) (kawasaki.Attacher, error) {
 	panic("not supported on this platform")
 }
@@ -0,0 +1,50 @@
+package kawasaki
+
+import "sync"
+
+// HandleLocks serialises changes to the networking of each container, so that
+// the drift detector does not re-apply rules which are being added or removed
+type HandleLocks struct {
+	mu    sync.Mutex
+	locks map[string]*handleLock
+}
+
+type handleLock struct {
+	sync.Mutex
+	waiters int
+}
+
+func NewHandleLocks() *HandleLocks {
+	return &HandleLocks{locks: map[string]*handleLock{}}
+}
+
+func (l *HandleLocks) Lock(handle string) {
+	l.mu.Lock()
+	lock, ok := l.locks[handle]
+	if !ok {
+		lock = &handleLock{}
+		l.locks[handle] = lock
+	}
+	lock.waiters++
+	l.mu.Unlock()
+
+	lock.Lock()
+}
+
+// Unlock releases the handle's lock, forgetting it once nobody else holds or
+// waits for it
+func (l *HandleLocks) Unlock(handle string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lock, ok := l.locks[handle]
+	if !ok {
+		return
+	}
+
+	lock.waiters--
+	if lock.waiters == 0 {
+		delete(l.locks, handle)
+	}
+	lock.Unlock()
+}
@@ -0,0 +1,51 @@
+package kawasaki_test
+
+import (
+	"code.cloudfoundry.org/guardian/kawasaki"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HandleLocks", func() {
+	var locks *kawasaki.HandleLocks
+
+	BeforeEach(func() {
+		locks = kawasaki.NewHandleLocks()
+	})
+
+	lockInBackground := func(handle string) chan struct{} {
+		locked := make(chan struct{})
+		go func() {
+			locks.Lock(handle)
+			close(locked)
+		}()
+
+		return locked
+	}
+
+	It("blocks until the handle is unlocked", func() {
+		locks.Lock("some-handle")
+
+		locked := lockInBackground("some-handle")
+		Consistently(locked).ShouldNot(BeClosed())
+
+		locks.Unlock("some-handle")
+		Eventually(locked).Should(BeClosed())
+	})
+
+	It("does not block other handles", func() {
+		locks.Lock("some-handle")
+
+		locked := lockInBackground("other-handle")
+		Eventually(locked).Should(BeClosed())
+	})
+
+	It("can lock a handle again once it has been unlocked", func() {
+		locks.Lock("some-handle")
+		locks.Unlock("some-handle")
+
+		locked := lockInBackground("some-handle")
+		Eventually(locked).Should(BeClosed())
+	})
+})
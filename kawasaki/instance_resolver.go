@@ -0,0 +1,93 @@
+package kawasaki
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+)
+
+const instanceRefreshInterval = time.Second
+
+// InstanceResolver finds the container which owns an iptables instance, so
+// that log entries tagged with an instance ID can be attributed to a handle
+type InstanceResolver struct {
+	handles     HandleLister
+	configStore ConfigStore
+	clock       clock.Clock
+
+	mu          sync.Mutex
+	instances   map[string]string
+	refreshedAt time.Time
+}
+
+func NewInstanceResolver(handles HandleLister, configStore ConfigStore, clock clock.Clock) *InstanceResolver {
+	return &InstanceResolver{
+		handles:     handles,
+		configStore: configStore,
+		clock:       clock,
+		instances:   map[string]string{},
+	}
+}
+
+// Handle returns the handle of the container owning the instance. Instances
+// created since the last lookup are found by re-reading the ConfigStore, at
+// most once a second.
+func (r *InstanceResolver) Handle(instanceID string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if handle, ok := r.instances[instanceID]; ok {
+		return handle, true
+	}
+
+	now := r.clock.Now()
+	if !r.refreshedAt.IsZero() && now.Sub(r.refreshedAt) < instanceRefreshInterval {
+		return "", false
+	}
+	r.refreshedAt = now
+
+	if err := r.refresh(); err != nil {
+		return "", false
+	}
+
+	handle, ok := r.instances[instanceID]
+	return handle, ok
+}
+
+func (r *InstanceResolver) refresh() error {
+	handles, err := r.handles.Handles()
+	if err != nil {
+		return err
+	}
+
+	instances := map[string]string{}
+	for _, handle := range handles {
+		if instance, ok := r.configStore.Get(handle, iptableInstanceKey); ok && instance != "" {
+			instances[instance] = handle
+		}
+
+		names, ok := r.configStore.Get(handle, attachmentsKey)
+		if !ok || names == "" {
+			continue
+		}
+
+		for _, name := range strings.Split(names, ",") {
+			cfgJSON, ok := r.configStore.Get(handle, attachmentConfigKeyPrefix+name)
+			if !ok {
+				continue
+			}
+
+			var cfg NetworkConfig
+			if err := json.Unmarshal([]byte(cfgJSON), &cfg); err != nil {
+				continue
+			}
+			instances[cfg.IPTableInstance] = handle
+		}
+	}
+
+	r.instances = instances
+	return nil
+}
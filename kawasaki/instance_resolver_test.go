@@ -0,0 +1,83 @@
+package kawasaki_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/guardian/kawasaki"
+	fakes "code.cloudfoundry.org/guardian/kawasaki/kawasakifakes"
+	"code.cloudfoundry.org/guardian/properties"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("InstanceResolver", func() {
+	var (
+		fakeHandleLister *fakes.FakeHandleLister
+		propertyManager  *properties.Manager
+		clock            *fakeclock.FakeClock
+		resolver         *kawasaki.InstanceResolver
+	)
+
+	BeforeEach(func() {
+		fakeHandleLister = new(fakes.FakeHandleLister)
+		propertyManager = properties.NewManager()
+		clock = fakeclock.NewFakeClock(time.Unix(123, 456))
+
+		propertyManager.Set("some-handle", "kawasaki.iptable-inst", "some-inst")
+		propertyManager.Set("some-handle", "kawasaki.attachments", "some-network")
+		propertyManager.Set("some-handle", "kawasaki.attachment.some-network", `{"IPTableInstance":"attachment-inst"}`)
+		fakeHandleLister.HandlesReturns([]string{"some-handle"}, nil)
+
+		resolver = kawasaki.NewInstanceResolver(fakeHandleLister, propertyManager, clock)
+	})
+
+	It("resolves the instance of a container's primary network", func() {
+		handle, ok := resolver.Handle("some-inst")
+		Expect(ok).To(BeTrue())
+		Expect(handle).To(Equal("some-handle"))
+	})
+
+	It("resolves the instances of a container's attachments", func() {
+		handle, ok := resolver.Handle("attachment-inst")
+		Expect(ok).To(BeTrue())
+		Expect(handle).To(Equal("some-handle"))
+	})
+
+	It("only lists containers again when an instance is unknown", func() {
+		resolver.Handle("some-inst")
+		resolver.Handle("some-inst")
+		Expect(fakeHandleLister.HandlesCallCount()).To(Equal(1))
+	})
+
+	Context("when an instance is unknown", func() {
+		It("does not list containers again for a second", func() {
+			_, ok := resolver.Handle("other-inst")
+			Expect(ok).To(BeFalse())
+
+			propertyManager.Set("other-handle", "kawasaki.iptable-inst", "other-inst")
+			fakeHandleLister.HandlesReturns([]string{"some-handle", "other-handle"}, nil)
+
+			_, ok = resolver.Handle("other-inst")
+			Expect(ok).To(BeFalse())
+
+			clock.Increment(time.Second)
+			handle, ok := resolver.Handle("other-inst")
+			Expect(ok).To(BeTrue())
+			Expect(handle).To(Equal("other-handle"))
+		})
+	})
+
+	Context("when listing containers fails", func() {
+		BeforeEach(func() {
+			fakeHandleLister.HandlesReturns(nil, errors.New("boom"))
+		})
+
+		It("does not resolve the instance", func() {
+			_, ok := resolver.Handle("some-inst")
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,425 @@
+package iptables
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/kawasaki"
+	"code.cloudfoundry.org/lager"
+)
+
+// ChainReconciler compares the live rules of a container's instance chains
+// with the rules kawasaki applied to it, and re-applies any that are missing.
+// The masquerade rule is shared by every container in a subnet and is not
+// checked; a container's own egress SNAT rule is.
+type ChainReconciler struct {
+	iptables       *IPTablesController
+	ruleTranslator RuleTranslator
+	nflogGroup     uint16
+}
+
+func NewChainReconciler(iptables *IPTablesController, ruleTranslator RuleTranslator, nflogGroup uint16) *ChainReconciler {
+	return &ChainReconciler{
+		iptables:       iptables,
+		ruleTranslator: ruleTranslator,
+		nflogGroup:     nflogGroup,
+	}
+}
+
+type expectedChain struct {
+	table, chain string
+	rules        []Rule
+
+	// exclusive chains belong to the container, so any rule we did not
+	// expect is reported
+	exclusive bool
+	repair    func() error
+}
+
+func (r *ChainReconciler) Reconcile(logger lager.Logger, handle string, cfg kawasaki.NetworkConfig, netOut []garden.NetOutRule, portMappings []garden.PortMapping) (kawasaki.ChainDrift, error) {
+	logger = logger.Session("reconcile-instance-chains", lager.Data{"handle": handle, "instance": cfg.IPTableInstance})
+
+	chains, err := r.expectedChains(handle, cfg, netOut, portMappings)
+	if err != nil {
+		return kawasaki.ChainDrift{}, err
+	}
+
+	drift := kawasaki.ChainDrift{}
+	var repairs []func() error
+	for _, c := range chains {
+		missing, unexpected, err := r.compare(c)
+		if err != nil {
+			return kawasaki.ChainDrift{}, err
+		}
+
+		for _, rule := range missing {
+			drift.Missing = append(drift.Missing, fmt.Sprintf("%s %s: %s", c.table, c.chain, rule))
+		}
+
+		for _, rule := range unexpected {
+			drift.Unexpected = append(drift.Unexpected, fmt.Sprintf("%s %s: %s", c.table, c.chain, rule))
+		}
+
+		if len(missing) > 0 {
+			repairs = append(repairs, c.repair)
+		}
+	}
+
+	if len(repairs) == 0 {
+		return drift, nil
+	}
+
+	logger.Info("repairing", lager.Data{"missing": drift.Missing})
+	for _, repair := range repairs {
+		if err := repair(); err != nil {
+			logger.Error("repair-failed", err)
+			return drift, fmt.Errorf("repairing instance chains: %s", err)
+		}
+	}
+	drift.Repaired = true
+
+	return drift, nil
+}
+
+func (r *ChainReconciler) expectedChains(handle string, cfg kawasaki.NetworkConfig, netOut []garden.NetOutRule, portMappings []garden.PortMapping) ([]expectedChain, error) {
+	ipt := r.iptables
+	instanceChain := ipt.InstanceChain(cfg.IPTableInstance)
+	loggingChain := instanceChain + "-log"
+
+	var netOutRules []Rule
+	for _, rule := range netOut {
+		rules, err := r.ruleTranslator.TranslateRule(handle, rule)
+		if err != nil {
+			return nil, err
+		}
+
+		netOutRules = append(netOutRules, rules...)
+	}
+
+	var natRules []Rule
+	for _, m := range portMappings {
+		natRules = append(natRules, dnatFlags(cfg.ExternalIP.String(), m.HostPort, cfg.ContainerIP.String(), m.ContainerPort, handle))
+	}
+
+	rules := newInstanceRules(ipt, r.nflogGroup, handle, cfg.IPTableInstance, cfg.BridgeName, cfg.ContainerIP, cfg.Subnet, cfg.ExternalIP, cfg.EgressIP, cfg.ConnectionLimits)
+
+	// chains are listed in the order they must be repaired, so that every
+	// chain exists before a rule jumps to it
+	chains := []expectedChain{
+		{
+			table: "filter", chain: loggingChain, exclusive: true,
+			rules: rules.logging,
+			repair: func() error {
+				return r.rebuild("filter", loggingChain, rules.logging, nil)
+			},
+		},
+		{
+			table: "filter", chain: instanceChain, exclusive: true,
+			rules: append(append([]Rule{}, netOutRules...), rules.filter...),
+			repair: func() error {
+				// net-out rules are always prepended, so replay them in the
+				// order they were originally applied
+				return r.rebuild("filter", instanceChain, rules.filter, netOutRules)
+			},
+		},
+	}
+
+	if cfg.ConnectionLimits.IsSet() {
+		limit := limitChain(instanceChain)
+		chains = append(chains, expectedChain{
+			table: "filter", chain: limit, exclusive: true,
+			rules: rules.limit,
+			repair: func() error {
+				return r.rebuild("filter", limit, rules.limit, nil)
+			},
+		})
+	}
+
+	chains = append(chains, []expectedChain{
+		{
+			table: "nat", chain: instanceChain, exclusive: true,
+			rules: natRules,
+			repair: func() error {
+				return r.rebuild("nat", instanceChain, natRules, nil)
+			},
+		},
+		{
+			table: "filter", chain: ipt.forwardChain,
+			rules: []Rule{rules.forward},
+			repair: func() error {
+				payload := &restorePayload{}
+				payload.table("filter").insertRule(ipt.forwardChain, 2, rules.forward)
+				return ipt.restore("repair-forward-chain", payload)
+			},
+		},
+		{
+			table: "nat", chain: ipt.preroutingChain,
+			rules: []Rule{rules.prerouting},
+			repair: func() error {
+				payload := &restorePayload{}
+				payload.table("nat").appendRule(ipt.preroutingChain, rules.prerouting)
+				return ipt.restore("repair-prerouting-chain", payload)
+			},
+		},
+	}...)
+
+	if cfg.EgressIP != nil {
+		snat := snatChain(instanceChain)
+		chains = append(chains,
+			expectedChain{
+				table: "nat", chain: snat, exclusive: true,
+				rules: rules.snat,
+				repair: func() error {
+					return r.rebuild("nat", snat, rules.snat, nil)
+				},
+			},
+			expectedChain{
+				table: "nat", chain: ipt.postroutingChain,
+				rules: []Rule{rules.postrouting},
+				repair: func() error {
+					payload := &restorePayload{}
+					payload.table("nat").insertRule(ipt.postroutingChain, 1, rules.postrouting)
+					return ipt.restore("repair-postrouting-chain", payload)
+				},
+			},
+		)
+	}
+
+	return chains, nil
+}
+
+// compare returns the expected rules which are not present in the chain, and,
+// for exclusive chains, the present rules which were not expected
+func (r *ChainReconciler) compare(c expectedChain) ([]string, []string, error) {
+	live, exists, err := r.iptables.listRules(c.table, c.chain)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !exists {
+		missing := []string{fmt.Sprintf("chain %s does not exist", c.chain)}
+		for _, rule := range c.rules {
+			missing = append(missing, strings.Join(rule.Flags(c.chain), " "))
+		}
+		return missing, nil, nil
+	}
+
+	remaining := map[string]int{}
+	for _, line := range live {
+		remaining[canonicalRule(splitRule(line))]++
+	}
+
+	var missing []string
+	for _, rule := range c.rules {
+		flags := rule.Flags(c.chain)
+		key := canonicalRule(flags)
+		if remaining[key] > 0 {
+			remaining[key]--
+			continue
+		}
+
+		missing = append(missing, strings.Join(flags, " "))
+	}
+
+	var unexpected []string
+	if c.exclusive {
+		for _, line := range live {
+			key := canonicalRule(splitRule(line))
+			if remaining[key] > 0 {
+				remaining[key]--
+				unexpected = append(unexpected, line)
+			}
+		}
+	}
+
+	return missing, unexpected, nil
+}
+
+// rebuild recreates the chain if necessary, and replaces its contents with
+// the given rules, preceded by the prepended rules
+func (r *ChainReconciler) rebuild(table, chain string, rules, prepended []Rule) error {
+	payload := &restorePayload{}
+	t := payload.table(table)
+	t.declareChain(chain)
+	for _, rule := range rules {
+		t.appendRule(chain, rule)
+	}
+	for _, rule := range prepended {
+		t.insertRule(chain, 1, rule)
+	}
+
+	return r.iptables.restore("repair-instance-chain", payload)
+}
+
+func logPrefix(handle string) string {
+	if len(handle) > 28 {
+		handle = handle[0:28]
+	}
+
+	return handle + " "
+}
+
+var shortFlags = map[string]string{
+	"--source":           "-s",
+	"--destination":      "-d",
+	"--protocol":         "-p",
+	"--jump":             "-j",
+	"--goto":             "-g",
+	"--in-interface":     "-i",
+	"--out-interface":    "-o",
+	"--match":            "-m",
+	"--table":            "-t",
+	"--destination-port": "--dport",
+	"--source-port":      "--sport",
+}
+
+const defaultHashlimitBurst = "5"
+
+// matches which iptables adds implicitly when a protocol is given
+var implicitMatches = map[string]bool{"tcp": true, "udp": true, "icmp": true}
+
+// options which iptables always prints before any match, wherever they were
+// given
+var baseOptions = map[string]bool{"-s": true, "-d": true, "-p": true, "-i": true, "-o": true, "-f": true}
+
+// canonicalRule reduces a rule to a form which is the same whether it came
+// from the flags we passed to iptables or from the output of "iptables -S",
+// which abbreviates flags, adds implicit matches, moves the base options to
+// the front and the target to the end, and may reorder the options of each.
+// The order of the matches themselves is significant and is kept.
+func canonicalRule(args []string) string {
+	var base []string
+	var matches [][]string
+	var target []string
+	current := &base
+	negate := false
+
+	for i := 0; i < len(args); {
+		opt := args[i]
+		i++
+
+		if opt == "!" {
+			negate = true
+			continue
+		}
+
+		if short, ok := shortFlags[opt]; ok {
+			opt = short
+		}
+
+		var values []string
+		for i < len(args) && !isOption(args[i]) {
+			values = append(values, args[i])
+			i++
+		}
+
+		switch opt {
+		case "-t", "-w", "--wait":
+			continue
+		case "-m":
+			if len(values) == 1 && implicitMatches[values[0]] {
+				// the options of an implicit match stay wherever they were
+				// given, as iptables loads it on the first of them
+				continue
+			}
+		case "-p":
+			if len(values) == 1 && values[0] == "all" {
+				continue
+			}
+		case "-s", "-d":
+			for j, v := range values {
+				values[j] = strings.TrimSuffix(v, "/32")
+			}
+		case "--hashlimit-burst":
+			// "iptables -S" omits the default burst
+			if len(values) == 1 && values[0] == defaultHashlimitBurst {
+				continue
+			}
+		case "--ctstate":
+			for j, v := range values {
+				states := strings.Split(v, ",")
+				sort.Strings(states)
+				values[j] = strings.Join(states, ",")
+			}
+		}
+
+		option := strings.Join(append([]string{opt}, values...), " ")
+		if negate {
+			option = "! " + option
+			negate = false
+		}
+
+		switch {
+		case baseOptions[opt]:
+			base = append(base, option)
+		case opt == "-m":
+			matches = append(matches, []string{option})
+			current = &matches[len(matches)-1]
+		case opt == "-j" || opt == "-g":
+			target = append(target, option)
+			current = &target
+		default:
+			*current = append(*current, option)
+		}
+	}
+
+	sort.Strings(base)
+	options := base
+	for _, match := range append(matches, target) {
+		if len(match) == 0 {
+			continue
+		}
+
+		// the match or target itself comes first, then its own options
+		sort.Strings(match[1:])
+		options = append(options, match...)
+	}
+
+	return strings.Join(options, " ")
+}
+
+func isOption(arg string) bool {
+	return arg == "!" || (len(arg) > 1 && strings.HasPrefix(arg, "-"))
+}
+
+// splitRule splits a line of "iptables -S" output into arguments, honouring
+// the double quotes iptables puts around arguments containing spaces
+func splitRule(line string) []string {
+	var (
+		args    []string
+		current []rune
+		quoted  bool
+		started bool
+		escaped bool
+	)
+
+	for _, c := range line {
+		switch {
+		case escaped:
+			current = append(current, c)
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+			started = true
+		case c == ' ' && !quoted:
+			if started {
+				args = append(args, string(current))
+			}
+			current = current[:0]
+			started = false
+		default:
+			current = append(current, c)
+			started = true
+		}
+	}
+
+	if started {
+		args = append(args, string(current))
+	}
+
+	return args
+}
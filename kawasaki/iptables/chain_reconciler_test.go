@@ -0,0 +1,377 @@
+package iptables_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os/exec"
+
+	"code.cloudfoundry.org/commandrunner/fake_command_runner"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/kawasaki"
+	"code.cloudfoundry.org/guardian/kawasaki/iptables"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ChainReconciler", func() {
+	var (
+		fakeRunner   *fake_command_runner.FakeCommandRunner
+		reconciler   *iptables.ChainReconciler
+		logger       lager.Logger
+		cfg          kawasaki.NetworkConfig
+		netOut       []garden.NetOutRule
+		portMappings []garden.PortMapping
+		liveRules    map[string]string
+		restored     []string
+	)
+
+	BeforeEach(func() {
+		fakeRunner = fake_command_runner.New()
+		logger = lagertest.NewTestLogger("test")
+
+		restored = nil
+		fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: "/sbin/iptables-restore",
+		}, func(cmd *exec.Cmd) error {
+			payload, err := ioutil.ReadAll(cmd.Stdin)
+			Expect(err).NotTo(HaveOccurred())
+			restored = append(restored, string(payload))
+			return nil
+		})
+
+		_, subnet, err := net.ParseCIDR("10.0.0.0/30")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg = kawasaki.NetworkConfig{
+			IPTableInstance: "some-id",
+			BridgeName:      "some-bridge",
+			ContainerIP:     net.ParseIP("10.0.0.2"),
+			ExternalIP:      net.ParseIP("1.2.3.4"),
+			Subnet:          subnet,
+		}
+
+		netOut = []garden.NetOutRule{{
+			Protocol: garden.ProtocolTCP,
+			Networks: []garden.IPRange{{Start: net.ParseIP("8.8.8.8")}},
+			Ports:    []garden.PortRange{garden.PortRangeFromPort(53)},
+		}}
+		portMappings = []garden.PortMapping{{HostPort: 60000, ContainerPort: 8080}}
+
+		liveRules = map[string]string{
+			"filter prefix-instance-some-id-log": `-N prefix-instance-some-id-log
+-A prefix-instance-some-id-log -m conntrack --ctstate NEW,INVALID,UNTRACKED -m comment --comment some-handle -j LOG --log-prefix "some-handle "
+-A prefix-instance-some-id-log -m comment --comment some-handle -j RETURN
+`,
+			"filter prefix-instance-some-id": `-N prefix-instance-some-id
+-A prefix-instance-some-id -d 8.8.8.8/32 -p tcp -m tcp --dport 53 -m comment --comment some-handle -j RETURN
+-A prefix-instance-some-id -s 10.0.0.0/30 -d 10.0.0.0/30 -m comment --comment some-handle -j ACCEPT
+-A prefix-instance-some-id -m conntrack --ctstate DNAT --ctorigdst 1.2.3.4 -m comment --comment some-handle -j ACCEPT
+-A prefix-instance-some-id -m comment --comment some-handle -g prefix-default
+`,
+			"nat prefix-instance-some-id": `-N prefix-instance-some-id
+-A prefix-instance-some-id -d 1.2.3.4/32 -p tcp -m tcp --dport 60000 -m comment --comment some-handle -j DNAT --to-destination 10.0.0.2:8080
+`,
+			"filter prefix-forward": `-N prefix-forward
+-A prefix-forward -i w+ -j ACCEPT
+-A prefix-forward -i some-bridge -s 10.0.0.2/32 -m comment --comment some-handle -g prefix-instance-some-id
+-A prefix-forward -i other-bridge -s 10.0.1.2/32 -m comment --comment other-handle -g prefix-instance-other-id
+`,
+			"nat prefix-prerouting": `-N prefix-prerouting
+-A prefix-prerouting -m comment --comment some-handle -j prefix-instance-some-id
+`,
+		}
+
+		reconciler = iptables.NewChainReconciler(
+			iptables.New("/sbin/iptables", "/sbin/iptables-restore", fakeRunner, NewFakeLocksmith(), "prefix-"),
+			iptables.NewRuleTranslator(),
+			0,
+		)
+	})
+
+	JustBeforeEach(func() {
+		for key, rules := range liveRules {
+			table, chain := splitKey(key)
+			output := rules
+			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+				Path: "/sbin/iptables",
+				Args: []string{"--wait", "--table", table, "-S", chain},
+			}, func(cmd *exec.Cmd) error {
+				if output == "" {
+					cmd.Stderr.Write([]byte("iptables: No chain/target/match by that name.\n"))
+					return errors.New("exit status 1")
+				}
+
+				cmd.Stdout.Write([]byte(output))
+				return nil
+			})
+		}
+	})
+
+	Context("when the live rules match the recorded rules", func() {
+		It("reports no drift", func() {
+			drift, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift.Drifted()).To(BeFalse())
+			Expect(drift.Repaired).To(BeFalse())
+		})
+
+		It("does not change any rules", func() {
+			_, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeRunner.ExecutedCommands()).To(HaveLen(5))
+		})
+	})
+
+	Context("when a net-out rule is missing", func() {
+		BeforeEach(func() {
+			liveRules["filter prefix-instance-some-id"] = `-N prefix-instance-some-id
+-A prefix-instance-some-id -s 10.0.0.0/30 -d 10.0.0.0/30 -m comment --comment some-handle -j ACCEPT
+-A prefix-instance-some-id -m conntrack --ctstate DNAT --ctorigdst 1.2.3.4 -m comment --comment some-handle -j ACCEPT
+-A prefix-instance-some-id -m comment --comment some-handle -g prefix-default
+`
+		})
+
+		It("reports the missing rule", func() {
+			drift, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift.Missing).To(ConsistOf(
+				"filter prefix-instance-some-id: --protocol tcp --destination 8.8.8.8 --destination-port 53 --jump RETURN -m comment --comment some-handle",
+			))
+			Expect(drift.Repaired).To(BeTrue())
+		})
+
+		It("rebuilds the instance chain, replaying the net-out rules", func() {
+			_, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(restored).To(ConsistOf(`*filter
+:prefix-instance-some-id - [0:0]
+-A prefix-instance-some-id -s 10.0.0.0/30 -d 10.0.0.0/30 -j ACCEPT -m comment --comment some-handle
+-A prefix-instance-some-id -m conntrack --ctstate DNAT --ctorigdst 1.2.3.4 -j ACCEPT -m comment --comment some-handle
+-A prefix-instance-some-id --goto prefix-default -m comment --comment some-handle
+-I prefix-instance-some-id 1 --protocol tcp --destination 8.8.8.8 --destination-port 53 --jump RETURN -m comment --comment some-handle
+COMMIT
+`))
+		})
+	})
+
+	Context("when the instance chain predates connections to mapped ports being allowed", func() {
+		BeforeEach(func() {
+			liveRules["filter prefix-instance-some-id"] = `-N prefix-instance-some-id
+-A prefix-instance-some-id -d 8.8.8.8/32 -p tcp -m tcp --dport 53 -m comment --comment some-handle -j RETURN
+-A prefix-instance-some-id -s 10.0.0.0/30 -d 10.0.0.0/30 -m comment --comment some-handle -j ACCEPT
+-A prefix-instance-some-id -m comment --comment some-handle -g prefix-default
+`
+		})
+
+		It("reports the missing rule so that it is added", func() {
+			drift, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift.Missing).To(ConsistOf(
+				"filter prefix-instance-some-id: -m conntrack --ctstate DNAT --ctorigdst 1.2.3.4 -j ACCEPT -m comment --comment some-handle",
+			))
+			Expect(drift.Repaired).To(BeTrue())
+		})
+	})
+
+	Context("when the nat instance chain has been deleted", func() {
+		BeforeEach(func() {
+			liveRules["nat prefix-instance-some-id"] = ""
+		})
+
+		It("reports the missing chain and its rules", func() {
+			drift, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift.Missing).To(ConsistOf(
+				"nat prefix-instance-some-id: chain prefix-instance-some-id does not exist",
+				"nat prefix-instance-some-id: --protocol tcp --destination 1.2.3.4 --destination-port 60000 --jump DNAT --to-destination 10.0.0.2:8080 -m comment --comment some-handle",
+			))
+		})
+
+		It("recreates the chain and its port forwarding rules", func() {
+			_, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(restored).To(ConsistOf(`*nat
+:prefix-instance-some-id - [0:0]
+-A prefix-instance-some-id --protocol tcp --destination 1.2.3.4 --destination-port 60000 --jump DNAT --to-destination 10.0.0.2:8080 -m comment --comment some-handle
+COMMIT
+`))
+		})
+	})
+
+	Context("when the forward chain no longer references the instance chain", func() {
+		BeforeEach(func() {
+			liveRules["filter prefix-forward"] = `-N prefix-forward
+-A prefix-forward -i w+ -j ACCEPT
+`
+		})
+
+		It("restores the reference", func() {
+			drift, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift.Missing).To(HaveLen(1))
+
+			Expect(restored).To(ConsistOf(`*filter
+-I prefix-forward 2 --in-interface some-bridge --source 10.0.0.2 --goto prefix-instance-some-id -m comment --comment some-handle
+COMMIT
+`))
+		})
+	})
+
+	Context("when the container has an egress IP", func() {
+		BeforeEach(func() {
+			cfg.EgressIP = net.ParseIP("5.6.7.8")
+			liveRules["nat prefix-instance-some-id-snat"] = `-N prefix-instance-some-id-snat
+-A prefix-instance-some-id-snat -s 10.0.0.2/32 ! -d 10.0.0.0/30 -m comment --comment some-handle -j SNAT --to-source 5.6.7.8
+`
+			liveRules["nat prefix-postrouting"] = `-N prefix-postrouting
+-A prefix-postrouting -m comment --comment some-handle -j prefix-instance-some-id-snat
+-A prefix-postrouting -s 10.0.0.0/30 ! -d 10.0.0.0/30 -m comment --comment some-handle -j MASQUERADE
+`
+		})
+
+		It("checks the SNAT chain and its reference", func() {
+			drift, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift.Drifted()).To(BeFalse())
+			Expect(fakeRunner.ExecutedCommands()).To(HaveLen(7))
+		})
+
+		Context("when the postrouting chain no longer references the SNAT chain", func() {
+			BeforeEach(func() {
+				liveRules["nat prefix-postrouting"] = `-N prefix-postrouting
+-A prefix-postrouting -s 10.0.0.0/30 ! -d 10.0.0.0/30 -m comment --comment some-handle -j MASQUERADE
+`
+			})
+
+			It("restores the reference ahead of the masquerade rule", func() {
+				drift, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(drift.Missing).To(HaveLen(1))
+
+				Expect(restored).To(ConsistOf(`*nat
+-I prefix-postrouting 1 --jump prefix-instance-some-id-snat -m comment --comment some-handle
+COMMIT
+`))
+			})
+		})
+	})
+
+	Context("when the container has connection limits", func() {
+		BeforeEach(func() {
+			cfg.ConnectionLimits = kawasaki.ConnectionLimits{MaxConnections: 100, Rate: 5}
+			liveRules["filter prefix-instance-some-id-lim"] = `-N prefix-instance-some-id-lim
+-A prefix-instance-some-id-lim -m conntrack --ctstate NEW -m connlimit --connlimit-above 100 --connlimit-mask 32 --connlimit-saddr -m comment --comment some-handle -j REJECT --reject-with icmp-port-unreachable
+-A prefix-instance-some-id-lim -m conntrack --ctstate NEW -m hashlimit --hashlimit-above 5/sec --hashlimit-mode srcip --hashlimit-name some-id -m comment --comment some-handle -j REJECT --reject-with icmp-port-unreachable
+-A prefix-instance-some-id-lim -m comment --comment some-handle -g prefix-instance-some-id
+`
+			liveRules["filter prefix-forward"] = `-N prefix-forward
+-A prefix-forward -i w+ -j ACCEPT
+-A prefix-forward -i some-bridge -s 10.0.0.2/32 -m comment --comment some-handle -g prefix-instance-some-id-lim
+`
+		})
+
+		It("checks the limit chain and the forward chain's reference to it", func() {
+			drift, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift.Drifted()).To(BeFalse())
+			Expect(fakeRunner.ExecutedCommands()).To(HaveLen(6))
+		})
+
+		Context("when a limit has been removed", func() {
+			BeforeEach(func() {
+				liveRules["filter prefix-instance-some-id-lim"] = `-N prefix-instance-some-id-lim
+-A prefix-instance-some-id-lim -m comment --comment some-handle -g prefix-instance-some-id
+`
+			})
+
+			It("rebuilds the limit chain", func() {
+				drift, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(drift.Missing).To(HaveLen(2))
+
+				Expect(restored).To(ConsistOf(`*filter
+:prefix-instance-some-id-lim - [0:0]
+-A prefix-instance-some-id-lim -m conntrack --ctstate NEW -m connlimit --connlimit-above 100 --connlimit-mask 32 --connlimit-saddr --jump REJECT --reject-with icmp-port-unreachable -m comment --comment some-handle
+-A prefix-instance-some-id-lim -m conntrack --ctstate NEW -m hashlimit --hashlimit-above 5/sec --hashlimit-burst 5 --hashlimit-mode srcip --hashlimit-name some-id --jump REJECT --reject-with icmp-port-unreachable -m comment --comment some-handle
+-A prefix-instance-some-id-lim --goto prefix-instance-some-id -m comment --comment some-handle
+COMMIT
+`))
+			})
+		})
+	})
+
+	Context("when an instance chain contains a rule which was never applied", func() {
+		BeforeEach(func() {
+			liveRules["filter prefix-instance-some-id"] = `-N prefix-instance-some-id
+-A prefix-instance-some-id -j ACCEPT
+-A prefix-instance-some-id -d 8.8.8.8/32 -p tcp -m tcp --dport 53 -m comment --comment some-handle -j RETURN
+-A prefix-instance-some-id -s 10.0.0.0/30 -d 10.0.0.0/30 -m comment --comment some-handle -j ACCEPT
+-A prefix-instance-some-id -m conntrack --ctstate DNAT --ctorigdst 1.2.3.4 -m comment --comment some-handle -j ACCEPT
+-A prefix-instance-some-id -m comment --comment some-handle -g prefix-default
+`
+		})
+
+		It("reports the unexpected rule without removing it", func() {
+			drift, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift.Unexpected).To(ConsistOf("filter prefix-instance-some-id: -j ACCEPT"))
+			Expect(drift.Missing).To(BeEmpty())
+			Expect(drift.Repaired).To(BeFalse())
+		})
+	})
+
+	Context("when the options of a live rule are in a different match", func() {
+		BeforeEach(func() {
+			liveRules["filter prefix-instance-some-id"] = `-N prefix-instance-some-id
+-A prefix-instance-some-id -d 8.8.8.8/32 -p tcp -m tcp --dport 53 -m comment --comment some-handle -j RETURN
+-A prefix-instance-some-id -s 10.0.0.0/30 -d 10.0.0.0/30 -m comment --comment some-handle -j ACCEPT
+-A prefix-instance-some-id -m conntrack --ctstate DNAT -m comment --comment some-handle --ctorigdst 1.2.3.4 -j ACCEPT
+-A prefix-instance-some-id -m comment --comment some-handle -g prefix-default
+`
+		})
+
+		It("reports the rule as drifted", func() {
+			drift, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift.Missing).To(ConsistOf(
+				"filter prefix-instance-some-id: -m conntrack --ctstate DNAT --ctorigdst 1.2.3.4 -j ACCEPT -m comment --comment some-handle",
+			))
+			Expect(drift.Unexpected).To(ConsistOf(
+				"filter prefix-instance-some-id: -m conntrack --ctstate DNAT -m comment --comment some-handle --ctorigdst 1.2.3.4 -j ACCEPT",
+			))
+		})
+	})
+
+	Context("when listing a chain fails", func() {
+		BeforeEach(func() {
+			delete(liveRules, "nat prefix-prerouting")
+			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+				Path: "/sbin/iptables",
+				Args: []string{"--wait", "--table", "nat", "-S", "prefix-prerouting"},
+			}, func(cmd *exec.Cmd) error {
+				cmd.Stderr.Write([]byte("permission denied"))
+				return errors.New("exit status 4")
+			})
+		})
+
+		It("returns the error", func() {
+			_, err := reconciler.Reconcile(logger, "some-handle", cfg, netOut, portMappings)
+			Expect(err).To(MatchError(ContainSubstring("permission denied")))
+		})
+	})
+})
+
+func splitKey(key string) (string, string) {
+	for i, c := range key {
+		if c == ' ' {
+			return key[:i], key[i+1:]
+		}
+	}
+
+	return key, ""
+}
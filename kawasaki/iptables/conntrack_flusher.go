@@ -0,0 +1,48 @@
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"code.cloudfoundry.org/commandrunner"
+	"code.cloudfoundry.org/lager"
+)
+
+// conntrack exits non-zero when there was nothing to delete
+const noConntrackEntries = "0 flow entries have been deleted"
+
+type ConntrackFlusher struct {
+	conntrackBinPath string
+	runner           commandrunner.CommandRunner
+}
+
+func NewConntrackFlusher(conntrackBinPath string, runner commandrunner.CommandRunner) *ConntrackFlusher {
+	return &ConntrackFlusher{
+		conntrackBinPath: conntrackBinPath,
+		runner:           runner,
+	}
+}
+
+// Flush deletes the connection tracking entries of connections from an IP, and
+// of connections forwarded to it, which are only matched by their replies
+func (f *ConntrackFlusher) Flush(logger lager.Logger, ip net.IP) error {
+	logger = logger.Session("flush-conntrack", lager.Data{"ip": ip.String()})
+	logger.Info("started")
+	defer logger.Info("finished")
+
+	for _, direction := range []string{"--orig-src", "--reply-src"} {
+		var output bytes.Buffer
+		cmd := exec.Command(f.conntrackBinPath, "--delete", direction, ip.String())
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+
+		if err := f.runner.Run(cmd); err != nil && !strings.Contains(output.String(), noConntrackEntries) {
+			return fmt.Errorf("conntrack: delete %s %s: %s", direction, ip, strings.TrimSpace(output.String()))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package iptables_test
+
+import (
+	"errors"
+	"net"
+	"os/exec"
+
+	"code.cloudfoundry.org/commandrunner/fake_command_runner"
+	"code.cloudfoundry.org/guardian/kawasaki/iptables"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "code.cloudfoundry.org/commandrunner/fake_command_runner/matchers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConntrackFlusher", func() {
+	var (
+		fakeRunner *fake_command_runner.FakeCommandRunner
+		flusher    *iptables.ConntrackFlusher
+		logger     lager.Logger
+	)
+
+	BeforeEach(func() {
+		fakeRunner = fake_command_runner.New()
+		logger = lagertest.NewTestLogger("test")
+		flusher = iptables.NewConntrackFlusher("/sbin/conntrack", fakeRunner)
+	})
+
+	It("deletes the entries of connections from and to the IP", func() {
+		Expect(flusher.Flush(logger, net.ParseIP("10.0.0.2"))).To(Succeed())
+
+		Expect(fakeRunner).To(HaveExecutedSerially(
+			fake_command_runner.CommandSpec{
+				Path: "/sbin/conntrack",
+				Args: []string{"--delete", "--orig-src", "10.0.0.2"},
+			},
+			fake_command_runner.CommandSpec{
+				Path: "/sbin/conntrack",
+				Args: []string{"--delete", "--reply-src", "10.0.0.2"},
+			},
+		))
+	})
+
+	Context("when there are no entries to delete", func() {
+		BeforeEach(func() {
+			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+				Path: "/sbin/conntrack",
+			}, func(cmd *exec.Cmd) error {
+				cmd.Stderr.Write([]byte("conntrack v1.4.4 (conntrack-tools): 0 flow entries have been deleted.\n"))
+				return errors.New("exit status 1")
+			})
+		})
+
+		It("succeeds", func() {
+			Expect(flusher.Flush(logger, net.ParseIP("10.0.0.2"))).To(Succeed())
+		})
+	})
+
+	Context("when conntrack fails", func() {
+		BeforeEach(func() {
+			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+				Path: "/sbin/conntrack",
+			}, func(cmd *exec.Cmd) error {
+				cmd.Stderr.Write([]byte("conntrack v1.4.4 (conntrack-tools): Operation failed: not supported\n"))
+				return errors.New("exit status 1")
+			})
+		})
+
+		It("returns an error including its output", func() {
+			Expect(flusher.Flush(logger, net.ParseIP("10.0.0.2"))).To(MatchError(
+				"conntrack: delete --orig-src 10.0.0.2: conntrack v1.4.4 (conntrack-tools): Operation failed: not supported",
+			))
+		})
+	})
+})
@@ -3,139 +3,334 @@ package iptables
 import (
 	"fmt"
 	"net"
-	"os/exec"
+	"strconv"
 
+	"code.cloudfoundry.org/guardian/kawasaki"
 	"code.cloudfoundry.org/lager"
 )
 
 type InstanceChainCreator struct {
-	iptables *IPTablesController
+	iptables   *IPTablesController
+	nflogGroup uint16
 }
 
-func NewInstanceChainCreator(iptables *IPTablesController) *InstanceChainCreator {
+// NewInstanceChainCreator returns an InstanceChainCreator. Connections
+// matched by logged net-out rules are sent to the given NFLOG group, or to
+// the kernel log if the group is 0.
+func NewInstanceChainCreator(iptables *IPTablesController, nflogGroup uint16) *InstanceChainCreator {
 	return &InstanceChainCreator{
-		iptables: iptables,
+		iptables:   iptables,
+		nflogGroup: nflogGroup,
 	}
 }
 
-func (cc *InstanceChainCreator) Create(logger lager.Logger, handle, instanceId, bridgeName string, ip net.IP, network *net.IPNet) error {
-	instanceChain := cc.iptables.InstanceChain(instanceId)
+// instanceRules are the rules which bind a container's instance chains to
+// the global chains, and make up the instance chains before any net-in or
+// net-out rules are added
+type instanceRules struct {
+	prerouting Rule
+	forward    Rule
+	filter     []Rule
+	logging    []Rule
 
-	if err := cc.iptables.CreateChain("nat", instanceChain); err != nil {
-		return err
+	// postrouting and snat are only set for containers with an egress IP
+	postrouting Rule
+	snat        []Rule
+
+	// limit is only set for containers with connection limits, in which
+	// case the forward rule goes to the limit chain rather than the instance
+	// chain
+	limit []Rule
+}
+
+func newInstanceRules(ipt *IPTablesController, nflogGroup uint16, handle, instanceId, bridgeName string, ip net.IP, network *net.IPNet, externalIP, egressIP net.IP, limits kawasaki.ConnectionLimits) instanceRules {
+	instanceChain := ipt.InstanceChain(instanceId)
+
+	// The kernel log prefix is limited to 29 characters, so NFLOG records
+	// carry the instance id, which the egress logger resolves to the handle
+	logTarget := []string{"--jump", "LOG", "--log-prefix", logPrefix(handle)}
+	if nflogGroup != 0 {
+		logTarget = []string{"--jump", "NFLOG", "--nflog-group", strconv.Itoa(int(nflogGroup)), "--nflog-prefix", instanceId}
 	}
 
-	// Bind nat instance chain to nat prerouting chain
-	cmd := exec.Command(cc.iptables.iptablesBinPath, "--wait", "--table", "nat", "-A", cc.iptables.preroutingChain, "--jump", instanceChain, "-m", "comment", "--comment", handle)
-	if err := cc.iptables.run("create-instance-chains", cmd); err != nil {
-		return err
+	rules := instanceRules{
+		prerouting: iptablesFlags{"--jump", instanceChain, "-m", "comment", "--comment", handle},
+		forward:    iptablesFlags{"--in-interface", bridgeName, "--source", ip.String(), "--goto", instanceChain, "-m", "comment", "--comment", handle},
+		filter: []Rule{
+			// Allow intra-subnet traffic (Linux ethernet bridging goes through ip stack)
+			iptablesFlags{"-s", network.String(), "-d", network.String(), "-j", "ACCEPT", "-m", "comment", "--comment", handle},
+		},
+		logging: []Rule{
+			append(append(iptablesFlags{"-m", "conntrack", "--ctstate", "NEW,UNTRACKED,INVALID", "--protocol", "all"}, logTarget...), "-m", "comment", "--comment", handle),
+			iptablesFlags{"--jump", "RETURN", "-m", "comment", "--comment", handle},
+		},
 	}
 
-	// Enable NAT for traffic coming from containers
-	cmd = exec.Command("sh", "-c", fmt.Sprintf(
-		`(%s --wait --table nat -S %s | grep "\-j MASQUERADE\b" | grep -q -F -- "-s %s") || %s --wait --table nat -A %s --source %s ! --destination %s --jump MASQUERADE -m comment --comment %s`,
-		cc.iptables.iptablesBinPath, cc.iptables.postroutingChain, network.String(), cc.iptables.iptablesBinPath, cc.iptables.postroutingChain,
-		network.String(), network.String(), handle,
-	))
-	if err := cc.iptables.run("create-instance-chains", cmd); err != nil {
-		return err
+	// Allow connections to mapped ports on the external IP, which are
+	// forwarded to this or another container on the host, even when the
+	// default chain denies container networks
+	if externalIP != nil {
+		rules.filter = append(rules.filter, iptablesFlags{"-m", "conntrack", "--ctstate", "DNAT", "--ctorigdst", externalIP.String(), "-j", "ACCEPT", "-m", "comment", "--comment", handle})
 	}
 
-	// Create filter instance chain
-	if err := cc.iptables.CreateChain("filter", instanceChain); err != nil {
-		return err
+	// Otherwise, use the default filter chain
+	rules.filter = append(rules.filter, iptablesFlags{"--goto", ipt.defaultChain, "-m", "comment", "--comment", handle})
+
+	if egressIP != nil {
+		rules.postrouting = iptablesFlags{"--jump", snatChain(instanceChain), "-m", "comment", "--comment", handle}
+		rules.snat = []Rule{
+			iptablesFlags{"--source", ip.String(), "!", "--destination", network.String(), "--jump", "SNAT", "--to-source", egressIP.String(), "-m", "comment", "--comment", handle},
+		}
 	}
 
-	// Allow intra-subnet traffic (Linux ethernet bridging goes through ip stack)
-	cmd = exec.Command(cc.iptables.iptablesBinPath, "--wait", "-A", instanceChain, "-s", network.String(), "-d", network.String(), "-j", "ACCEPT", "-m", "comment", "--comment", handle)
-	if err := cc.iptables.run("create-instance-chains", cmd); err != nil {
-		return err
+	if limits.IsSet() {
+		rules.forward = iptablesFlags{"--in-interface", bridgeName, "--source", ip.String(), "--goto", limitChain(instanceChain), "-m", "comment", "--comment", handle}
+		rules.limit = append(limitRules(handle, instanceId, limits), iptablesFlags{"--goto", instanceChain, "-m", "comment", "--comment", handle})
 	}
 
-	// Otherwise, use the default filter chain
-	cmd = exec.Command(cc.iptables.iptablesBinPath, "--wait", "-A", instanceChain, "--goto", cc.iptables.defaultChain, "-m", "comment", "--comment", handle)
-	if err := cc.iptables.run("create-instance-chains", cmd); err != nil {
-		return err
+	return rules
+}
+
+// limitRules reject new connections once a container has too many open, or
+// is opening them too quickly. Replies to connections into the container are
+// never new, so are not limited.
+func limitRules(handle, instanceId string, limits kawasaki.ConnectionLimits) []Rule {
+	var rules []Rule
+	if limits.MaxConnections != 0 {
+		rules = append(rules, iptablesFlags{
+			"-m", "conntrack", "--ctstate", "NEW",
+			"-m", "connlimit", "--connlimit-above", strconv.Itoa(limits.MaxConnections), "--connlimit-mask", "32", "--connlimit-saddr",
+			"--jump", "REJECT", "--reject-with", "icmp-port-unreachable",
+			"-m", "comment", "--comment", handle,
+		})
 	}
 
-	// Bind filter instance chain to filter forward chain
-	cmd = exec.Command(cc.iptables.iptablesBinPath, "--wait", "-I", cc.iptables.forwardChain, "2", "--in-interface", bridgeName, "--source", ip.String(), "--goto", instanceChain, "-m", "comment", "--comment", handle)
-	if err := cc.iptables.run("create-instance-chains", cmd); err != nil {
-		return err
+	if limits.Rate != 0 {
+		rate := strconv.Itoa(limits.Rate)
+		rules = append(rules, iptablesFlags{
+			"-m", "conntrack", "--ctstate", "NEW",
+			"-m", "hashlimit", "--hashlimit-above", rate + "/sec", "--hashlimit-burst", rate, "--hashlimit-mode", "srcip", "--hashlimit-name", instanceId,
+			"--jump", "REJECT", "--reject-with", "icmp-port-unreachable",
+			"-m", "comment", "--comment", handle,
+		})
 	}
 
-	// Create Logging Chain
-	return cc.createLoggingChain(logger, handle, instanceId)
+	return rules
+}
+
+func snatChain(instanceChain string) string {
+	return instanceChain + "-snat"
+}
+
+func limitChain(instanceChain string) string {
+	return instanceChain + "-lim"
 }
 
-func (cc *InstanceChainCreator) createLoggingChain(logger lager.Logger, handle, instanceId string) error {
+// Create sets up the nat, filter and logging chains of a container, and binds
+// them to the global chains, in a single iptables-restore transaction. If an
+// egress IP is given, traffic leaving the host from the container is source
+// NATed to it instead of being masqueraded. If connection limits are given,
+// traffic from the container passes through a limit chain before reaching
+// its instance chain. If an external IP is given, the container may connect
+// to ports mapped on it, including its own.
+func (cc *InstanceChainCreator) Create(logger lager.Logger, handle, instanceId, bridgeName string, ip net.IP, network *net.IPNet, externalIP, egressIP net.IP, limits kawasaki.ConnectionLimits) error {
 	instanceChain := cc.iptables.InstanceChain(instanceId)
 	loggingChain := fmt.Sprintf("%s-log", instanceChain)
+	rules := newInstanceRules(cc.iptables, cc.nflogGroup, handle, instanceId, bridgeName, ip, network, externalIP, egressIP, limits)
 
-	if err := cc.iptables.CreateChain("filter", loggingChain); err != nil {
+	masqueraded, hairpinned, err := cc.subnetNAT(network)
+	if err != nil {
 		return err
 	}
 
-	logPrefix := handle
-	if len(logPrefix) > 28 {
-		logPrefix = logPrefix[0:28]
+	payload := &restorePayload{}
+
+	nat := payload.table("nat")
+	nat.declareChain(instanceChain)
+	nat.appendRule(cc.iptables.preroutingChain, rules.prerouting)
+
+	// Enable NAT for traffic coming from containers, unless another container
+	// in the subnet already has
+	if !masqueraded {
+		nat.appendRule(cc.iptables.postroutingChain, iptablesFlags{"--source", network.String(), "!", "--destination", network.String(), "--jump", "MASQUERADE", "-m", "comment", "--comment", handle})
 	}
-	logPrefix = logPrefix + " "
 
-	cmd := exec.Command(cc.iptables.iptablesBinPath, "--wait", "-A", loggingChain, "-m", "conntrack", "--ctstate", "NEW,UNTRACKED,INVALID", "--protocol", "all", "--jump", "LOG", "--log-prefix", logPrefix, "-m", "comment", "--comment", handle)
-	if err := cc.iptables.run("create-instance-chains", cmd); err != nil {
-		return err
+	// A container connecting to a port mapped to itself or a neighbour in its
+	// subnet would be answered directly over the bridge, from an address it
+	// did not connect to, so masquerade such connections as the bridge
+	if !hairpinned {
+		nat.appendRule(cc.iptables.postroutingChain, hairpinFlags(network, handle))
 	}
 
-	cmd = exec.Command(cc.iptables.iptablesBinPath, "--wait", "-A", loggingChain, "--jump", "RETURN", "-m", "comment", "--comment", handle)
-	if err := cc.iptables.run("create-instance-chains", cmd); err != nil {
-		return err
+	// SNAT ahead of the subnet's masquerade rule
+	if egressIP != nil {
+		nat.declareChain(snatChain(instanceChain))
+		for _, rule := range rules.snat {
+			nat.appendRule(snatChain(instanceChain), rule)
+		}
+		nat.insertRule(cc.iptables.postroutingChain, 1, rules.postrouting)
+	}
+
+	filter := payload.table("filter")
+	filter.declareChain(instanceChain)
+	filter.declareChain(loggingChain)
+	for _, rule := range rules.filter {
+		filter.appendRule(instanceChain, rule)
+	}
+	if limits.IsSet() {
+		filter.declareChain(limitChain(instanceChain))
+		for _, rule := range rules.limit {
+			filter.appendRule(limitChain(instanceChain), rule)
+		}
+	}
+	filter.insertRule(cc.iptables.forwardChain, 2, rules.forward)
+	for _, rule := range rules.logging {
+		filter.appendRule(loggingChain, rule)
+	}
+
+	return cc.iptables.restore("create-instance-chains", payload)
+}
+
+func hairpinFlags(network *net.IPNet, handle string) iptablesFlags {
+	return iptablesFlags{"--source", network.String(), "--destination", network.String(), "-m", "conntrack", "--ctstate", "DNAT", "--jump", "MASQUERADE", "-m", "comment", "--comment", handle}
+}
+
+// subnetNAT returns whether traffic from a subnet is already masqueraded on
+// leaving it, and on being forwarded back into it
+func (cc *InstanceChainCreator) subnetNAT(network *net.IPNet) (masqueraded, hairpinned bool, err error) {
+	rules, _, err := cc.iptables.listRules("nat", cc.iptables.postroutingChain)
+	if err != nil {
+		return false, false, err
 	}
 
-	return nil
+	for _, rule := range rules {
+		args := splitRule(rule)
+		if !hasArgs(args, "-j", "MASQUERADE") || !hasArgs(args, "-s", network.String()) {
+			continue
+		}
+
+		if hasArgs(args, "--ctstate", "DNAT") {
+			hairpinned = true
+		} else {
+			masqueraded = true
+		}
+	}
+
+	return masqueraded, hairpinned, nil
 }
 
+// Destroy removes the chains of a container, and any references to them from
+// the global chains, in a single iptables-restore transaction. It succeeds if
+// the chains have already been removed.
 func (cc *InstanceChainCreator) Destroy(logger lager.Logger, instanceId string) error {
 	instanceChain := cc.iptables.InstanceChain(instanceId)
+	loggingChain := fmt.Sprintf("%s-log", instanceChain)
 
-	// Prune nat prerouting chain
-	cmd := exec.Command("sh", "-c", fmt.Sprintf(
-		`%s --wait --table nat -S %s 2> /dev/null | grep "\-j %s\b" | sed -e "s/-A/-D/" | xargs --no-run-if-empty --max-lines=1 %s --wait --table nat`,
-		cc.iptables.iptablesBinPath, cc.iptables.preroutingChain, instanceChain, cc.iptables.iptablesBinPath,
-	))
-	if err := cc.iptables.run("prune-prerouting-chain", cmd); err != nil {
+	preroutingRefs, err := cc.references("nat", cc.iptables.preroutingChain, "-j", instanceChain)
+	if err != nil {
 		return err
 	}
 
-	// Flush instance chain
-	if err := cc.iptables.FlushChain("nat", instanceChain); err != nil {
+	postroutingRefs, err := cc.references("nat", cc.iptables.postroutingChain, "-j", snatChain(instanceChain))
+	if err != nil {
 		return err
 	}
 
-	// Delete nat instance chain
-	if err := cc.iptables.DeleteChain("nat", instanceChain); err != nil {
+	forwardRefs, err := cc.references("filter", cc.iptables.forwardChain, "-g", instanceChain, limitChain(instanceChain))
+	if err != nil {
 		return err
 	}
 
-	// Prune forward chain
-	cmd = exec.Command("sh", "-c", fmt.Sprintf(
-		`%s --wait -S %s 2> /dev/null | grep "\-g %s\b" | sed -e "s/-A/-D/" | xargs --no-run-if-empty --max-lines=1 %s --wait`,
-		cc.iptables.iptablesBinPath, cc.iptables.forwardChain, instanceChain, cc.iptables.iptablesBinPath,
-	))
-	if err := cc.iptables.run("prune-forward-chain", cmd); err != nil {
+	limited := false
+	for _, ref := range forwardRefs {
+		limited = limited || hasArgs(splitRule(ref), "-g", limitChain(instanceChain))
+	}
+
+	payload := &restorePayload{}
+
+	nat := payload.table("nat")
+	for _, ref := range preroutingRefs {
+		nat.deleteListedRule(cc.iptables.preroutingChain, ref)
+	}
+	for _, ref := range postroutingRefs {
+		nat.deleteListedRule(cc.iptables.postroutingChain, ref)
+	}
+	nat.declareChain(instanceChain)
+	nat.deleteChain(instanceChain)
+	if len(postroutingRefs) > 0 {
+		nat.declareChain(snatChain(instanceChain))
+		nat.deleteChain(snatChain(instanceChain))
+	}
+
+	filter := payload.table("filter")
+	for _, ref := range forwardRefs {
+		filter.deleteListedRule(cc.iptables.forwardChain, ref)
+	}
+	if limited {
+		// the limit chain goes to the instance chain, so must go first
+		filter.declareChain(limitChain(instanceChain))
+		filter.deleteChain(limitChain(instanceChain))
+	}
+	filter.declareChain(instanceChain)
+	filter.declareChain(loggingChain)
+	filter.deleteChain(instanceChain)
+	filter.deleteChain(loggingChain)
+
+	return cc.iptables.restore("destroy-instance-chains", payload)
+}
+
+// DestroySubnet removes the rules masquerading traffic from a subnet and
+// hairpin connections within it, which Create adds for the first container
+// in it. It is called once the last container in the subnet has been
+// destroyed.
+func (cc *InstanceChainCreator) DestroySubnet(logger lager.Logger, network *net.IPNet) error {
+	rules, _, err := cc.iptables.listRules("nat", cc.iptables.postroutingChain)
+	if err != nil {
 		return err
 	}
 
-	// Flush instance chain
-	cc.iptables.FlushChain("filter", instanceChain)
+	payload := &restorePayload{}
 
-	// delete instance chain
-	cc.iptables.DeleteChain("filter", instanceChain)
+	nat := payload.table("nat")
+	for _, rule := range rules {
+		args := splitRule(rule)
+		if !hasArgs(args, "-j", "MASQUERADE") || !hasArgs(args, "-s", network.String()) {
+			continue
+		}
+
+		nat.deleteListedRule(cc.iptables.postroutingChain, rule)
+	}
+
+	return cc.iptables.restore("destroy-subnet-rules", payload)
+}
 
-	// delete the logging chain
-	instanceLoggingChain := fmt.Sprintf("%s-log", instanceChain)
-	cc.iptables.FlushChain("filter", instanceLoggingChain)
-	cc.iptables.DeleteChain("filter", instanceLoggingChain)
+// references returns the rules in chain which jump or go to any of targets
+func (cc *InstanceChainCreator) references(table, chain, flag string, targets ...string) ([]string, error) {
+	rules, _, err := cc.iptables.listRules(table, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	for _, rule := range rules {
+		args := splitRule(rule)
+		for _, target := range targets {
+			if hasArgs(args, flag, target) {
+				refs = append(refs, rule)
+				break
+			}
+		}
+	}
+
+	return refs, nil
+}
+
+func hasArgs(args []string, flag, value string) bool {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == flag && args[i+1] == value {
+			return true
+		}
+	}
 
-	return nil
+	return false
 }
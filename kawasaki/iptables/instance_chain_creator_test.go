@@ -3,17 +3,18 @@ package iptables_test
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os/exec"
 
 	"code.cloudfoundry.org/commandrunner/fake_command_runner"
+	"code.cloudfoundry.org/guardian/kawasaki"
 	"code.cloudfoundry.org/guardian/kawasaki/iptables"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 
 	. "code.cloudfoundry.org/commandrunner/fake_command_runner/matchers"
 	. "github.com/onsi/ginkgo"
-	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
@@ -24,10 +25,27 @@ var _ = Describe("Create", func() {
 		bridgeName string
 		ip         net.IP
 		network    *net.IPNet
+		externalIP net.IP
 		logger     lager.Logger
 		handle     string
+		restored   []string
+		restoreErr error
 	)
 
+	listRulesSpec := func(table, chain string) fake_command_runner.CommandSpec {
+		return fake_command_runner.CommandSpec{
+			Path: "/sbin/iptables",
+			Args: []string{"--wait", "--table", table, "-S", chain},
+		}
+	}
+
+	whenListing := func(table, chain, output string) {
+		fakeRunner.WhenRunning(listRulesSpec(table, chain), func(cmd *exec.Cmd) error {
+			cmd.Stdout.Write([]byte(output))
+			return nil
+		})
+	}
+
 	BeforeEach(func() {
 		var err error
 
@@ -38,169 +56,352 @@ var _ = Describe("Create", func() {
 		bridgeName = "some-bridge"
 		ip, network, err = net.ParseCIDR("1.2.3.4/28")
 		Expect(err).NotTo(HaveOccurred())
+		externalIP = net.ParseIP("9.8.7.6")
+
+		restored = nil
+		restoreErr = nil
+		fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: "/sbin/iptables-restore",
+			Args: []string{"--noflush"},
+		}, func(cmd *exec.Cmd) error {
+			payload, err := ioutil.ReadAll(cmd.Stdin)
+			Expect(err).NotTo(HaveOccurred())
+			restored = append(restored, string(payload))
+
+			if restoreErr != nil {
+				cmd.Stderr.Write([]byte("iptables failed"))
+			}
+			return restoreErr
+		})
 
 		fakeLocksmith := NewFakeLocksmith()
 		creator = iptables.NewInstanceChainCreator(
 			iptables.New("/sbin/iptables", "/sbin/iptables-restore", fakeRunner, fakeLocksmith, "prefix-"),
+			0,
 		)
 	})
 
 	Describe("Container Creation", func() {
-		var specs []fake_command_runner.CommandSpec
+		It("sets up the chains in a single transaction", func() {
+			Expect(creator.Create(logger, handle, "some-id", bridgeName, ip, network, externalIP, nil, kawasaki.ConnectionLimits{})).To(Succeed())
 
-		BeforeEach(func() {
-			specs = []fake_command_runner.CommandSpec{
-				{
-					Path: "/sbin/iptables",
-					Args: []string{"--wait", "--table", "nat", "-N", "prefix-instance-some-id"},
-				},
-				{
-					Path: "/sbin/iptables",
-					Args: []string{"--wait", "--table", "nat", "-A", "prefix-prerouting",
-						"--jump", "prefix-instance-some-id",
-						"-m", "comment", "--comment", handle},
-				},
-				{
-					Path: "sh",
-					Args: []string{"-c", fmt.Sprintf(
-						`(/sbin/iptables --wait --table nat -S %s | grep "\-j MASQUERADE\b" | grep -q -F -- "-s %s") || /sbin/iptables --wait --table nat -A %s --source %s ! --destination %s --jump MASQUERADE -m comment --comment %s`,
-						"prefix-postrouting", network.String(), "prefix-postrouting",
-						network.String(), network.String(), handle,
-					)},
-				},
-				{
-					Path: "/sbin/iptables",
-					Args: []string{"--wait", "--table", "filter", "-N", "prefix-instance-some-id"},
-				},
-				{
-					Path: "/sbin/iptables",
-					Args: []string{"--wait", "-A", "prefix-instance-some-id",
-						"-s", network.String(), "-d", network.String(), "-j", "ACCEPT",
-						"-m", "comment", "--comment", handle,
-					},
-				},
-				{
-					Path: "/sbin/iptables",
-					Args: []string{"--wait", "-A", "prefix-instance-some-id",
-						"--goto", "prefix-default",
-						"-m", "comment", "--comment", handle,
-					},
-				},
-				{
-					Path: "/sbin/iptables",
-					Args: []string{"--wait", "-I", "prefix-forward", "2", "--in-interface", bridgeName,
-						"--source", ip.String(), "--goto", "prefix-instance-some-id",
-						"-m", "comment", "--comment", handle,
-					},
-				},
-				{
-					Path: "/sbin/iptables",
-					Args: []string{"--wait", "--table", "filter", "-N", "prefix-instance-some-id-log"},
-				},
-				{
-					Path: "/sbin/iptables",
-					Args: []string{"--wait", "-A", "prefix-instance-some-id-log", "-m", "conntrack", "--ctstate", "NEW,UNTRACKED,INVALID",
-						"--protocol", "all", "--jump", "LOG", "--log-prefix", "some-handle-that-is-longer-t ",
-						"-m", "comment", "--comment", handle,
-					},
-				},
-				{
-					Path: "/sbin/iptables",
-					Args: []string{"--wait", "-A", "prefix-instance-some-id-log", "--jump", "RETURN",
-						"-m", "comment", "--comment", handle,
-					},
-				},
-			}
+			Expect(restored).To(Equal([]string{fmt.Sprintf(`*nat
+:prefix-instance-some-id - [0:0]
+-A prefix-prerouting --jump prefix-instance-some-id -m comment --comment %[1]s
+-A prefix-postrouting --source 1.2.3.0/28 ! --destination 1.2.3.0/28 --jump MASQUERADE -m comment --comment %[1]s
+-A prefix-postrouting --source 1.2.3.0/28 --destination 1.2.3.0/28 -m conntrack --ctstate DNAT --jump MASQUERADE -m comment --comment %[1]s
+COMMIT
+*filter
+:prefix-instance-some-id - [0:0]
+:prefix-instance-some-id-log - [0:0]
+-A prefix-instance-some-id -s 1.2.3.0/28 -d 1.2.3.0/28 -j ACCEPT -m comment --comment %[1]s
+-A prefix-instance-some-id -m conntrack --ctstate DNAT --ctorigdst 9.8.7.6 -j ACCEPT -m comment --comment %[1]s
+-A prefix-instance-some-id --goto prefix-default -m comment --comment %[1]s
+-I prefix-forward 2 --in-interface some-bridge --source 1.2.3.4 --goto prefix-instance-some-id -m comment --comment %[1]s
+-A prefix-instance-some-id-log -m conntrack --ctstate NEW,UNTRACKED,INVALID --protocol all --jump LOG --log-prefix "some-handle-that-is-longer-t " -m comment --comment %[1]s
+-A prefix-instance-some-id-log --jump RETURN -m comment --comment %[1]s
+COMMIT
+`, handle)}))
+		})
+
+		Context("when logged connections are sent to an NFLOG group", func() {
+			BeforeEach(func() {
+				creator = iptables.NewInstanceChainCreator(
+					iptables.New("/sbin/iptables", "/sbin/iptables-restore", fakeRunner, NewFakeLocksmith(), "prefix-"),
+					5,
+				)
+			})
+
+			It("logs new connections to the group, prefixed with the instance id", func() {
+				Expect(creator.Create(logger, handle, "some-id", bridgeName, ip, network, externalIP, nil, kawasaki.ConnectionLimits{})).To(Succeed())
+
+				Expect(restored).To(HaveLen(1))
+				Expect(restored[0]).To(ContainSubstring(
+					"-A prefix-instance-some-id-log -m conntrack --ctstate NEW,UNTRACKED,INVALID --protocol all --jump NFLOG --nflog-group 5 --nflog-prefix some-id -m comment --comment " + handle + "\n",
+				))
+				Expect(restored[0]).NotTo(ContainSubstring("--log-prefix"))
+			})
+		})
+
+		Context("when the container has an egress IP", func() {
+			It("source NATs its traffic to the egress IP ahead of the subnet's masquerade rule", func() {
+				Expect(creator.Create(logger, handle, "some-id", bridgeName, ip, network, externalIP, net.ParseIP("5.6.7.8"), kawasaki.ConnectionLimits{})).To(Succeed())
+
+				Expect(restored).To(HaveLen(1))
+				Expect(restored[0]).To(HavePrefix(fmt.Sprintf(`*nat
+:prefix-instance-some-id - [0:0]
+-A prefix-prerouting --jump prefix-instance-some-id -m comment --comment %[1]s
+-A prefix-postrouting --source 1.2.3.0/28 ! --destination 1.2.3.0/28 --jump MASQUERADE -m comment --comment %[1]s
+-A prefix-postrouting --source 1.2.3.0/28 --destination 1.2.3.0/28 -m conntrack --ctstate DNAT --jump MASQUERADE -m comment --comment %[1]s
+:prefix-instance-some-id-snat - [0:0]
+-A prefix-instance-some-id-snat --source 1.2.3.4 ! --destination 1.2.3.0/28 --jump SNAT --to-source 5.6.7.8 -m comment --comment %[1]s
+-I prefix-postrouting 1 --jump prefix-instance-some-id-snat -m comment --comment %[1]s
+COMMIT
+`, handle)))
+			})
+		})
+
+		Context("when the container has connection limits", func() {
+			It("sends its traffic through a limit chain on the way to the instance chain", func() {
+				limits := kawasaki.ConnectionLimits{MaxConnections: 100, Rate: 10}
+				Expect(creator.Create(logger, handle, "some-id", bridgeName, ip, network, externalIP, nil, limits)).To(Succeed())
+
+				Expect(restored).To(HaveLen(1))
+				Expect(restored[0]).To(HaveSuffix(fmt.Sprintf(`*filter
+:prefix-instance-some-id - [0:0]
+:prefix-instance-some-id-log - [0:0]
+-A prefix-instance-some-id -s 1.2.3.0/28 -d 1.2.3.0/28 -j ACCEPT -m comment --comment %[1]s
+-A prefix-instance-some-id -m conntrack --ctstate DNAT --ctorigdst 9.8.7.6 -j ACCEPT -m comment --comment %[1]s
+-A prefix-instance-some-id --goto prefix-default -m comment --comment %[1]s
+:prefix-instance-some-id-lim - [0:0]
+-A prefix-instance-some-id-lim -m conntrack --ctstate NEW -m connlimit --connlimit-above 100 --connlimit-mask 32 --connlimit-saddr --jump REJECT --reject-with icmp-port-unreachable -m comment --comment %[1]s
+-A prefix-instance-some-id-lim -m conntrack --ctstate NEW -m hashlimit --hashlimit-above 10/sec --hashlimit-burst 10 --hashlimit-mode srcip --hashlimit-name some-id --jump REJECT --reject-with icmp-port-unreachable -m comment --comment %[1]s
+-A prefix-instance-some-id-lim --goto prefix-instance-some-id -m comment --comment %[1]s
+-I prefix-forward 2 --in-interface some-bridge --source 1.2.3.4 --goto prefix-instance-some-id-lim -m comment --comment %[1]s
+-A prefix-instance-some-id-log -m conntrack --ctstate NEW,UNTRACKED,INVALID --protocol all --jump LOG --log-prefix "some-handle-that-is-longer-t " -m comment --comment %[1]s
+-A prefix-instance-some-id-log --jump RETURN -m comment --comment %[1]s
+COMMIT
+`, handle)))
+			})
+
+			It("only limits the rate when there is no connection limit", func() {
+				Expect(creator.Create(logger, handle, "some-id", bridgeName, ip, network, externalIP, nil, kawasaki.ConnectionLimits{Rate: 10})).To(Succeed())
+
+				Expect(restored).To(HaveLen(1))
+				Expect(restored[0]).To(ContainSubstring("hashlimit"))
+				Expect(restored[0]).NotTo(ContainSubstring("connlimit"))
+			})
+		})
+
+		Context("when traffic from the subnet is already masqueraded", func() {
+			BeforeEach(func() {
+				whenListing("nat", "prefix-postrouting", `-N prefix-postrouting
+-A prefix-postrouting -s 1.2.3.0/28 ! -d 1.2.3.0/28 -m comment --comment other-handle -j MASQUERADE
+-A prefix-postrouting -s 1.2.3.0/28 -d 1.2.3.0/28 -m conntrack --ctstate DNAT -m comment --comment other-handle -j MASQUERADE
+`)
+			})
+
+			It("does not masquerade it again", func() {
+				Expect(creator.Create(logger, handle, "some-id", bridgeName, ip, network, externalIP, nil, kawasaki.ConnectionLimits{})).To(Succeed())
+
+				Expect(restored).To(HaveLen(1))
+				Expect(restored[0]).NotTo(ContainSubstring("MASQUERADE"))
+			})
+		})
+
+		Context("when the subnet was masqueraded before hairpin connections were", func() {
+			BeforeEach(func() {
+				whenListing("nat", "prefix-postrouting", `-N prefix-postrouting
+-A prefix-postrouting -s 1.2.3.0/28 ! -d 1.2.3.0/28 -m comment --comment other-handle -j MASQUERADE
+`)
+			})
+
+			It("only masquerades hairpin connections", func() {
+				Expect(creator.Create(logger, handle, "some-id", bridgeName, ip, network, externalIP, nil, kawasaki.ConnectionLimits{})).To(Succeed())
+
+				Expect(restored).To(HaveLen(1))
+				Expect(restored[0]).To(ContainSubstring(fmt.Sprintf(
+					"-A prefix-postrouting --source 1.2.3.0/28 --destination 1.2.3.0/28 -m conntrack --ctstate DNAT --jump MASQUERADE -m comment --comment %s\n", handle,
+				)))
+				Expect(restored[0]).NotTo(ContainSubstring("! --destination"))
+			})
 		})
 
-		It("should set up the chain", func() {
-			Expect(creator.Create(logger, handle, "some-id", bridgeName, ip, network)).To(Succeed())
-			Expect(fakeRunner).To(HaveExecutedSerially(specs...))
+		Context("when there is no external IP", func() {
+			It("does not allow connections to mapped ports", func() {
+				Expect(creator.Create(logger, handle, "some-id", bridgeName, ip, network, nil, nil, kawasaki.ConnectionLimits{})).To(Succeed())
+
+				Expect(restored).To(HaveLen(1))
+				Expect(restored[0]).NotTo(ContainSubstring("--ctorigdst"))
+			})
 		})
 
-		DescribeTable("iptables failures",
-			func(specIndex int, errorString string) {
-				fakeRunner.WhenRunning(specs[specIndex], func(cmd *exec.Cmd) error {
+		Context("when listing the postrouting chain fails", func() {
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(listRulesSpec("nat", "prefix-postrouting"), func(cmd *exec.Cmd) error {
 					cmd.Stderr.Write([]byte("iptables failed"))
-					return errors.New("Exit status blah")
+					return errors.New("exit status 4")
 				})
+			})
 
-				Expect(creator.Create(logger, handle, "some-id", bridgeName, ip, network)).To(MatchError(errorString))
-			},
-			Entry("create nat instance chain", 0, "iptables: create-instance-chains: iptables failed"),
-			Entry("bind nat instance chain to nat prerouting chain", 1, "iptables: create-instance-chains: iptables failed"),
-			Entry("enable NAT for traffic coming from containers", 2, "iptables: create-instance-chains: iptables failed"),
-			Entry("create logging instance chain", 7, "iptables: create-instance-chains: iptables failed"),
-			Entry("append logging to instance chain", 8, "iptables: create-instance-chains: iptables failed"),
-			Entry("return from logging instance chain", 9, "iptables: create-instance-chains: iptables failed"),
-		)
+			It("returns an error without changing any rules", func() {
+				Expect(creator.Create(logger, handle, "some-id", bridgeName, ip, network, externalIP, nil, kawasaki.ConnectionLimits{})).To(MatchError("iptables: list-rules: iptables failed"))
+				Expect(restored).To(BeEmpty())
+			})
+		})
+
+		Context("when the transaction fails", func() {
+			BeforeEach(func() {
+				restoreErr = errors.New("exit status 1")
+			})
+
+			It("returns an error", func() {
+				Expect(creator.Create(logger, handle, "some-id", bridgeName, ip, network, externalIP, nil, kawasaki.ConnectionLimits{})).To(MatchError("iptables: create-instance-chains: iptables failed"))
+			})
+		})
 	})
 
 	Describe("ContainerTeardown", func() {
-		var specs []fake_command_runner.CommandSpec
+		var forwardRules string
 
 		BeforeEach(func() {
-			specs = []fake_command_runner.CommandSpec{
-				{
-					Path: "sh",
-					Args: []string{"-c", fmt.Sprintf(
-						`/sbin/iptables --wait --table nat -S %s 2> /dev/null | grep "\-j %s\b" | sed -e "s/-A/-D/" | xargs --no-run-if-empty --max-lines=1 /sbin/iptables --wait --table nat`,
-						"prefix-prerouting", "prefix-instance-some-id",
-					)},
-				},
-				{
-					Path: "sh",
-					Args: []string{"-c", fmt.Sprintf(
-						`/sbin/iptables --wait --table nat -F %s 2> /dev/null || true`,
-						"prefix-instance-some-id",
-					)},
-				},
-				{
-					Path: "sh",
-					Args: []string{"-c", fmt.Sprintf(
-						`/sbin/iptables --wait --table nat -X %s 2> /dev/null || true`,
-						"prefix-instance-some-id",
-					)},
-				},
-				{
-					Path: "sh",
-					Args: []string{"-c", fmt.Sprintf(
-						`/sbin/iptables --wait -S %s 2> /dev/null | grep "\-g %s\b" | sed -e "s/-A/-D/" | xargs --no-run-if-empty --max-lines=1 /sbin/iptables --wait`,
-						"prefix-forward", "prefix-instance-some-id",
-					)},
-				},
-				{
-					Path: "sh",
-					Args: []string{"-c", fmt.Sprintf("/sbin/iptables --wait --table filter -F %s 2> /dev/null || true", "prefix-instance-some-id")},
-				},
-				{
-					Path: "sh",
-					Args: []string{"-c", fmt.Sprintf("/sbin/iptables --wait --table filter -X %s 2> /dev/null || true", "prefix-instance-some-id")},
-				},
-				{
-					Path: "sh",
-					Args: []string{"-c", fmt.Sprintf("/sbin/iptables --wait --table filter -F %s 2> /dev/null || true", "prefix-instance-some-id-log")},
-				},
-				{
-					Path: "sh",
-					Args: []string{"-c", fmt.Sprintf("/sbin/iptables --wait --table filter -X %s 2> /dev/null || true", "prefix-instance-some-id-log")},
-				},
-			}
+			whenListing("nat", "prefix-prerouting", `-N prefix-prerouting
+-A prefix-prerouting -m comment --comment some-handle -j prefix-instance-some-id
+-A prefix-prerouting -m comment --comment other-handle -j prefix-instance-other-id
+`)
+			forwardRules = `-N prefix-forward
+-A prefix-forward -i some-bridge -s 1.2.3.4/32 -m comment --comment some-handle -g prefix-instance-some-id
+-A prefix-forward -i some-bridge -s 1.2.3.5/32 -m comment --comment other-handle -g prefix-instance-other-id
+`
 		})
 
-		It("should tear down the chain", func() {
+		JustBeforeEach(func() {
+			whenListing("filter", "prefix-forward", forwardRules)
+		})
+
+		It("tears down the chains and their references in a single transaction", func() {
 			Expect(creator.Destroy(logger, "some-id")).To(Succeed())
-			Expect(fakeRunner).To(HaveExecutedSerially(specs...))
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				listRulesSpec("nat", "prefix-prerouting"),
+				listRulesSpec("nat", "prefix-postrouting"),
+				listRulesSpec("filter", "prefix-forward"),
+			))
+
+			Expect(restored).To(Equal([]string{`*nat
+-D prefix-prerouting -m comment --comment some-handle -j prefix-instance-some-id
+:prefix-instance-some-id - [0:0]
+-X prefix-instance-some-id
+COMMIT
+*filter
+-D prefix-forward -i some-bridge -s 1.2.3.4/32 -m comment --comment some-handle -g prefix-instance-some-id
+:prefix-instance-some-id - [0:0]
+:prefix-instance-some-id-log - [0:0]
+-X prefix-instance-some-id
+-X prefix-instance-some-id-log
+COMMIT
+`}))
+		})
+
+		Context("when the container has an egress IP", func() {
+			BeforeEach(func() {
+				whenListing("nat", "prefix-postrouting", `-N prefix-postrouting
+-A prefix-postrouting -m comment --comment some-handle -j prefix-instance-some-id-snat
+-A prefix-postrouting -s 1.2.3.0/28 ! -d 1.2.3.0/28 -m comment --comment some-handle -j MASQUERADE
+`)
+			})
+
+			It("also tears down the SNAT chain and its reference", func() {
+				Expect(creator.Destroy(logger, "some-id")).To(Succeed())
+
+				Expect(restored).To(HaveLen(1))
+				Expect(restored[0]).To(HavePrefix(`*nat
+-D prefix-prerouting -m comment --comment some-handle -j prefix-instance-some-id
+-D prefix-postrouting -m comment --comment some-handle -j prefix-instance-some-id-snat
+:prefix-instance-some-id - [0:0]
+-X prefix-instance-some-id
+:prefix-instance-some-id-snat - [0:0]
+-X prefix-instance-some-id-snat
+COMMIT
+`))
+			})
+		})
+
+		Context("when the container has connection limits", func() {
+			BeforeEach(func() {
+				forwardRules = `-N prefix-forward
+-A prefix-forward -i some-bridge -s 1.2.3.4/32 -m comment --comment some-handle -g prefix-instance-some-id-lim
+-A prefix-forward -i some-bridge -s 1.2.3.5/32 -m comment --comment other-handle -g prefix-instance-other-id
+`
+			})
+
+			It("also tears down the limit chain and its reference, ahead of the instance chain", func() {
+				Expect(creator.Destroy(logger, "some-id")).To(Succeed())
+
+				Expect(restored).To(HaveLen(1))
+				Expect(restored[0]).To(HaveSuffix(`*filter
+-D prefix-forward -i some-bridge -s 1.2.3.4/32 -m comment --comment some-handle -g prefix-instance-some-id-lim
+:prefix-instance-some-id-lim - [0:0]
+-X prefix-instance-some-id-lim
+:prefix-instance-some-id - [0:0]
+:prefix-instance-some-id-log - [0:0]
+-X prefix-instance-some-id
+-X prefix-instance-some-id-log
+COMMIT
+`))
+			})
 		})
 
 		Describe("iptables failure", func() {
+			BeforeEach(func() {
+				restoreErr = errors.New("exit status 1")
+			})
+
 			It("returns an error", func() {
-				fakeRunner.WhenRunning(specs[0], func(cmd *exec.Cmd) error {
+				Expect(creator.Destroy(logger, "some-id")).To(MatchError("iptables: destroy-instance-chains: iptables failed"))
+			})
+		})
+	})
+
+	Describe("SubnetTeardown", func() {
+		var postroutingRules string
+
+		BeforeEach(func() {
+			postroutingRules = `-N prefix-postrouting
+-A prefix-postrouting -m comment --comment other-handle -j prefix-instance-other-id-snat
+-A prefix-postrouting -s 1.2.3.0/28 ! -d 1.2.3.0/28 -m comment --comment some-handle -j MASQUERADE
+-A prefix-postrouting -s 1.2.3.0/28 -d 1.2.3.0/28 -m conntrack --ctstate DNAT -m comment --comment some-handle -j MASQUERADE
+-A prefix-postrouting -s 1.2.3.16/28 ! -d 1.2.3.16/28 -m comment --comment other-handle -j MASQUERADE
+-A prefix-postrouting -s 1.2.3.16/28 -d 1.2.3.16/28 -m conntrack --ctstate DNAT -m comment --comment other-handle -j MASQUERADE
+`
+		})
+
+		JustBeforeEach(func() {
+			whenListing("nat", "prefix-postrouting", postroutingRules)
+		})
+
+		It("removes the subnet's masquerade and hairpin rules", func() {
+			Expect(creator.DestroySubnet(logger, network)).To(Succeed())
+
+			Expect(restored).To(Equal([]string{`*nat
+-D prefix-postrouting -s 1.2.3.0/28 ! -d 1.2.3.0/28 -m comment --comment some-handle -j MASQUERADE
+-D prefix-postrouting -s 1.2.3.0/28 -d 1.2.3.0/28 -m conntrack --ctstate DNAT -m comment --comment some-handle -j MASQUERADE
+COMMIT
+`}))
+		})
+
+		Context("when the subnet's rules have already been removed", func() {
+			BeforeEach(func() {
+				postroutingRules = `-N prefix-postrouting
+`
+			})
+
+			It("does not run a transaction", func() {
+				Expect(creator.DestroySubnet(logger, network)).To(Succeed())
+				Expect(restored).To(BeEmpty())
+			})
+		})
+
+		Context("when listing the postrouting chain fails", func() {
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(listRulesSpec("nat", "prefix-postrouting"), func(cmd *exec.Cmd) error {
 					cmd.Stderr.Write([]byte("iptables failed"))
-					return errors.New("exit status foo")
+					return errors.New("exit status 4")
 				})
+			})
 
-				Expect(creator.Destroy(logger, "some-id")).To(MatchError("iptables: prune-prerouting-chain: iptables failed"))
+			It("returns an error", func() {
+				Expect(creator.DestroySubnet(logger, network)).To(MatchError("iptables: list-rules: iptables failed"))
+			})
+		})
+
+		Context("when the transaction fails", func() {
+			BeforeEach(func() {
+				restoreErr = errors.New("exit status 1")
+			})
+
+			It("returns an error", func() {
+				Expect(creator.DestroySubnet(logger, network)).To(MatchError("iptables: destroy-subnet-rules: iptables failed"))
 			})
 		})
 	})
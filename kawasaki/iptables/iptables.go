@@ -39,6 +39,7 @@ type IPTablesController struct {
 	iptablesBinPath                                                                                string
 	iptablesRestoreBinPath                                                                         string
 	preroutingChain, postroutingChain, inputChain, forwardChain, defaultChain, instanceChainPrefix string
+	poolChainPrefix                                                                                string
 }
 
 type Chains struct {
@@ -58,6 +59,7 @@ func New(iptablesBinPath, iptablesRestoreBinPath string, runner commandrunner.Co
 		forwardChain:        chainPrefix + "forward",
 		defaultChain:        chainPrefix + "default",
 		instanceChainPrefix: chainPrefix + "instance-",
+		poolChainPrefix:     chainPrefix + "pool-",
 	}
 }
 
@@ -94,34 +96,59 @@ func (iptables *IPTablesController) PrependRule(chain string, rule Rule) error {
 }
 
 func (iptables *IPTablesController) BulkPrependRules(chain string, rules []Rule) error {
-	if len(rules) == 0 {
-		return nil
-	}
-
-	in := bytes.NewBuffer([]byte{})
-	in.WriteString("*filter\n")
+	payload := &restorePayload{}
+	filter := payload.table("filter")
 	for _, r := range rules {
-		in.WriteString(fmt.Sprintf("-I %s 1 ", chain))
-		in.WriteString(strings.Join(r.Flags(chain), " "))
-		in.WriteString("\n")
+		filter.insertRule(chain, 1, r)
 	}
-	in.WriteString("COMMIT\n")
 
-	cmd := exec.Command(iptables.iptablesRestoreBinPath, "--noflush")
-	cmd.Stdin = in
-
-	return iptables.run("bulk-prepend-rules", cmd)
+	return iptables.restore("bulk-prepend-rules", payload)
 }
 
 func (iptables *IPTablesController) InstanceChain(instanceId string) string {
 	return iptables.instanceChainPrefix + instanceId
 }
 
-func (iptables *IPTablesController) run(action string, cmd *exec.Cmd) (err error) {
+func (iptables *IPTablesController) PoolChain(poolName string) string {
+	return iptables.poolChainPrefix + poolName
+}
+
+func (iptables *IPTablesController) run(action string, cmd *exec.Cmd) error {
 	var buff bytes.Buffer
 	cmd.Stdout = &buff
 	cmd.Stderr = &buff
 
+	return iptables.runWithOutput(action, cmd, &buff)
+}
+
+// listRules returns the rules of a chain as printed by "iptables -S", without
+// the leading "-A <chain>". It returns false if the chain does not exist.
+func (iptables *IPTablesController) listRules(table, chain string) ([]string, bool, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(iptables.iptablesBinPath, "--wait", "--table", table, "-S", chain)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := iptables.runWithOutput("list-rules", cmd, &stderr); err != nil {
+		if strings.Contains(stderr.String(), "No chain/target/match by that name") {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	rules := []string{}
+	prefix := "-A " + chain + " "
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			rules = append(rules, strings.TrimPrefix(line, prefix))
+		}
+	}
+
+	return rules, true, nil
+}
+
+func (iptables *IPTablesController) runWithOutput(action string, cmd *exec.Cmd, output *bytes.Buffer) (err error) {
 	u, err := iptables.locksmith.Lock(LockKey)
 	if err != nil {
 		return err
@@ -138,7 +165,7 @@ func (iptables *IPTablesController) run(action string, cmd *exec.Cmd) (err error
 	}()
 
 	if err := iptables.runner.Run(cmd); err != nil {
-		return fmt.Errorf("iptables: %s: %s", action, buff.String())
+		return fmt.Errorf("iptables: %s: %s", action, output.String())
 	}
 
 	return nil
@@ -0,0 +1,111 @@
+package iptables
+
+import (
+	"fmt"
+	"os/exec"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// PoolRules is the firewall policy of a named network pool. It replaces the
+// server-wide deny networks and host access setting for containers in the pool.
+type PoolRules struct {
+	Name            string
+	Network         string
+	DenyNetworks    []string
+	AllowHostAccess bool
+}
+
+// maxChainNameLength is the longest chain name iptables accepts
+const maxChainNameLength = 28
+
+type PoolStarter struct {
+	iptables *IPTablesController
+	pools    []PoolRules
+	logger   lager.Logger
+}
+
+func NewPoolStarter(iptables *IPTablesController, pools []PoolRules, logger lager.Logger) *PoolStarter {
+	return &PoolStarter{
+		iptables: iptables,
+		pools:    pools,
+		logger:   logger.Session("create-pool-iptables-chains"),
+	}
+}
+
+// Validate returns an error if the chain of any pool would have a name longer
+// than iptables accepts, so that gdn fails to start rather than failing to
+// create the chain
+func (s PoolStarter) Validate() error {
+	for _, pool := range s.pools {
+		if chain := s.iptables.PoolChain(pool.Name); len(chain) > maxChainNameLength {
+			return fmt.Errorf("network pool name '%s' is too long: its iptables chain '%s' exceeds %d characters", pool.Name, chain, maxChainNameLength)
+		}
+	}
+
+	return nil
+}
+
+// Start (re)creates a filter chain for every pool and routes traffic
+// originating from the pool's network through it. It must run after the
+// global chains have been set up.
+func (s PoolStarter) Start() error {
+	s.logger.Info("started")
+	defer s.logger.Info("finished")
+
+	for _, pool := range s.pools {
+		chain := s.iptables.PoolChain(pool.Name)
+		if err := s.iptables.DeleteChainReferences("filter", s.iptables.defaultChain, chain); err != nil {
+			return err
+		}
+
+		if err := s.iptables.DeleteChainReferences("filter", s.iptables.inputChain, chain); err != nil {
+			return err
+		}
+	}
+
+	for _, pool := range s.pools {
+		if err := s.setupPool(pool); err != nil {
+			return fmt.Errorf("setting up chains for network pool %s: %s", pool.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s PoolStarter) setupPool(pool PoolRules) error {
+	chain := s.iptables.PoolChain(pool.Name)
+
+	cmd := exec.Command("sh", "-c", fmt.Sprintf(
+		`%s --wait -N %s 2> /dev/null || %s --wait -F %s`,
+		s.iptables.iptablesBinPath, chain, s.iptables.iptablesBinPath, chain,
+	))
+	if err := s.iptables.run("create-pool-chain", cmd); err != nil {
+		return err
+	}
+
+	if err := s.iptables.appendRule(chain, iptablesFlags{"-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "--jump", "ACCEPT"}); err != nil {
+		return err
+	}
+
+	for _, n := range pool.DenyNetworks {
+		if err := s.iptables.appendRule(chain, rejectRule(n)); err != nil {
+			return err
+		}
+	}
+
+	// Containers in the pool skip the server-wide deny networks
+	if err := s.iptables.PrependRule(s.iptables.defaultChain, iptablesFlags{"--source", pool.Network, "--goto", chain}); err != nil {
+		return err
+	}
+
+	hostAccess := []string{"--jump", "REJECT", "--reject-with", "icmp-host-prohibited"}
+	if pool.AllowHostAccess {
+		hostAccess = []string{"--jump", "ACCEPT"}
+	}
+
+	return s.iptables.PrependRule(s.iptables.inputChain, iptablesFlags(append([]string{
+		"--source", pool.Network,
+		"-m", "conntrack", "!", "--ctstate", "ESTABLISHED,RELATED",
+	}, append(hostAccess, "-m", "comment", "--comment", chain)...)))
+}
@@ -0,0 +1,147 @@
+package iptables_test
+
+import (
+	"errors"
+	"os/exec"
+
+	"code.cloudfoundry.org/commandrunner/fake_command_runner"
+	. "code.cloudfoundry.org/commandrunner/fake_command_runner/matchers"
+	"code.cloudfoundry.org/guardian/kawasaki/iptables"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PoolStarter", func() {
+	var (
+		fakeRunner *fake_command_runner.FakeCommandRunner
+		pools      []iptables.PoolRules
+		starter    *iptables.PoolStarter
+	)
+
+	BeforeEach(func() {
+		fakeRunner = fake_command_runner.New()
+		pools = []iptables.PoolRules{
+			{
+				Name:         "isolated",
+				Network:      "10.100.0.0/22",
+				DenyNetworks: []string{"0.0.0.0/0"},
+			},
+			{
+				Name:            "public",
+				Network:         "10.101.0.0/22",
+				AllowHostAccess: true,
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		starter = iptables.NewPoolStarter(
+			iptables.New("/sbin/iptables", "/sbin/iptables-restore", fakeRunner, NewFakeLocksmith(), "prefix-"),
+			pools,
+			lagertest.NewTestLogger("pool_chains_test"),
+		)
+	})
+
+	It("removes the rules of previous runs before creating the pool chains", func() {
+		Expect(starter.Start()).To(Succeed())
+
+		Expect(fakeRunner).To(HaveExecutedSerially(
+			fake_command_runner.CommandSpec{
+				Path: "sh",
+				Args: []string{"-c", `set -e; /sbin/iptables --wait --table filter -S prefix-default | grep "prefix-pool-isolated" | sed -e "s/-A/-D/" | xargs --no-run-if-empty --max-lines=1 /sbin/iptables -w -t filter`},
+			},
+			fake_command_runner.CommandSpec{
+				Path: "sh",
+				Args: []string{"-c", `set -e; /sbin/iptables --wait --table filter -S prefix-input | grep "prefix-pool-public" | sed -e "s/-A/-D/" | xargs --no-run-if-empty --max-lines=1 /sbin/iptables -w -t filter`},
+			},
+			fake_command_runner.CommandSpec{
+				Path: "sh",
+				Args: []string{"-c", `/sbin/iptables --wait -N prefix-pool-isolated 2> /dev/null || /sbin/iptables --wait -F prefix-pool-isolated`},
+			},
+		))
+	})
+
+	It("rejects the pool's deny networks in the pool chain", func() {
+		Expect(starter.Start()).To(Succeed())
+
+		Expect(fakeRunner).To(HaveExecutedSerially(
+			fake_command_runner.CommandSpec{
+				Path: "/sbin/iptables",
+				Args: []string{"-w", "-A", "prefix-pool-isolated", "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "--jump", "ACCEPT"},
+			},
+			fake_command_runner.CommandSpec{
+				Path: "/sbin/iptables",
+				Args: []string{"-w", "-A", "prefix-pool-isolated", "--destination", "0.0.0.0/0", "--jump", "REJECT"},
+			},
+		))
+	})
+
+	It("sends traffic from the pool's network to the pool chain", func() {
+		Expect(starter.Start()).To(Succeed())
+
+		Expect(fakeRunner).To(HaveExecuted(fake_command_runner.CommandSpec{
+			Path: "/sbin/iptables",
+			Args: []string{"-w", "-I", "prefix-default", "1", "--source", "10.100.0.0/22", "--goto", "prefix-pool-isolated"},
+		}))
+	})
+
+	It("applies the host access setting of each pool", func() {
+		Expect(starter.Start()).To(Succeed())
+
+		Expect(fakeRunner).To(HaveExecuted(fake_command_runner.CommandSpec{
+			Path: "/sbin/iptables",
+			Args: []string{
+				"-w", "-I", "prefix-input", "1",
+				"--source", "10.100.0.0/22",
+				"-m", "conntrack", "!", "--ctstate", "ESTABLISHED,RELATED",
+				"--jump", "REJECT", "--reject-with", "icmp-host-prohibited",
+				"-m", "comment", "--comment", "prefix-pool-isolated",
+			},
+		}))
+
+		Expect(fakeRunner).To(HaveExecuted(fake_command_runner.CommandSpec{
+			Path: "/sbin/iptables",
+			Args: []string{
+				"-w", "-I", "prefix-input", "1",
+				"--source", "10.101.0.0/22",
+				"-m", "conntrack", "!", "--ctstate", "ESTABLISHED,RELATED",
+				"--jump", "ACCEPT",
+				"-m", "comment", "--comment", "prefix-pool-public",
+			},
+		}))
+	})
+
+	Context("when creating a pool chain fails", func() {
+		BeforeEach(func() {
+			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+				Path: "sh",
+				Args: []string{"-c", `/sbin/iptables --wait -N prefix-pool-isolated 2> /dev/null || /sbin/iptables --wait -F prefix-pool-isolated`},
+			}, func(cmd *exec.Cmd) error {
+				cmd.Stderr.Write([]byte("oh no!"))
+				return errors.New("exit status 1")
+			})
+		})
+
+		It("returns the error", func() {
+			Expect(starter.Start()).To(MatchError(ContainSubstring("setting up chains for network pool isolated")))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("accepts pools whose chain names fit within iptables' limit", func() {
+			Expect(starter.Validate()).To(Succeed())
+		})
+
+		Context("when the chain name of a pool would be too long", func() {
+			BeforeEach(func() {
+				// prefix-pool- leaves 16 characters for the name
+				pools = append(pools, iptables.PoolRules{Name: "a-very-long-pool-name", Network: "10.102.0.0/22"})
+			})
+
+			It("returns an error", func() {
+				Expect(starter.Validate()).To(MatchError("network pool name 'a-very-long-pool-name' is too long: its iptables chain 'prefix-pool-a-very-long-pool-name' exceeds 28 characters"))
+			})
+		})
+	})
+})
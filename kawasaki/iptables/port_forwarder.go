@@ -13,9 +13,10 @@ func NewPortForwarder(iptables *IPTablesController) *PortForwarder {
 }
 
 func (p *PortForwarder) Forward(spec kawasaki.PortForwarderSpec) error {
-	return p.iptables.appendRule(
+	payload := &restorePayload{}
+	payload.table("nat").appendRule(
 		p.iptables.InstanceChain(spec.InstanceID),
-		natRule(
+		dnatFlags(
 			spec.ExternalIP.String(),
 			spec.FromPort,
 			spec.ContainerIP.String(),
@@ -23,4 +24,6 @@ func (p *PortForwarder) Forward(spec kawasaki.PortForwarderSpec) error {
 			spec.Handle,
 		),
 	)
+
+	return p.iptables.restore("forward-port", payload)
 }
@@ -1,10 +1,11 @@
 package iptables_test
 
 import (
+	"io/ioutil"
 	"net"
+	"os/exec"
 
 	"code.cloudfoundry.org/commandrunner/fake_command_runner"
-	. "code.cloudfoundry.org/commandrunner/fake_command_runner/matchers"
 	"code.cloudfoundry.org/guardian/kawasaki"
 	"code.cloudfoundry.org/guardian/kawasaki/iptables"
 
@@ -16,6 +17,7 @@ var _ = Describe("PortForwarder", func() {
 	var (
 		fakeRunner *fake_command_runner.FakeCommandRunner
 		forwarder  *iptables.PortForwarder
+		restored   []string
 	)
 
 	BeforeEach(func() {
@@ -24,6 +26,17 @@ var _ = Describe("PortForwarder", func() {
 		forwarder = iptables.NewPortForwarder(
 			iptables.New("/sbin/iptables", "/sbin/iptables-restore", fakeRunner, fakeLocksmith, "prefix-"),
 		)
+
+		restored = nil
+		fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: "/sbin/iptables-restore",
+			Args: []string{"--noflush"},
+		}, func(cmd *exec.Cmd) error {
+			payload, err := ioutil.ReadAll(cmd.Stdin)
+			Expect(err).NotTo(HaveOccurred())
+			restored = append(restored, string(payload))
+			return nil
+		})
 	})
 
 	It("adds a NAT rule to forward the port", func() {
@@ -36,24 +49,9 @@ var _ = Describe("PortForwarder", func() {
 			ToPort:      33,
 		})).To(Succeed())
 
-		Expect(fakeRunner).To(HaveExecutedSerially(
-			fake_command_runner.CommandSpec{
-				Path: "/sbin/iptables",
-				Args: []string{
-					"-w",
-					"-A", "prefix-instance-some-instance",
-					"--table", "nat",
-					"--protocol", "tcp",
-					"--destination", "5.6.7.8",
-					"--destination-port", "22",
-					"--jump", "DNAT",
-					"--to-destination", "1.2.3.4:33",
-					"-m",
-					"comment",
-					"--comment",
-					"some-handle",
-				},
-			},
-		))
+		Expect(restored).To(Equal([]string{`*nat
+-A prefix-instance-some-instance --protocol tcp --destination 5.6.7.8 --destination-port 22 --jump DNAT --to-destination 1.2.3.4:33 -m comment --comment some-handle
+COMMIT
+`}))
 	})
 })
@@ -0,0 +1,108 @@
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// restorePayload builds input for "iptables-restore --noflush", so that a
+// group of changes is applied in a single invocation. Each table is
+// committed atomically: if any line fails, none of the table's changes apply.
+type restorePayload struct {
+	tables []*restoreTable
+}
+
+type restoreTable struct {
+	name  string
+	lines []string
+}
+
+func (p *restorePayload) table(name string) *restoreTable {
+	for _, t := range p.tables {
+		if t.name == name {
+			return t
+		}
+	}
+
+	t := &restoreTable{name: name}
+	p.tables = append(p.tables, t)
+	return t
+}
+
+func (p *restorePayload) empty() bool {
+	for _, t := range p.tables {
+		if len(t.lines) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *restorePayload) String() string {
+	var b bytes.Buffer
+	for _, t := range p.tables {
+		if len(t.lines) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "*%s\n", t.name)
+		for _, line := range t.lines {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+		b.WriteString("COMMIT\n")
+	}
+
+	return b.String()
+}
+
+// declareChain creates the chain, or flushes it if it already exists
+func (t *restoreTable) declareChain(chain string) {
+	t.lines = append(t.lines, fmt.Sprintf(":%s - [0:0]", chain))
+}
+
+func (t *restoreTable) deleteChain(chain string) {
+	t.lines = append(t.lines, fmt.Sprintf("-X %s", chain))
+}
+
+func (t *restoreTable) appendRule(chain string, rule Rule) {
+	t.lines = append(t.lines, fmt.Sprintf("-A %s %s", chain, restoreArgs(rule.Flags(chain))))
+}
+
+func (t *restoreTable) insertRule(chain string, position int, rule Rule) {
+	t.lines = append(t.lines, fmt.Sprintf("-I %s %d %s", chain, position, restoreArgs(rule.Flags(chain))))
+}
+
+// deleteListedRule deletes a rule as printed by "iptables -S", which is
+// already quoted for iptables-restore
+func (t *restoreTable) deleteListedRule(chain, rule string) {
+	t.lines = append(t.lines, fmt.Sprintf("-D %s %s", chain, rule))
+}
+
+// restoreArgs quotes any argument which iptables-restore would otherwise
+// split, such as log prefixes ending in a space
+func restoreArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"") {
+			arg = `"` + strings.Replace(arg, `"`, `\"`, -1) + `"`
+		}
+		quoted[i] = arg
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+func (iptables *IPTablesController) restore(action string, payload *restorePayload) error {
+	if payload.empty() {
+		return nil
+	}
+
+	cmd := exec.Command(iptables.iptablesRestoreBinPath, "--noflush")
+	cmd.Stdin = strings.NewReader(payload.String())
+
+	return iptables.run(action, cmd)
+}
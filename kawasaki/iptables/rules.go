@@ -12,9 +12,8 @@ func (flags iptablesFlags) Flags(chain string) []string {
 	return flags
 }
 
-func natRule(destination string, destinationPort uint32, containerIP string, containerPort uint32, comment string) Rule {
+func dnatFlags(destination string, destinationPort uint32, containerIP string, containerPort uint32, comment string) iptablesFlags {
 	return iptablesFlags([]string{
-		"--table", "nat",
 		"--protocol", "tcp",
 		"--destination", destination,
 		"--destination-port", fmt.Sprintf("%d", destinationPort),
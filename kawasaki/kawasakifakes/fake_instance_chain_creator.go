@@ -10,7 +10,7 @@ import (
 )
 
 type FakeInstanceChainCreator struct {
-	CreateStub        func(logger lager.Logger, handle, instanceChain, bridgeName string, ip net.IP, network *net.IPNet) error
+	CreateStub        func(logger lager.Logger, handle string, instanceChain string, bridgeName string, ip net.IP, network *net.IPNet, externalIP net.IP, egressIP net.IP, limits kawasaki.ConnectionLimits) error
 	createMutex       sync.RWMutex
 	createArgsForCall []struct {
 		logger        lager.Logger
@@ -19,6 +19,9 @@ type FakeInstanceChainCreator struct {
 		bridgeName    string
 		ip            net.IP
 		network       *net.IPNet
+		externalIP    net.IP
+		egressIP      net.IP
+		limits        kawasaki.ConnectionLimits
 	}
 	createReturns struct {
 		result1 error
@@ -38,11 +41,23 @@ type FakeInstanceChainCreator struct {
 	destroyReturnsOnCall map[int]struct {
 		result1 error
 	}
+	DestroySubnetStub        func(logger lager.Logger, network *net.IPNet) error
+	destroySubnetMutex       sync.RWMutex
+	destroySubnetArgsForCall []struct {
+		logger  lager.Logger
+		network *net.IPNet
+	}
+	destroySubnetReturns struct {
+		result1 error
+	}
+	destroySubnetReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeInstanceChainCreator) Create(logger lager.Logger, handle string, instanceChain string, bridgeName string, ip net.IP, network *net.IPNet) error {
+func (fake *FakeInstanceChainCreator) Create(logger lager.Logger, handle string, instanceChain string, bridgeName string, ip net.IP, network *net.IPNet, externalIP net.IP, egressIP net.IP, limits kawasaki.ConnectionLimits) error {
 	fake.createMutex.Lock()
 	ret, specificReturn := fake.createReturnsOnCall[len(fake.createArgsForCall)]
 	fake.createArgsForCall = append(fake.createArgsForCall, struct {
@@ -52,11 +67,14 @@ func (fake *FakeInstanceChainCreator) Create(logger lager.Logger, handle string,
 		bridgeName    string
 		ip            net.IP
 		network       *net.IPNet
-	}{logger, handle, instanceChain, bridgeName, ip, network})
-	fake.recordInvocation("Create", []interface{}{logger, handle, instanceChain, bridgeName, ip, network})
+		externalIP    net.IP
+		egressIP      net.IP
+		limits        kawasaki.ConnectionLimits
+	}{logger, handle, instanceChain, bridgeName, ip, network, externalIP, egressIP, limits})
+	fake.recordInvocation("Create", []interface{}{logger, handle, instanceChain, bridgeName, ip, network, externalIP, egressIP, limits})
 	fake.createMutex.Unlock()
 	if fake.CreateStub != nil {
-		return fake.CreateStub(logger, handle, instanceChain, bridgeName, ip, network)
+		return fake.CreateStub(logger, handle, instanceChain, bridgeName, ip, network, externalIP, egressIP, limits)
 	}
 	if specificReturn {
 		return ret.result1
@@ -70,10 +88,10 @@ func (fake *FakeInstanceChainCreator) CreateCallCount() int {
 	return len(fake.createArgsForCall)
 }
 
-func (fake *FakeInstanceChainCreator) CreateArgsForCall(i int) (lager.Logger, string, string, string, net.IP, *net.IPNet) {
+func (fake *FakeInstanceChainCreator) CreateArgsForCall(i int) (lager.Logger, string, string, string, net.IP, *net.IPNet, net.IP, net.IP, kawasaki.ConnectionLimits) {
 	fake.createMutex.RLock()
 	defer fake.createMutex.RUnlock()
-	return fake.createArgsForCall[i].logger, fake.createArgsForCall[i].handle, fake.createArgsForCall[i].instanceChain, fake.createArgsForCall[i].bridgeName, fake.createArgsForCall[i].ip, fake.createArgsForCall[i].network
+	return fake.createArgsForCall[i].logger, fake.createArgsForCall[i].handle, fake.createArgsForCall[i].instanceChain, fake.createArgsForCall[i].bridgeName, fake.createArgsForCall[i].ip, fake.createArgsForCall[i].network, fake.createArgsForCall[i].externalIP, fake.createArgsForCall[i].egressIP, fake.createArgsForCall[i].limits
 }
 
 func (fake *FakeInstanceChainCreator) CreateReturns(result1 error) {
@@ -144,6 +162,55 @@ func (fake *FakeInstanceChainCreator) DestroyReturnsOnCall(i int, result1 error)
 	}{result1}
 }
 
+func (fake *FakeInstanceChainCreator) DestroySubnet(logger lager.Logger, network *net.IPNet) error {
+	fake.destroySubnetMutex.Lock()
+	ret, specificReturn := fake.destroySubnetReturnsOnCall[len(fake.destroySubnetArgsForCall)]
+	fake.destroySubnetArgsForCall = append(fake.destroySubnetArgsForCall, struct {
+		logger  lager.Logger
+		network *net.IPNet
+	}{logger, network})
+	fake.recordInvocation("DestroySubnet", []interface{}{logger, network})
+	fake.destroySubnetMutex.Unlock()
+	if fake.DestroySubnetStub != nil {
+		return fake.DestroySubnetStub(logger, network)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.destroySubnetReturns.result1
+}
+
+func (fake *FakeInstanceChainCreator) DestroySubnetCallCount() int {
+	fake.destroySubnetMutex.RLock()
+	defer fake.destroySubnetMutex.RUnlock()
+	return len(fake.destroySubnetArgsForCall)
+}
+
+func (fake *FakeInstanceChainCreator) DestroySubnetArgsForCall(i int) (lager.Logger, *net.IPNet) {
+	fake.destroySubnetMutex.RLock()
+	defer fake.destroySubnetMutex.RUnlock()
+	return fake.destroySubnetArgsForCall[i].logger, fake.destroySubnetArgsForCall[i].network
+}
+
+func (fake *FakeInstanceChainCreator) DestroySubnetReturns(result1 error) {
+	fake.DestroySubnetStub = nil
+	fake.destroySubnetReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeInstanceChainCreator) DestroySubnetReturnsOnCall(i int, result1 error) {
+	fake.DestroySubnetStub = nil
+	if fake.destroySubnetReturnsOnCall == nil {
+		fake.destroySubnetReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.destroySubnetReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeInstanceChainCreator) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -151,6 +218,8 @@ func (fake *FakeInstanceChainCreator) Invocations() map[string][][]interface{} {
 	defer fake.createMutex.RUnlock()
 	fake.destroyMutex.RLock()
 	defer fake.destroyMutex.RUnlock()
+	fake.destroySubnetMutex.RLock()
+	defer fake.destroySubnetMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -2,16 +2,29 @@
 package kawasakifakes
 
 import (
+	"net"
 	"sync"
 
 	"code.cloudfoundry.org/guardian/kawasaki"
 )
 
 type FakePortPool struct {
-	AcquireStub        func() (uint32, error)
+	ExternalIPStub        func() net.IP
+	externalIPMutex       sync.RWMutex
+	externalIPArgsForCall []struct{}
+	externalIPReturns     struct {
+		result1 net.IP
+	}
+	externalIPReturnsOnCall map[int]struct {
+		result1 net.IP
+	}
+	AcquireStub        func(handle string, externalIP net.IP) (uint32, error)
 	acquireMutex       sync.RWMutex
-	acquireArgsForCall []struct{}
-	acquireReturns     struct {
+	acquireArgsForCall []struct {
+		handle     string
+		externalIP net.IP
+	}
+	acquireReturns struct {
 		result1 uint32
 		result2 error
 	}
@@ -19,15 +32,18 @@ type FakePortPool struct {
 		result1 uint32
 		result2 error
 	}
-	ReleaseStub        func(uint32)
+	ReleaseStub        func(externalIP net.IP, port uint32)
 	releaseMutex       sync.RWMutex
 	releaseArgsForCall []struct {
-		arg1 uint32
+		externalIP net.IP
+		port       uint32
 	}
-	RemoveStub        func(uint32) error
+	RemoveStub        func(handle string, externalIP net.IP, port uint32) error
 	removeMutex       sync.RWMutex
 	removeArgsForCall []struct {
-		arg1 uint32
+		handle     string
+		externalIP net.IP
+		port       uint32
 	}
 	removeReturns struct {
 		result1 error
@@ -39,14 +55,57 @@ type FakePortPool struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakePortPool) Acquire() (uint32, error) {
+func (fake *FakePortPool) ExternalIP() net.IP {
+	fake.externalIPMutex.Lock()
+	ret, specificReturn := fake.externalIPReturnsOnCall[len(fake.externalIPArgsForCall)]
+	fake.externalIPArgsForCall = append(fake.externalIPArgsForCall, struct{}{})
+	fake.recordInvocation("ExternalIP", []interface{}{})
+	fake.externalIPMutex.Unlock()
+	if fake.ExternalIPStub != nil {
+		return fake.ExternalIPStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.externalIPReturns.result1
+}
+
+func (fake *FakePortPool) ExternalIPCallCount() int {
+	fake.externalIPMutex.RLock()
+	defer fake.externalIPMutex.RUnlock()
+	return len(fake.externalIPArgsForCall)
+}
+
+func (fake *FakePortPool) ExternalIPReturns(result1 net.IP) {
+	fake.ExternalIPStub = nil
+	fake.externalIPReturns = struct {
+		result1 net.IP
+	}{result1}
+}
+
+func (fake *FakePortPool) ExternalIPReturnsOnCall(i int, result1 net.IP) {
+	fake.ExternalIPStub = nil
+	if fake.externalIPReturnsOnCall == nil {
+		fake.externalIPReturnsOnCall = make(map[int]struct {
+			result1 net.IP
+		})
+	}
+	fake.externalIPReturnsOnCall[i] = struct {
+		result1 net.IP
+	}{result1}
+}
+
+func (fake *FakePortPool) Acquire(handle string, externalIP net.IP) (uint32, error) {
 	fake.acquireMutex.Lock()
 	ret, specificReturn := fake.acquireReturnsOnCall[len(fake.acquireArgsForCall)]
-	fake.acquireArgsForCall = append(fake.acquireArgsForCall, struct{}{})
-	fake.recordInvocation("Acquire", []interface{}{})
+	fake.acquireArgsForCall = append(fake.acquireArgsForCall, struct {
+		handle     string
+		externalIP net.IP
+	}{handle, externalIP})
+	fake.recordInvocation("Acquire", []interface{}{handle, externalIP})
 	fake.acquireMutex.Unlock()
 	if fake.AcquireStub != nil {
-		return fake.AcquireStub()
+		return fake.AcquireStub(handle, externalIP)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -60,6 +119,12 @@ func (fake *FakePortPool) AcquireCallCount() int {
 	return len(fake.acquireArgsForCall)
 }
 
+func (fake *FakePortPool) AcquireArgsForCall(i int) (string, net.IP) {
+	fake.acquireMutex.RLock()
+	defer fake.acquireMutex.RUnlock()
+	return fake.acquireArgsForCall[i].handle, fake.acquireArgsForCall[i].externalIP
+}
+
 func (fake *FakePortPool) AcquireReturns(result1 uint32, result2 error) {
 	fake.AcquireStub = nil
 	fake.acquireReturns = struct {
@@ -82,15 +147,16 @@ func (fake *FakePortPool) AcquireReturnsOnCall(i int, result1 uint32, result2 er
 	}{result1, result2}
 }
 
-func (fake *FakePortPool) Release(arg1 uint32) {
+func (fake *FakePortPool) Release(externalIP net.IP, port uint32) {
 	fake.releaseMutex.Lock()
 	fake.releaseArgsForCall = append(fake.releaseArgsForCall, struct {
-		arg1 uint32
-	}{arg1})
-	fake.recordInvocation("Release", []interface{}{arg1})
+		externalIP net.IP
+		port       uint32
+	}{externalIP, port})
+	fake.recordInvocation("Release", []interface{}{externalIP, port})
 	fake.releaseMutex.Unlock()
 	if fake.ReleaseStub != nil {
-		fake.ReleaseStub(arg1)
+		fake.ReleaseStub(externalIP, port)
 	}
 }
 
@@ -100,22 +166,24 @@ func (fake *FakePortPool) ReleaseCallCount() int {
 	return len(fake.releaseArgsForCall)
 }
 
-func (fake *FakePortPool) ReleaseArgsForCall(i int) uint32 {
+func (fake *FakePortPool) ReleaseArgsForCall(i int) (net.IP, uint32) {
 	fake.releaseMutex.RLock()
 	defer fake.releaseMutex.RUnlock()
-	return fake.releaseArgsForCall[i].arg1
+	return fake.releaseArgsForCall[i].externalIP, fake.releaseArgsForCall[i].port
 }
 
-func (fake *FakePortPool) Remove(arg1 uint32) error {
+func (fake *FakePortPool) Remove(handle string, externalIP net.IP, port uint32) error {
 	fake.removeMutex.Lock()
 	ret, specificReturn := fake.removeReturnsOnCall[len(fake.removeArgsForCall)]
 	fake.removeArgsForCall = append(fake.removeArgsForCall, struct {
-		arg1 uint32
-	}{arg1})
-	fake.recordInvocation("Remove", []interface{}{arg1})
+		handle     string
+		externalIP net.IP
+		port       uint32
+	}{handle, externalIP, port})
+	fake.recordInvocation("Remove", []interface{}{handle, externalIP, port})
 	fake.removeMutex.Unlock()
 	if fake.RemoveStub != nil {
-		return fake.RemoveStub(arg1)
+		return fake.RemoveStub(handle, externalIP, port)
 	}
 	if specificReturn {
 		return ret.result1
@@ -129,10 +197,10 @@ func (fake *FakePortPool) RemoveCallCount() int {
 	return len(fake.removeArgsForCall)
 }
 
-func (fake *FakePortPool) RemoveArgsForCall(i int) uint32 {
+func (fake *FakePortPool) RemoveArgsForCall(i int) (string, net.IP, uint32) {
 	fake.removeMutex.RLock()
 	defer fake.removeMutex.RUnlock()
-	return fake.removeArgsForCall[i].arg1
+	return fake.removeArgsForCall[i].handle, fake.removeArgsForCall[i].externalIP, fake.removeArgsForCall[i].port
 }
 
 func (fake *FakePortPool) RemoveReturns(result1 error) {
@@ -157,6 +225,8 @@ func (fake *FakePortPool) RemoveReturnsOnCall(i int, result1 error) {
 func (fake *FakePortPool) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.externalIPMutex.RLock()
+	defer fake.externalIPMutex.RUnlock()
 	fake.acquireMutex.RLock()
 	defer fake.acquireMutex.RUnlock()
 	fake.releaseMutex.RLock()
@@ -9,6 +9,7 @@ import (
 
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/guardian/gardener"
+	"code.cloudfoundry.org/guardian/kawasaki/ports"
 	"code.cloudfoundry.org/guardian/kawasaki/subnets"
 	"code.cloudfoundry.org/lager"
 )
@@ -17,6 +18,7 @@ import (
 const containerIpKey = gardener.ContainerIPKey
 const bridgeIpKey = gardener.BridgeIPKey
 const externalIpKey = gardener.ExternalIPKey
+const egressIpKey = gardener.EgressIPKey
 
 // kawasaki-specific state properties
 const hostIntfKey = "kawasaki.host-interface"
@@ -28,6 +30,15 @@ const iptableInstanceKey = "kawasaki.iptable-inst"
 const mtuKey = "kawasaki.mtu"
 const dnsServerKey = "kawasaki.dns-servers"
 const hostEntriesKey = "kawasaki.host-entries"
+const poolKey = "kawasaki.pool"
+const modeKey = "kawasaki.mode"
+const maxConnectionsKey = "kawasaki.max-connections"
+const connectionRateKey = "kawasaki.connection-rate"
+
+// netOutRulesKey records every net-out rule applied to the container, so that
+// its firewall can be checked for drift. It is written once the container's
+// network is fully set up, and cleared when the network is being destroyed.
+const netOutRulesKey = "kawasaki.net-out-rules"
 
 //go:generate counterfeiter . SpecParser
 
@@ -59,9 +70,10 @@ type ConfigStore interface {
 //go:generate counterfeiter . PortPool
 
 type PortPool interface {
-	Acquire() (uint32, error)
-	Release(uint32)
-	Remove(uint32) error
+	ExternalIP() net.IP
+	Acquire(handle string, externalIP net.IP) (uint32, error)
+	Release(externalIP net.IP, port uint32)
+	Remove(handle string, externalIP net.IP, port uint32) error
 }
 
 //go:generate counterfeiter . PortForwarder
@@ -101,27 +113,38 @@ type Networker interface {
 type networker struct {
 	specParser     SpecParser
 	subnetPool     subnets.Pool
+	pools          map[string]NetworkPool
 	configCreator  ConfigCreator
 	configStore    ConfigStore
 	portForwarder  PortForwarder
 	portPool       PortPool
 	firewallOpener FirewallOpener
 	configurer     Configurer
+	attacher       Attacher
+	egressIPs      []net.IP
+	maxMtu         int
+	locks          *HandleLocks
 }
 
 func New(
 	specParser SpecParser,
 	subnetPool subnets.Pool,
+	pools map[string]NetworkPool,
 	configCreator ConfigCreator,
 	configStore ConfigStore,
 	configurer Configurer,
 	portPool PortPool,
 	portForwarder PortForwarder,
 	firewallOpener FirewallOpener,
+	attacher Attacher,
+	egressIPs []net.IP,
+	maxMtu int,
+	locks *HandleLocks,
 ) *networker {
 	return &networker{
 		specParser:    specParser,
 		subnetPool:    subnetPool,
+		pools:         pools,
 		configCreator: configCreator,
 		configStore:   configStore,
 		configurer:    configurer,
@@ -130,6 +153,10 @@ func New(
 		portPool:      portPool,
 
 		firewallOpener: firewallOpener,
+		attacher:       attacher,
+		egressIPs:      egressIPs,
+		maxMtu:         maxMtu,
+		locks:          locks,
 	}
 }
 
@@ -142,13 +169,66 @@ func (n *networker) Network(log lager.Logger, containerSpec garden.ContainerSpec
 	log.Info("started")
 	defer log.Info("finished")
 
-	subnetReq, ipReq, err := n.specParser.Parse(log, containerSpec.Network)
+	n.locks.Lock(containerSpec.Handle)
+	defer n.locks.Unlock(containerSpec.Handle)
+
+	spec, options, err := ParseSpecOptions(containerSpec.Network)
+	if err != nil {
+		log.Error("parse-options-failed", err)
+		return err
+	}
+
+	if options.Mtu != 0 && (options.Mtu < minMtuSize || options.Mtu > n.maxMtu) {
+		err := fmt.Errorf("mtu %d must be between %d and the host interface mtu of %d", options.Mtu, minMtuSize, n.maxMtu)
+		log.Error("invalid-mtu", err)
+		return err
+	}
+
+	poolName, spec := parsePoolSpec(spec)
+	subnetPool, err := n.subnetPoolFor(poolName)
+	if err != nil {
+		log.Error("select-pool-failed", err)
+		return err
+	}
+
+	subnetReq, ipReq, err := n.specParser.Parse(log, spec)
 	if err != nil {
 		log.Error("parse-failed", err)
 		return err
 	}
 
-	subnet, ip, err := n.subnetPool.Acquire(log, subnetReq, ipReq)
+	pool := n.pools[poolName]
+	egressIP, err := n.egressIPFor(pool, containerSpec.Properties)
+	if err != nil {
+		log.Error("select-egress-ip-failed", err)
+		return err
+	}
+
+	if pool.IsDirect() && (options.MaxConnections != nil || options.ConnectionRate != nil) {
+		err := fmt.Errorf("connection limits are not supported in %s mode: traffic does not pass through the host firewall", pool.Spec.Mode)
+		log.Error("invalid-connection-limits", err)
+		return err
+	}
+
+	if pool.IsDirect() {
+		// every container in a direct pool shares the pool's whole network
+		subnetReq = wholeNetworkSelector{pool.Network}
+	}
+
+	if pool.IsVXLAN() {
+		if options.Mtu > pool.Spec.Mtu {
+			err := fmt.Errorf("mtu %d exceeds the mtu of %d available within the vxlan overlay", options.Mtu, pool.Spec.Mtu)
+			log.Error("invalid-mtu", err)
+			return err
+		}
+
+		// the pool's network is shared with the other hosts on the overlay,
+		// each of which hands out addresses from its own range
+		subnetReq = wholeNetworkSelector{pool.Network}
+		ipReq = allocationRangeSelector{pool.AllocationRange, pool.Gateway}
+	}
+
+	subnet, ip, err := subnetPool.Acquire(log, subnetReq, ipReq)
 	if err != nil {
 		log.Error("acquire-failed", err)
 		return err
@@ -159,40 +239,154 @@ func (n *networker) Network(log lager.Logger, containerSpec garden.ContainerSpec
 		log.Error("create-config-failed", err)
 		return fmt.Errorf("create network config: %s", err)
 	}
+
+	if poolName != "" {
+		config.Pool = poolName
+		if mtu := pool.Spec.Mtu; mtu != 0 {
+			config.Mtu = mtu
+		}
+	}
+
+	if options.Mtu != 0 {
+		config.Mtu = options.Mtu
+	}
+
+	if options.MaxConnections != nil {
+		config.ConnectionLimits.MaxConnections = *options.MaxConnections
+	}
+
+	if options.ConnectionRate != nil {
+		config.ConnectionLimits.Rate = *options.ConnectionRate
+	}
+
+	// spread containers across the external IPs so that each IP's ports
+	// last as long as possible
+	if externalIP := n.portPool.ExternalIP(); externalIP != nil {
+		config.ExternalIP = externalIP
+	}
+
+	if pool.IsDirect() {
+		// there is no bridge and no NAT: the container routes via the
+		// pool's gateway and is reachable on its own address
+		config.Mode = pool.Spec.Mode
+		config.BridgeName = ""
+		config.BridgeIP = pool.Gateway
+		config.ExternalIP = config.ContainerIP
+		config.ConnectionLimits = ConnectionLimits{}
+	}
+
+	if pool.IsVXLAN() {
+		config.Mode = pool.Spec.Mode
+		config.BridgeIP = pool.Gateway
+	}
+
+	config.EgressIP = egressIP
 	log.Info("config-create", lager.Data{"config": config})
 
 	save(n.configStore, containerSpec.Handle, config)
 
-	if err := n.configurer.Apply(log, config, pid); err != nil {
+	if err := n.configurerFor(poolName).Apply(log, config, pid); err != nil {
 		return err
 	}
 
+	if attachments := AttachmentNames(containerSpec.Properties); len(attachments) > 0 {
+		if err := n.attacher.Attach(log, containerSpec.Handle, attachments, pid); err != nil {
+			log.Error("attach-failed", err)
+			return err
+		}
+	}
+
 	for _, netIn := range containerSpec.NetIn {
-		if _, _, err := n.NetIn(log, containerSpec.Handle, netIn.HostPort, netIn.ContainerPort); err != nil {
+		if _, _, err := n.netIn(log, containerSpec.Handle, netIn.HostPort, netIn.ContainerPort); err != nil {
 			return err
 		}
 	}
 
-	if err := n.BulkNetOut(log, containerSpec.Handle, containerSpec.NetOut); err != nil {
+	if err := n.bulkNetOut(log, containerSpec.Handle, containerSpec.NetOut); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// Capacity returns the number of subnets this network can host
+// Capacity returns the number of subnets this network can host across all
+// of its pools
 func (n *networker) Capacity() uint64 {
-	return uint64(n.subnetPool.Capacity())
+	capacity := uint64(n.subnetPool.Capacity())
+	for _, pool := range n.pools {
+		capacity += uint64(pool.Capacity())
+	}
+
+	return capacity
+}
+
+func (n *networker) subnetPoolFor(poolName string) (subnets.Pool, error) {
+	if poolName == "" {
+		return n.subnetPool, nil
+	}
+
+	pool, ok := n.pools[poolName]
+	if !ok {
+		return nil, fmt.Errorf("unknown network pool: %s", poolName)
+	}
+
+	return pool.Subnets, nil
+}
+
+// egressIPFor returns the address a container's traffic should be source
+// NATed to, if any. A container may request one of the operator's egress IPs,
+// otherwise it uses its pool's.
+func (n *networker) egressIPFor(pool NetworkPool, properties garden.Properties) (net.IP, error) {
+	requested := properties[gardener.EgressIPKey]
+	if requested == "" {
+		return pool.EgressIP, nil
+	}
+
+	if pool.IsDirect() {
+		return nil, fmt.Errorf("egress ip is not supported in %s mode: traffic is not NATed", pool.Spec.Mode)
+	}
+
+	ip := net.ParseIP(requested)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid egress ip: %s", requested)
+	}
+
+	for _, allowed := range n.egressIPs {
+		if allowed.Equal(ip) {
+			return ip, nil
+		}
+	}
+
+	return nil, fmt.Errorf("egress ip %s is not one of the configured egress ips", requested)
+}
+
+func (n *networker) configurerFor(poolName string) Configurer {
+	if pool, ok := n.pools[poolName]; ok && pool.Configurer != nil {
+		return pool.Configurer
+	}
+
+	return n.configurer
 }
 
 func (n *networker) NetIn(log lager.Logger, handle string, externalPort, containerPort uint32) (uint32, uint32, error) {
+	n.locks.Lock(handle)
+	defer n.locks.Unlock(handle)
+
+	return n.netIn(log, handle, externalPort, containerPort)
+}
+
+func (n *networker) netIn(log lager.Logger, handle string, externalPort, containerPort uint32) (uint32, uint32, error) {
 	cfg, err := load(n.configStore, handle)
 	if err != nil {
 		return 0, 0, err
 	}
 
+	if isDirectMode(cfg.Mode) {
+		return 0, 0, fmt.Errorf("net-in is not supported in %s mode: the container is reachable directly at %s", cfg.Mode, cfg.ContainerIP)
+	}
+
 	if externalPort == 0 {
-		externalPort, err = n.portPool.Acquire()
+		externalPort, err = n.portPool.Acquire(handle, cfg.ExternalIP)
 		if err != nil {
 			return 0, 0, err
 		}
@@ -226,35 +420,82 @@ func (n *networker) NetIn(log lager.Logger, handle string, externalPort, contain
 }
 
 func (n *networker) NetOut(log lager.Logger, handle string, rule garden.NetOutRule) error {
+	n.locks.Lock(handle)
+	defer n.locks.Unlock(handle)
+
 	cfg, err := load(n.configStore, handle)
 	if err != nil {
 		return err
 	}
 
-	return n.firewallOpener.Open(log, cfg.IPTableInstance, handle, rule)
+	if isDirectMode(cfg.Mode) {
+		return netOutNotSupported(cfg.Mode)
+	}
+
+	if err := n.firewallOpener.Open(log, cfg.IPTableInstance, handle, rule); err != nil {
+		return err
+	}
+
+	return addNetOutRules(n.configStore, handle, []garden.NetOutRule{rule})
 }
 
 func (n *networker) BulkNetOut(log lager.Logger, handle string, rules []garden.NetOutRule) error {
+	n.locks.Lock(handle)
+	defer n.locks.Unlock(handle)
+
+	return n.bulkNetOut(log, handle, rules)
+}
+
+func (n *networker) bulkNetOut(log lager.Logger, handle string, rules []garden.NetOutRule) error {
 	cfg, err := load(n.configStore, handle)
 	if err != nil {
 		return err
 	}
 
-	return n.firewallOpener.BulkOpen(log, cfg.IPTableInstance, handle, rules)
+	if isDirectMode(cfg.Mode) {
+		if len(rules) == 0 {
+			return nil
+		}
+
+		return netOutNotSupported(cfg.Mode)
+	}
+
+	if err := n.firewallOpener.BulkOpen(log, cfg.IPTableInstance, handle, rules); err != nil {
+		return err
+	}
+
+	return addNetOutRules(n.configStore, handle, rules)
 }
 
 func (n *networker) Destroy(log lager.Logger, handle string) error {
+	n.locks.Lock(handle)
+	defer n.locks.Unlock(handle)
+
 	cfg, err := load(n.configStore, handle)
 	if err != nil {
 		log.Error("no-properties-for-container-skipping-destroy-network", err)
 		return nil
 	}
 
-	if err := n.configurer.DestroyIPTablesRules(log, cfg); err != nil {
+	// stop drift detection from recreating the rules we are about to remove
+	n.configStore.Set(handle, netOutRulesKey, "")
+
+	if err := n.attacher.Detach(log, handle); err != nil {
+		return err
+	}
+
+	configurer := n.configurerFor(cfg.Pool)
+	if err := configurer.DestroyIPTablesRules(log, cfg); err != nil {
 		return err
 	}
 
-	if err := n.subnetPool.Release(cfg.Subnet, cfg.ContainerIP); err != nil && err != subnets.ErrReleasedUnallocatedSubnet {
+	subnetPool, err := n.subnetPoolFor(cfg.Pool)
+	if err != nil {
+		log.Error("select-pool-failed", err)
+		return err
+	}
+
+	if err := subnetPool.Release(cfg.Subnet, cfg.ContainerIP); err != nil && err != subnets.ErrReleasedUnallocatedSubnet {
 		log.Error("release-failed", err)
 		return err
 	}
@@ -266,12 +507,12 @@ func (n *networker) Destroy(log lager.Logger, handle string) error {
 		}
 
 		for _, m := range mappings {
-			n.portPool.Release(m.HostPort)
+			n.portPool.Release(cfg.ExternalIP, m.HostPort)
 		}
 	}
 
-	err = n.subnetPool.RunIfFree(cfg.Subnet, func() error {
-		return n.configurer.DestroyBridge(log, cfg)
+	err = subnetPool.RunIfFree(cfg.Subnet, func() error {
+		return configurer.DestroyBridge(log, cfg)
 	})
 
 	return err
@@ -283,11 +524,20 @@ func (n *networker) Restore(log lager.Logger, handle string) error {
 		return fmt.Errorf("loading %s: %v", handle, err)
 	}
 
-	err = n.subnetPool.Remove(networkConfig.Subnet, networkConfig.ContainerIP)
+	subnetPool, err := n.subnetPoolFor(networkConfig.Pool)
+	if err != nil {
+		return fmt.Errorf("restoring %s: %v", handle, err)
+	}
+
+	err = subnetPool.Remove(networkConfig.Subnet, networkConfig.ContainerIP)
 	if err != nil {
 		return fmt.Errorf("subnet pool removing %s: %v", handle, err)
 	}
 
+	if err := n.attacher.Restore(log, handle); err != nil {
+		return err
+	}
+
 	currentMappingsJson, ok := n.configStore.Get(handle, gardener.MappedPortsKey)
 	if !ok {
 		return nil
@@ -299,7 +549,19 @@ func (n *networker) Restore(log lager.Logger, handle string) error {
 	}
 
 	for _, mapping := range currentMappings {
-		if err = n.portPool.Remove(mapping.HostPort); err != nil {
+		err = n.portPool.Remove(handle, networkConfig.ExternalIP, mapping.HostPort)
+		if _, unknown := err.(ports.UnknownExternalIPError); unknown {
+			// the external IP has changed since the port was mapped, so
+			// there is no longer a range to reserve it in
+			log.Info("skipping-port-for-unknown-external-ip", lager.Data{
+				"handle":      handle,
+				"external-ip": networkConfig.ExternalIP.String(),
+				"port":        mapping.HostPort,
+			})
+			continue
+		}
+
+		if err != nil {
 			return fmt.Errorf("port pool removing %s: %v", handle, err)
 		}
 	}
@@ -307,6 +569,12 @@ func (n *networker) Restore(log lager.Logger, handle string) error {
 	return nil
 }
 
+// traffic from directly attached containers never passes through the host's
+// firewall, so egress rules cannot be enforced
+func netOutNotSupported(mode string) error {
+	return fmt.Errorf("net-out is not supported in %s mode: traffic does not pass through the host firewall", mode)
+}
+
 func AddPortMapping(logger lager.Logger, configStore ConfigStore, handle string, newMapping garden.PortMapping) error {
 	var currentMappings portMappingList
 	if currentMappingsJson, ok := configStore.Get(handle, gardener.MappedPortsKey); ok {
@@ -322,6 +590,32 @@ func AddPortMapping(logger lager.Logger, configStore ConfigStore, handle string,
 	return nil
 }
 
+func addNetOutRules(configStore ConfigStore, handle string, rules []garden.NetOutRule) error {
+	currentRules, err := netOutRules(configStore, handle)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(append(currentRules, rules...))
+	if err != nil {
+		return err
+	}
+
+	configStore.Set(handle, netOutRulesKey, string(b))
+	return nil
+}
+
+func netOutRules(configStore ConfigStore, handle string) ([]garden.NetOutRule, error) {
+	rules := []garden.NetOutRule{}
+	if rulesJson, ok := configStore.Get(handle, netOutRulesKey); ok && rulesJson != "" {
+		if err := json.Unmarshal([]byte(rulesJson), &rules); err != nil {
+			return nil, fmt.Errorf("parsing net-out rules: %s", err)
+		}
+	}
+
+	return rules, nil
+}
+
 func getAll(config ConfigStore, handle string, key ...string) (vals []string, err error) {
 	for _, k := range key {
 		v, ok := config.Get(handle, k)
@@ -354,6 +648,23 @@ func save(config ConfigStore, handle string, netConfig NetworkConfig) {
 
 	config.Set(handle, dnsServerKey, strings.Join(dnsServers, ", "))
 	config.Set(handle, hostEntriesKey, strings.Join(netConfig.AdditionalHostEntries, ", "))
+
+	if netConfig.Pool != "" {
+		config.Set(handle, poolKey, netConfig.Pool)
+	}
+
+	if netConfig.Mode != "" {
+		config.Set(handle, modeKey, netConfig.Mode)
+	}
+
+	if netConfig.EgressIP != nil {
+		config.Set(handle, egressIpKey, netConfig.EgressIP.String())
+	}
+
+	if netConfig.ConnectionLimits.IsSet() {
+		config.Set(handle, maxConnectionsKey, strconv.Itoa(netConfig.ConnectionLimits.MaxConnections))
+		config.Set(handle, connectionRateKey, strconv.Itoa(netConfig.ConnectionLimits.Rate))
+	}
 }
 
 func appendIfNotNil(errors []error, err error) []error {
@@ -395,22 +706,60 @@ func load(config ConfigStore, handle string) (NetworkConfig, error) {
 
 	additionalHostEntries := strings.Split(vals[11], ", ")
 
+	pool, _ := config.Get(handle, poolKey)
+	mode, _ := config.Get(handle, modeKey)
+
+	var egressIP net.IP
+	if egressIPValue, ok := config.Get(handle, egressIpKey); ok && egressIPValue != "" {
+		if egressIP = net.ParseIP(egressIPValue); egressIP == nil {
+			return NetworkConfig{}, fmt.Errorf("invalid egress ip: %s", egressIPValue)
+		}
+	}
+
+	limits, err := loadConnectionLimits(config, handle)
+	if err != nil {
+		return NetworkConfig{}, err
+	}
+
 	return NetworkConfig{
+		Pool:                  pool,
+		Mode:                  mode,
 		HostIntf:              vals[0],
 		ContainerIntf:         vals[1],
 		BridgeName:            vals[2],
 		BridgeIP:              net.ParseIP(vals[3]),
 		ContainerIP:           net.ParseIP(vals[4]),
 		ExternalIP:            net.ParseIP(vals[9]),
+		EgressIP:              egressIP,
 		Subnet:                ipnet,
 		IPTablePrefix:         vals[6],
 		IPTableInstance:       vals[7],
 		Mtu:                   mtu,
+		ConnectionLimits:      limits,
 		OperatorNameservers:   dnsServers,
 		AdditionalHostEntries: additionalHostEntries,
 	}, nil
 }
 
+// loadConnectionLimits returns no limits for containers created before
+// connection limits were recorded
+func loadConnectionLimits(config ConfigStore, handle string) (ConnectionLimits, error) {
+	var limits ConnectionLimits
+	for key, limit := range map[string]*int{maxConnectionsKey: &limits.MaxConnections, connectionRateKey: &limits.Rate} {
+		value, ok := config.Get(handle, key)
+		if !ok || value == "" {
+			continue
+		}
+
+		var err error
+		if *limit, err = strconv.Atoi(value); err != nil {
+			return ConnectionLimits{}, fmt.Errorf("invalid %s: %s", key, value)
+		}
+	}
+
+	return limits, nil
+}
+
 type portMappingList []garden.PortMapping
 
 func (l portMappingList) toJson() string {
@@ -11,24 +11,33 @@ import (
 	"code.cloudfoundry.org/guardian/gardener"
 	"code.cloudfoundry.org/guardian/kawasaki"
 	fakes "code.cloudfoundry.org/guardian/kawasaki/kawasakifakes"
+	"code.cloudfoundry.org/guardian/kawasaki/ports"
 	"code.cloudfoundry.org/guardian/kawasaki/subnets"
 	"code.cloudfoundry.org/guardian/kawasaki/subnets/fake_subnet_pool"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("Networker", func() {
 	var (
 		fakeSpecParser     *fakes.FakeSpecParser
 		fakeSubnetPool     *fake_subnet_pool.FakePool
+		fakeIsolatedPool   *fake_subnet_pool.FakePool
+		fakeEgressPool     *fake_subnet_pool.FakePool
+		fakeDirectPool     *fake_subnet_pool.FakePool
+		fakeDirectConfig   *fakes.FakeConfigurer
+		fakeOverlayPool    *fake_subnet_pool.FakePool
+		fakeOverlayConfig  *fakes.FakeConfigurer
 		fakeConfigCreator  *fakes.FakeConfigCreator
 		fakeConfigStore    *fakes.FakeConfigStore
 		fakePortForwarder  *fakes.FakePortForwarder
 		fakePortPool       *fakes.FakePortPool
 		fakeFirewallOpener *fakes.FakeFirewallOpener
 		fakeConfigurer     *fakes.FakeConfigurer
+		fakeAttacher       *fakes.FakeAttacher
 		containerSpec      garden.ContainerSpec
 		networker          kawasaki.Networker
 		logger             lager.Logger
@@ -39,12 +48,19 @@ var _ = Describe("Networker", func() {
 	BeforeEach(func() {
 		fakeSpecParser = new(fakes.FakeSpecParser)
 		fakeSubnetPool = new(fake_subnet_pool.FakePool)
+		fakeIsolatedPool = new(fake_subnet_pool.FakePool)
+		fakeEgressPool = new(fake_subnet_pool.FakePool)
+		fakeDirectPool = new(fake_subnet_pool.FakePool)
+		fakeDirectConfig = new(fakes.FakeConfigurer)
+		fakeOverlayPool = new(fake_subnet_pool.FakePool)
+		fakeOverlayConfig = new(fakes.FakeConfigurer)
 		fakeConfigCreator = new(fakes.FakeConfigCreator)
 		fakeConfigStore = new(fakes.FakeConfigStore)
 		fakePortForwarder = new(fakes.FakePortForwarder)
 		fakePortPool = new(fakes.FakePortPool)
 		fakeFirewallOpener = new(fakes.FakeFirewallOpener)
 		fakeConfigurer = new(fakes.FakeConfigurer)
+		fakeAttacher = new(fakes.FakeAttacher)
 
 		containerSpec = garden.ContainerSpec{
 			Handle:  "some-handle",
@@ -73,16 +89,55 @@ var _ = Describe("Networker", func() {
 			},
 		}
 
+		_, directNetwork, err := net.ParseCIDR("10.200.0.0/24")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, overlayNetwork, err := net.ParseCIDR("10.210.0.0/16")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, overlayAllocationRange, err := net.ParseCIDR("10.210.2.0/24")
+		Expect(err).NotTo(HaveOccurred())
+
 		logger = lagertest.NewTestLogger("test")
 		networker = kawasaki.New(
 			fakeSpecParser,
 			fakeSubnetPool,
+			map[string]kawasaki.NetworkPool{
+				"isolated": {
+					Spec:    kawasaki.NetworkPoolSpec{Name: "isolated", Mtu: 1400},
+					Subnets: fakeIsolatedPool,
+				},
+				"egress": {
+					Spec:     kawasaki.NetworkPoolSpec{Name: "egress", EgressIP: "5.6.7.10"},
+					EgressIP: net.ParseIP("5.6.7.10"),
+					Subnets:  fakeEgressPool,
+				},
+				"direct": {
+					Spec:       kawasaki.NetworkPoolSpec{Name: "direct", Mode: "macvlan"},
+					Network:    directNetwork,
+					Gateway:    net.ParseIP("10.200.0.254"),
+					Subnets:    fakeDirectPool,
+					Configurer: fakeDirectConfig,
+				},
+				"overlay": {
+					Spec:            kawasaki.NetworkPoolSpec{Name: "overlay", Mode: "vxlan", VNI: 42, Mtu: 8950},
+					Network:         overlayNetwork,
+					Gateway:         net.ParseIP("10.210.2.0"),
+					AllocationRange: overlayAllocationRange,
+					Subnets:         fakeOverlayPool,
+					Configurer:      fakeOverlayConfig,
+				},
+			},
 			fakeConfigCreator,
 			fakeConfigStore,
 			fakeConfigurer,
 			fakePortPool,
 			fakePortForwarder,
 			fakeFirewallOpener,
+			fakeAttacher,
+			[]net.IP{net.ParseIP("5.6.7.8"), net.ParseIP("5.6.7.9")},
+			9000,
+			kawasaki.NewHandleLocks(),
 		)
 
 		ip, subnet, err := net.ParseCIDR("123.123.123.12/24")
@@ -242,6 +297,408 @@ var _ = Describe("Networker", func() {
 			})
 		})
 
+		Context("when a named pool is requested", func() {
+			var storedConfig map[string]string
+
+			BeforeEach(func() {
+				containerSpec.Network = "pool:isolated"
+
+				storedConfig = make(map[string]string)
+				fakeConfigStore.SetStub = func(handle, name, value string) {
+					storedConfig[name] = value
+				}
+			})
+
+			It("parses an empty spec so that the subnet is allocated dynamically", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				_, spec := fakeSpecParser.ParseArgsForCall(0)
+				Expect(spec).To(Equal(""))
+			})
+
+			It("acquires the subnet from the named pool", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(fakeIsolatedPool.AcquireCallCount()).To(Equal(1))
+				Expect(fakeSubnetPool.AcquireCallCount()).To(Equal(0))
+			})
+
+			It("uses the MTU of the pool", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				_, appliedConfig, _ := fakeConfigurer.ApplyArgsForCall(0)
+				Expect(appliedConfig.Mtu).To(Equal(1400))
+				Expect(appliedConfig.Pool).To(Equal("isolated"))
+			})
+
+			It("records the pool in the config store", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(storedConfig).To(HaveKeyWithValue("kawasaki.pool", "isolated"))
+				Expect(storedConfig).To(HaveKeyWithValue("kawasaki.mtu", "1400"))
+			})
+
+			Context("when the pool does not exist", func() {
+				BeforeEach(func() {
+					containerSpec.Network = "pool:nope"
+				})
+
+				It("returns an error", func() {
+					Expect(networker.Network(logger, containerSpec, 42)).To(MatchError("unknown network pool: nope"))
+					Expect(fakeIsolatedPool.AcquireCallCount()).To(Equal(0))
+					Expect(fakeSubnetPool.AcquireCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when the spec overrides the MTU", func() {
+			var storedConfig map[string]string
+
+			BeforeEach(func() {
+				containerSpec.Network = "1.2.3.4/30,mtu=9000"
+
+				storedConfig = make(map[string]string)
+				fakeConfigStore.SetStub = func(handle, name, value string) {
+					storedConfig[name] = value
+				}
+			})
+
+			It("parses the spec without the options", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				_, spec := fakeSpecParser.ParseArgsForCall(0)
+				Expect(spec).To(Equal("1.2.3.4/30"))
+			})
+
+			It("applies and records the MTU", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				_, appliedConfig, _ := fakeConfigurer.ApplyArgsForCall(0)
+				Expect(appliedConfig.Mtu).To(Equal(9000))
+				Expect(storedConfig).To(HaveKeyWithValue("kawasaki.mtu", "9000"))
+			})
+
+			It("takes precedence over the MTU of the pool", func() {
+				containerSpec.Network = "pool:isolated,mtu=8000"
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				_, appliedConfig, _ := fakeConfigurer.ApplyArgsForCall(0)
+				Expect(appliedConfig.Mtu).To(Equal(8000))
+				Expect(appliedConfig.Pool).To(Equal("isolated"))
+			})
+
+			Context("when the MTU is larger than the host interface's", func() {
+				BeforeEach(func() {
+					containerSpec.Network = "mtu=9001"
+				})
+
+				It("returns an error without acquiring a subnet", func() {
+					Expect(networker.Network(logger, containerSpec, 42)).To(MatchError("mtu 9001 must be between 68 and the host interface mtu of 9000"))
+					Expect(fakeSubnetPool.AcquireCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the MTU is too small", func() {
+				BeforeEach(func() {
+					containerSpec.Network = "mtu=10"
+				})
+
+				It("returns an error", func() {
+					Expect(networker.Network(logger, containerSpec, 42)).To(MatchError("mtu 10 must be between 68 and the host interface mtu of 9000"))
+				})
+			})
+
+			Context("when the options cannot be parsed", func() {
+				BeforeEach(func() {
+					containerSpec.Network = "mtu=big"
+				})
+
+				It("returns an error", func() {
+					Expect(networker.Network(logger, containerSpec, 42)).To(MatchError("invalid mtu in network spec: big"))
+				})
+			})
+		})
+
+		Context("when the spec sets connection limits", func() {
+			var storedConfig map[string]string
+
+			BeforeEach(func() {
+				networkConfig.ConnectionLimits = kawasaki.ConnectionLimits{MaxConnections: 500, Rate: 50}
+				fakeConfigCreator.CreateReturns(networkConfig, nil)
+
+				containerSpec.Network = "1.2.3.4/30,max-conns=100"
+
+				storedConfig = make(map[string]string)
+				fakeConfigStore.SetStub = func(handle, name, value string) {
+					storedConfig[name] = value
+				}
+			})
+
+			It("overrides only the given default limits", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				_, appliedConfig, _ := fakeConfigurer.ApplyArgsForCall(0)
+				Expect(appliedConfig.ConnectionLimits).To(Equal(kawasaki.ConnectionLimits{MaxConnections: 100, Rate: 50}))
+			})
+
+			It("records the limits in the config store", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(storedConfig).To(HaveKeyWithValue("kawasaki.max-connections", "100"))
+				Expect(storedConfig).To(HaveKeyWithValue("kawasaki.connection-rate", "50"))
+			})
+
+			Context("when a limit is set to zero", func() {
+				BeforeEach(func() {
+					containerSpec.Network = "1.2.3.4/30,conn-rate=0"
+				})
+
+				It("removes the default limit", func() {
+					Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+					_, appliedConfig, _ := fakeConfigurer.ApplyArgsForCall(0)
+					Expect(appliedConfig.ConnectionLimits).To(Equal(kawasaki.ConnectionLimits{MaxConnections: 500}))
+				})
+			})
+
+			Context("when the container is in a macvlan pool", func() {
+				BeforeEach(func() {
+					containerSpec.Network = "pool:direct,max-conns=100"
+				})
+
+				It("returns an error without acquiring an address", func() {
+					Expect(networker.Network(logger, containerSpec, 42)).To(MatchError("connection limits are not supported in macvlan mode: traffic does not pass through the host firewall"))
+					Expect(fakeDirectPool.AcquireCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when the container requests an egress IP", func() {
+			var storedConfig map[string]string
+
+			BeforeEach(func() {
+				containerSpec.Properties = garden.Properties{gardener.EgressIPKey: "5.6.7.9"}
+
+				storedConfig = make(map[string]string)
+				fakeConfigStore.SetStub = func(handle, name, value string) {
+					storedConfig[name] = value
+				}
+			})
+
+			It("applies the configuration with the egress IP", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				_, appliedConfig, _ := fakeConfigurer.ApplyArgsForCall(0)
+				Expect(appliedConfig.EgressIP).To(Equal(net.ParseIP("5.6.7.9")))
+			})
+
+			It("records the egress IP in the config store", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(storedConfig).To(HaveKeyWithValue(gardener.EgressIPKey, "5.6.7.9"))
+			})
+
+			It("prefers the requested egress IP to the pool's", func() {
+				containerSpec.Network = "pool:egress"
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				_, appliedConfig, _ := fakeConfigurer.ApplyArgsForCall(0)
+				Expect(appliedConfig.EgressIP).To(Equal(net.ParseIP("5.6.7.9")))
+			})
+
+			Context("when the egress IP is not one of the configured egress IPs", func() {
+				BeforeEach(func() {
+					containerSpec.Properties = garden.Properties{gardener.EgressIPKey: "9.9.9.9"}
+				})
+
+				It("returns an error without acquiring a subnet", func() {
+					Expect(networker.Network(logger, containerSpec, 42)).To(MatchError("egress ip 9.9.9.9 is not one of the configured egress ips"))
+					Expect(fakeSubnetPool.AcquireCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the egress IP is not an IP address", func() {
+				BeforeEach(func() {
+					containerSpec.Properties = garden.Properties{gardener.EgressIPKey: "banana"}
+				})
+
+				It("returns an error", func() {
+					Expect(networker.Network(logger, containerSpec, 42)).To(MatchError("invalid egress ip: banana"))
+				})
+			})
+		})
+
+		Context("when the container's pool has an egress IP", func() {
+			BeforeEach(func() {
+				containerSpec.Network = "pool:egress"
+			})
+
+			It("applies the configuration with the pool's egress IP", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				_, appliedConfig, _ := fakeConfigurer.ApplyArgsForCall(0)
+				Expect(appliedConfig.EgressIP).To(Equal(net.ParseIP("5.6.7.10")))
+			})
+		})
+
+		It("does not source NAT to an egress IP by default", func() {
+			Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+			_, appliedConfig, _ := fakeConfigurer.ApplyArgsForCall(0)
+			Expect(appliedConfig.EgressIP).To(BeNil())
+		})
+
+		Context("when the port pool selects an external IP", func() {
+			BeforeEach(func() {
+				fakePortPool.ExternalIPReturns(net.ParseIP("128.128.90.91"))
+			})
+
+			It("applies the configuration with the selected external IP", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				_, appliedConfig, _ := fakeConfigurer.ApplyArgsForCall(0)
+				Expect(appliedConfig.ExternalIP).To(Equal(net.ParseIP("128.128.90.91")))
+			})
+
+			It("records the selected external IP in the config store", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(config[gardener.ExternalIPKey]).To(Equal("128.128.90.91"))
+			})
+
+			Context("when a macvlan pool is requested", func() {
+				BeforeEach(func() {
+					containerSpec.Network = "pool:direct"
+					containerSpec.NetIn = nil
+					containerSpec.NetOut = nil
+				})
+
+				It("uses the container's own IP", func() {
+					Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+					_, appliedConfig, _ := fakeDirectConfig.ApplyArgsForCall(0)
+					Expect(appliedConfig.ExternalIP).To(Equal(appliedConfig.ContainerIP))
+				})
+			})
+		})
+
+		Context("when a vxlan pool is requested", func() {
+			BeforeEach(func() {
+				containerSpec.Network = "pool:overlay"
+				containerSpec.NetIn = nil
+				containerSpec.NetOut = nil
+			})
+
+			It("acquires an address from the host's allocation range of the pool's whole network", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(fakeOverlayPool.AcquireCallCount()).To(Equal(1))
+
+				_, subnetSelector, ipSelector := fakeOverlayPool.AcquireArgsForCall(0)
+				subnet, err := subnetSelector.SelectSubnet(nil, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(subnet.String()).To(Equal("10.210.0.0/16"))
+
+				ip, err := ipSelector.SelectIP(subnet, []net.IP{net.ParseIP("10.210.2.1")})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ip.String()).To(Equal("10.210.2.2"), "skips the gateway and allocated addresses")
+			})
+
+			It("applies the configuration with the pool's configurer, routing via this host's gateway", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(fakeConfigurer.ApplyCallCount()).To(Equal(0))
+				Expect(fakeOverlayConfig.ApplyCallCount()).To(Equal(1))
+
+				_, appliedConfig, _ := fakeOverlayConfig.ApplyArgsForCall(0)
+				Expect(appliedConfig.Mode).To(Equal("vxlan"))
+				Expect(appliedConfig.BridgeName).To(Equal(networkConfig.BridgeName))
+				Expect(appliedConfig.BridgeIP.String()).To(Equal("10.210.2.0"))
+				Expect(appliedConfig.ExternalIP).To(Equal(networkConfig.ExternalIP))
+				Expect(appliedConfig.Mtu).To(Equal(8950))
+			})
+
+			It("opens NetOut rules as in bridge mode", func() {
+				containerSpec.NetOut = []garden.NetOutRule{{Protocol: garden.ProtocolTCP}}
+
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(fakeFirewallOpener.BulkOpenCallCount()).To(Equal(1))
+			})
+
+			Context("when the spec requests an MTU larger than the overlay can carry", func() {
+				BeforeEach(func() {
+					containerSpec.Network = "pool:overlay,mtu=9000"
+				})
+
+				It("returns an error without acquiring an address", func() {
+					Expect(networker.Network(logger, containerSpec, 42)).To(MatchError("mtu 9000 exceeds the mtu of 8950 available within the vxlan overlay"))
+					Expect(fakeOverlayPool.AcquireCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when a macvlan pool is requested", func() {
+			BeforeEach(func() {
+				containerSpec.Network = "pool:direct"
+				containerSpec.NetIn = nil
+				containerSpec.NetOut = nil
+
+				config["kawasaki.pool"] = "direct"
+				config["kawasaki.mode"] = "macvlan"
+			})
+
+			It("acquires an address from the pool's whole network", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(fakeDirectPool.AcquireCallCount()).To(Equal(1))
+
+				_, subnetSelector, _ := fakeDirectPool.AcquireArgsForCall(0)
+				subnet, err := subnetSelector.SelectSubnet(nil, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(subnet.String()).To(Equal("10.200.0.0/24"))
+			})
+
+			It("applies the configuration with the pool's configurer", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(fakeConfigurer.ApplyCallCount()).To(Equal(0))
+				Expect(fakeDirectConfig.ApplyCallCount()).To(Equal(1))
+
+				_, appliedConfig, _ := fakeDirectConfig.ApplyArgsForCall(0)
+				Expect(appliedConfig.Mode).To(Equal("macvlan"))
+				Expect(appliedConfig.BridgeName).To(BeEmpty())
+				Expect(appliedConfig.BridgeIP.String()).To(Equal("10.200.0.254"))
+				Expect(appliedConfig.ExternalIP).To(Equal(appliedConfig.ContainerIP))
+			})
+
+			It("does not apply the default connection limits", func() {
+				networkConfig.ConnectionLimits = kawasaki.ConnectionLimits{MaxConnections: 500}
+				fakeConfigCreator.CreateReturns(networkConfig, nil)
+
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				_, appliedConfig, _ := fakeDirectConfig.ApplyArgsForCall(0)
+				Expect(appliedConfig.ConnectionLimits.IsSet()).To(BeFalse())
+			})
+
+			It("does not open any firewall rules", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(fakeFirewallOpener.BulkOpenCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when no attachment networks are requested", func() {
+			It("does not attach any additional interfaces", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(fakeAttacher.AttachCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when attachment networks are requested", func() {
+			BeforeEach(func() {
+				containerSpec.Properties = garden.Properties{
+					gardener.NetworkAttachmentsKey: "data, mgmt",
+				}
+			})
+
+			It("attaches the container to each of them", func() {
+				Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(fakeAttacher.AttachCallCount()).To(Equal(1))
+
+				_, handle, networks, pid := fakeAttacher.AttachArgsForCall(0)
+				Expect(handle).To(Equal("some-handle"))
+				Expect(networks).To(Equal([]string{"data", "mgmt"}))
+				Expect(pid).To(Equal(42))
+			})
+
+			Context("when attaching fails", func() {
+				BeforeEach(func() {
+					fakeAttacher.AttachReturns(errors.New("no-such-network"))
+				})
+
+				It("returns the error", func() {
+					Expect(networker.Network(logger, containerSpec, 42)).To(MatchError("no-such-network"))
+				})
+			})
+		})
+
 		It("opens any NetOut rules provided on the firewall", func() {
 			Expect(networker.Network(logger, containerSpec, 42)).To(Succeed())
 			_, _, _, appliedRules := fakeFirewallOpener.BulkOpenArgsForCall(0)
@@ -271,6 +728,11 @@ var _ = Describe("Networker", func() {
 			Expect(fakeSubnetPool.CapacityCallCount()).To(Equal(1))
 			Expect(cap).To(BeEquivalentTo(9000))
 		})
+
+		It("includes the capacity of the named pools", func() {
+			fakeIsolatedPool.CapacityReturns(16)
+			Expect(networker.Capacity()).To(BeEquivalentTo(9016))
+		})
 	})
 
 	Describe("Destroy", func() {
@@ -281,6 +743,70 @@ var _ = Describe("Networker", func() {
 			})
 		})
 
+		It("clears the recorded net-out rules so the rules are not repaired", func() {
+			Expect(networker.Destroy(logger, "some-handle")).To(Succeed())
+
+			Expect(fakeConfigStore.SetCallCount()).To(Equal(1))
+			_, name, value := fakeConfigStore.SetArgsForCall(0)
+			Expect(name).To(Equal("kawasaki.net-out-rules"))
+			Expect(value).To(BeEmpty())
+		})
+
+		It("detaches any attachment networks", func() {
+			Expect(networker.Destroy(logger, "some-handle")).To(Succeed())
+
+			Expect(fakeAttacher.DetachCallCount()).To(Equal(1))
+			_, handle := fakeAttacher.DetachArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+		})
+
+		Context("when detaching fails", func() {
+			It("returns the error", func() {
+				fakeAttacher.DetachReturns(errors.New("oh no"))
+				Expect(networker.Destroy(logger, "some-handle")).To(MatchError("oh no"))
+			})
+		})
+
+		It("destroys the iptables rules with the recorded connection limits", func() {
+			config["kawasaki.max-connections"] = "100"
+			config["kawasaki.connection-rate"] = "0"
+
+			Expect(networker.Destroy(logger, "some-handle")).To(Succeed())
+
+			Expect(fakeConfigurer.DestroyIPTablesRulesCallCount()).To(Equal(1))
+			_, destroyedConfig := fakeConfigurer.DestroyIPTablesRulesArgsForCall(0)
+			Expect(destroyedConfig.ConnectionLimits).To(Equal(kawasaki.ConnectionLimits{MaxConnections: 100}))
+		})
+
+		Context("when the container is in a pool with its own configurer", func() {
+			BeforeEach(func() {
+				config["kawasaki.pool"] = "direct"
+				config["kawasaki.mode"] = "macvlan"
+			})
+
+			It("destroys the network with the pool's configurer", func() {
+				Expect(networker.Destroy(logger, "some-handle")).To(Succeed())
+
+				Expect(fakeDirectConfig.DestroyIPTablesRulesCallCount()).To(Equal(1))
+				Expect(fakeConfigurer.DestroyIPTablesRulesCallCount()).To(Equal(0))
+				Expect(fakeDirectPool.ReleaseCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the container is in a named pool", func() {
+			BeforeEach(func() {
+				config["kawasaki.pool"] = "isolated"
+			})
+
+			It("releases the subnet to the named pool", func() {
+				Expect(networker.Destroy(logger, "some-handle")).To(Succeed())
+
+				Expect(fakeIsolatedPool.ReleaseCallCount()).To(Equal(1))
+				Expect(fakeSubnetPool.ReleaseCallCount()).To(Equal(0))
+				Expect(fakeIsolatedPool.RunIfFreeCallCount()).To(Equal(1))
+			})
+		})
+
 		It("releases the subnet", func() {
 			Expect(networker.Destroy(logger, "some-handle")).To(Succeed())
 
@@ -324,8 +850,14 @@ var _ = Describe("Networker", func() {
 
 				Expect(networker.Destroy(logger, "some-handle")).To(Succeed())
 				Expect(fakePortPool.ReleaseCallCount()).To(Equal(2))
-				Expect(fakePortPool.ReleaseArgsForCall(0)).To(BeEquivalentTo(123))
-				Expect(fakePortPool.ReleaseArgsForCall(1)).To(BeEquivalentTo(456))
+
+				externalIP, port := fakePortPool.ReleaseArgsForCall(0)
+				Expect(externalIP).To(Equal(networkConfig.ExternalIP))
+				Expect(port).To(BeEquivalentTo(123))
+
+				externalIP, port = fakePortPool.ReleaseArgsForCall(1)
+				Expect(externalIP).To(Equal(networkConfig.ExternalIP))
+				Expect(port).To(BeEquivalentTo(456))
 			})
 
 			It("returns an error if the ports property is not valid JSON", func() {
@@ -392,6 +924,14 @@ var _ = Describe("Networker", func() {
 	})
 
 	Describe("NetOut", func() {
+		Context("when the container is attached directly with macvlan", func() {
+			It("returns an error", func() {
+				config["kawasaki.mode"] = "macvlan"
+				Expect(networker.NetOut(logger, "some-handle", garden.NetOutRule{})).To(MatchError(ContainSubstring("net-out is not supported in macvlan mode")))
+				Expect(fakeFirewallOpener.OpenCallCount()).To(Equal(0))
+			})
+		})
+
 		It("delegates to FirewallOpener", func() {
 			rule := garden.NetOutRule{Protocol: garden.ProtocolICMP}
 
@@ -403,9 +943,41 @@ var _ = Describe("Networker", func() {
 			Expect(handleArg).To(Equal("some-handle"))
 			Expect(ruleArg).To(Equal(rule))
 		})
+
+		It("records the rule in the ConfigStore", func() {
+			config["kawasaki.net-out-rules"] = `[{"protocol":1}]`
+			Expect(networker.NetOut(logger, "some-handle", garden.NetOutRule{Protocol: garden.ProtocolICMP})).To(Succeed())
+
+			Expect(fakeConfigStore.SetCallCount()).To(Equal(1))
+			_, name, value := fakeConfigStore.SetArgsForCall(0)
+			Expect(name).To(Equal("kawasaki.net-out-rules"))
+			Expect(value).To(Equal(`[{"protocol":1},{"protocol":3}]`))
+		})
+
+		It("does not record the rule when it cannot be applied", func() {
+			fakeFirewallOpener.OpenReturns(errors.New("potato"))
+			Expect(networker.NetOut(logger, "some-handle", garden.NetOutRule{})).NotTo(Succeed())
+			Expect(fakeConfigStore.SetCallCount()).To(Equal(0))
+		})
 	})
 
 	Describe("BulkNetOut", func() {
+		Context("when the container is attached directly with ipvlan", func() {
+			BeforeEach(func() {
+				config["kawasaki.mode"] = "ipvlan"
+			})
+
+			It("succeeds when there are no rules", func() {
+				Expect(networker.BulkNetOut(logger, "some-handle", nil)).To(Succeed())
+				Expect(fakeFirewallOpener.BulkOpenCallCount()).To(Equal(0))
+			})
+
+			It("returns an error when there are rules", func() {
+				rules := []garden.NetOutRule{{Protocol: garden.ProtocolTCP}}
+				Expect(networker.BulkNetOut(logger, "some-handle", rules)).To(MatchError(ContainSubstring("net-out is not supported in ipvlan mode")))
+			})
+		})
+
 		It("delegates to FirewallOpener", func() {
 			rules := []garden.NetOutRule{
 				{Protocol: garden.ProtocolICMP},
@@ -420,6 +992,15 @@ var _ = Describe("Networker", func() {
 			Expect(handleArg).To(Equal("some-handle"))
 			Expect(rulesArg).To(Equal(rules))
 		})
+
+		It("records the rules in the ConfigStore, even when there are none", func() {
+			Expect(networker.BulkNetOut(logger, "some-handle", nil)).To(Succeed())
+
+			Expect(fakeConfigStore.SetCallCount()).To(Equal(1))
+			_, name, value := fakeConfigStore.SetArgsForCall(0)
+			Expect(name).To(Equal("kawasaki.net-out-rules"))
+			Expect(value).To(Equal(`[]`))
+		})
 	})
 
 	Describe("NetIn", func() {
@@ -436,6 +1017,16 @@ var _ = Describe("Networker", func() {
 			handle = "some-handle"
 		})
 
+		Context("when the container is attached directly with macvlan", func() {
+			It("returns an error", func() {
+				config["kawasaki.mode"] = "macvlan"
+
+				_, _, err := networker.NetIn(logger, handle, externalPort, containerPort)
+				Expect(err).To(MatchError("net-in is not supported in macvlan mode: the container is reachable directly at 123.123.123.12"))
+				Expect(fakePortForwarder.ForwardCallCount()).To(Equal(0))
+			})
+		})
+
 		It("calls the PortForwarder with correct parameters", func() {
 			_, _, err := networker.NetIn(logger, handle, externalPort, containerPort)
 			Expect(err).NotTo(HaveOccurred())
@@ -462,6 +1053,10 @@ var _ = Describe("Networker", func() {
 				Expect(actualContainerPort).To(Equal(containerPort))
 
 				Expect(fakePortPool.AcquireCallCount()).To(Equal(1))
+				acquiringHandle, externalIP := fakePortPool.AcquireArgsForCall(0)
+				Expect(acquiringHandle).To(Equal(handle))
+				Expect(externalIP).To(Equal(networkConfig.ExternalIP))
+
 				Expect(fakePortForwarder.ForwardCallCount()).To(Equal(1))
 				spec := fakePortForwarder.ForwardArgsForCall(0)
 
@@ -566,10 +1161,42 @@ var _ = Describe("Networker", func() {
 			Expect(calledContainerIP.String()).To(Equal("123.123.123.12"))
 		})
 
+		Context("when the container is in a named pool", func() {
+			It("removes the subnet from the named pool", func() {
+				config["kawasaki.pool"] = "isolated"
+
+				Expect(networker.Restore(logger, "some-handle")).To(Succeed())
+				Expect(fakeIsolatedPool.RemoveCallCount()).To(Equal(1))
+				Expect(fakeSubnetPool.RemoveCallCount()).To(Equal(0))
+			})
+
+			It("returns an error when the pool is no longer defined", func() {
+				config["kawasaki.pool"] = "gone"
+
+				Expect(networker.Restore(logger, "some-handle")).To(MatchError("restoring some-handle: unknown network pool: gone"))
+			})
+		})
+
+		It("restores the attachment networks", func() {
+			Expect(networker.Restore(logger, "some-handle")).To(Succeed())
+			Expect(fakeAttacher.RestoreCallCount()).To(Equal(1))
+			_, handle := fakeAttacher.RestoreArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+		})
+
+		Context("when restoring the attachment networks fails", func() {
+			It("returns the error", func() {
+				fakeAttacher.RestoreReturns(errors.New("oh no"))
+				Expect(networker.Restore(logger, "some-handle")).To(MatchError("oh no"))
+			})
+		})
+
 		It("removes the port from port mapping list", func() {
 			Expect(networker.Restore(logger, "some-handle")).To(Succeed())
 			Expect(fakePortPool.RemoveCallCount()).To(Equal(1))
-			calledPort := fakePortPool.RemoveArgsForCall(0)
+			calledHandle, calledIP, calledPort := fakePortPool.RemoveArgsForCall(0)
+			Expect(calledHandle).To(Equal("some-handle"))
+			Expect(calledIP).To(Equal(networkConfig.ExternalIP))
 			Expect(calledPort).To(BeEquivalentTo(60000))
 		})
 
@@ -617,5 +1244,16 @@ var _ = Describe("Networker", func() {
 				Expect(networker.Restore(logger, "some-handle")).To(MatchError("port pool removing some-handle: failed-to-remove-from-port-pool"))
 			})
 		})
+
+		Context("when the port pool no longer has a range for the external IP", func() {
+			BeforeEach(func() {
+				fakePortPool.RemoveReturns(ports.UnknownExternalIPError{IP: networkConfig.ExternalIP})
+			})
+
+			It("skips the port and logs it", func() {
+				Expect(networker.Restore(logger, "some-handle")).To(Succeed())
+				Expect(logger.(*lagertest.TestLogger)).To(gbytes.Say("skipping-port-for-unknown-external-ip"))
+			})
+		})
 	})
 })
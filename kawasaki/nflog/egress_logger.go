@@ -0,0 +1,130 @@
+package nflog
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-golang/clock"
+)
+
+// receiveRetryInterval is how long to wait before receiving again after an
+// unexpected error, so that a persistent failure does not spin
+const receiveRetryInterval = time.Second
+
+var (
+	// ErrClosed is returned by a Source once it has been closed
+	ErrClosed = errors.New("nflog: socket closed")
+
+	// ErrOverrun is returned by a Source when the kernel dropped packets
+	// because they were not received quickly enough
+	ErrOverrun = errors.New("nflog: receive buffer overrun")
+)
+
+//go:generate counterfeiter . Source
+
+// Source receives packets logged to an NFLOG group
+type Source interface {
+	Receive() ([]Message, error)
+}
+
+//go:generate counterfeiter . HandleResolver
+
+// HandleResolver finds the container owning the iptables instance which
+// logged a packet
+type HandleResolver interface {
+	Handle(instanceID string) (string, bool)
+}
+
+// EgressLogger turns packets logged by containers' net-out rules into
+// structured records
+type EgressLogger struct {
+	source   Source
+	resolver HandleResolver
+	sink     Sink
+	clock    clock.Clock
+	logger   lager.Logger
+
+	mu       sync.Mutex
+	overruns int
+}
+
+func NewEgressLogger(source Source, resolver HandleResolver, sink Sink, clock clock.Clock, logger lager.Logger) *EgressLogger {
+	return &EgressLogger{
+		source:   source,
+		resolver: resolver,
+		sink:     sink,
+		clock:    clock,
+		logger:   logger,
+	}
+}
+
+// Run emits a record for every logged packet until the source is closed. Any
+// other failure to receive is logged and receiving carries on, so that the
+// audit log does not stop for the life of gdn.
+func (l *EgressLogger) Run() {
+	log := l.logger.Session("egress-logger")
+
+	log.Info("started")
+	defer log.Info("finished")
+
+	for {
+		messages, err := l.source.Receive()
+		switch err {
+		case nil:
+		case ErrClosed:
+			return
+		case ErrOverrun:
+			log.Info("packets-dropped")
+			l.recordOverrun()
+			continue
+		default:
+			log.Error("receive-failed", err)
+			l.clock.Sleep(receiveRetryInterval)
+			continue
+		}
+
+		for _, message := range messages {
+			l.emit(log, message)
+		}
+	}
+}
+
+func (l *EgressLogger) recordOverrun() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.overruns++
+}
+
+// DroppedPackets returns the number of times the kernel has dropped logged
+// packets because they were not received quickly enough. Each time, one or
+// more packets were dropped.
+func (l *EgressLogger) DroppedPackets() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.overruns
+}
+
+func (l *EgressLogger) emit(log lager.Logger, message Message) {
+	record, err := ParsePacket(message.Payload)
+	if err != nil {
+		log.Error("parse-packet-failed", err, lager.Data{"prefix": message.Prefix})
+		return
+	}
+
+	// packets logged after a container was destroyed keep the instance ID
+	record.Handle = message.Prefix
+	if handle, ok := l.resolver.Handle(message.Prefix); ok {
+		record.Handle = handle
+	}
+
+	record.Time = message.Timestamp
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	if err := l.sink.Emit(record); err != nil {
+		log.Error("emit-failed", err, lager.Data{"handle": record.Handle})
+	}
+}
@@ -0,0 +1,173 @@
+package nflog_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/guardian/kawasaki/nflog"
+	"code.cloudfoundry.org/guardian/kawasaki/nflog/nflogfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/pivotal-golang/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("EgressLogger", func() {
+	var (
+		fakeSource   *nflogfakes.FakeSource
+		fakeResolver *nflogfakes.FakeHandleResolver
+		fakeSink     *nflogfakes.FakeSink
+		logger       *lagertest.TestLogger
+		fakeClock    *fakeclock.FakeClock
+		egressLogger *nflog.EgressLogger
+		loggedAt     time.Time
+	)
+
+	tcpPacket := func(dst string, port uint16) []byte {
+		packet := make([]byte, 24)
+		packet[0] = 0x45
+		packet[9] = 6
+		copy(packet[12:16], net.ParseIP("10.0.0.2").To4())
+		copy(packet[16:20], net.ParseIP(dst).To4())
+		binary.BigEndian.PutUint16(packet[20:22], 50000)
+		binary.BigEndian.PutUint16(packet[22:24], port)
+		return packet
+	}
+
+	BeforeEach(func() {
+		fakeSource = new(nflogfakes.FakeSource)
+		fakeResolver = new(nflogfakes.FakeHandleResolver)
+		fakeSink = new(nflogfakes.FakeSink)
+		logger = lagertest.NewTestLogger("test")
+		loggedAt = time.Unix(1500000000, 0)
+
+		fakeSource.ReceiveReturnsOnCall(0, []nflog.Message{
+			{Prefix: "some-id", Payload: tcpPacket("8.8.8.8", 53), Timestamp: loggedAt},
+		}, nil)
+		fakeSource.ReceiveReturnsOnCall(1, nil, nflog.ErrClosed)
+
+		fakeResolver.HandleStub = func(instanceID string) (string, bool) {
+			if instanceID == "some-id" {
+				return "some-handle", true
+			}
+			return "", false
+		}
+
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		egressLogger = nflog.NewEgressLogger(fakeSource, fakeResolver, fakeSink, fakeClock, logger)
+	})
+
+	It("emits a record for each logged packet, attributed to its container", func() {
+		egressLogger.Run()
+
+		Expect(fakeSink.EmitCallCount()).To(Equal(1))
+		Expect(fakeSink.EmitArgsForCall(0)).To(Equal(nflog.Record{
+			Time:            loggedAt,
+			Handle:          "some-handle",
+			Protocol:        "tcp",
+			Source:          "10.0.0.2",
+			SourcePort:      50000,
+			Destination:     "8.8.8.8",
+			DestinationPort: 53,
+		}))
+	})
+
+	Context("when the container cannot be found", func() {
+		BeforeEach(func() {
+			fakeSource.ReceiveReturnsOnCall(0, []nflog.Message{
+				{Prefix: "gone-id", Payload: tcpPacket("8.8.8.8", 53)},
+			}, nil)
+		})
+
+		It("attributes the record to the instance ID", func() {
+			egressLogger.Run()
+
+			record := fakeSink.EmitArgsForCall(0)
+			Expect(record.Handle).To(Equal("gone-id"))
+		})
+
+		It("timestamps the record when the packet was not", func() {
+			egressLogger.Run()
+
+			record := fakeSink.EmitArgsForCall(0)
+			Expect(record.Time).To(BeTemporally("~", time.Now(), time.Second))
+		})
+	})
+
+	Context("when a packet cannot be parsed", func() {
+		BeforeEach(func() {
+			fakeSource.ReceiveReturnsOnCall(0, []nflog.Message{
+				{Prefix: "some-id", Payload: []byte{0x10}},
+				{Prefix: "some-id", Payload: tcpPacket("8.8.4.4", 53)},
+			}, nil)
+		})
+
+		It("logs the failure and carries on", func() {
+			egressLogger.Run()
+
+			Expect(logger).To(gbytes.Say("parse-packet-failed"))
+			Expect(fakeSink.EmitCallCount()).To(Equal(1))
+			Expect(fakeSink.EmitArgsForCall(0).Destination).To(Equal("8.8.4.4"))
+		})
+	})
+
+	Context("when emitting a record fails", func() {
+		BeforeEach(func() {
+			fakeSink.EmitReturns(errors.New("disk full"))
+		})
+
+		It("logs the failure", func() {
+			egressLogger.Run()
+			Expect(logger).To(gbytes.Say("emit-failed"))
+		})
+	})
+
+	Context("when the kernel drops packets", func() {
+		BeforeEach(func() {
+			fakeSource.ReceiveReturnsOnCall(0, nil, nflog.ErrOverrun)
+			fakeSource.ReceiveReturnsOnCall(1, []nflog.Message{
+				{Prefix: "some-id", Payload: tcpPacket("8.8.8.8", 53)},
+			}, nil)
+			fakeSource.ReceiveReturnsOnCall(2, nil, nflog.ErrClosed)
+		})
+
+		It("counts the dropped packets and carries on receiving", func() {
+			egressLogger.Run()
+
+			Expect(egressLogger.DroppedPackets()).To(Equal(1))
+			Expect(fakeSink.EmitCallCount()).To(Equal(1))
+			Expect(fakeSink.EmitArgsForCall(0).Handle).To(Equal("some-handle"))
+		})
+	})
+
+	Context("when receiving fails", func() {
+		BeforeEach(func() {
+			fakeSource.ReceiveReturnsOnCall(0, nil, errors.New("nflog: receiving: interrupted system call"))
+			fakeSource.ReceiveReturnsOnCall(1, []nflog.Message{
+				{Prefix: "some-id", Payload: tcpPacket("8.8.8.8", 53)},
+			}, nil)
+			fakeSource.ReceiveReturnsOnCall(2, nil, nflog.ErrClosed)
+		})
+
+		It("logs the failure, and receives again after a pause", func() {
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				egressLogger.Run()
+				close(done)
+			}()
+
+			Eventually(logger).Should(gbytes.Say("receive-failed"))
+			Consistently(fakeSource.ReceiveCallCount).Should(Equal(1))
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(done).Should(BeClosed())
+			Expect(fakeSink.EmitCallCount()).To(Equal(1))
+			Expect(egressLogger.DroppedPackets()).To(Equal(0))
+		})
+	})
+})
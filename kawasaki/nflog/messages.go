@@ -0,0 +1,129 @@
+package nflog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	nlmsgHeaderLen  = 16
+	nfgenmsgLen     = 4
+	nlattrHeaderLen = 4
+
+	nlmsgError = 2
+
+	nfnlSubsysULOG  = 4
+	nfulnlMsgPacket = 0
+	nfulnlMsgConfig = 1
+
+	nfulaTimestamp = 3
+	nfulaPayload   = 9
+	nfulaPrefix    = 10
+
+	nlaTypeMask = 0x3fff
+)
+
+// Message is a packet logged by an NFLOG rule
+type Message struct {
+	Prefix    string
+	Payload   []byte
+	Timestamp time.Time
+}
+
+// ParseMessages parses the NFLOG packet messages in a buffer read from a
+// netfilter netlink socket. Other message types are ignored. Netlink headers
+// are in host byte order, which is little endian on every platform gdn runs on.
+func ParseMessages(buf []byte) ([]Message, error) {
+	var messages []Message
+
+	for len(buf) >= nlmsgHeaderLen {
+		msgLen := int(binary.LittleEndian.Uint32(buf[0:4]))
+		msgType := binary.LittleEndian.Uint16(buf[4:6])
+		if msgLen < nlmsgHeaderLen || msgLen > len(buf) {
+			return nil, fmt.Errorf("invalid netlink message length: %d", msgLen)
+		}
+
+		body := buf[nlmsgHeaderLen:msgLen]
+		buf = buf[align(msgLen):]
+
+		if msgType == nlmsgError {
+			if err := parseError(body); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if msgType != nfnlSubsysULOG<<8|nfulnlMsgPacket || len(body) < nfgenmsgLen {
+			continue
+		}
+
+		message, err := parsePacketMessage(body[nfgenmsgLen:])
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+func parsePacketMessage(attrs []byte) (Message, error) {
+	var message Message
+
+	for len(attrs) >= nlattrHeaderLen {
+		attrLen := int(binary.LittleEndian.Uint16(attrs[0:2]))
+		attrType := binary.LittleEndian.Uint16(attrs[2:4]) & nlaTypeMask
+		if attrLen < nlattrHeaderLen || attrLen > len(attrs) {
+			return Message{}, fmt.Errorf("invalid netlink attribute length: %d", attrLen)
+		}
+
+		value := attrs[nlattrHeaderLen:attrLen]
+		switch attrType {
+		case nfulaPrefix:
+			message.Prefix = nullTerminated(value)
+		case nfulaPayload:
+			message.Payload = append([]byte{}, value...)
+		case nfulaTimestamp:
+			if len(value) >= 16 {
+				sec := binary.BigEndian.Uint64(value[0:8])
+				usec := binary.BigEndian.Uint64(value[8:16])
+				message.Timestamp = time.Unix(int64(sec), int64(usec)*1000)
+			}
+		}
+
+		if align(attrLen) >= len(attrs) {
+			break
+		}
+		attrs = attrs[align(attrLen):]
+	}
+
+	return message, nil
+}
+
+func parseError(body []byte) error {
+	if len(body) < 4 {
+		return errors.New("truncated netlink error")
+	}
+
+	if errno := int32(binary.LittleEndian.Uint32(body[0:4])); errno != 0 {
+		return fmt.Errorf("netlink error: errno %d", -errno)
+	}
+
+	return nil
+}
+
+func nullTerminated(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+
+	return string(b)
+}
+
+func align(n int) int {
+	return (n + 3) &^ 3
+}
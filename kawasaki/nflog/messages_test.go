@@ -0,0 +1,92 @@
+package nflog_test
+
+import (
+	"encoding/binary"
+	"time"
+
+	"code.cloudfoundry.org/guardian/kawasaki/nflog"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseMessages", func() {
+	attr := func(attrType uint16, value []byte) []byte {
+		length := 4 + len(value)
+		b := make([]byte, (length+3)&^3)
+		binary.LittleEndian.PutUint16(b[0:2], uint16(length))
+		binary.LittleEndian.PutUint16(b[2:4], attrType)
+		copy(b[4:], value)
+		return b
+	}
+
+	message := func(msgType uint16, body []byte) []byte {
+		b := make([]byte, 16, 16+len(body))
+		b = append(b, body...)
+		binary.LittleEndian.PutUint32(b[0:4], uint32(len(b)))
+		binary.LittleEndian.PutUint16(b[4:6], msgType)
+		return b
+	}
+
+	packet := func(attrs ...[]byte) []byte {
+		body := []byte{2, 0, 0, 5}
+		for _, a := range attrs {
+			body = append(body, a...)
+		}
+		return message(0x0400, body)
+	}
+
+	timestamp := func(t time.Time) []byte {
+		b := make([]byte, 16)
+		binary.BigEndian.PutUint64(b[0:8], uint64(t.Unix()))
+		binary.BigEndian.PutUint64(b[8:16], uint64(t.Nanosecond()/1000))
+		return b
+	}
+
+	It("parses the prefix, payload and timestamp of each logged packet", func() {
+		loggedAt := time.Unix(1500000000, 123000)
+
+		buf := append(
+			packet(attr(10, []byte("some-id\x00")), attr(9, []byte{0x45, 0x00}), attr(3, timestamp(loggedAt))),
+			packet(attr(10, []byte("other-id\x00")), attr(9, []byte{0x45, 0x01}))...,
+		)
+
+		messages, err := nflog.ParseMessages(buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(messages).To(HaveLen(2))
+
+		Expect(messages[0].Prefix).To(Equal("some-id"))
+		Expect(messages[0].Payload).To(Equal([]byte{0x45, 0x00}))
+		Expect(messages[0].Timestamp.Equal(loggedAt)).To(BeTrue())
+
+		Expect(messages[1].Prefix).To(Equal("other-id"))
+		Expect(messages[1].Timestamp.IsZero()).To(BeTrue())
+	})
+
+	It("ignores other message types", func() {
+		messages, err := nflog.ParseMessages(message(3, []byte{0, 0, 0, 0}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(messages).To(BeEmpty())
+	})
+
+	It("ignores acknowledgements", func() {
+		messages, err := nflog.ParseMessages(message(2, make([]byte, 20)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(messages).To(BeEmpty())
+	})
+
+	It("returns netlink errors", func() {
+		body := make([]byte, 20)
+		binary.LittleEndian.PutUint32(body[0:4], uint32(0xffffffff)) // -EPERM
+
+		_, err := nflog.ParseMessages(message(2, body))
+		Expect(err).To(MatchError("netlink error: errno 1"))
+	})
+
+	It("returns an error when a message is truncated", func() {
+		buf := packet(attr(10, []byte("some-id\x00")))
+
+		_, err := nflog.ParseMessages(buf[:len(buf)-4])
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,98 @@
+package nflog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Record is a structured log entry for a connection from a container
+type Record struct {
+	Time            time.Time `json:"time"`
+	Handle          string    `json:"handle"`
+	Protocol        string    `json:"protocol"`
+	Source          string    `json:"source"`
+	SourcePort      uint16    `json:"source_port,omitempty"`
+	Destination     string    `json:"destination"`
+	DestinationPort uint16    `json:"destination_port,omitempty"`
+	ICMPType        *uint8    `json:"icmp_type,omitempty"`
+	ICMPCode        *uint8    `json:"icmp_code,omitempty"`
+}
+
+var protocols = map[uint8]string{
+	1:  "icmp",
+	6:  "tcp",
+	17: "udp",
+	58: "icmpv6",
+}
+
+// ParsePacket fills in the addresses, protocol and ports of a record from the
+// IP header of a logged packet and the start of its transport header
+func ParsePacket(payload []byte) (Record, error) {
+	if len(payload) < 1 {
+		return Record{}, errors.New("empty packet")
+	}
+
+	var (
+		record    Record
+		protocol  uint8
+		transport []byte
+	)
+
+	switch payload[0] >> 4 {
+	case 4:
+		if len(payload) < 20 {
+			return Record{}, errors.New("truncated IPv4 header")
+		}
+
+		headerLen := int(payload[0]&0x0f) * 4
+		if headerLen < 20 || headerLen > len(payload) {
+			return Record{}, errors.New("invalid IPv4 header length")
+		}
+
+		protocol = payload[9]
+		record.Source = net.IP(payload[12:16]).String()
+		record.Destination = net.IP(payload[16:20]).String()
+		transport = payload[headerLen:]
+	case 6:
+		if len(payload) < 40 {
+			return Record{}, errors.New("truncated IPv6 header")
+		}
+
+		// extension headers are not followed, so their protocol is reported
+		protocol = payload[6]
+		record.Source = net.IP(payload[8:24]).String()
+		record.Destination = net.IP(payload[24:40]).String()
+		transport = payload[40:]
+	default:
+		return Record{}, errors.New("not an IP packet")
+	}
+
+	record.Protocol = protocolName(protocol)
+
+	switch record.Protocol {
+	case "tcp", "udp":
+		if len(transport) >= 4 {
+			record.SourcePort = binary.BigEndian.Uint16(transport[0:2])
+			record.DestinationPort = binary.BigEndian.Uint16(transport[2:4])
+		}
+	case "icmp", "icmpv6":
+		if len(transport) >= 2 {
+			icmpType, icmpCode := transport[0], transport[1]
+			record.ICMPType = &icmpType
+			record.ICMPCode = &icmpCode
+		}
+	}
+
+	return record, nil
+}
+
+func protocolName(protocol uint8) string {
+	if name, ok := protocols[protocol]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("%d", protocol)
+}
@@ -0,0 +1,88 @@
+package nflog_test
+
+import (
+	"net"
+
+	"code.cloudfoundry.org/guardian/kawasaki/nflog"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParsePacket", func() {
+	ipv4 := func(protocol byte, src, dst string, transport ...byte) []byte {
+		header := make([]byte, 20)
+		header[0] = 0x45
+		header[9] = protocol
+		copy(header[12:16], net.ParseIP(src).To4())
+		copy(header[16:20], net.ParseIP(dst).To4())
+		return append(header, transport...)
+	}
+
+	It("parses a TCP packet", func() {
+		record, err := nflog.ParsePacket(ipv4(6, "10.0.0.2", "8.8.8.8", 0xc3, 0x50, 0x00, 0x35))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(record).To(Equal(nflog.Record{
+			Protocol:        "tcp",
+			Source:          "10.0.0.2",
+			SourcePort:      50000,
+			Destination:     "8.8.8.8",
+			DestinationPort: 53,
+		}))
+	})
+
+	It("parses a UDP packet", func() {
+		record, err := nflog.ParsePacket(ipv4(17, "10.0.0.2", "8.8.8.8", 0xc3, 0x50, 0x00, 0x35))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(record.Protocol).To(Equal("udp"))
+		Expect(record.DestinationPort).To(Equal(uint16(53)))
+	})
+
+	It("skips IP options to find the ports", func() {
+		packet := ipv4(6, "10.0.0.2", "8.8.8.8", 0, 0, 0, 0, 0xc3, 0x50, 0x01, 0xbb)
+		packet[0] = 0x46
+
+		record, err := nflog.ParsePacket(packet)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(record.DestinationPort).To(Equal(uint16(443)))
+	})
+
+	It("parses the type and code of an ICMP packet", func() {
+		record, err := nflog.ParsePacket(ipv4(1, "10.0.0.2", "8.8.8.8", 8, 0))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(record.Protocol).To(Equal("icmp"))
+		Expect(*record.ICMPType).To(Equal(uint8(8)))
+		Expect(*record.ICMPCode).To(Equal(uint8(0)))
+	})
+
+	It("reports other protocols by number", func() {
+		record, err := nflog.ParsePacket(ipv4(47, "10.0.0.2", "8.8.8.8"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(record.Protocol).To(Equal("47"))
+	})
+
+	It("parses an IPv6 packet", func() {
+		packet := make([]byte, 40)
+		packet[0] = 0x60
+		packet[6] = 17
+		copy(packet[8:24], net.ParseIP("fd00::2"))
+		copy(packet[24:40], net.ParseIP("2001:db8::1"))
+		packet = append(packet, 0xc3, 0x50, 0x00, 0x35)
+
+		record, err := nflog.ParsePacket(packet)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(record.Source).To(Equal("fd00::2"))
+		Expect(record.Destination).To(Equal("2001:db8::1"))
+		Expect(record.DestinationPort).To(Equal(uint16(53)))
+	})
+
+	It("returns an error when the packet is not IP", func() {
+		_, err := nflog.ParsePacket([]byte{0x10, 0x00})
+		Expect(err).To(MatchError("not an IP packet"))
+	})
+
+	It("returns an error when the header is truncated", func() {
+		_, err := nflog.ParsePacket(ipv4(6, "10.0.0.2", "8.8.8.8")[:12])
+		Expect(err).To(MatchError("truncated IPv4 header"))
+	})
+})
@@ -0,0 +1,65 @@
+package nflog
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"code.cloudfoundry.org/lager"
+)
+
+//go:generate counterfeiter . Sink
+
+type Sink interface {
+	Emit(record Record) error
+}
+
+type jsonSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewJSONSink writes each record as a line of JSON
+func NewJSONSink(w io.Writer) Sink {
+	return &jsonSink{encoder: json.NewEncoder(w)}
+}
+
+func (s *jsonSink) Emit(record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.encoder.Encode(record)
+}
+
+type lagerSink struct {
+	logger lager.Logger
+}
+
+// NewLagerSink logs each record as an "egress" message of the given logger
+func NewLagerSink(logger lager.Logger) Sink {
+	return &lagerSink{logger: logger}
+}
+
+func (s *lagerSink) Emit(record Record) error {
+	data := lager.Data{
+		"time":        record.Time,
+		"handle":      record.Handle,
+		"protocol":    record.Protocol,
+		"source":      record.Source,
+		"destination": record.Destination,
+	}
+
+	if record.SourcePort != 0 {
+		data["source_port"] = record.SourcePort
+	}
+	if record.DestinationPort != 0 {
+		data["destination_port"] = record.DestinationPort
+	}
+	if record.ICMPType != nil {
+		data["icmp_type"] = *record.ICMPType
+		data["icmp_code"] = *record.ICMPCode
+	}
+
+	s.logger.Info("egress", data)
+	return nil
+}
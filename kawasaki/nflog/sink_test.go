@@ -0,0 +1,56 @@
+package nflog_test
+
+import (
+	"bytes"
+	"time"
+
+	"code.cloudfoundry.org/guardian/kawasaki/nflog"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sinks", func() {
+	var record nflog.Record
+
+	BeforeEach(func() {
+		record = nflog.Record{
+			Time:            time.Unix(1500000000, 0).UTC(),
+			Handle:          "some-handle",
+			Protocol:        "tcp",
+			Source:          "10.0.0.2",
+			SourcePort:      50000,
+			Destination:     "8.8.8.8",
+			DestinationPort: 53,
+		}
+	})
+
+	Describe("JSONSink", func() {
+		It("writes each record as a line of JSON", func() {
+			buf := new(bytes.Buffer)
+			sink := nflog.NewJSONSink(buf)
+
+			Expect(sink.Emit(record)).To(Succeed())
+			Expect(sink.Emit(record)).To(Succeed())
+
+			line := `{"time":"2017-07-14T02:40:00Z","handle":"some-handle","protocol":"tcp","source":"10.0.0.2","source_port":50000,"destination":"8.8.8.8","destination_port":53}` + "\n"
+			Expect(buf.String()).To(Equal(line + line))
+		})
+	})
+
+	Describe("LagerSink", func() {
+		It("logs each record", func() {
+			logger := lagertest.NewTestLogger("test")
+			sink := nflog.NewLagerSink(logger)
+
+			Expect(sink.Emit(record)).To(Succeed())
+
+			Expect(logger.LogMessages()).To(ConsistOf("test.egress"))
+			data := logger.Logs()[0].Data
+			Expect(data).To(HaveKeyWithValue("handle", "some-handle"))
+			Expect(data).To(HaveKeyWithValue("destination", "8.8.8.8"))
+			Expect(data).To(HaveKeyWithValue("destination_port", BeNumerically("==", 53)))
+		})
+	})
+})
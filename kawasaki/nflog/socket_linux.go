@@ -0,0 +1,128 @@
+package nflog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+)
+
+const (
+	netlinkNetfilter = 12
+
+	nfulnlCfgCmdBind   = 1
+	nfulnlCfgCmdPFBind = 3
+
+	nfulaCfgCmd  = 1
+	nfulaCfgMode = 2
+
+	nfulnlCopyPacket = 2
+
+	// enough of each packet to read the IP and transport headers
+	copyRange = 128
+
+	receiveBufferSize = 64 * 1024
+)
+
+// Socket receives the packets logged to an NFLOG group
+type Socket struct {
+	fd     int
+	seq    uint32
+	closed int32
+}
+
+// Open binds a netfilter netlink socket to the NFLOG group. Only one process
+// may be bound to a group at a time.
+func Open(group uint16) (*Socket, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, netlinkNetfilter)
+	if err != nil {
+		return nil, fmt.Errorf("nflog: opening socket: %s", err)
+	}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("nflog: binding socket: %s", err)
+	}
+
+	s := &Socket{fd: fd}
+
+	// older kernels need the address family bound explicitly; newer kernels
+	// reject this as unnecessary, which is harmless
+	s.configure(syscall.AF_INET, 0, attr(nfulaCfgCmd, []byte{nfulnlCfgCmdPFBind}))
+
+	if err := s.configure(syscall.AF_UNSPEC, group, attr(nfulaCfgCmd, []byte{nfulnlCfgCmdBind})); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("nflog: binding group %d: %s", group, err)
+	}
+
+	mode := make([]byte, 6)
+	binary.BigEndian.PutUint32(mode[0:4], copyRange)
+	mode[4] = nfulnlCopyPacket
+	if err := s.configure(syscall.AF_UNSPEC, group, attr(nfulaCfgMode, mode)); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("nflog: setting copy mode: %s", err)
+	}
+
+	return s, nil
+}
+
+func (s *Socket) Receive() ([]Message, error) {
+	buf := make([]byte, receiveBufferSize)
+	n, _, err := syscall.Recvfrom(s.fd, buf, 0)
+	if err != nil {
+		if atomic.LoadInt32(&s.closed) == 1 {
+			return nil, ErrClosed
+		}
+
+		if err == syscall.ENOBUFS {
+			return nil, ErrOverrun
+		}
+
+		return nil, fmt.Errorf("nflog: receiving: %s", err)
+	}
+
+	return ParseMessages(buf[:n])
+}
+
+func (s *Socket) Close() error {
+	atomic.StoreInt32(&s.closed, 1)
+	return syscall.Close(s.fd)
+}
+
+// configure sends an NFULNL_MSG_CONFIG message and waits for its
+// acknowledgement
+func (s *Socket) configure(family uint8, group uint16, attrs []byte) error {
+	s.seq++
+
+	msg := make([]byte, nlmsgHeaderLen+nfgenmsgLen, nlmsgHeaderLen+nfgenmsgLen+len(attrs))
+	msg = append(msg, attrs...)
+	binary.LittleEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	binary.LittleEndian.PutUint16(msg[4:6], nfnlSubsysULOG<<8|nfulnlMsgConfig)
+	binary.LittleEndian.PutUint16(msg[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_ACK)
+	binary.LittleEndian.PutUint32(msg[8:12], s.seq)
+	msg[16] = family
+	msg[17] = 0 // NFNETLINK_V0
+	binary.BigEndian.PutUint16(msg[18:20], group)
+
+	if err := syscall.Sendto(s.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, syscall.Getpagesize())
+	n, _, err := syscall.Recvfrom(s.fd, buf, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = ParseMessages(buf[:n])
+	return err
+}
+
+func attr(attrType uint16, value []byte) []byte {
+	length := nlattrHeaderLen + len(value)
+	b := make([]byte, align(length))
+	binary.LittleEndian.PutUint16(b[0:2], uint16(length))
+	binary.LittleEndian.PutUint16(b[2:4], attrType)
+	copy(b[nlattrHeaderLen:], value)
+	return b
+}
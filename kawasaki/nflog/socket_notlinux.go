@@ -0,0 +1,19 @@
+// +build !linux
+
+package nflog
+
+import "errors"
+
+type Socket struct{}
+
+func Open(group uint16) (*Socket, error) {
+	return nil, errors.New("nflog: not supported on this platform")
+}
+
+func (s *Socket) Receive() ([]Message, error) {
+	return nil, ErrClosed
+}
+
+func (s *Socket) Close() error {
+	return nil
+}
@@ -0,0 +1,376 @@
+package kawasaki
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+
+	"code.cloudfoundry.org/guardian/kawasaki/subnets"
+)
+
+const poolSpecPrefix = "pool:"
+
+// pool names are used in the names of iptables chains, which may not contain
+// whitespace or punctuation with meaning to iptables
+var validPoolName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+const (
+	// NetworkModeBridge connects containers to a per-subnet bridge behind NAT
+	NetworkModeBridge = "bridge"
+	// NetworkModeMacvlan and NetworkModeIPvlan attach containers directly to
+	// the pool's parent interface, with no bridge, NAT or firewall
+	NetworkModeMacvlan = "macvlan"
+	NetworkModeIPvlan  = "ipvlan"
+	// NetworkModeVXLAN connects containers to a bridge shared by the whole
+	// pool, which is joined to the bridges of other hosts over VXLAN
+	NetworkModeVXLAN = "vxlan"
+)
+
+const (
+	// DefaultVXLANPort is the IANA assigned port for VXLAN
+	DefaultVXLANPort = 4789
+
+	// vxlanOverhead is the size of the outer headers added to each frame
+	vxlanOverhead = 50
+)
+
+// NetworkPoolSpec describes an operator-defined pool of container subnets.
+// Containers select a pool by passing "pool:<name>" as their network spec.
+type NetworkPoolSpec struct {
+	Name            string   `json:"name"`
+	CIDR            string   `json:"cidr"`
+	DenyNetworks    []string `json:"deny_networks,omitempty"`
+	Mtu             int      `json:"mtu,omitempty"`
+	AllowHostAccess bool     `json:"allow_host_access,omitempty"`
+
+	// EgressIP is a host address to which traffic from the pool's containers
+	// is source NATed, unless a container requests its own
+	EgressIP string `json:"egress_ip,omitempty"`
+
+	// Mode is one of bridge (the default), macvlan or ipvlan. Direct modes
+	// hand out individual addresses from CIDR on the network of
+	// ParentInterface, routing via Gateway (by default the first address).
+	Mode            string `json:"mode,omitempty"`
+	ParentInterface string `json:"parent_interface,omitempty"`
+	Gateway         string `json:"gateway,omitempty"`
+
+	// The VXLAN mode shares CIDR between every host with the same VNI. Each
+	// host's bridge is given Gateway, which must differ between hosts, and
+	// hands out container addresses from its own AllocationRange. Frames are
+	// sent to the Peers, and to any listed as JSON in PeersFile, from
+	// VTEPLocalIP on ParentInterface.
+	VNI             int      `json:"vni,omitempty"`
+	AllocationRange string   `json:"allocation_range,omitempty"`
+	VTEPLocalIP     string   `json:"vtep_local_ip,omitempty"`
+	VTEPPort        int      `json:"vtep_port,omitempty"`
+	Peers           []string `json:"peers,omitempty"`
+	PeersFile       string   `json:"peers_file,omitempty"`
+}
+
+type NetworkPool struct {
+	Spec     NetworkPoolSpec
+	Network  *net.IPNet
+	Gateway  net.IP
+	EgressIP net.IP
+	Subnets  subnets.Pool
+
+	// AllocationRange and Peers are only set for VXLAN pools
+	AllocationRange *net.IPNet
+	Peers           VXLANPeers
+
+	// Configurer, if set, replaces the networker's configurer for containers
+	// in this pool
+	Configurer Configurer
+}
+
+func (p NetworkPool) IsDirect() bool {
+	return isDirectMode(p.Spec.Mode)
+}
+
+func (p NetworkPool) IsVXLAN() bool {
+	return p.Spec.Mode == NetworkModeVXLAN
+}
+
+// Capacity returns the number of containers the pool can host. Bridge pools
+// give each container a /30, direct pools give each container one address
+// and VXLAN pools one address from the host's allocation range.
+func (p NetworkPool) Capacity() int {
+	if p.IsVXLAN() {
+		capacity := addressCount(p.AllocationRange)
+		for _, reserved := range []net.IP{subnets.NetworkIP(p.Network), p.Gateway, subnets.BroadcastIP(p.Network)} {
+			if p.AllocationRange.Contains(reserved) {
+				capacity--
+			}
+		}
+
+		return capacity
+	}
+
+	if !p.IsDirect() {
+		return p.Subnets.Capacity()
+	}
+
+	return addressCount(p.Network) - 3 // network, gateway and broadcast addresses
+}
+
+// Free returns the number of further containers the pool can host
+func (p NetworkPool) Free() int {
+	if !p.IsDirect() && !p.IsVXLAN() {
+		return p.Subnets.Free()
+	}
+
+	// direct and VXLAN pools allocate every address from the one network,
+	// which also holds the reserved gateway address of direct pools
+	free := p.Capacity()
+	for _, ip := range p.Subnets.IPs(p.Network) {
+		if ip.Equal(p.Gateway) || (p.IsVXLAN() && !p.AllocationRange.Contains(ip)) {
+			continue
+		}
+
+		free--
+	}
+
+	return free
+}
+
+func addressCount(network *net.IPNet) int {
+	ones, bits := network.Mask.Size()
+	return 1 << uint(bits-ones)
+}
+
+func isDirectMode(mode string) bool {
+	return mode == NetworkModeMacvlan || mode == NetworkModeIPvlan
+}
+
+func LoadNetworkPools(path string) ([]NetworkPoolSpec, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening network pools config: %s", err)
+	}
+	defer file.Close()
+
+	var specs []NetworkPoolSpec
+	if err := json.NewDecoder(file).Decode(&specs); err != nil {
+		return nil, fmt.Errorf("parsing network pools config: %s", err)
+	}
+
+	return specs, nil
+}
+
+// NewNetworkPools creates a subnet pool for each of the given specs. Pools may
+// not overlap each other or any of the reserved networks, and may not use an
+// MTU larger than maxMtu, the MTU of the host's interface.
+func NewNetworkPools(specs []NetworkPoolSpec, maxMtu int, reserved ...*net.IPNet) (map[string]NetworkPool, error) {
+	pools := map[string]NetworkPool{}
+	taken := append([]*net.IPNet{}, reserved...)
+
+	for _, spec := range specs {
+		if !validPoolName.MatchString(spec.Name) {
+			return nil, fmt.Errorf("invalid network pool name: '%s'", spec.Name)
+		}
+
+		if _, exists := pools[spec.Name]; exists {
+			return nil, fmt.Errorf("network pool '%s' is defined more than once", spec.Name)
+		}
+
+		_, ipNet, err := net.ParseCIDR(spec.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("network pool '%s': %s", spec.Name, err)
+		}
+
+		for _, t := range taken {
+			if t.Contains(ipNet.IP) || ipNet.Contains(t.IP) {
+				return nil, fmt.Errorf("network pool '%s' overlaps %s", spec.Name, t)
+			}
+		}
+
+		for _, n := range spec.DenyNetworks {
+			if _, _, err := net.ParseCIDR(n); err != nil {
+				return nil, fmt.Errorf("network pool '%s': deny network: %s", spec.Name, err)
+			}
+		}
+
+		if spec.Mtu > maxMtu {
+			return nil, fmt.Errorf("network pool '%s': mtu %d exceeds the maximum of %d", spec.Name, spec.Mtu, maxMtu)
+		}
+
+		var egressIP net.IP
+		if spec.EgressIP != "" {
+			if egressIP = net.ParseIP(spec.EgressIP); egressIP == nil {
+				return nil, fmt.Errorf("network pool '%s': invalid egress ip: %s", spec.Name, spec.EgressIP)
+			}
+		}
+
+		pool := NetworkPool{
+			Spec:     spec,
+			Network:  ipNet,
+			Gateway:  subnets.GatewayIP(ipNet),
+			EgressIP: egressIP,
+			Subnets:  subnets.NewPool(ipNet),
+		}
+
+		if spec.Gateway != "" && (isDirectMode(spec.Mode) || spec.Mode == NetworkModeVXLAN) {
+			pool.Gateway = net.ParseIP(spec.Gateway)
+			if pool.Gateway == nil || !ipNet.Contains(pool.Gateway) {
+				return nil, fmt.Errorf("network pool '%s': gateway %s is not in %s", spec.Name, spec.Gateway, ipNet)
+			}
+		}
+
+		switch spec.Mode {
+		case "", NetworkModeBridge:
+		case NetworkModeMacvlan, NetworkModeIPvlan:
+			if err := validateDirectPool(spec); err != nil {
+				return nil, err
+			}
+
+			if spec.Gateway != "" {
+				// never hand the gateway's address out to a container
+				if err := pool.Subnets.Remove(ipNet, pool.Gateway); err != nil {
+					return nil, fmt.Errorf("network pool '%s': reserving gateway: %s", spec.Name, err)
+				}
+			}
+		case NetworkModeVXLAN:
+			if err := configureVXLANPool(&pool, maxMtu); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("network pool '%s': unknown mode: %s", spec.Name, spec.Mode)
+		}
+
+		taken = append(taken, ipNet)
+		pools[spec.Name] = pool
+	}
+
+	return pools, nil
+}
+
+func validateDirectPool(spec NetworkPoolSpec) error {
+	if spec.ParentInterface == "" {
+		return fmt.Errorf("network pool '%s': %s mode requires a parent interface", spec.Name, spec.Mode)
+	}
+
+	if len(spec.DenyNetworks) > 0 || spec.AllowHostAccess {
+		return fmt.Errorf("network pool '%s': deny networks and host access cannot be enforced in %s mode", spec.Name, spec.Mode)
+	}
+
+	if spec.EgressIP != "" {
+		return fmt.Errorf("network pool '%s': egress ip cannot be used in %s mode, as traffic is not NATed", spec.Name, spec.Mode)
+	}
+
+	return nil
+}
+
+// configureVXLANPool validates the VXLAN settings of the pool and fills in
+// their defaults. The pool's MTU leaves room for the VXLAN headers within the
+// host interface's MTU.
+func configureVXLANPool(pool *NetworkPool, maxMtu int) error {
+	spec := &pool.Spec
+
+	if spec.VNI < 1 || spec.VNI > 1<<24-1 {
+		return fmt.Errorf("network pool '%s': vni must be between 1 and %d", spec.Name, 1<<24-1)
+	}
+
+	pool.AllocationRange = pool.Network
+	if spec.AllocationRange != "" {
+		_, allocationRange, err := net.ParseCIDR(spec.AllocationRange)
+		if err != nil {
+			return fmt.Errorf("network pool '%s': allocation range: %s", spec.Name, err)
+		}
+
+		if !pool.Network.Contains(allocationRange.IP) || addressCount(allocationRange) > addressCount(pool.Network) {
+			return fmt.Errorf("network pool '%s': allocation range %s is not in %s", spec.Name, allocationRange, pool.Network)
+		}
+
+		pool.AllocationRange = allocationRange
+	}
+
+	if spec.VTEPLocalIP != "" && net.ParseIP(spec.VTEPLocalIP) == nil {
+		return fmt.Errorf("network pool '%s': invalid vtep local ip: %s", spec.Name, spec.VTEPLocalIP)
+	}
+
+	if spec.VTEPPort == 0 {
+		spec.VTEPPort = DefaultVXLANPort
+	}
+
+	for _, peer := range spec.Peers {
+		ip := net.ParseIP(peer)
+		if ip == nil {
+			return fmt.Errorf("network pool '%s': invalid peer: %s", spec.Name, peer)
+		}
+
+		pool.Peers.Static = append(pool.Peers.Static, ip)
+	}
+	pool.Peers.File = spec.PeersFile
+
+	if spec.Mtu == 0 {
+		spec.Mtu = maxMtu - vxlanOverhead
+	}
+
+	if spec.Mtu > maxMtu-vxlanOverhead {
+		return fmt.Errorf("network pool '%s': mtu %d leaves no room for the vxlan headers within the maximum of %d", spec.Name, spec.Mtu, maxMtu)
+	}
+
+	return nil
+}
+
+// VXLANPeers are the remote VTEPs of a VXLAN pool. The peers file is read
+// each time the peers are listed, so that hosts can join and leave the
+// overlay while the server is running.
+type VXLANPeers struct {
+	Static []net.IP
+	File   string
+}
+
+func (p VXLANPeers) List() ([]net.IP, error) {
+	peers := append([]net.IP{}, p.Static...)
+	if p.File == "" {
+		return peers, nil
+	}
+
+	file, err := os.Open(p.File)
+	if err != nil {
+		return nil, fmt.Errorf("opening vxlan peers file: %s", err)
+	}
+	defer file.Close()
+
+	var listed []string
+	if err := json.NewDecoder(file).Decode(&listed); err != nil {
+		return nil, fmt.Errorf("parsing vxlan peers file: %s", err)
+	}
+
+	for _, peer := range listed {
+		ip := net.ParseIP(peer)
+		if ip == nil {
+			return nil, fmt.Errorf("parsing vxlan peers file: invalid peer: %s", peer)
+		}
+
+		peers = append(peers, ip)
+	}
+
+	return peers, nil
+}
+
+// allocationRangeSelector selects the next free address in a VXLAN pool's
+// allocation range, so that hosts sharing the pool's network never hand out
+// the same address, or that of this host's bridge
+type allocationRangeSelector struct {
+	allocationRange *net.IPNet
+	gateway         net.IP
+}
+
+func (s allocationRangeSelector) SelectIP(_ *net.IPNet, existing []net.IP) (net.IP, error) {
+	return subnets.DynamicIPSelector.SelectIP(s.allocationRange, append(existing, s.gateway))
+}
+
+// parsePoolSpec splits a "pool:<name>" network spec into the pool name and
+// the remaining spec, which is always dynamic for named pools
+func parsePoolSpec(spec string) (string, string) {
+	if !strings.HasPrefix(spec, poolSpecPrefix) {
+		return "", spec
+	}
+
+	return strings.TrimPrefix(spec, poolSpecPrefix), ""
+}
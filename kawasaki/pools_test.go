@@ -0,0 +1,333 @@
+package kawasaki_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+
+	"code.cloudfoundry.org/guardian/kawasaki"
+	"code.cloudfoundry.org/guardian/kawasaki/subnets"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NetworkPools", func() {
+	Describe("LoadNetworkPools", func() {
+		var path string
+
+		BeforeEach(func() {
+			file, err := ioutil.TempFile("", "network-pools")
+			Expect(err).NotTo(HaveOccurred())
+			path = file.Name()
+			Expect(file.Close()).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.Remove(path)).To(Succeed())
+		})
+
+		It("parses the pool definitions", func() {
+			Expect(ioutil.WriteFile(path, []byte(`[{"name": "isolated", "cidr": "10.100.0.0/22", "deny_networks": ["0.0.0.0/0"], "mtu": 1400, "allow_host_access": true}]`), 0600)).To(Succeed())
+
+			specs, err := kawasaki.LoadNetworkPools(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(specs).To(Equal([]kawasaki.NetworkPoolSpec{{
+				Name:            "isolated",
+				CIDR:            "10.100.0.0/22",
+				DenyNetworks:    []string{"0.0.0.0/0"},
+				Mtu:             1400,
+				AllowHostAccess: true,
+			}}))
+		})
+
+		It("returns an error when the file is not valid JSON", func() {
+			Expect(ioutil.WriteFile(path, []byte(`{`), 0600)).To(Succeed())
+
+			_, err := kawasaki.LoadNetworkPools(path)
+			Expect(err).To(MatchError(ContainSubstring("parsing network pools config")))
+		})
+	})
+
+	Describe("NewNetworkPools", func() {
+		var defaultPool *net.IPNet
+
+		BeforeEach(func() {
+			_, defaultPool, _ = net.ParseCIDR("10.254.0.0/22")
+		})
+
+		It("creates a subnet pool for each spec", func() {
+			pools, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+				{Name: "isolated", CIDR: "10.100.0.0/22"},
+				{Name: "public", CIDR: "10.101.0.0/24"},
+			}, 9000, defaultPool)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(pools).To(HaveLen(2))
+			Expect(pools["isolated"].Subnets.Capacity()).To(Equal(256))
+			Expect(pools["public"].Subnets.Capacity()).To(Equal(64))
+		})
+
+		It("rejects pools which overlap a reserved network", func() {
+			_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+				{Name: "isolated", CIDR: "10.254.1.0/24"},
+			}, 9000, defaultPool)
+			Expect(err).To(MatchError("network pool 'isolated' overlaps 10.254.0.0/22"))
+		})
+
+		It("rejects pools which overlap each other", func() {
+			_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+				{Name: "isolated", CIDR: "10.100.0.0/16"},
+				{Name: "public", CIDR: "10.100.4.0/24"},
+			}, 9000)
+			Expect(err).To(MatchError("network pool 'public' overlaps 10.100.0.0/16"))
+		})
+
+		It("rejects pools defined more than once", func() {
+			_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+				{Name: "isolated", CIDR: "10.100.0.0/24"},
+				{Name: "isolated", CIDR: "10.101.0.0/24"},
+			}, 9000)
+			Expect(err).To(MatchError("network pool 'isolated' is defined more than once"))
+		})
+
+		It("rejects invalid pool names", func() {
+			_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+				{Name: "iso:lated", CIDR: "10.100.0.0/24"},
+			}, 9000)
+			Expect(err).To(MatchError("invalid network pool name: 'iso:lated'"))
+		})
+
+		It("rejects pool names containing characters which iptables chain names may not", func() {
+			for _, name := range []string{"iso lated", "iso/lated", "iso!lated", ""} {
+				_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+					{Name: name, CIDR: "10.100.0.0/24"},
+				}, 9000)
+				Expect(err).To(MatchError(fmt.Sprintf("invalid network pool name: '%s'", name)))
+			}
+		})
+
+		It("rejects invalid deny networks", func() {
+			_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+				{Name: "isolated", CIDR: "10.100.0.0/24", DenyNetworks: []string{"banana"}},
+			}, 9000)
+			Expect(err).To(MatchError(ContainSubstring("network pool 'isolated': deny network")))
+		})
+
+		Context("when a pool uses a direct mode", func() {
+			It("counts each address as capacity", func() {
+				pools, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+					{Name: "direct", CIDR: "10.100.0.0/24", Mode: "macvlan", ParentInterface: "eth1"},
+				}, 9000)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pools["direct"].IsDirect()).To(BeTrue())
+				Expect(pools["direct"].Capacity()).To(Equal(253))
+				Expect(pools["direct"].Gateway.String()).To(Equal("10.100.0.1"))
+			})
+
+			It("never hands out a configured gateway", func() {
+				pools, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+					{Name: "direct", CIDR: "10.100.0.0/30", Mode: "ipvlan", ParentInterface: "eth1", Gateway: "10.100.0.2"},
+				}, 9000)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pools["direct"].Gateway.String()).To(Equal("10.100.0.2"))
+
+				err = pools["direct"].Subnets.Remove(pools["direct"].Network, net.ParseIP("10.100.0.2"))
+				Expect(err).To(MatchError(subnets.ErrOverlapsExistingSubnet))
+			})
+
+			It("counts the addresses not yet given to a container as free", func() {
+				pools, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+					{Name: "direct", CIDR: "10.100.0.0/24", Mode: "macvlan", ParentInterface: "eth1", Gateway: "10.100.0.254"},
+				}, 9000)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pools["direct"].Free()).To(Equal(253))
+
+				Expect(pools["direct"].Subnets.Remove(pools["direct"].Network, net.ParseIP("10.100.0.2"))).To(Succeed())
+				Expect(pools["direct"].Free()).To(Equal(252))
+			})
+
+			It("requires a parent interface", func() {
+				_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+					{Name: "direct", CIDR: "10.100.0.0/24", Mode: "macvlan"},
+				}, 9000)
+				Expect(err).To(MatchError("network pool 'direct': macvlan mode requires a parent interface"))
+			})
+
+			It("rejects deny networks, which cannot be enforced", func() {
+				_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+					{Name: "direct", CIDR: "10.100.0.0/24", Mode: "macvlan", ParentInterface: "eth1", DenyNetworks: []string{"0.0.0.0/0"}},
+				}, 9000)
+				Expect(err).To(MatchError("network pool 'direct': deny networks and host access cannot be enforced in macvlan mode"))
+			})
+
+			It("rejects a gateway outside the pool", func() {
+				_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+					{Name: "direct", CIDR: "10.100.0.0/24", Mode: "macvlan", ParentInterface: "eth1", Gateway: "10.1.0.1"},
+				}, 9000)
+				Expect(err).To(MatchError("network pool 'direct': gateway 10.1.0.1 is not in 10.100.0.0/24"))
+			})
+
+			It("rejects an egress IP, as traffic is not NATed", func() {
+				_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+					{Name: "direct", CIDR: "10.100.0.0/24", Mode: "macvlan", ParentInterface: "eth1", EgressIP: "5.6.7.8"},
+				}, 9000)
+				Expect(err).To(MatchError("network pool 'direct': egress ip cannot be used in macvlan mode, as traffic is not NATed"))
+			})
+		})
+
+		Context("when a pool uses vxlan mode", func() {
+			var spec kawasaki.NetworkPoolSpec
+
+			BeforeEach(func() {
+				spec = kawasaki.NetworkPoolSpec{
+					Name:            "overlay",
+					CIDR:            "10.210.0.0/16",
+					Mode:            "vxlan",
+					VNI:             42,
+					Gateway:         "10.210.2.1",
+					AllocationRange: "10.210.2.0/24",
+					Peers:           []string{"192.168.0.2", "192.168.0.3"},
+				}
+			})
+
+			It("parses the allocation range and peers", func() {
+				pools, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{spec}, 9000)
+				Expect(err).NotTo(HaveOccurred())
+
+				pool := pools["overlay"]
+				Expect(pool.IsVXLAN()).To(BeTrue())
+				Expect(pool.IsDirect()).To(BeFalse())
+				Expect(pool.Gateway.String()).To(Equal("10.210.2.1"))
+				Expect(pool.AllocationRange.String()).To(Equal("10.210.2.0/24"))
+				Expect(pool.Peers.List()).To(Equal([]net.IP{net.ParseIP("192.168.0.2"), net.ParseIP("192.168.0.3")}))
+			})
+
+			It("defaults the port and leaves room for the vxlan headers in the MTU", func() {
+				pools, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{spec}, 9000)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pools["overlay"].Spec.VTEPPort).To(Equal(kawasaki.DefaultVXLANPort))
+				Expect(pools["overlay"].Spec.Mtu).To(Equal(8950))
+			})
+
+			It("counts each address in the allocation range, other than the gateway, as capacity", func() {
+				pools, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{spec}, 9000)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pools["overlay"].Capacity()).To(Equal(255))
+			})
+
+			It("allocates from the whole network when there is no allocation range", func() {
+				spec.AllocationRange = ""
+				spec.Gateway = ""
+
+				pools, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{spec}, 9000)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pools["overlay"].AllocationRange.String()).To(Equal("10.210.0.0/16"))
+				Expect(pools["overlay"].Capacity()).To(Equal(65533))
+			})
+
+			It("requires a valid VNI", func() {
+				spec.VNI = 0
+				_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{spec}, 9000)
+				Expect(err).To(MatchError("network pool 'overlay': vni must be between 1 and 16777215"))
+			})
+
+			It("rejects an allocation range outside the pool", func() {
+				spec.AllocationRange = "10.211.0.0/24"
+				_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{spec}, 9000)
+				Expect(err).To(MatchError("network pool 'overlay': allocation range 10.211.0.0/24 is not in 10.210.0.0/16"))
+			})
+
+			It("rejects a gateway outside the pool", func() {
+				spec.Gateway = "10.1.0.1"
+				_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{spec}, 9000)
+				Expect(err).To(MatchError("network pool 'overlay': gateway 10.1.0.1 is not in 10.210.0.0/16"))
+			})
+
+			It("rejects invalid peers", func() {
+				spec.Peers = []string{"banana"}
+				_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{spec}, 9000)
+				Expect(err).To(MatchError("network pool 'overlay': invalid peer: banana"))
+			})
+
+			It("rejects an MTU which leaves no room for the vxlan headers", func() {
+				spec.Mtu = 8951
+				_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{spec}, 9000)
+				Expect(err).To(MatchError("network pool 'overlay': mtu 8951 leaves no room for the vxlan headers within the maximum of 9000"))
+			})
+		})
+
+		It("parses the egress IP of a pool", func() {
+			pools, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+				{Name: "isolated", CIDR: "10.100.0.0/24", EgressIP: "5.6.7.8"},
+			}, 9000)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pools["isolated"].EgressIP).To(Equal(net.ParseIP("5.6.7.8")))
+		})
+
+		It("rejects an invalid egress IP", func() {
+			_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+				{Name: "isolated", CIDR: "10.100.0.0/24", EgressIP: "banana"},
+			}, 9000)
+			Expect(err).To(MatchError("network pool 'isolated': invalid egress ip: banana"))
+		})
+
+		It("rejects unknown modes", func() {
+			_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+				{Name: "isolated", CIDR: "10.100.0.0/24", Mode: "banana"},
+			}, 9000)
+			Expect(err).To(MatchError("network pool 'isolated': unknown mode: banana"))
+		})
+
+		It("rejects an MTU larger than the host interface's", func() {
+			_, err := kawasaki.NewNetworkPools([]kawasaki.NetworkPoolSpec{
+				{Name: "isolated", CIDR: "10.100.0.0/24", Mtu: 9001},
+			}, 9000)
+			Expect(err).To(MatchError("network pool 'isolated': mtu 9001 exceeds the maximum of 9000"))
+		})
+	})
+
+	Describe("VXLANPeers", func() {
+		var path string
+
+		BeforeEach(func() {
+			file, err := ioutil.TempFile("", "vxlan-peers")
+			Expect(err).NotTo(HaveOccurred())
+			path = file.Name()
+			Expect(file.Close()).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.Remove(path)).To(Succeed())
+		})
+
+		It("lists the static peers followed by those in the peers file", func() {
+			Expect(ioutil.WriteFile(path, []byte(`["192.168.0.4"]`), 0600)).To(Succeed())
+
+			peers := kawasaki.VXLANPeers{Static: []net.IP{net.ParseIP("192.168.0.2")}, File: path}
+			Expect(peers.List()).To(Equal([]net.IP{net.ParseIP("192.168.0.2"), net.ParseIP("192.168.0.4")}))
+		})
+
+		It("re-reads the peers file each time", func() {
+			peers := kawasaki.VXLANPeers{File: path}
+
+			Expect(ioutil.WriteFile(path, []byte(`["192.168.0.4"]`), 0600)).To(Succeed())
+			Expect(peers.List()).To(HaveLen(1))
+
+			Expect(ioutil.WriteFile(path, []byte(`["192.168.0.4", "192.168.0.5"]`), 0600)).To(Succeed())
+			Expect(peers.List()).To(HaveLen(2))
+		})
+
+		It("returns an error when the peers file lists an invalid peer", func() {
+			Expect(ioutil.WriteFile(path, []byte(`["banana"]`), 0600)).To(Succeed())
+
+			_, err := kawasaki.VXLANPeers{File: path}.List()
+			Expect(err).To(MatchError("parsing vxlan peers file: invalid peer: banana"))
+		})
+
+		It("returns an error when the peers file cannot be read", func() {
+			_, err := kawasaki.VXLANPeers{File: "/does/not/exist"}.List()
+			Expect(err).To(MatchError(ContainSubstring("opening vxlan peers file")))
+		})
+	})
+})
@@ -2,17 +2,28 @@ package ports
 
 import (
 	"fmt"
+	"net"
 	"sync"
 )
 
-type PortPool struct {
-	start uint32
-	size  uint32
+// Range is a block of ports which may be mapped to containers on a single
+// external IP. Ranges on different IPs may overlap.
+type Range struct {
+	IP    net.IP
+	Start uint32
+	Size  uint32
+}
 
-	pool      []uint32
+type PortPool struct {
+	ranges    []*portRange
 	poolMutex sync.Mutex
+}
+
+type portRange struct {
+	Range
 
-	state State
+	pool   []uint32
+	owners map[uint32]string
 }
 
 type PoolExhaustedError struct{}
@@ -29,96 +40,223 @@ func (e PortTakenError) Error() string {
 	return fmt.Sprintf("port already acquired: %d", e.Port)
 }
 
-func NewPool(start, size uint32, state State) (*PortPool, error) {
-	if start+size > 65535 {
-		return nil, fmt.Errorf("port_pool: New: invalid port range: startL %d, size: %d", start, size)
+type UnknownExternalIPError struct {
+	IP net.IP
+}
+
+func (e UnknownExternalIPError) Error() string {
+	return fmt.Sprintf("no port range for external ip: %s", e.IP)
+}
+
+// NewPool returns a pool allocating ports from each of the given ranges.
+// Ports recorded as allocated in the state remain owned by their handles
+// until released or pruned.
+func NewPool(ranges []Range, state State) (*PortPool, error) {
+	p := &PortPool{}
+
+	for i, r := range ranges {
+		if r.Start+r.Size > 65535 {
+			return nil, fmt.Errorf("port_pool: New: invalid port range: startL %d, size: %d", r.Start, r.Size)
+		}
+
+		if _, err := p.rangeFor(r.IP); err == nil {
+			return nil, fmt.Errorf("port_pool: New: duplicate port range for external ip: %s", r.IP)
+		}
+
+		rangeState, ok := state.Ranges[r.IP.String()]
+		if !ok && i == 0 {
+			// state files written before ranges were introduced only
+			// recorded the offset of the single range
+			rangeState.Offset = state.Offset
+		}
+
+		p.ranges = append(p.ranges, newPortRange(r, rangeState))
 	}
 
-	if state.Offset >= size {
-		state.Offset = 0
+	return p, nil
+}
+
+func newPortRange(r Range, state RangeState) *portRange {
+	offset := state.Offset
+	if offset >= r.Size {
+		offset = 0
 	}
 
-	pool := make([]uint32, size)
+	owners := map[uint32]string{}
+	for port, handle := range state.Allocations {
+		if port >= r.Start && port < r.Start+r.Size {
+			owners[port] = handle
+		}
+	}
 
-	i := 0
-	for port := start + state.Offset; port < start+size; port++ {
-		pool[i] = port
-		i += 1
+	pool := make([]uint32, 0, r.Size)
+	for i := uint32(0); i < r.Size; i++ {
+		port := r.Start + (offset+i)%r.Size
+		if _, taken := owners[port]; !taken {
+			pool = append(pool, port)
+		}
+	}
+
+	return &portRange{
+		Range:  r,
+		pool:   pool,
+		owners: owners,
 	}
-	for port := start; port < start+state.Offset; port++ {
-		pool[i] = port
-		i += 1
+}
+
+// ExternalIP returns the external IP with the most free ports, preferring
+// earlier ranges when several have the same number free.
+func (p *PortPool) ExternalIP() net.IP {
+	p.poolMutex.Lock()
+	defer p.poolMutex.Unlock()
+
+	var selected *portRange
+	for _, r := range p.ranges {
+		if selected == nil || len(r.pool) > len(selected.pool) {
+			selected = r
+		}
 	}
 
-	return &PortPool{
-		start: start,
-		size:  size,
+	if selected == nil {
+		return nil
+	}
 
-		pool: pool,
-	}, nil
+	return selected.IP
 }
 
-func (p *PortPool) Acquire() (uint32, error) {
+func (p *PortPool) Acquire(handle string, ip net.IP) (uint32, error) {
 	p.poolMutex.Lock()
 	defer p.poolMutex.Unlock()
 
-	if len(p.pool) == 0 {
+	r, err := p.rangeFor(ip)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(r.pool) == 0 {
 		return 0, PoolExhaustedError{}
 	}
 
-	port := p.pool[0]
+	port := r.pool[0]
 
-	p.pool = p.pool[1:]
+	r.pool = r.pool[1:]
+	r.owners[port] = handle
 
 	return port, nil
 }
 
-func (p *PortPool) Remove(port uint32) error {
-	idx := 0
-	found := false
-
+// Remove claims a specific port for the handle. Claiming a port the handle
+// already owns succeeds, so that ports recorded in the state can be
+// restored.
+func (p *PortPool) Remove(handle string, ip net.IP, port uint32) error {
 	p.poolMutex.Lock()
 	defer p.poolMutex.Unlock()
 
-	for i, existingPort := range p.pool {
-		if existingPort == port {
-			idx = i
-			found = true
-			break
-		}
+	r, err := p.rangeFor(ip)
+	if err != nil {
+		return err
 	}
 
-	if !found {
+	if owner, ok := r.owners[port]; ok {
+		if owner == handle {
+			return nil
+		}
+
 		return PortTakenError{port}
 	}
 
-	p.pool = append(p.pool[:idx], p.pool[idx+1:]...)
+	for i, existingPort := range r.pool {
+		if existingPort == port {
+			r.pool = append(r.pool[:i], r.pool[i+1:]...)
+			r.owners[port] = handle
+			return nil
+		}
+	}
 
-	return nil
+	return PortTakenError{port}
 }
 
-func (p *PortPool) Release(port uint32) {
-	if port < p.start || port >= p.start+p.size {
+func (p *PortPool) Release(ip net.IP, port uint32) {
+	p.poolMutex.Lock()
+	defer p.poolMutex.Unlock()
+
+	r, err := p.rangeFor(ip)
+	if err != nil {
 		return
 	}
 
+	r.release(port)
+}
+
+// Prune releases every port owned by a handle which is not in the given
+// list, such as those of containers which disappeared while the server was
+// not running.
+func (p *PortPool) Prune(handles []string) {
 	p.poolMutex.Lock()
 	defer p.poolMutex.Unlock()
 
-	for _, existingPort := range p.pool {
-		if existingPort == port {
-			return
-		}
+	live := map[string]bool{}
+	for _, handle := range handles {
+		live[handle] = true
 	}
 
-	p.pool = append(p.pool, port)
+	for _, r := range p.ranges {
+		for port := r.Start; port < r.Start+r.Size; port++ {
+			if owner, ok := r.owners[port]; ok && !live[owner] {
+				r.release(port)
+			}
+		}
+	}
 }
 
 func (p *PortPool) RefreshState() State {
-	if len(p.pool) == 0 {
-		p.state.Offset = 0
-	} else {
-		p.state.Offset = p.pool[0] - p.start
+	p.poolMutex.Lock()
+	defer p.poolMutex.Unlock()
+
+	state := State{Ranges: map[string]RangeState{}}
+
+	for i, r := range p.ranges {
+		rangeState := RangeState{Allocations: map[uint32]string{}}
+		if len(r.pool) > 0 {
+			rangeState.Offset = r.pool[0] - r.Start
+		}
+
+		for port, handle := range r.owners {
+			rangeState.Allocations[port] = handle
+		}
+
+		if i == 0 {
+			state.Offset = rangeState.Offset
+		}
+
+		state.Ranges[r.IP.String()] = rangeState
+	}
+
+	return state
+}
+
+func (p *PortPool) rangeFor(ip net.IP) (*portRange, error) {
+	for _, r := range p.ranges {
+		if r.IP.Equal(ip) {
+			return r, nil
+		}
 	}
-	return p.state
+
+	return nil, UnknownExternalIPError{ip}
+}
+
+func (r *portRange) release(port uint32) {
+	if port < r.Start || port >= r.Start+r.Size {
+		return
+	}
+
+	delete(r.owners, port)
+
+	for _, existingPort := range r.pool {
+		if existingPort == port {
+			return
+		}
+	}
+
+	r.pool = append(r.pool, port)
 }
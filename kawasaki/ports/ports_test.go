@@ -1,6 +1,8 @@
 package ports_test
 
 import (
+	"net"
+
 	"code.cloudfoundry.org/guardian/kawasaki/ports"
 
 	. "github.com/onsi/ginkgo"
@@ -8,30 +10,138 @@ import (
 )
 
 var _ = Describe("Port pool", func() {
-	var initialState ports.State
+	var (
+		initialState ports.State
+		externalIP   net.IP
+	)
 
 	BeforeEach(func() {
 		initialState = ports.State{Offset: 0}
+		externalIP = net.ParseIP("1.2.3.4")
 	})
 
+	singleRange := func(start, size uint32) []ports.Range {
+		return []ports.Range{{IP: externalIP, Start: start, Size: size}}
+	}
+
 	Describe("initialization", func() {
 		Context("when port range exeeding Linux limit given", func() {
 			It("will return an error", func() {
-				_, err := ports.NewPool(61001, 5000, initialState)
+				_, err := ports.NewPool(singleRange(61001, 5000), initialState)
 				Expect(err).To(MatchError(ContainSubstring("invalid port range")))
 			})
 		})
+
+		Context("when two ranges are given for the same external IP", func() {
+			It("will return an error", func() {
+				_, err := ports.NewPool(append(singleRange(10000, 5), singleRange(20000, 5)...), initialState)
+				Expect(err).To(MatchError(ContainSubstring("duplicate port range for external ip: 1.2.3.4")))
+			})
+		})
+
+		Context("when the state records allocated ports", func() {
+			BeforeEach(func() {
+				initialState.Ranges = map[string]ports.RangeState{
+					"1.2.3.4": {Allocations: map[uint32]string{10000: "some-handle", 10002: "other-handle", 20000: "gone-handle"}},
+				}
+			})
+
+			It("does not hand them out again", func() {
+				pool, err := ports.NewPool(singleRange(10000, 4), initialState)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(pool.Acquire("new-handle", externalIP)).To(Equal(uint32(10001)))
+				Expect(pool.Acquire("new-handle", externalIP)).To(Equal(uint32(10003)))
+
+				_, err = pool.Acquire("new-handle", externalIP)
+				Expect(err).To(Equal(ports.PoolExhaustedError{}))
+			})
+
+			It("keeps the owner of each port which is still in range", func() {
+				pool, err := ports.NewPool(singleRange(10000, 4), initialState)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(pool.RefreshState().Ranges["1.2.3.4"].Allocations).To(Equal(map[uint32]string{
+					10000: "some-handle",
+					10002: "other-handle",
+				}))
+			})
+		})
+	})
+
+	Describe("multiple external IPs", func() {
+		var (
+			otherIP net.IP
+			pool    *ports.PortPool
+		)
+
+		BeforeEach(func() {
+			otherIP = net.ParseIP("5.6.7.8")
+
+			var err error
+			pool, err = ports.NewPool([]ports.Range{
+				{IP: externalIP, Start: 10000, Size: 2},
+				{IP: otherIP, Start: 10000, Size: 3},
+			}, initialState)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("allocates the same port number independently on each IP", func() {
+			Expect(pool.Acquire("some-handle", externalIP)).To(Equal(uint32(10000)))
+			Expect(pool.Acquire("other-handle", otherIP)).To(Equal(uint32(10000)))
+		})
+
+		It("exhausts each IP's range separately", func() {
+			for i := 0; i < 2; i++ {
+				_, err := pool.Acquire("some-handle", externalIP)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			_, err := pool.Acquire("some-handle", externalIP)
+			Expect(err).To(Equal(ports.PoolExhaustedError{}))
+
+			Expect(pool.Acquire("some-handle", otherIP)).To(Equal(uint32(10000)))
+		})
+
+		It("selects the external IP with the most free ports", func() {
+			Expect(pool.ExternalIP()).To(Equal(otherIP))
+
+			_, err := pool.Acquire("some-handle", otherIP)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pool.ExternalIP()).To(Equal(externalIP))
+		})
+
+		It("releases a port only on its own IP", func() {
+			Expect(pool.Acquire("some-handle", externalIP)).To(Equal(uint32(10000)))
+			Expect(pool.Acquire("other-handle", otherIP)).To(Equal(uint32(10000)))
+
+			pool.Release(otherIP, 10000)
+
+			Expect(pool.Remove("other-handle", externalIP, 10000)).To(Equal(ports.PortTakenError{Port: 10000}))
+			Expect(pool.Remove("other-handle", otherIP, 10000)).To(Succeed())
+		})
+
+		Context("when the external IP has no range", func() {
+			It("returns an UnknownExternalIPError", func() {
+				unknownIP := net.ParseIP("9.9.9.9")
+
+				_, err := pool.Acquire("some-handle", unknownIP)
+				Expect(err).To(Equal(ports.UnknownExternalIPError{IP: unknownIP}))
+
+				Expect(pool.Remove("some-handle", unknownIP, 10000)).To(Equal(ports.UnknownExternalIPError{IP: unknownIP}))
+			})
+		})
 	})
 
 	Describe("acquiring", func() {
 		It("returns the next available port from the pool", func() {
-			pool, err := ports.NewPool(10000, 5, initialState)
+			pool, err := ports.NewPool(singleRange(10000, 5), initialState)
 			Expect(err).ToNot(HaveOccurred())
 
-			port1, err := pool.Acquire()
+			port1, err := pool.Acquire("some-handle", externalIP)
 			Expect(err).ToNot(HaveOccurred())
 
-			port2, err := pool.Acquire()
+			port2, err := pool.Acquire("some-handle", externalIP)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(port1).To(Equal(uint32(10000)))
@@ -40,15 +150,15 @@ var _ = Describe("Port pool", func() {
 
 		Context("when the pool is exhausted", func() {
 			It("returns an error", func() {
-				pool, err := ports.NewPool(10000, 5, initialState)
+				pool, err := ports.NewPool(singleRange(10000, 5), initialState)
 				Expect(err).ToNot(HaveOccurred())
 
 				for i := 0; i < 5; i++ {
-					_, err := pool.Acquire()
+					_, err := pool.Acquire("some-handle", externalIP)
 					Expect(err).ToNot(HaveOccurred())
 				}
 
-				_, err = pool.Acquire()
+				_, err = pool.Acquire("some-handle", externalIP)
 				Expect(err).To(HaveOccurred())
 			})
 		})
@@ -56,13 +166,13 @@ var _ = Describe("Port pool", func() {
 		Context("when the offset is positive", func() {
 			It("returns the next available port while honoring the offset", func() {
 				initialState.Offset = 2
-				pool, err := ports.NewPool(10000, 5, initialState)
+				pool, err := ports.NewPool(singleRange(10000, 5), initialState)
 				Expect(err).ToNot(HaveOccurred())
 
-				port1, err := pool.Acquire()
+				port1, err := pool.Acquire("some-handle", externalIP)
 				Expect(err).ToNot(HaveOccurred())
 
-				port2, err := pool.Acquire()
+				port2, err := pool.Acquire("some-handle", externalIP)
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(port1).To(Equal(uint32(10002)))
@@ -71,10 +181,10 @@ var _ = Describe("Port pool", func() {
 
 			Context("when offset is greater than the size", func() {
 				It("returns the first port of the range", func() {
-					pool, err := ports.NewPool(10000, 5, initialState)
+					pool, err := ports.NewPool(singleRange(10000, 5), initialState)
 					Expect(err).ToNot(HaveOccurred())
 
-					port, err := pool.Acquire()
+					port, err := pool.Acquire("some-handle", externalIP)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(port).To(Equal(uint32(10000)))
 				})
@@ -85,15 +195,15 @@ var _ = Describe("Port pool", func() {
 				portOffset := uint32(4)
 				initialState.Offset = portOffset
 
-				pool, err := ports.NewPool(startPort, 5, initialState)
+				pool, err := ports.NewPool(singleRange(startPort, 5), initialState)
 				Expect(err).ToNot(HaveOccurred())
 
-				port, err := pool.Acquire()
+				port, err := pool.Acquire("some-handle", externalIP)
 				Expect(port).To(Equal(uint32(10004)))
 				Expect(err).ToNot(HaveOccurred())
 
 				for i := uint32(0); i < portOffset; i++ {
-					port, err := pool.Acquire()
+					port, err := pool.Acquire("some-handle", externalIP)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(port).To(Equal(startPort + i))
 				}
@@ -103,87 +213,118 @@ var _ = Describe("Port pool", func() {
 
 	Describe("removing", func() {
 		It("acquires a specific port from the pool", func() {
-			pool, err := ports.NewPool(10000, 2, initialState)
+			pool, err := ports.NewPool(singleRange(10000, 2), initialState)
 			Expect(err).ToNot(HaveOccurred())
 
-			err = pool.Remove(10000)
+			err = pool.Remove("some-handle", externalIP, 10000)
 			Expect(err).ToNot(HaveOccurred())
 
-			port, err := pool.Acquire()
+			port, err := pool.Acquire("some-handle", externalIP)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(port).To(Equal(uint32(10001)))
 
-			_, err = pool.Acquire()
+			_, err = pool.Acquire("some-handle", externalIP)
 			Expect(err).To(HaveOccurred())
 		})
 
 		Context("when the resource is already acquired", func() {
 			It("returns a PortTakenError", func() {
-				pool, err := ports.NewPool(10000, 2, initialState)
+				pool, err := ports.NewPool(singleRange(10000, 2), initialState)
 				Expect(err).ToNot(HaveOccurred())
 
-				port, err := pool.Acquire()
+				port, err := pool.Acquire("some-handle", externalIP)
 				Expect(err).ToNot(HaveOccurred())
 
-				err = pool.Remove(port)
+				err = pool.Remove("other-handle", externalIP, port)
 				Expect(err).To(Equal(ports.PortTakenError{Port: port}))
 			})
+
+			Context("by the same handle", func() {
+				It("succeeds", func() {
+					pool, err := ports.NewPool(singleRange(10000, 2), initialState)
+					Expect(err).ToNot(HaveOccurred())
+
+					port, err := pool.Acquire("some-handle", externalIP)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(pool.Remove("some-handle", externalIP, port)).To(Succeed())
+				})
+			})
+		})
+	})
+
+	Describe("pruning", func() {
+		It("releases ports owned by handles which are not given", func() {
+			pool, err := ports.NewPool(singleRange(10000, 3), initialState)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(pool.Acquire("live-handle", externalIP)).To(Equal(uint32(10000)))
+			Expect(pool.Acquire("gone-handle", externalIP)).To(Equal(uint32(10001)))
+			Expect(pool.Acquire("live-handle", externalIP)).To(Equal(uint32(10002)))
+
+			pool.Prune([]string{"live-handle"})
+
+			Expect(pool.RefreshState().Ranges["1.2.3.4"].Allocations).To(Equal(map[uint32]string{
+				10000: "live-handle",
+				10002: "live-handle",
+			}))
+			Expect(pool.Acquire("new-handle", externalIP)).To(Equal(uint32(10001)))
 		})
 	})
 
 	Describe("releasing", func() {
 		It("places a port back at the end of the pool", func() {
-			pool, err := ports.NewPool(10000, 2, initialState)
+			pool, err := ports.NewPool(singleRange(10000, 2), initialState)
 			Expect(err).ToNot(HaveOccurred())
 
-			port1, err := pool.Acquire()
+			port1, err := pool.Acquire("some-handle", externalIP)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(port1).To(Equal(uint32(10000)))
 
-			pool.Release(port1)
+			pool.Release(externalIP, port1)
 
-			port2, err := pool.Acquire()
+			port2, err := pool.Acquire("some-handle", externalIP)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(port2).To(Equal(uint32(10001)))
 
-			nextPort, err := pool.Acquire()
+			nextPort, err := pool.Acquire("some-handle", externalIP)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(nextPort).To(Equal(uint32(10000)))
 		})
 
 		Context("when the released port is out of the range", func() {
 			It("does not add it to the pool", func() {
-				pool, err := ports.NewPool(10000, 0, initialState)
+				pool, err := ports.NewPool(singleRange(10000, 0), initialState)
 				Expect(err).ToNot(HaveOccurred())
 
-				pool.Release(20000)
+				pool.Release(externalIP, 20000)
 
-				_, err = pool.Acquire()
+				_, err = pool.Acquire("some-handle", externalIP)
 				Expect(err).To(HaveOccurred())
 			})
 		})
 
 		Context("when the released port is already released", func() {
 			It("does not duplicate it", func() {
-				pool, err := ports.NewPool(10000, 2, initialState)
+				pool, err := ports.NewPool(singleRange(10000, 2), initialState)
 				Expect(err).ToNot(HaveOccurred())
 
-				port1, err := pool.Acquire()
+				port1, err := pool.Acquire("some-handle", externalIP)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(port1).To(Equal(uint32(10000)))
 
-				pool.Release(port1)
-				pool.Release(port1)
+				pool.Release(externalIP, port1)
+				pool.Release(externalIP, port1)
 
-				port2, err := pool.Acquire()
+				port2, err := pool.Acquire("some-handle", externalIP)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(port2).ToNot(Equal(port1))
 
-				port3, err := pool.Acquire()
+				port3, err := pool.Acquire("some-handle", externalIP)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(port3).To(Equal(port1))
 
-				_, err = pool.Acquire()
+				_, err = pool.Acquire("some-handle", externalIP)
 				Expect(err).To(HaveOccurred())
 			})
 		})
@@ -191,22 +332,58 @@ var _ = Describe("Port pool", func() {
 
 	Describe("RefreshState", func() {
 		It("returns the state with the appropriate offset", func() {
-			pool, err := ports.NewPool(10000, 5, initialState)
+			pool, err := ports.NewPool(singleRange(10000, 5), initialState)
 			Expect(err).ToNot(HaveOccurred())
 
-			_, err = pool.Acquire()
+			_, err = pool.Acquire("some-handle", externalIP)
 			Expect(err).NotTo(HaveOccurred())
 
 			newState := pool.RefreshState()
 			Expect(newState.Offset).To(BeNumerically("==", 1))
 		})
 
+		It("returns the offset and owned ports of each range", func() {
+			otherIP := net.ParseIP("5.6.7.8")
+			pool, err := ports.NewPool([]ports.Range{
+				{IP: externalIP, Start: 10000, Size: 5},
+				{IP: otherIP, Start: 20000, Size: 5},
+			}, initialState)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = pool.Acquire("some-handle", otherIP)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = pool.Acquire("other-handle", otherIP)
+			Expect(err).NotTo(HaveOccurred())
+
+			newState := pool.RefreshState()
+			Expect(newState.Ranges).To(Equal(map[string]ports.RangeState{
+				"1.2.3.4": {Offset: 0, Allocations: map[uint32]string{}},
+				"5.6.7.8": {Offset: 2, Allocations: map[uint32]string{20000: "some-handle", 20001: "other-handle"}},
+			}))
+		})
+
+		It("can be used to recreate the pool", func() {
+			pool, err := ports.NewPool(singleRange(10000, 5), initialState)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = pool.Acquire("some-handle", externalIP)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pool.Acquire("other-handle", externalIP)).To(Equal(uint32(10001)))
+			pool.Release(externalIP, 10001)
+
+			recreated, err := ports.NewPool(singleRange(10000, 5), pool.RefreshState())
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(recreated.Remove("other-handle", externalIP, 10000)).To(Equal(ports.PortTakenError{Port: 10000}))
+			Expect(recreated.Acquire("other-handle", externalIP)).To(Equal(uint32(10002)))
+		})
+
 		Context("when port pool is exhausted", func() {
 			It("returns the state reset to offset 0", func() {
-				pool, err := ports.NewPool(10000, 1, initialState)
+				pool, err := ports.NewPool(singleRange(10000, 1), initialState)
 				Expect(err).ToNot(HaveOccurred())
 
-				_, err = pool.Acquire()
+				_, err = pool.Acquire("some-handle", externalIP)
 				Expect(err).NotTo(HaveOccurred())
 
 				newState := pool.RefreshState()
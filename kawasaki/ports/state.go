@@ -7,7 +7,16 @@ import (
 )
 
 type State struct {
+	// Offset is that of the first range, for servers which predate ranges
 	Offset uint32 `json:"offset"`
+
+	// Ranges holds the state of each range, keyed by its external IP
+	Ranges map[string]RangeState `json:"ranges,omitempty"`
+}
+
+type RangeState struct {
+	Offset      uint32            `json:"offset"`
+	Allocations map[uint32]string `json:"allocations,omitempty"`
 }
 
 type StateFileNotFoundError struct {
@@ -40,6 +40,22 @@ var _ = Describe("State", func() {
 			Expect(portPoolState.Offset).To(BeNumerically("==", 10))
 		})
 
+		It("should parse the allocations of each range", func() {
+			Expect(ioutil.WriteFile(filePath, []byte(`{
+				"offset": 10,
+				"ranges": {
+					"1.2.3.4": {"offset": 10, "allocations": {"61001": "some-handle"}}
+				}
+			}`), 0660)).To(Succeed())
+
+			portPoolState, err := ports.LoadState(filePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(portPoolState.Ranges).To(Equal(map[string]ports.RangeState{
+				"1.2.3.4": {Offset: 10, Allocations: map[uint32]string{61001: "some-handle"}},
+			}))
+		})
+
 		Context("when the file does not exist", func() {
 			It("should return a wrapped error", func() {
 				_, err := ports.LoadState("/path/to/not/existing/banana")
@@ -73,6 +89,21 @@ var _ = Describe("State", func() {
 			Expect(string(contents)).To(ContainSubstring("\"offset\":10"))
 		})
 
+		It("should write the allocations of each range", func() {
+			state := ports.State{
+				Ranges: map[string]ports.RangeState{
+					"1.2.3.4": {Offset: 1, Allocations: map[uint32]string{61001: "some-handle"}},
+				},
+			}
+
+			Expect(ports.SaveState(filePath, state)).To(Succeed())
+
+			contents, err := ioutil.ReadFile(filePath)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(contents)).To(ContainSubstring(`"ranges":{"1.2.3.4":{"offset":1,"allocations":{"61001":"some-handle"}}}`))
+		})
+
 		Context("when file can not be created", func() {
 			It("should return a sensible error", func() {
 				state := ports.State{
@@ -1,7 +1,9 @@
 package kawasaki
 
 import (
+	"fmt"
 	"net"
+	"strconv"
 	"strings"
 
 	"code.cloudfoundry.org/guardian/kawasaki/subnets"
@@ -41,3 +43,71 @@ func suffixIfNeeded(spec string) string {
 
 	return spec
 }
+
+// SpecOptions are given as comma-separated key=value pairs after the subnet or
+// pool in a network spec, e.g. "10.0.0.0/30,mtu=9000" or "pool:storage,mtu=9000"
+type SpecOptions struct {
+	// Mtu overrides the MTU of the container's interfaces
+	Mtu int
+
+	// MaxConnections and ConnectionRate override the server's default
+	// connection limits, given as "max-conns" and "conn-rate"
+	MaxConnections *int
+	ConnectionRate *int
+}
+
+// ParseSpecOptions splits the options from a network spec, returning the
+// remaining spec
+func ParseSpecOptions(spec string) (string, SpecOptions, error) {
+	var (
+		remaining []string
+		options   SpecOptions
+	)
+
+	for _, part := range strings.Split(spec, ",") {
+		if !strings.Contains(part, "=") {
+			remaining = append(remaining, part)
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+		switch strings.TrimSpace(kv[0]) {
+		case "mtu":
+			mtu, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+			if err != nil {
+				return "", SpecOptions{}, fmt.Errorf("invalid mtu in network spec: %s", kv[1])
+			}
+			options.Mtu = mtu
+		case "max-conns":
+			max, err := parseLimit(kv[1])
+			if err != nil {
+				return "", SpecOptions{}, fmt.Errorf("invalid max-conns in network spec: %s", kv[1])
+			}
+			options.MaxConnections = &max
+		case "conn-rate":
+			rate, err := parseLimit(kv[1])
+			if err != nil {
+				return "", SpecOptions{}, fmt.Errorf("invalid conn-rate in network spec: %s", kv[1])
+			}
+			options.ConnectionRate = &rate
+		default:
+			return "", SpecOptions{}, fmt.Errorf("unknown network spec option: %s", kv[0])
+		}
+	}
+
+	return strings.Join(remaining, ","), options, nil
+}
+
+// parseLimit parses a non-negative connection limit, where 0 removes the limit
+func parseLimit(value string) (int, error) {
+	limit, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+
+	if limit < 0 {
+		return 0, fmt.Errorf("negative limit: %d", limit)
+	}
+
+	return limit, nil
+}
@@ -73,3 +73,68 @@ var _ = Describe("ParseSpec", func() {
 		})
 	})
 })
+
+var _ = Describe("ParseSpecOptions", func() {
+	It("returns a spec without options unchanged", func() {
+		spec, options, err := kawasaki.ParseSpecOptions("1.2.3.0/30")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec).To(Equal("1.2.3.0/30"))
+		Expect(options).To(Equal(kawasaki.SpecOptions{}))
+	})
+
+	It("parses an mtu following the subnet", func() {
+		spec, options, err := kawasaki.ParseSpecOptions("1.2.3.0/30,mtu=9000")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec).To(Equal("1.2.3.0/30"))
+		Expect(options.Mtu).To(Equal(9000))
+	})
+
+	It("parses an mtu following a pool", func() {
+		spec, options, err := kawasaki.ParseSpecOptions("pool:storage,mtu=9000")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec).To(Equal("pool:storage"))
+		Expect(options.Mtu).To(Equal(9000))
+	})
+
+	It("parses an mtu on its own", func() {
+		spec, options, err := kawasaki.ParseSpecOptions("mtu=9000")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec).To(Equal(""))
+		Expect(options.Mtu).To(Equal(9000))
+	})
+
+	It("returns an error when the mtu is not a number", func() {
+		_, _, err := kawasaki.ParseSpecOptions("mtu=jumbo")
+		Expect(err).To(MatchError("invalid mtu in network spec: jumbo"))
+	})
+
+	It("parses connection limits", func() {
+		spec, options, err := kawasaki.ParseSpecOptions("pool:storage,max-conns=100,conn-rate=10")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spec).To(Equal("pool:storage"))
+		Expect(*options.MaxConnections).To(Equal(100))
+		Expect(*options.ConnectionRate).To(Equal(10))
+	})
+
+	It("parses a connection limit of zero, which removes the default", func() {
+		_, options, err := kawasaki.ParseSpecOptions("max-conns=0")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*options.MaxConnections).To(Equal(0))
+		Expect(options.ConnectionRate).To(BeNil())
+	})
+
+	It("returns an error when a connection limit is negative", func() {
+		_, _, err := kawasaki.ParseSpecOptions("max-conns=-1")
+		Expect(err).To(MatchError("invalid max-conns in network spec: -1"))
+	})
+
+	It("returns an error when a connection rate is not a number", func() {
+		_, _, err := kawasaki.ParseSpecOptions("conn-rate=lots")
+		Expect(err).To(MatchError("invalid conn-rate in network spec: lots"))
+	})
+
+	It("returns an error for unknown options", func() {
+		_, _, err := kawasaki.ParseSpecOptions("1.2.3.0/30,colour=blue")
+		Expect(err).To(MatchError("unknown network spec option: colour"))
+	})
+})
@@ -72,6 +72,26 @@ type FakePool struct {
 	runIfFreeReturnsOnCall map[int]struct {
 		result1 error
 	}
+	FreeStub        func() int
+	freeMutex       sync.RWMutex
+	freeArgsForCall []struct{}
+	freeReturns     struct {
+		result1 int
+	}
+	freeReturnsOnCall map[int]struct {
+		result1 int
+	}
+	IPsStub        func(arg1 *net.IPNet) []net.IP
+	iPsMutex       sync.RWMutex
+	iPsArgsForCall []struct {
+		arg1 *net.IPNet
+	}
+	iPsReturns struct {
+		result1 []net.IP
+	}
+	iPsReturnsOnCall map[int]struct {
+		result1 []net.IP
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -319,6 +339,94 @@ func (fake *FakePool) RunIfFreeReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakePool) Free() int {
+	fake.freeMutex.Lock()
+	ret, specificReturn := fake.freeReturnsOnCall[len(fake.freeArgsForCall)]
+	fake.freeArgsForCall = append(fake.freeArgsForCall, struct{}{})
+	fake.recordInvocation("Free", []interface{}{})
+	fake.freeMutex.Unlock()
+	if fake.FreeStub != nil {
+		return fake.FreeStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.freeReturns.result1
+}
+
+func (fake *FakePool) FreeCallCount() int {
+	fake.freeMutex.RLock()
+	defer fake.freeMutex.RUnlock()
+	return len(fake.freeArgsForCall)
+}
+
+func (fake *FakePool) FreeReturns(result1 int) {
+	fake.FreeStub = nil
+	fake.freeReturns = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *FakePool) FreeReturnsOnCall(i int, result1 int) {
+	fake.FreeStub = nil
+	if fake.freeReturnsOnCall == nil {
+		fake.freeReturnsOnCall = make(map[int]struct {
+			result1 int
+		})
+	}
+	fake.freeReturnsOnCall[i] = struct {
+		result1 int
+	}{result1}
+}
+
+func (fake *FakePool) IPs(arg1 *net.IPNet) []net.IP {
+	fake.iPsMutex.Lock()
+	ret, specificReturn := fake.iPsReturnsOnCall[len(fake.iPsArgsForCall)]
+	fake.iPsArgsForCall = append(fake.iPsArgsForCall, struct {
+		arg1 *net.IPNet
+	}{arg1})
+	fake.recordInvocation("IPs", []interface{}{arg1})
+	fake.iPsMutex.Unlock()
+	if fake.IPsStub != nil {
+		return fake.IPsStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.iPsReturns.result1
+}
+
+func (fake *FakePool) IPsCallCount() int {
+	fake.iPsMutex.RLock()
+	defer fake.iPsMutex.RUnlock()
+	return len(fake.iPsArgsForCall)
+}
+
+func (fake *FakePool) IPsArgsForCall(i int) *net.IPNet {
+	fake.iPsMutex.RLock()
+	defer fake.iPsMutex.RUnlock()
+	return fake.iPsArgsForCall[i].arg1
+}
+
+func (fake *FakePool) IPsReturns(result1 []net.IP) {
+	fake.IPsStub = nil
+	fake.iPsReturns = struct {
+		result1 []net.IP
+	}{result1}
+}
+
+func (fake *FakePool) IPsReturnsOnCall(i int, result1 []net.IP) {
+	fake.IPsStub = nil
+	if fake.iPsReturnsOnCall == nil {
+		fake.iPsReturnsOnCall = make(map[int]struct {
+			result1 []net.IP
+		})
+	}
+	fake.iPsReturnsOnCall[i] = struct {
+		result1 []net.IP
+	}{result1}
+}
+
 func (fake *FakePool) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -332,6 +440,10 @@ func (fake *FakePool) Invocations() map[string][][]interface{} {
 	defer fake.capacityMutex.RUnlock()
 	fake.runIfFreeMutex.RLock()
 	defer fake.runIfFreeMutex.RUnlock()
+	fake.freeMutex.RLock()
+	defer fake.freeMutex.RUnlock()
+	fake.iPsMutex.RLock()
+	defer fake.iPsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
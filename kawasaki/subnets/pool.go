@@ -29,6 +29,12 @@ type Pool interface {
 	// Returns the number of /30 subnets which can be Acquired by a DynamicSubnetSelector.
 	Capacity() int
 
+	// Returns the number of /30 subnets which can still be Acquired by a DynamicSubnetSelector.
+	Free() int
+
+	// Returns the IP addresses associated with the given subnet.
+	IPs(*net.IPNet) []net.IP
+
 	// Run the provided callback if the given subnet is not in use
 	RunIfFree(*net.IPNet, func() error) error
 }
@@ -128,6 +134,29 @@ func (m *pool) Capacity() int {
 	return int(math.Pow(2, float64(total-masked)) / 4)
 }
 
+// Free returns the number of /30 subnets in the pool's dynamic allocation
+// range which have no IP addresses allocated.
+func (p *pool) Free() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	free := p.Capacity()
+	for _, subnet := range existingSubnets(p.allocated) {
+		if p.dynamicRange.Contains(subnet.IP) {
+			free--
+		}
+	}
+
+	return free
+}
+
+func (p *pool) IPs(subnet *net.IPNet) []net.IP {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]net.IP{}, p.allocated[subnet.String()]...)
+}
+
 func (p *pool) RunIfFree(subnet *net.IPNet, cb func() error) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -63,6 +63,43 @@ var _ = Describe("Subnet Pool", func() {
 		})
 	})
 
+	Describe("Free", func() {
+		BeforeEach(func() {
+			defaultSubnetPool = subnetPool("10.2.3.0/27")
+		})
+
+		It("counts the subnets in the dynamic range which have no addresses allocated", func() {
+			Expect(subnetpool.Free()).To(Equal(8))
+
+			subnet, ip, err := subnetpool.Acquire(logger, subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnetpool.Free()).To(Equal(7))
+
+			Expect(subnetpool.Release(subnet, ip)).To(Succeed())
+			Expect(subnetpool.Free()).To(Equal(8))
+		})
+
+		It("does not count subnets outside the dynamic range", func() {
+			_, _, err := subnetpool.Acquire(logger, subnets.StaticSubnetSelector{IPNet: subnetPool("10.9.0.0/30")}, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(subnetpool.Free()).To(Equal(8))
+		})
+	})
+
+	Describe("IPs", func() {
+		BeforeEach(func() {
+			defaultSubnetPool = subnetPool("10.2.3.0/27")
+		})
+
+		It("returns the addresses allocated in the subnet", func() {
+			subnet, ip, err := subnetpool.Acquire(logger, subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(subnetpool.IPs(subnet)).To(Equal([]net.IP{ip}))
+			Expect(subnetpool.IPs(subnetPool("10.9.0.0/30"))).To(BeEmpty())
+		})
+	})
+
 	Describe("Allocating and Releasing", func() {
 		Describe("Static Subnet Allocation", func() {
 			Context("when the requested subnet is within the dynamic allocation range", func() {
@@ -0,0 +1,94 @@
+package netplugin
+
+import (
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/gardener"
+	"code.cloudfoundry.org/lager"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// compositeNetworker runs the built-in networker (bridge, NAT and DNS) and
+// then hands the container to an external plugin for any additional policy or
+// attachments. Both share the same ConfigStore, so the plugin sees the
+// properties recorded by the built-in networker. The plugin must have been
+// created as chained, so that it neither rewrites the container's resolv.conf
+// nor overwrites the built-in networker's properties.
+type compositeNetworker struct {
+	primary gardener.Networker
+	plugin  ExternalNetworker
+}
+
+func NewCompositeNetworker(primary gardener.Networker, plugin ExternalNetworker) *compositeNetworker {
+	return &compositeNetworker{
+		primary: primary,
+		plugin:  plugin,
+	}
+}
+
+func (c *compositeNetworker) Network(log lager.Logger, spec garden.ContainerSpec, pid int) error {
+	if err := c.primary.Network(log, spec, pid); err != nil {
+		return err
+	}
+
+	return c.plugin.Network(log, spec, pid)
+}
+
+// Capacity is bounded by the built-in networker's subnet pool
+func (c *compositeNetworker) Capacity() uint64 {
+	return c.primary.Capacity()
+}
+
+// Destroy tears down in the reverse order to Network, so that the plugin can
+// still rely on the state owned by the built-in networker. The built-in
+// networker is torn down even when the plugin fails, so that its bridge, rules
+// and addresses are not leaked.
+func (c *compositeNetworker) Destroy(log lager.Logger, handle string) error {
+	var result *multierror.Error
+	if err := c.plugin.Destroy(log, handle); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := c.primary.Destroy(log, handle); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	return result.ErrorOrNil()
+}
+
+// NetIn is handled by the built-in networker alone, as it owns the port pool
+// and the port mappings recorded for the container
+func (c *compositeNetworker) NetIn(log lager.Logger, handle string, hostPort, containerPort uint32) (uint32, uint32, error) {
+	return c.primary.NetIn(log, handle, hostPort, containerPort)
+}
+
+func (c *compositeNetworker) NetOut(log lager.Logger, handle string, rule garden.NetOutRule) error {
+	if err := c.primary.NetOut(log, handle, rule); err != nil {
+		return err
+	}
+
+	if !c.plugin.Supports(ActionNetOut) {
+		return nil
+	}
+
+	return c.plugin.NetOut(log, handle, rule)
+}
+
+func (c *compositeNetworker) BulkNetOut(log lager.Logger, handle string, rules []garden.NetOutRule) error {
+	if err := c.primary.BulkNetOut(log, handle, rules); err != nil {
+		return err
+	}
+
+	if !c.plugin.Supports(ActionBulkNetOut) && !c.plugin.Supports(ActionNetOut) {
+		return nil
+	}
+
+	return c.plugin.BulkNetOut(log, handle, rules)
+}
+
+func (c *compositeNetworker) Restore(log lager.Logger, handle string) error {
+	if err := c.primary.Restore(log, handle); err != nil {
+		return err
+	}
+
+	return c.plugin.Restore(log, handle)
+}
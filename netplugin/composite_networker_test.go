@@ -0,0 +1,210 @@
+package netplugin_test
+
+import (
+	"errors"
+	"net"
+	"os/exec"
+
+	"code.cloudfoundry.org/commandrunner/fake_command_runner"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/gardener"
+	"code.cloudfoundry.org/guardian/kawasaki/kawasakifakes"
+	"code.cloudfoundry.org/guardian/netplugin"
+	"code.cloudfoundry.org/guardian/properties"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("CompositeNetworker", func() {
+	var (
+		primary           *kawasakifakes.FakeNetworker
+		fakeCommandRunner *fake_command_runner.FakeCommandRunner
+		configStore       *properties.Manager
+		logger            *lagertest.TestLogger
+		calls             []string
+		pluginOutput      string
+		pluginErr         error
+		resolvConfigurer  *kawasakifakes.FakeDnsResolvConfigurer
+		plugin            netplugin.ExternalNetworker
+		composite         gardener.Networker
+	)
+
+	BeforeEach(func() {
+		calls = []string{}
+		pluginOutput = ""
+		pluginErr = nil
+		logger = lagertest.NewTestLogger("test")
+		configStore = properties.NewManager()
+
+		primary = new(kawasakifakes.FakeNetworker)
+		primary.NetworkStub = func(_ lager.Logger, spec garden.ContainerSpec, _ int) error {
+			calls = append(calls, "primary-network")
+			configStore.Set(spec.Handle, gardener.ContainerIPKey, "10.0.0.2")
+			configStore.Set(spec.Handle, gardener.BridgeIPKey, "10.0.0.1")
+			return nil
+		}
+		primary.DestroyStub = func(_ lager.Logger, _ string) error {
+			calls = append(calls, "primary-destroy")
+			return nil
+		}
+		primary.RestoreStub = func(_ lager.Logger, _ string) error {
+			calls = append(calls, "primary-restore")
+			return nil
+		}
+
+		fakeCommandRunner = fake_command_runner.New()
+		fakeCommandRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: "some/path",
+		}, func(cmd *exec.Cmd) error {
+			calls = append(calls, "plugin-"+cmd.Args[2])
+			cmd.Stdout.Write([]byte(pluginOutput))
+			return pluginErr
+		})
+
+		resolvConfigurer = new(kawasakifakes.FakeDnsResolvConfigurer)
+		plugin = netplugin.New(
+			logger,
+			fakeCommandRunner,
+			configStore,
+			net.ParseIP("1.2.3.4"),
+			nil,
+			nil,
+			resolvConfigurer,
+			"/depot",
+			"some/path",
+			[]string{},
+			true,
+		)
+
+		composite = netplugin.NewCompositeNetworker(primary, plugin)
+	})
+
+	Describe("Network", func() {
+		It("networks the container with the primary networker and then the plugin", func() {
+			Expect(composite.Network(logger, garden.ContainerSpec{Handle: "some-handle"}, 42)).To(Succeed())
+			Expect(calls).To(Equal([]string{"primary-network", "plugin-up"}))
+		})
+
+		It("leaves the container's DNS configuration to the primary networker", func() {
+			pluginOutput = `{"dns_servers": ["8.8.8.8"]}`
+			Expect(composite.Network(logger, garden.ContainerSpec{Handle: "some-handle"}, 42)).To(Succeed())
+			Expect(resolvConfigurer.ConfigureCallCount()).To(Equal(0))
+		})
+
+		Context("when the plugin returns properties", func() {
+			BeforeEach(func() {
+				configStore.Set("some-handle", "kawasaki.mtu", "1500")
+				pluginOutput = `{"properties": {"kawasaki.mtu": "9000", "policy.tag": "abc"}}`
+			})
+
+			It("records them", func() {
+				Expect(composite.Network(logger, garden.ContainerSpec{Handle: "some-handle"}, 42)).To(Succeed())
+				tag, _ := configStore.Get("some-handle", "policy.tag")
+				Expect(tag).To(Equal("abc"))
+			})
+
+			It("does not let them overwrite the primary networker's properties", func() {
+				Expect(composite.Network(logger, garden.ContainerSpec{Handle: "some-handle"}, 42)).To(Succeed())
+				mtu, _ := configStore.Get("some-handle", "kawasaki.mtu")
+				Expect(mtu).To(Equal("1500"))
+				Expect(logger).To(gbytes.Say("ignoring-reserved-property"))
+			})
+		})
+
+		Context("when the primary networker fails", func() {
+			BeforeEach(func() {
+				primary.NetworkStub = nil
+				primary.NetworkReturns(errors.New("boom"))
+			})
+
+			It("does not call the plugin", func() {
+				Expect(composite.Network(logger, garden.ContainerSpec{Handle: "some-handle"}, 42)).To(MatchError("boom"))
+				Expect(fakeCommandRunner.ExecutedCommands()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Destroy", func() {
+		It("destroys the plugin's networking before the primary networker's", func() {
+			Expect(composite.Destroy(logger, "some-handle")).To(Succeed())
+			Expect(calls).To(Equal([]string{"plugin-down", "primary-destroy"}))
+		})
+
+		Context("when the plugin fails", func() {
+			BeforeEach(func() {
+				pluginErr = errors.New("boom")
+			})
+
+			It("returns the error", func() {
+				Expect(composite.Destroy(logger, "some-handle")).To(MatchError(ContainSubstring("external networker down: boom")))
+			})
+
+			It("still destroys the primary networker's networking", func() {
+				composite.Destroy(logger, "some-handle")
+				Expect(calls).To(Equal([]string{"plugin-down", "primary-destroy"}))
+			})
+
+			Context("and the primary networker fails too", func() {
+				BeforeEach(func() {
+					primary.DestroyReturns(errors.New("bang"))
+				})
+
+				It("returns both errors", func() {
+					err := composite.Destroy(logger, "some-handle")
+					Expect(err).To(MatchError(ContainSubstring("external networker down: boom")))
+					Expect(err).To(MatchError(ContainSubstring("bang")))
+				})
+			})
+		})
+	})
+
+	Describe("Restore", func() {
+		It("restores the primary networker and then the plugin", func() {
+			Expect(composite.Restore(logger, "some-handle")).To(Succeed())
+			Expect(calls).To(Equal([]string{"primary-restore"}))
+		})
+	})
+
+	Describe("NetIn", func() {
+		It("only asks the primary networker", func() {
+			primary.NetInReturns(1234, 8080, nil)
+
+			hostPort, containerPort, err := composite.NetIn(logger, "some-handle", 0, 8080)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hostPort).To(BeEquivalentTo(1234))
+			Expect(containerPort).To(BeEquivalentTo(8080))
+			Expect(fakeCommandRunner.ExecutedCommands()).To(BeEmpty())
+		})
+	})
+
+	Describe("BulkNetOut", func() {
+		BeforeEach(func() {
+			configStore.Set("some-handle", gardener.ContainerIPKey, "10.0.0.2")
+		})
+
+		It("applies the rules with both the primary networker and the plugin", func() {
+			rules := []garden.NetOutRule{createRule("1.1.1.1", "2.2.2.2", 1111, 2222)}
+			Expect(composite.BulkNetOut(logger, "some-handle", rules)).To(Succeed())
+
+			Expect(primary.BulkNetOutCallCount()).To(Equal(1))
+			Expect(fakeCommandRunner.ExecutedCommands()).To(HaveLen(1))
+			checkBulkPluginArgs(fakeCommandRunner.ExecutedCommands()[0], rules)
+		})
+
+		Context("when the plugin supports neither net-out nor bulk-net-out", func() {
+			BeforeEach(func() {
+				pluginOutput = `{"protocol_version": 1, "capabilities": ["up", "down"]}`
+			})
+
+			It("only applies the rules with the primary networker", func() {
+				Expect(plugin.Start()).To(Succeed())
+				Expect(composite.BulkNetOut(logger, "some-handle", nil)).To(Succeed())
+				Expect(fakeCommandRunner.ExecutedCommands()).To(HaveLen(1))
+			})
+		})
+	})
+})
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"net"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"code.cloudfoundry.org/commandrunner"
@@ -18,45 +21,137 @@ import (
 
 const NetworkPropertyPrefix = "network."
 
+// ProtocolVersion is the newest version of the plugin protocol understood by
+// gdn. Plugins which do not answer the capabilities handshake are treated as
+// version 0 and are sent the original, un-versioned inputs.
+const ProtocolVersion = 1
+
+const (
+	ActionCapabilities = "capabilities"
+	ActionUp           = "up"
+	ActionDown         = "down"
+	ActionNetIn        = "net-in"
+	ActionNetOut       = "net-out"
+	ActionBulkNetOut   = "bulk-net-out"
+)
+
+// the context of a container is recorded in its bundle, rather than in its
+// properties, so that it is not returned to clients with the container's info
+const containerContextFile = "network-plugin-context.json"
+
+// properties in this namespace belong to the built-in networker, which a
+// chained plugin may not overwrite
+const kawasakiPropertyPrefix = "kawasaki."
+
+var legacyCapabilities = []string{ActionUp, ActionDown, ActionNetIn, ActionNetOut, ActionBulkNetOut}
+
 type externalBinaryNetworker struct {
+	log                   lager.Logger
 	commandRunner         commandrunner.CommandRunner
 	configStore           kawasaki.ConfigStore
 	externalIP            net.IP
 	operatorNameservers   []net.IP
 	additionalNameservers []net.IP
 	resolvConfigurer      kawasaki.DnsResolvConfigurer
+	depotDir              string
 	path                  string
 	extraArg              []string
+	chained               bool
+
+	protocolVersion int
+	capabilities    map[string]bool
 }
 
 func New(
+	log lager.Logger,
 	commandRunner commandrunner.CommandRunner,
 	configStore kawasaki.ConfigStore,
 	externalIP net.IP,
 	operatorNameServers []net.IP,
 	additionalNameservers []net.IP,
 	resolvConfigurer kawasaki.DnsResolvConfigurer,
+	depotDir string,
 	path string,
 	extraArg []string,
+	chained bool,
 ) ExternalNetworker {
 	return &externalBinaryNetworker{
+		log:                   log,
 		commandRunner:         commandRunner,
 		configStore:           configStore,
 		externalIP:            externalIP,
 		operatorNameservers:   operatorNameServers,
 		additionalNameservers: additionalNameservers,
 		resolvConfigurer:      resolvConfigurer,
+		depotDir:              depotDir,
 		path:                  path,
 		extraArg:              extraArg,
+		chained:               chained,
+		capabilities:          capabilitySet(legacyCapabilities),
 	}
 }
 
 type ExternalNetworker interface {
 	gardener.Networker
 	gardener.Starter
+	Supports(action string) bool
+}
+
+type CapabilitiesOutputs struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// Start negotiates the protocol version and the set of supported actions with
+// the plugin. A plugin which rejects the handshake, or does not report a
+// protocol version, is assumed to be a legacy plugin supporting every action.
+// A plugin which cannot be run at all fails the start.
+func (p *externalBinaryNetworker) Start() error {
+	log := p.log.Session("external-networker-start")
+
+	outputs := CapabilitiesOutputs{}
+	err := p.exec(log, ActionCapabilities, "", nil, &outputs)
+	if _, rejected := err.(actionError); err != nil && !rejected {
+		return err
+	}
+
+	if err != nil || outputs.ProtocolVersion == 0 {
+		log.Info("assuming-legacy-plugin", lager.Data{"error": fmt.Sprint(err)})
+		p.protocolVersion = 0
+		p.capabilities = capabilitySet(legacyCapabilities)
+		return nil
+	}
+
+	p.protocolVersion = outputs.ProtocolVersion
+	if p.protocolVersion > ProtocolVersion {
+		p.protocolVersion = ProtocolVersion
+	}
+	p.capabilities = capabilitySet(outputs.Capabilities)
+
+	if !p.Supports(ActionUp) {
+		return fmt.Errorf("external networker does not support the required '%s' action", ActionUp)
+	}
+
+	return nil
 }
 
-func (p *externalBinaryNetworker) Start() error { return nil }
+func capabilitySet(actions []string) map[string]bool {
+	set := map[string]bool{}
+	for _, action := range actions {
+		set[action] = true
+	}
+
+	return set
+}
+
+// Supports reports whether the plugin negotiated support for the given action
+func (p *externalBinaryNetworker) Supports(action string) bool {
+	return p.capabilities[action]
+}
+
+func unsupportedActionError(action string) error {
+	return fmt.Errorf("external networker does not support the '%s' action", action)
+}
 
 func networkProperties(containerProperties garden.Properties) garden.Properties {
 	properties := garden.Properties{}
@@ -71,13 +166,34 @@ func networkProperties(containerProperties garden.Properties) garden.Properties
 	return properties
 }
 
+// ContainerContext describes the container being networked. It is only sent
+// to plugins speaking protocol version 1 or later.
+type ContainerContext struct {
+	Handle     string            `json:"handle"`
+	Network    string            `json:"network,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Limits     garden.Limits     `json:"limits"`
+	Privileged bool              `json:"privileged"`
+	BundlePath string            `json:"bundle_path"`
+}
+
+type VersionedInputs struct {
+	ProtocolVersion int               `json:"protocol_version,omitempty"`
+	Container       *ContainerContext `json:"container,omitempty"`
+}
+
 type UpInputs struct {
+	VersionedInputs
 	Pid        int
 	Properties map[string]string
 	NetOut     []garden.NetOutRule `json:"netout_rules,omitempty"`
 	NetIn      []garden.NetIn      `json:"netin,omitempty"`
 }
 
+type DownInputs struct {
+	VersionedInputs
+}
+
 type UpOutputs struct {
 	Properties map[string]string
 	DNSServers []string `json:"dns_servers,omitempty"`
@@ -86,23 +202,45 @@ type UpOutputs struct {
 func (p *externalBinaryNetworker) Network(log lager.Logger, containerSpec garden.ContainerSpec, pid int) error {
 	p.configStore.Set(containerSpec.Handle, gardener.ExternalIPKey, p.externalIP.String())
 
+	if p.protocolVersion > 0 {
+		if err := p.saveContainerContext(containerSpec); err != nil {
+			return err
+		}
+	}
+
 	inputs := UpInputs{
-		Pid:        pid,
-		Properties: networkProperties(containerSpec.Properties),
-		NetOut:     containerSpec.NetOut,
-		NetIn:      containerSpec.NetIn,
+		VersionedInputs: p.versionedInputs(log, containerSpec.Handle),
+		Pid:             pid,
+		Properties:      networkProperties(containerSpec.Properties),
+		NetOut:          containerSpec.NetOut,
+		NetIn:           containerSpec.NetIn,
 	}
 
 	outputs := UpOutputs{}
-	err := p.exec(log, "up", containerSpec.Handle, inputs, &outputs)
+	err := p.exec(log, ActionUp, containerSpec.Handle, inputs, &outputs)
 	if err != nil {
 		return err
 	}
 
 	for k, v := range outputs.Properties {
+		if p.chained && strings.HasPrefix(k, kawasakiPropertyPrefix) {
+			log.Info("external-networker-ignoring-reserved-property", lager.Data{"property": k})
+			continue
+		}
+
 		p.configStore.Set(containerSpec.Handle, k, v)
 	}
 
+	// when chained, the built-in networker has already written the
+	// container's resolv.conf
+	if p.chained {
+		if outputs.DNSServers != nil {
+			log.Info("external-networker-ignoring-dns-servers", lager.Data{"dnsServers": outputs.DNSServers})
+		}
+
+		return nil
+	}
+
 	var pluginNameservers []net.IP
 	if outputs.DNSServers != nil {
 		pluginNameservers = []net.IP{}
@@ -135,7 +273,16 @@ func (p *externalBinaryNetworker) Network(log lager.Logger, containerSpec garden
 }
 
 func (p *externalBinaryNetworker) Destroy(log lager.Logger, handle string) error {
-	return p.exec(log, "down", handle, nil, nil)
+	if !p.Supports(ActionDown) {
+		log.Info("external-networker-skipping-unsupported-action", lager.Data{"action": ActionDown})
+		return nil
+	}
+
+	if p.protocolVersion == 0 {
+		return p.exec(log, ActionDown, handle, nil, nil)
+	}
+
+	return p.exec(log, ActionDown, handle, DownInputs{p.versionedInputs(log, handle)}, nil)
 }
 
 func (p *externalBinaryNetworker) Restore(log lager.Logger, handle string) error {
@@ -147,6 +294,7 @@ func (p *externalBinaryNetworker) Capacity() (m uint64) {
 }
 
 type NetInInputs struct {
+	VersionedInputs
 	HostIP        string
 	HostPort      uint32
 	ContainerIP   string
@@ -159,20 +307,25 @@ type NetInOutputs struct {
 }
 
 func (p *externalBinaryNetworker) NetIn(log lager.Logger, handle string, hostPort, containerPort uint32) (uint32, uint32, error) {
+	if !p.Supports(ActionNetIn) {
+		return 0, 0, unsupportedActionError(ActionNetIn)
+	}
+
 	containerIP, ok := p.configStore.Get(handle, gardener.ContainerIPKey)
 	if !ok {
 		return 0, 0, fmt.Errorf("cannot find container [%s]\n", handle)
 	}
 
 	inputs := NetInInputs{
-		HostIP:        p.externalIP.String(),
-		ContainerIP:   containerIP,
-		HostPort:      hostPort,
-		ContainerPort: containerPort,
+		VersionedInputs: p.versionedInputs(log, handle),
+		HostIP:          p.externalIP.String(),
+		ContainerIP:     containerIP,
+		HostPort:        hostPort,
+		ContainerPort:   containerPort,
 	}
 	outputs := NetInOutputs{}
 
-	err := p.exec(log, "net-in", handle, inputs, &outputs)
+	err := p.exec(log, ActionNetIn, handle, inputs, &outputs)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -189,22 +342,28 @@ func (p *externalBinaryNetworker) NetIn(log lager.Logger, handle string, hostPor
 }
 
 type NetOutInputs struct {
+	VersionedInputs
 	ContainerIP string            `json:"container_ip"`
 	NetOutRule  garden.NetOutRule `json:"netout_rule"`
 }
 
 func (p *externalBinaryNetworker) NetOut(log lager.Logger, handle string, rule garden.NetOutRule) error {
+	if !p.Supports(ActionNetOut) {
+		return unsupportedActionError(ActionNetOut)
+	}
+
 	containerIP, ok := p.configStore.Get(handle, gardener.ContainerIPKey)
 	if !ok {
 		return fmt.Errorf("cannot find container [%s]\n", handle)
 	}
 
 	inputs := NetOutInputs{
-		ContainerIP: containerIP,
-		NetOutRule:  rule,
+		VersionedInputs: p.versionedInputs(log, handle),
+		ContainerIP:     containerIP,
+		NetOutRule:      rule,
 	}
 
-	err := p.exec(log, "net-out", handle, inputs, nil)
+	err := p.exec(log, ActionNetOut, handle, inputs, nil)
 	if err != nil {
 		return err
 	}
@@ -213,22 +372,110 @@ func (p *externalBinaryNetworker) NetOut(log lager.Logger, handle string, rule g
 }
 
 type BulkNetOutInputs struct {
+	VersionedInputs
 	ContainerIP string              `json:"container_ip"`
 	NetOutRules []garden.NetOutRule `json:"netout_rules"`
 }
 
 func (p *externalBinaryNetworker) BulkNetOut(log lager.Logger, handle string, rules []garden.NetOutRule) error {
+	if !p.Supports(ActionBulkNetOut) {
+		return p.netOutEach(log, handle, rules)
+	}
+
 	containerIP, ok := p.configStore.Get(handle, gardener.ContainerIPKey)
 	if !ok {
 		return fmt.Errorf("cannot find container [%s]\n", handle)
 	}
 
 	inputs := BulkNetOutInputs{
-		ContainerIP: containerIP,
-		NetOutRules: rules,
+		VersionedInputs: p.versionedInputs(log, handle),
+		ContainerIP:     containerIP,
+		NetOutRules:     rules,
+	}
+
+	return p.exec(log, ActionBulkNetOut, handle, inputs, nil)
+}
+
+// netOutEach falls back to one net-out call per rule for plugins which do not
+// support bulk-net-out
+func (p *externalBinaryNetworker) netOutEach(log lager.Logger, handle string, rules []garden.NetOutRule) error {
+	for _, rule := range rules {
+		if err := p.NetOut(log, handle, rule); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *externalBinaryNetworker) versionedInputs(log lager.Logger, handle string) VersionedInputs {
+	if p.protocolVersion == 0 {
+		return VersionedInputs{}
 	}
 
-	return p.exec(log, "bulk-net-out", handle, inputs, nil)
+	return VersionedInputs{
+		ProtocolVersion: p.protocolVersion,
+		Container:       p.loadContainerContext(log, handle),
+	}
+}
+
+func (p *externalBinaryNetworker) saveContainerContext(containerSpec garden.ContainerSpec) error {
+	containerContext := ContainerContext{
+		Handle:     containerSpec.Handle,
+		Network:    containerSpec.Network,
+		Properties: containerSpec.Properties,
+		Limits:     containerSpec.Limits,
+		Privileged: containerSpec.Privileged,
+		BundlePath: filepath.Join(p.depotDir, containerSpec.Handle),
+	}
+
+	contextJSON, err := json.Marshal(containerContext)
+	if err != nil {
+		return fmt.Errorf("marshaling container context: %s", err)
+	}
+
+	if err := ioutil.WriteFile(p.containerContextPath(containerSpec.Handle), contextJSON, 0600); err != nil {
+		return fmt.Errorf("writing container context: %s", err)
+	}
+
+	return nil
+}
+
+func (p *externalBinaryNetworker) containerContextPath(handle string) string {
+	return filepath.Join(p.depotDir, handle, containerContextFile)
+}
+
+// loadContainerContext returns nil, so that no context is sent to the plugin,
+// when the recorded context cannot be parsed; sending a partial context could
+// mislead the plugin, and failing would stop the container being torn down
+func (p *externalBinaryNetworker) loadContainerContext(log lager.Logger, handle string) *ContainerContext {
+	containerContext := &ContainerContext{
+		Handle:     handle,
+		BundlePath: filepath.Join(p.depotDir, handle),
+	}
+
+	contextJSON, err := ioutil.ReadFile(p.containerContextPath(handle))
+	if os.IsNotExist(err) {
+		return containerContext
+	}
+
+	if err != nil {
+		log.Error("read-container-context-failed", err, lager.Data{"handle": handle})
+		return nil
+	}
+
+	if err := json.Unmarshal(contextJSON, containerContext); err != nil {
+		log.Error("parse-container-context-failed", err, lager.Data{"handle": handle})
+		return nil
+	}
+
+	return containerContext
+}
+
+// actionError is returned when the plugin ran but failed the action, as
+// opposed to when the plugin could not be run at all
+type actionError struct {
+	error
 }
 
 func (p *externalBinaryNetworker) exec(log lager.Logger, action, handle string,
@@ -239,7 +486,11 @@ func (p *externalBinaryNetworker) exec(log lager.Logger, action, handle string,
 		return err
 	}
 
-	args := append(p.extraArg, "--action", action, "--handle", handle)
+	args := append([]string{}, p.extraArg...)
+	args = append(args, "--action", action)
+	if handle != "" {
+		args = append(args, "--handle", handle)
+	}
 	cmd := exec.Command(p.path, args...)
 	stdout := &bytes.Buffer{}
 	cmd.Stdout = stdout
@@ -252,6 +503,10 @@ func (p *externalBinaryNetworker) exec(log lager.Logger, action, handle string,
 	logData := lager.Data{"action": action, "stdin": string(stdinBytes), "stderr": stderr.String(), "stdout": stdout.String()}
 	if err != nil {
 		log.Error("external-networker-result", err, logData)
+		if _, exited := err.(*exec.ExitError); exited {
+			return actionError{fmt.Errorf("external networker %s: %s", action, err)}
+		}
+
 		return fmt.Errorf("external networker %s: %s", action, err)
 	}
 
@@ -259,7 +514,7 @@ func (p *externalBinaryNetworker) exec(log lager.Logger, action, handle string,
 		err = json.Unmarshal(stdout.Bytes(), outputData)
 		if err != nil {
 			log.Error("external-networker-result", err, logData)
-			return fmt.Errorf("unmarshaling result from external networker: %s", err)
+			return actionError{fmt.Errorf("unmarshaling result from external networker: %s", err)}
 		}
 	}
 
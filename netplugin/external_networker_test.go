@@ -5,7 +5,9 @@ import (
 	"errors"
 	"io/ioutil"
 	"net"
+	"os"
 	"os/exec"
+	"path/filepath"
 
 	"code.cloudfoundry.org/commandrunner/fake_command_runner"
 	"code.cloudfoundry.org/garden"
@@ -39,6 +41,7 @@ var _ = Describe("ExternalNetworker", func() {
 		resolvConfigurer     *kawasakifakes.FakeDnsResolvConfigurer
 		pluginOutput         string
 		pluginErr            error
+		depotDir             string
 		dnsServers           = []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("9.9.9.9")}
 		additionalDNSServers = []net.IP{net.ParseIP("11.11.11.11")}
 	)
@@ -62,15 +65,24 @@ var _ = Describe("ExternalNetworker", func() {
 		logger = lagertest.NewTestLogger("test")
 		externalIP := net.ParseIP("1.2.3.4")
 		resolvConfigurer = new(kawasakifakes.FakeDnsResolvConfigurer)
+
+		var err error
+		depotDir, err = ioutil.TempDir("", "depot")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Mkdir(filepath.Join(depotDir, "some-handle"), 0755)).To(Succeed())
+
 		plugin = netplugin.New(
+			logger,
 			fakeCommandRunner,
 			configStore,
 			externalIP,
 			dnsServers,
 			additionalDNSServers,
 			resolvConfigurer,
+			depotDir,
 			"some/path",
 			[]string{"arg1", "arg2", "arg3"},
+			false,
 		)
 
 		pluginErr = nil
@@ -83,6 +95,226 @@ var _ = Describe("ExternalNetworker", func() {
 		})
 	})
 
+	AfterEach(func() {
+		Expect(os.RemoveAll(depotDir)).To(Succeed())
+	})
+
+	Describe("Start", func() {
+		It("executes the external plugin with the capabilities action and no handle", func() {
+			Expect(plugin.Start()).To(Succeed())
+
+			cmd := fakeCommandRunner.ExecutedCommands()[0]
+			Expect(cmd.Args).To(Equal([]string{
+				"some/path",
+				"arg1",
+				"arg2",
+				"arg3",
+				"--action", "capabilities",
+			}))
+		})
+
+		Context("when the external plugin does not understand the capabilities action", func() {
+			BeforeEach(func() {
+				pluginErr = &exec.ExitError{}
+			})
+
+			It("succeeds", func() {
+				Expect(plugin.Start()).To(Succeed())
+			})
+
+			It("logs that it is assuming a legacy plugin", func() {
+				Expect(plugin.Start()).To(Succeed())
+				Expect(logger).To(gbytes.Say("assuming-legacy-plugin"))
+			})
+
+			It("falls back to the legacy inputs", func() {
+				Expect(plugin.Start()).To(Succeed())
+				pluginErr = nil
+
+				Expect(plugin.Network(logger, containerSpec, 42)).To(Succeed())
+
+				pluginInput, err := ioutil.ReadAll(fakeCommandRunner.ExecutedCommands()[1].Stdin)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(pluginInput)).NotTo(ContainSubstring("protocol_version"))
+			})
+		})
+
+		Context("when the external plugin does not return valid JSON for the capabilities action", func() {
+			BeforeEach(func() {
+				pluginOutput = "unknown action"
+			})
+
+			It("assumes a legacy plugin", func() {
+				Expect(plugin.Start()).To(Succeed())
+				Expect(plugin.Supports("net-in")).To(BeTrue())
+			})
+		})
+
+		Context("when the external plugin cannot be run", func() {
+			BeforeEach(func() {
+				pluginErr = errors.New("permission denied")
+			})
+
+			It("returns the error", func() {
+				Expect(plugin.Start()).To(MatchError("external networker capabilities: permission denied"))
+			})
+		})
+
+		Context("when the external plugin does not support up", func() {
+			BeforeEach(func() {
+				pluginOutput = `{"protocol_version": 1, "capabilities": ["down"]}`
+			})
+
+			It("returns an error", func() {
+				Expect(plugin.Start()).To(MatchError("external networker does not support the required 'up' action"))
+			})
+		})
+
+		Context("when the external plugin speaks protocol version 1", func() {
+			var capabilities []string
+
+			BeforeEach(func() {
+				capabilities = []string{"up", "down", "net-in", "net-out", "bulk-net-out"}
+				containerSpec.Privileged = true
+				containerSpec.Limits = garden.Limits{
+					Memory: garden.MemoryLimits{LimitInBytes: 1024},
+				}
+			})
+
+			JustBeforeEach(func() {
+				pluginOutput = mustMarshalJSON(netplugin.CapabilitiesOutputs{
+					ProtocolVersion: 1,
+					Capabilities:    capabilities,
+				})
+				Expect(plugin.Start()).To(Succeed())
+				pluginOutput = ""
+			})
+
+			It("passes the full container context to up", func() {
+				Expect(plugin.Network(logger, containerSpec, 42)).To(Succeed())
+
+				pluginInput, err := ioutil.ReadAll(fakeCommandRunner.ExecutedCommands()[1].Stdin)
+				Expect(err).NotTo(HaveOccurred())
+
+				var inputs netplugin.UpInputs
+				Expect(json.Unmarshal(pluginInput, &inputs)).To(Succeed())
+				Expect(inputs.ProtocolVersion).To(Equal(1))
+				Expect(inputs.Properties).To(Equal(map[string]string{
+					"some-key":       "some-network-value",
+					"some-other-key": "some-other-network-value",
+				}))
+				Expect(*inputs.Container).To(Equal(netplugin.ContainerContext{
+					Handle:     "some-handle",
+					Network:    "potato",
+					Properties: containerSpec.Properties,
+					Limits:     containerSpec.Limits,
+					Privileged: true,
+					BundlePath: filepath.Join(depotDir, "some-handle"),
+				}))
+			})
+
+			It("passes the container context recorded at up to subsequent actions", func() {
+				Expect(plugin.Network(logger, containerSpec, 42)).To(Succeed())
+				Expect(plugin.Destroy(logger, "some-handle")).To(Succeed())
+
+				pluginInput, err := ioutil.ReadAll(fakeCommandRunner.ExecutedCommands()[2].Stdin)
+				Expect(err).NotTo(HaveOccurred())
+
+				var inputs netplugin.DownInputs
+				Expect(json.Unmarshal(pluginInput, &inputs)).To(Succeed())
+				Expect(inputs.ProtocolVersion).To(Equal(1))
+				Expect(inputs.Container.Limits.Memory.LimitInBytes).To(BeEquivalentTo(1024))
+				Expect(inputs.Container.Privileged).To(BeTrue())
+			})
+
+			It("does not record the container context in the container's properties", func() {
+				Expect(plugin.Network(logger, containerSpec, 42)).To(Succeed())
+
+				props, err := configStore.(*properties.Manager).All("some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				for _, value := range props {
+					Expect(value).NotTo(ContainSubstring("bundle_path"))
+				}
+			})
+
+			Context("when the recorded container context cannot be parsed", func() {
+				It("logs the error and sends no container context", func() {
+					Expect(ioutil.WriteFile(filepath.Join(depotDir, "some-handle", "network-plugin-context.json"), []byte("{not json"), 0600)).To(Succeed())
+					Expect(plugin.Destroy(logger, "some-handle")).To(Succeed())
+
+					pluginInput, err := ioutil.ReadAll(fakeCommandRunner.ExecutedCommands()[1].Stdin)
+					Expect(err).NotTo(HaveOccurred())
+
+					var inputs netplugin.DownInputs
+					Expect(json.Unmarshal(pluginInput, &inputs)).To(Succeed())
+					Expect(inputs.ProtocolVersion).To(Equal(1))
+					Expect(inputs.Container).To(BeNil())
+					Expect(logger).To(gbytes.Say("parse-container-context-failed"))
+				})
+			})
+
+			Context("when the plugin reports a newer protocol version", func() {
+				JustBeforeEach(func() {
+					pluginOutput = `{"protocol_version": 99, "capabilities": ["up"]}`
+					Expect(plugin.Start()).To(Succeed())
+					pluginOutput = ""
+				})
+
+				It("uses the newest version gdn understands", func() {
+					Expect(plugin.Network(logger, containerSpec, 42)).To(Succeed())
+
+					pluginInput, err := ioutil.ReadAll(fakeCommandRunner.ExecutedCommands()[2].Stdin)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(pluginInput)).To(ContainSubstring(`"protocol_version":1`))
+				})
+			})
+
+			Context("when the plugin does not support down", func() {
+				BeforeEach(func() {
+					capabilities = []string{"up"}
+				})
+
+				It("skips the plugin on Destroy", func() {
+					Expect(plugin.Destroy(logger, "some-handle")).To(Succeed())
+					Expect(fakeCommandRunner.ExecutedCommands()).To(HaveLen(1))
+				})
+			})
+
+			Context("when the plugin does not support net-in", func() {
+				BeforeEach(func() {
+					capabilities = []string{"up"}
+					configStore.Set(handle, gardener.ContainerIPKey, "5.6.7.8")
+				})
+
+				It("returns an error without executing the plugin", func() {
+					_, _, err := plugin.NetIn(logger, handle, 22, 33)
+					Expect(err).To(MatchError("external networker does not support the 'net-in' action"))
+					Expect(fakeCommandRunner.ExecutedCommands()).To(HaveLen(1))
+				})
+			})
+
+			Context("when the plugin supports net-out but not bulk-net-out", func() {
+				BeforeEach(func() {
+					capabilities = []string{"up", "net-out"}
+					configStore.Set(handle, gardener.ContainerIPKey, "5.6.7.8")
+				})
+
+				It("falls back to a net-out per rule", func() {
+					rules := []garden.NetOutRule{
+						createRule("1.1.1.1", "2.2.2.2", 1111, 2222),
+						createRule("3.3.3.3", "4.4.4.4", 3333, 4444),
+					}
+					Expect(plugin.BulkNetOut(logger, handle, rules)).To(Succeed())
+
+					Expect(fakeCommandRunner.ExecutedCommands()).To(HaveLen(3))
+					Expect(fakeCommandRunner.ExecutedCommands()[1].Args).To(ContainElement("net-out"))
+					checkPluginArgs(fakeCommandRunner.ExecutedCommands()[1], rules[0])
+					checkPluginArgs(fakeCommandRunner.ExecutedCommands()[2], rules[1])
+				})
+			})
+		})
+	})
+
 	Describe("Network", func() {
 		It("passes the pid of the container to the external plugin's stdin", func() {
 			err := plugin.Network(logger, containerSpec, 42)
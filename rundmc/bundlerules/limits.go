@@ -1,6 +1,8 @@
 package bundlerules
 
 import (
+	"fmt"
+
 	"code.cloudfoundry.org/guardian/gardener"
 	"code.cloudfoundry.org/guardian/rundmc/goci"
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -13,21 +15,28 @@ type Limits struct {
 	CpuQuotaPerShare uint64
 	BlockIOWeight    uint16
 	TCPMemoryLimit   int64
+
+	// UnifiedCgroups causes the limits to be expressed as cgroup v2 interface
+	// files rather than v1 resources, for hosts with only the unified hierarchy
+	UnifiedCgroups bool
 }
 
 func (l Limits) Apply(bndl goci.Bndl, spec gardener.DesiredContainerSpec, _ string) (goci.Bndl, error) {
+	if l.UnifiedCgroups {
+		// the runtime converts shares to a cpu.weight itself, and keeping them
+		// lets the container's limits be reported as they were requested
+		shares := uint64(spec.Limits.CPU.LimitInShares)
+		bndl = bndl.WithCPUShares(specs.LinuxCPU{Shares: &shares})
+		return bndl.WithUnifiedResources(l.unified(spec)), nil
+	}
+
 	limit := int64(spec.Limits.Memory.LimitInBytes)
 	bndl = bndl.WithMemoryLimit(specs.LinuxMemory{Limit: &limit, Swap: &limit, KernelTCP: &l.TCPMemoryLimit})
 
 	shares := uint64(spec.Limits.CPU.LimitInShares)
 	cpuSpec := specs.LinuxCPU{Shares: &shares}
-	if l.CpuQuotaPerShare > 0 && shares > 0 {
+	if quota, ok := l.cpuQuota(shares); ok {
 		cpuSpec.Period = &CpuPeriod
-
-		quota := shares * l.CpuQuotaPerShare
-		if quota < MinCpuQuota {
-			quota = MinCpuQuota
-		}
 		cpuSpec.Quota = int64PtrVal(quota)
 	}
 	bndl = bndl.WithCPUShares(cpuSpec)
@@ -38,6 +47,62 @@ func (l Limits) Apply(bndl goci.Bndl, spec gardener.DesiredContainerSpec, _ stri
 	return bndl.WithPidLimit(specs.LinuxPids{Limit: pids}), nil
 }
 
+// unified translates the limits into their cgroup v2 equivalents. There is no
+// separate TCP memory limit in v2, as socket buffers are charged to the
+// container's memory.
+func (l Limits) unified(spec gardener.DesiredContainerSpec) map[string]string {
+	unified := map[string]string{
+		"memory.max": maxOrValue(spec.Limits.Memory.LimitInBytes),
+		"pids.max":   maxOrValue(spec.Limits.Pid.Max),
+	}
+
+	if spec.Limits.Memory.LimitInBytes > 0 {
+		// v1 limits memory+swap to the memory limit, i.e. no swap at all
+		unified["memory.swap.max"] = "0"
+	}
+
+	if quota, ok := l.cpuQuota(spec.Limits.CPU.LimitInShares); ok {
+		unified["cpu.max"] = fmt.Sprintf("%d %d", quota, CpuPeriod)
+	}
+
+	if l.BlockIOWeight > 0 {
+		unified["io.weight"] = fmt.Sprintf("%d", blockIOWeightToIOWeight(l.BlockIOWeight))
+	}
+
+	return unified
+}
+
+func (l Limits) cpuQuota(shares uint64) (uint64, bool) {
+	if l.CpuQuotaPerShare == 0 || shares == 0 {
+		return 0, false
+	}
+
+	quota := shares * l.CpuQuotaPerShare
+	if quota < MinCpuQuota {
+		quota = MinCpuQuota
+	}
+
+	return quota, true
+}
+
+func maxOrValue(n uint64) string {
+	if n == 0 {
+		return "max"
+	}
+
+	return fmt.Sprintf("%d", n)
+}
+
+// blockIOWeightToIOWeight maps v1 blkio weights [10-1000] onto v2 io weights
+// [1-10000], as runc does
+func blockIOWeightToIOWeight(weight uint16) uint64 {
+	if weight < 10 {
+		weight = 10
+	}
+
+	return 1 + (uint64(weight)-10)*9999/990
+}
+
 func int64PtrVal(n uint64) *int64 {
 	unsignedVal := int64(n)
 	return &unsignedVal
@@ -132,4 +132,70 @@ var _ = Describe("LimitsRule", func() {
 
 		Expect(newBndl.Resources().Pids.Limit).To(BeNumerically("==", 1))
 	})
+
+	Context("when the host has only the unified cgroup hierarchy", func() {
+		var limits bundlerules.Limits
+
+		BeforeEach(func() {
+			limits = bundlerules.Limits{
+				CpuQuotaPerShare: 100,
+				BlockIOWeight:    100,
+				TCPMemoryLimit:   100,
+				UnifiedCgroups:   true,
+			}
+		})
+
+		It("sets the limits as cgroup v2 interface files", func() {
+			newBndl, err := limits.Apply(goci.Bundle(), gardener.DesiredContainerSpec{
+				Limits: garden.Limits{
+					Memory: garden.MemoryLimits{LimitInBytes: 4096},
+					CPU:    garden.CPULimits{LimitInShares: 1024},
+					Pid:    garden.PidLimits{Max: 10},
+				},
+			}, "not-needed-path")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(newBndl.Resources().Unified).To(Equal(map[string]string{
+				"memory.max":      "4096",
+				"memory.swap.max": "0",
+				"cpu.max":         "102400 100000",
+				"io.weight":       "910",
+				"pids.max":        "10",
+			}))
+		})
+
+		It("leaves the conversion of cpu shares to the runtime", func() {
+			newBndl, err := limits.Apply(goci.Bundle(), gardener.DesiredContainerSpec{
+				Limits: garden.Limits{
+					CPU: garden.CPULimits{LimitInShares: 1024},
+				},
+			}, "not-needed-path")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(*(newBndl.Resources().CPU.Shares)).To(BeNumerically("==", 1024))
+			Expect(newBndl.Resources().CPU.Quota).To(BeNil())
+		})
+
+		It("does not set any other v1 resources", func() {
+			newBndl, err := limits.Apply(goci.Bundle(), gardener.DesiredContainerSpec{}, "not-needed-path")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(newBndl.Resources().Memory).To(BeNil())
+			Expect(newBndl.Resources().BlockIO).To(BeNil())
+			Expect(newBndl.Resources().Pids).To(BeNil())
+		})
+
+		Context("when no limits are given", func() {
+			It("leaves memory and pids unlimited", func() {
+				limits = bundlerules.Limits{UnifiedCgroups: true}
+				newBndl, err := limits.Apply(goci.Bundle(), gardener.DesiredContainerSpec{}, "not-needed-path")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(newBndl.Resources().Unified).To(Equal(map[string]string{
+					"memory.max": "max",
+					"pids.max":   "max",
+				}))
+			})
+		})
+	})
 })
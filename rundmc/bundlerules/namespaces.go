@@ -13,5 +13,9 @@ func (n Namespaces) Apply(bndl goci.Bndl, spec gardener.DesiredContainerSpec, co
 		bndl = bndl.WithNamespace(specs.LinuxNamespace{Type: specs.LinuxNamespaceType(ns), Path: path})
 	}
 
+	if spec.HostNetwork {
+		bndl = bndl.WithoutNamespace(specs.NetworkNamespace)
+	}
+
 	return bndl, nil
 }
@@ -23,4 +23,18 @@ var _ = Describe("Namespaces", func() {
 			specs.LinuxNamespace{Type: "user", Path: "test-user-ns"},
 		))
 	})
+
+	Context("when the container shares the host network", func() {
+		It("removes the network namespace from the bundle", func() {
+			initialBndl := goci.Bundle().WithNamespaces(
+				specs.LinuxNamespace{Type: specs.NetworkNamespace},
+				specs.LinuxNamespace{Type: specs.MountNamespace},
+			)
+
+			transformedBndl, err := bundlerules.Namespaces{}.Apply(initialBndl, gardener.DesiredContainerSpec{HostNetwork: true}, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(transformedBndl.Namespaces()).To(ConsistOf(specs.LinuxNamespace{Type: specs.MountNamespace}))
+		})
+	})
 })
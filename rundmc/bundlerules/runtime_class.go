@@ -0,0 +1,17 @@
+package bundlerules
+
+import (
+	"code.cloudfoundry.org/guardian/gardener"
+	"code.cloudfoundry.org/guardian/rundmc/goci"
+)
+
+type RuntimeClass struct {
+}
+
+func (r RuntimeClass) Apply(bndl goci.Bndl, spec gardener.DesiredContainerSpec, _ string) (goci.Bndl, error) {
+	if spec.RuntimeClass == "" {
+		return bndl, nil
+	}
+
+	return bndl.WithRuntimeClass(spec.RuntimeClass), nil
+}
@@ -0,0 +1,29 @@
+package bundlerules_test
+
+import (
+	"code.cloudfoundry.org/guardian/gardener"
+	"code.cloudfoundry.org/guardian/rundmc/bundlerules"
+	"code.cloudfoundry.org/guardian/rundmc/goci"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RuntimeClass", func() {
+	It("records the runtime class in the bundle", func() {
+		newBndl, err := bundlerules.RuntimeClass{}.Apply(goci.Bundle(), gardener.DesiredContainerSpec{
+			RuntimeClass: "sandboxed",
+		}, "not-needed-path")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(newBndl.RuntimeClass()).To(Equal("sandboxed"))
+	})
+
+	Context("when no runtime class is requested", func() {
+		It("leaves the bundle unannotated", func() {
+			newBndl, err := bundlerules.RuntimeClass{}.Apply(goci.Bundle(), gardener.DesiredContainerSpec{}, "not-needed-path")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(newBndl.Spec.Annotations).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,87 @@
+package cgroups
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultMountpoint is where the cgroup hierarchies are mounted when gdn does
+// not mount its own
+const DefaultMountpoint = "/sys/fs/cgroup"
+
+// OOMKills returns the number of OOM kills so far in the memory cgroup of the
+// process, in the hierarchies mounted under the mountpoint
+func OOMKills(mountpoint string, pid int) (uint64, error) {
+	path, err := oomKillsPath(mountpoint, pid)
+	if err != nil {
+		return 0, err
+	}
+
+	return readOOMKills(path)
+}
+
+// oomKillsPath returns the file in which the kernel counts the OOM kills in
+// the memory cgroup of the process: memory.events on the unified hierarchy,
+// and memory.oom_control on v1
+func oomKillsPath(mountpoint string, pid int) (string, error) {
+	contents, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return "", err
+	}
+
+	// on hybrid hosts the unified hierarchy is present alongside v1, but the
+	// memory controller is only enabled in one of them
+	unifiedPath := ""
+	for _, line := range strings.Split(string(contents), "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		if fields[0] == "0" && fields[1] == "" {
+			unifiedPath = filepath.Join(mountpoint, fields[2], "memory.events")
+			continue
+		}
+
+		for _, controller := range strings.Split(fields[1], ",") {
+			if controller == "memory" {
+				return filepath.Join(mountpoint, "memory", fields[2], "memory.oom_control"), nil
+			}
+		}
+	}
+
+	if unifiedPath == "" {
+		return "", fmt.Errorf("no memory cgroup found for process %d", pid)
+	}
+
+	return unifiedPath, nil
+}
+
+func readOOMKills(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	// kernels before 4.13 do not count OOM kills
+	return 0, fmt.Errorf("no oom_kill count in %s", path)
+}
@@ -10,6 +10,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 
@@ -20,6 +21,10 @@ import (
 
 const cgroupsHeader = "#subsys_name hierarchy num_cgroups enabled"
 
+// the leaf of its own cgroup which the server moves in to on the unified
+// hierarchy
+const serverCgroup = "server"
+
 type CgroupsFormatError struct {
 	Content string
 }
@@ -74,17 +79,21 @@ func (s *CgroupStarter) mountCgroupsIfNeeded(logger lager.Logger) error {
 		return err
 	}
 
+	subsystemGroupings, unifiedCgroup, err := s.subsystemGroupings()
+	if err != nil {
+		return err
+	}
+
+	if len(subsystemGroupings) == 0 && unifiedCgroup != "" {
+		return s.startUnified(logger, unifiedCgroup)
+	}
+
 	if !s.isMountPoint(s.CgroupPath) {
 		s.mountTmpfsOnCgroupPath(logger, s.CgroupPath)
 	} else {
 		logger.Info("cgroups-tmpfs-already-mounted", lager.Data{"path": s.CgroupPath})
 	}
 
-	subsystemGroupings, err := s.subsystemGroupings()
-	if err != nil {
-		return err
-	}
-
 	scanner := bufio.NewScanner(s.ProcCgroups)
 
 	if !scanner.Scan() {
@@ -137,6 +146,118 @@ func (s *CgroupStarter) mountCgroupsIfNeeded(logger lager.Logger) error {
 	return nil
 }
 
+// startUnified delegates a garden subtree of the server's own cgroup in the
+// cgroup v2 unified hierarchy, leaving the cgroups above it untouched. There
+// is no devices controller in v2; the runtime enforces each container's device
+// rules itself.
+func (s *CgroupStarter) startUnified(logger lager.Logger, ownCgroup string) error {
+	logger = logger.Session("unified")
+
+	if err := s.idempotentUnifiedMount(logger, s.CgroupPath); err != nil {
+		return err
+	}
+
+	// a cgroup other than the root cannot enable controllers for its children
+	// while it has processes in it, so the server first moves in to a leaf of
+	// its own cgroup. runc, started from the leaf, then places containers with
+	// relative cgroup paths beneath the server's own cgroup.
+	ownCgroupPath := filepath.Join(s.CgroupPath, ownCgroup)
+	if path.Clean(ownCgroup) != "/" {
+		if err := s.moveToLeafCgroup(logger, ownCgroupPath); err != nil {
+			return err
+		}
+	}
+
+	gardenCgroupPath := filepath.Join(ownCgroupPath, s.GardenCgroup)
+	if err := s.createGardenCgroup(logger, gardenCgroupPath); err != nil {
+		return err
+	}
+
+	controllers, err := ioutil.ReadFile(filepath.Join(ownCgroupPath, "cgroup.controllers"))
+	if err != nil {
+		return fmt.Errorf("reading available controllers: %s", err)
+	}
+
+	for _, cgroupPath := range []string{ownCgroupPath, gardenCgroupPath} {
+		if err := enableControllers(cgroupPath, strings.Fields(string(controllers))); err != nil {
+			return err
+		}
+	}
+
+	return s.Chowner.RecursiveChown(gardenCgroupPath)
+}
+
+// moveToLeafCgroup moves every process in the given cgroup, including the
+// server, in to its leaf server cgroup
+func (s *CgroupStarter) moveToLeafCgroup(logger lager.Logger, cgroupPath string) error {
+	leafCgroupPath := filepath.Join(cgroupPath, serverCgroup)
+	logger = logger.Session("move-to-leaf-cgroup", lager.Data{"path": leafCgroupPath})
+	logger.Info("started")
+	defer logger.Info("finished")
+
+	if err := os.MkdirAll(leafCgroupPath, 0755); err != nil {
+		return err
+	}
+
+	procs, err := ioutil.ReadFile(filepath.Join(cgroupPath, "cgroup.procs"))
+	if err != nil {
+		return fmt.Errorf("reading processes in '%s': %s", cgroupPath, err)
+	}
+
+	leafProcs, err := os.OpenFile(filepath.Join(leafCgroupPath, "cgroup.procs"), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer leafProcs.Close()
+
+	// the kernel only accepts one process per write
+	for _, pid := range strings.Fields(string(procs)) {
+		if _, err := leafProcs.Write([]byte(pid + "\n")); err != nil {
+			if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.ESRCH {
+				// the process has since exited
+				continue
+			}
+
+			return fmt.Errorf("moving process %s to '%s': %s", pid, leafCgroupPath, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *CgroupStarter) idempotentUnifiedMount(logger lager.Logger, cgroupPath string) error {
+	logger = logger.Session("mount-cgroup2", lager.Data{"path": cgroupPath})
+
+	logger.Info("started")
+
+	if !s.isMountPoint(cgroupPath) {
+		cmd := exec.Command("mount", "-n", "-t", "cgroup2", "cgroup2", cgroupPath)
+		cmd.Stderr = logging.Writer(logger.Session("mount-cgroup2-cmd"))
+		if err := s.CommandRunner.Run(cmd); err != nil {
+			return fmt.Errorf("mounting cgroup2 in '%s': %s", cgroupPath, err)
+		}
+	} else {
+		logger.Info("cgroup2-already-mounted")
+	}
+
+	logger.Info("finished")
+
+	return nil
+}
+
+func enableControllers(cgroupPath string, controllers []string) error {
+	if len(controllers) == 0 {
+		return nil
+	}
+
+	data := "+" + strings.Join(controllers, " +")
+	if err := ioutil.WriteFile(filepath.Join(cgroupPath, "cgroup.subtree_control"), []byte(data), 0); err != nil {
+		return fmt.Errorf("enabling controllers '%s' in '%s': %s", data, cgroupPath, err)
+	}
+
+	return nil
+}
+
 func (s *CgroupStarter) modifyAllowedDevices(dir string, devices []specs.LinuxDeviceCgroup) error {
 	if has, err := hasSubdirectories(dir); err != nil {
 		return err
@@ -212,8 +333,11 @@ type group struct {
 	Path      string
 }
 
-func (s *CgroupStarter) subsystemGroupings() (map[string]group, error) {
+// subsystemGroupings returns the v1 hierarchies the process belongs to, along
+// with its cgroup in the v2 unified hierarchy, if any
+func (s *CgroupStarter) subsystemGroupings() (map[string]group, string, error) {
 	groupings := map[string]group{}
+	unifiedCgroup := ""
 
 	scanner := bufio.NewScanner(s.ProcSelfCgroups)
 	for scanner.Scan() {
@@ -222,13 +346,18 @@ func (s *CgroupStarter) subsystemGroupings() (map[string]group, error) {
 			continue
 		}
 
+		if segs[0] == "0" && segs[1] == "" {
+			unifiedCgroup = segs[2]
+			continue
+		}
+
 		subsystems := strings.Split(segs[1], ",")
 		for _, subsystem := range subsystems {
 			groupings[subsystem] = group{segs[1], segs[2]}
 		}
 	}
 
-	return groupings, scanner.Err()
+	return groupings, unifiedCgroup, scanner.Err()
 }
 
 func (s *CgroupStarter) idempotentCgroupMount(logger lager.Logger, cgroupPath, subsystems string) error {
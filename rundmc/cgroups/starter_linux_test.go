@@ -305,6 +305,158 @@ var _ = Describe("CgroupStarter", func() {
 		})
 	})
 
+	Context("when the host has only the unified cgroup hierarchy", func() {
+		BeforeEach(func() {
+			procCgroupsContents = "#subsys_name\thierarchy\tnum_cgroups\tenabled\n" +
+				"memory\t0\t1\t1\n"
+
+			procSelfCgroupsContents = "0::/system.slice/garden.service\n"
+
+			serviceCgroupPath := path.Join(tmpDir, "cgroup", "system.slice", "garden.service")
+			Expect(os.MkdirAll(serviceCgroupPath, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(serviceCgroupPath, "cgroup.controllers"), []byte("cpu io memory pids\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(serviceCgroupPath, "cgroup.procs"), []byte("123\n456\n"), 0644)).To(Succeed())
+		})
+
+		It("succeeds", func() {
+			Expect(starter.Start()).To(Succeed())
+		})
+
+		It("does not mount any v1 hierarchies", func() {
+			Expect(starter.Start()).To(Succeed())
+
+			Expect(runner).NotTo(HaveExecutedSerially(fake_command_runner.CommandSpec{
+				Path: "mount",
+				Args: []string{"-t", "tmpfs", "-o", "uid=0,gid=0,mode=0755", "cgroup", path.Join(tmpDir, "cgroup")},
+			}))
+			Expect(runner).NotTo(HaveExecutedSerially(fake_command_runner.CommandSpec{
+				Path: "mount",
+				Args: []string{"-n", "-t", "cgroup", "-o", "memory", "cgroup", path.Join(tmpDir, "cgroup", "memory")},
+			}))
+		})
+
+		It("moves the processes in the server's cgroup in to a leaf of it", func() {
+			Expect(starter.Start()).To(Succeed())
+
+			content := readFile(path.Join(tmpDir, "cgroup", "system.slice", "garden.service", "server", "cgroup.procs"))
+			Expect(string(content)).To(Equal("123\n456\n"))
+		})
+
+		It("creates the garden cgroup beneath the server's own, owned by the specified user and group", func() {
+			Expect(starter.Start()).To(Succeed())
+
+			gardenCgroupPath := path.Join(tmpDir, "cgroup", "system.slice", "garden.service", "garden")
+			Expect(gardenCgroupPath).To(BeADirectory())
+			Expect(chowner.RecursiveChownCallCount()).To(Equal(1))
+			Expect(chowner.RecursiveChownArgsForCall(0)).To(Equal(gardenCgroupPath))
+		})
+
+		It("delegates the available controllers to the garden cgroup and its children", func() {
+			Expect(starter.Start()).To(Succeed())
+
+			content := readFile(path.Join(tmpDir, "cgroup", "system.slice", "garden.service", "cgroup.subtree_control"))
+			Expect(string(content)).To(Equal("+cpu +io +memory +pids"))
+
+			content = readFile(path.Join(tmpDir, "cgroup", "system.slice", "garden.service", "garden", "cgroup.subtree_control"))
+			Expect(string(content)).To(Equal("+cpu +io +memory +pids"))
+		})
+
+		It("does not enable controllers above the server's own cgroup", func() {
+			Expect(starter.Start()).To(Succeed())
+			Expect(path.Join(tmpDir, "cgroup", "system.slice", "cgroup.subtree_control")).NotTo(BeAnExistingFile())
+		})
+
+		It("does not write any device rules", func() {
+			Expect(starter.Start()).To(Succeed())
+			Expect(path.Join(tmpDir, "cgroup", "system.slice", "garden.service", "garden", "devices.deny")).NotTo(BeAnExistingFile())
+		})
+
+		Context("when the cgroup path is not a mountpoint", func() {
+			BeforeEach(func() {
+				runner.WhenRunning(fake_command_runner.CommandSpec{
+					Path: "mountpoint",
+					Args: []string{"-q", path.Join(tmpDir, "cgroup") + "/"},
+				}, func(cmd *exec.Cmd) error {
+					return errors.New("not a mountpoint")
+				})
+			})
+
+			It("mounts the unified hierarchy there", func() {
+				Expect(starter.Start()).To(Succeed())
+				Expect(runner).To(HaveExecutedSerially(fake_command_runner.CommandSpec{
+					Path: "mount",
+					Args: []string{"-n", "-t", "cgroup2", "cgroup2", path.Join(tmpDir, "cgroup")},
+				}))
+			})
+
+			Context("and mounting fails", func() {
+				BeforeEach(func() {
+					runner.WhenRunning(fake_command_runner.CommandSpec{
+						Path: "mount",
+					}, func(cmd *exec.Cmd) error {
+						return errors.New("no cgroup2 for you")
+					})
+				})
+
+				It("returns an error", func() {
+					Expect(starter.Start()).To(MatchError(ContainSubstring("no cgroup2 for you")))
+				})
+			})
+		})
+
+		Context("when the server is in the root cgroup", func() {
+			BeforeEach(func() {
+				procSelfCgroupsContents = "0::/\n"
+				Expect(ioutil.WriteFile(path.Join(tmpDir, "cgroup", "cgroup.controllers"), []byte("memory pids\n"), 0644)).To(Succeed())
+			})
+
+			It("creates the garden cgroup beneath the root", func() {
+				Expect(starter.Start()).To(Succeed())
+
+				Expect(path.Join(tmpDir, "cgroup", "garden")).To(BeADirectory())
+				content := readFile(path.Join(tmpDir, "cgroup", "garden", "cgroup.subtree_control"))
+				Expect(string(content)).To(Equal("+memory +pids"))
+			})
+
+			It("does not move the server, as the root may have both processes and controllers enabled", func() {
+				Expect(starter.Start()).To(Succeed())
+				Expect(path.Join(tmpDir, "cgroup", "server")).NotTo(BeADirectory())
+			})
+		})
+
+		Context("when the processes in the server's cgroup cannot be read", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(path.Join(tmpDir, "cgroup", "system.slice", "garden.service", "cgroup.procs"))).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(starter.Start()).To(MatchError(ContainSubstring("reading processes in")))
+			})
+		})
+
+		Context("when the available controllers cannot be read", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(path.Join(tmpDir, "cgroup", "system.slice", "garden.service", "cgroup.controllers"))).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(starter.Start()).To(MatchError(ContainSubstring("reading available controllers")))
+			})
+		})
+	})
+
+	Context("when the host has both v1 hierarchies and the unified hierarchy", func() {
+		BeforeEach(func() {
+			procSelfCgroupsContents = "5:devices:/\n" +
+				"0::/\n"
+		})
+
+		It("uses the v1 hierarchies", func() {
+			Expect(starter.Start()).To(Succeed())
+			Expect(path.Join(tmpDir, "cgroup", "devices", "garden", "devices.deny")).To(BeAnExistingFile())
+		})
+	})
+
 	Context("when /proc/cgroups contains malformed entries", func() {
 		BeforeEach(func() {
 			procCgroupsContents = "#subsys_name\thierarchy\tnum_cgroups\tenabled\n" +
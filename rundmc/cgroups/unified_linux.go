@@ -0,0 +1,17 @@
+package cgroups
+
+import "syscall"
+
+// CGROUP2_SUPER_MAGIC, see statfs(2)
+const cgroup2SuperMagic = 0x63677270
+
+// IsUnified returns whether the hierarchy mounted at the given path is the
+// cgroup v2 unified hierarchy
+func IsUnified(mountpoint string) bool {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(mountpoint, &stat); err != nil {
+		return false
+	}
+
+	return stat.Type == cgroup2SuperMagic
+}
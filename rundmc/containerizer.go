@@ -3,6 +3,8 @@ package rundmc
 import (
 	"fmt"
 	"io"
+	"strconv"
+	"syscall"
 	"time"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
@@ -24,6 +26,7 @@ import (
 //go:generate counterfeiter . StateStore
 //go:generate counterfeiter . RootfsFileCreator
 //go:generate counterfeiter . PeaCreator
+//go:generate counterfeiter . OOMCounter
 
 type Depot interface {
 	Create(log lager.Logger, handle string, spec gardener.DesiredContainerSpec) error
@@ -45,6 +48,7 @@ type OCIRuntime interface {
 	State(log lager.Logger, id string) (runrunc.State, error)
 	Stats(log lager.Logger, id string) (gardener.ActualContainerMetrics, error)
 	WatchEvents(log lager.Logger, id string, eventsNotifier runrunc.EventsNotifier) error
+	ProcessIDs(log lager.Logger, bundlePath, id string) ([]string, error)
 }
 
 type PeaCreator interface {
@@ -57,7 +61,7 @@ type NstarRunner interface {
 }
 
 type Stopper interface {
-	StopAll(log lager.Logger, cgroupName string, save []int, kill bool) error
+	StopAll(log lager.Logger, cgroupName string, save []int, signal syscall.Signal, gracePeriod time.Duration) ([]int, error)
 }
 
 type EventStore interface {
@@ -68,12 +72,25 @@ type EventStore interface {
 type StateStore interface {
 	StoreStopped(handle string)
 	IsStopped(handle string) bool
+	StoreOOMKills(handle string, kills uint64)
+	OOMKills(handle string) (uint64, bool)
 }
 
 type RootfsFileCreator interface {
 	CreateFiles(rootFSPath string, pathsToCreate ...string) error
 }
 
+// OOMCounter counts the OOM kills so far in the memory cgroup of a process
+type OOMCounter interface {
+	OOMKills(pid int) (uint64, error)
+}
+
+type OOMCounterFunc func(pid int) (uint64, error)
+
+func (fn OOMCounterFunc) OOMKills(pid int) (uint64, error) {
+	return fn(pid)
+}
+
 // Containerizer knows how to manage a depot of container bundles
 type Containerizer struct {
 	depot             Depot
@@ -85,9 +102,10 @@ type Containerizer struct {
 	states            StateStore
 	rootfsFileCreator RootfsFileCreator
 	peaCreator        PeaCreator
+	oomCounter        OOMCounter
 }
 
-func New(depot Depot, runtime OCIRuntime, loader BundleLoader, nstarRunner NstarRunner, stopper Stopper, events EventStore, states StateStore, rootfsFileCreator RootfsFileCreator, peaCreator PeaCreator) *Containerizer {
+func New(depot Depot, runtime OCIRuntime, loader BundleLoader, nstarRunner NstarRunner, stopper Stopper, events EventStore, states StateStore, rootfsFileCreator RootfsFileCreator, peaCreator PeaCreator, oomCounter OOMCounter) *Containerizer {
 	return &Containerizer{
 		depot:             depot,
 		runtime:           runtime,
@@ -98,6 +116,7 @@ func New(depot Depot, runtime OCIRuntime, loader BundleLoader, nstarRunner Nstar
 		states:            states,
 		rootfsFileCreator: rootfsFileCreator,
 		peaCreator:        peaCreator,
+		oomCounter:        oomCounter,
 	}
 }
 
@@ -129,11 +148,105 @@ func (c *Containerizer) Create(log lager.Logger, spec gardener.DesiredContainerS
 		return err
 	}
 
+	// the container's memory cgroup is new, so none of its OOM kills are
+	// unrecorded
+	c.states.StoreOOMKills(spec.Handle, 0)
+	c.watchEvents(log, spec.Handle)
+
+	return nil
+}
+
+// WatchEvents resumes watching for events in a container which was created
+// by a previous gdn, first recording any OOM kills in the container which
+// happened while nothing was watching. Recording the missed kills is best
+// effort: the kernel may not count them, and the container is watched
+// regardless.
+func (c *Containerizer) WatchEvents(log lager.Logger, handle string) {
+	log = log.Session("watch-events", lager.Data{"handle": handle})
+
+	log.Info("start")
+	defer log.Info("finished")
+
+	missed, err := c.recordOOMKills(log, handle, 0)
+	if err != nil {
+		log.Error("record-missed-oom-kills-failed", err)
+	}
+
+	if missed > 0 {
+		log.Info("recorded-missed-oom-kills", lager.Data{"kills": missed})
+	}
+
+	c.watchEvents(log, handle)
+}
+
+func (c *Containerizer) watchEvents(log lager.Logger, handle string) {
+	notifier := &oomNotifier{log: log, containerizer: c}
+
 	go func() {
-		if err := c.runtime.WatchEvents(log, spec.Handle, c.events); err != nil {
+		if err := c.runtime.WatchEvents(log, handle, notifier); err != nil {
 			log.Error("watch-failed", err)
 		}
 	}()
+}
+
+// recordOOMKills records an OOM event for each of the OOM kills counted in
+// the container's memory cgroup which has not been recorded yet, and at least
+// minimum of them. It returns how many were recorded.
+func (c *Containerizer) recordOOMKills(log lager.Logger, handle string, minimum uint64) (uint64, error) {
+	state, err := c.runtime.State(log, handle)
+	if err != nil {
+		return 0, err
+	}
+
+	kills, err := c.oomCounter.OOMKills(state.Pid)
+	if err != nil {
+		return 0, err
+	}
+
+	recorded, ok := c.states.OOMKills(handle)
+	if !ok {
+		// there is no telling which of the kills in containers created before
+		// the kills were counted have been recorded
+		c.states.StoreOOMKills(handle, kills)
+		return 0, nil
+	}
+
+	unrecorded := uint64(0)
+	if kills > recorded {
+		unrecorded = kills - recorded
+	}
+	if unrecorded < minimum {
+		unrecorded = minimum
+	}
+
+	for i := uint64(0); i < unrecorded; i++ {
+		if err := c.events.OnEvent(handle, runrunc.OutOfMemoryEvent); err != nil {
+			return i, err
+		}
+	}
+
+	c.states.StoreOOMKills(handle, recorded+unrecorded)
+	return unrecorded, nil
+}
+
+// oomNotifier records the OOMs which runc notifies in a container by the
+// number of OOM kills counted in its memory cgroup, so that those which
+// happened while nothing was watching are recorded too. runc may notify an
+// OOM before the kill is counted, so at least one is recorded for each.
+type oomNotifier struct {
+	log           lager.Logger
+	containerizer *Containerizer
+}
+
+func (n *oomNotifier) OnEvent(handle, event string) error {
+	if event != runrunc.OutOfMemoryEvent {
+		return n.containerizer.events.OnEvent(handle, event)
+	}
+
+	if _, err := n.containerizer.recordOOMKills(n.log, handle, 1); err != nil {
+		n.log.Error("count-oom-kills-failed", err)
+		return n.containerizer.events.OnEvent(handle, event)
+	}
 
 	return nil
 }
@@ -225,9 +338,10 @@ func (c *Containerizer) StreamOut(log lager.Logger, handle string, spec garden.S
 	return stream, nil
 }
 
-// Stop stops all the processes other than the init process in the container
-func (c *Containerizer) Stop(log lager.Logger, handle string, kill bool) error {
-	log = log.Session("stop", lager.Data{"handle": handle, "kill": kill})
+// Stop stops all the processes other than the init process in the container,
+// logging those which had to be killed because they outlived the grace period
+func (c *Containerizer) Stop(log lager.Logger, handle string, spec gardener.StopSpec) error {
+	log = log.Session("stop", lager.Data{"handle": handle, "kill": spec.Kill, "grace-period": spec.GracePeriod.String()})
 
 	log.Info("started")
 	defer log.Info("finished")
@@ -238,11 +352,21 @@ func (c *Containerizer) Stop(log lager.Logger, handle string, kill bool) error {
 		return fmt.Errorf("stop: pid not found for container: %s", err)
 	}
 
-	if err = c.stopper.StopAll(log, handle, []int{state.Pid}, kill); err != nil {
+	signal := syscall.SIGTERM
+	if spec.Kill {
+		signal = syscall.SIGKILL
+	}
+
+	killed, err := c.stopper.StopAll(log, handle, []int{state.Pid}, signal, spec.GracePeriod)
+	if err != nil {
 		log.Error("stop-all-failed", err, lager.Data{"pid": state.Pid})
 		return fmt.Errorf("stop: %s", err)
 	}
 
+	if len(killed) > 0 {
+		log.Info("killed-processes", lager.Data{"pids": killed})
+	}
+
 	c.states.StoreStopped(handle)
 	return nil
 }
@@ -299,6 +423,13 @@ func (c *Containerizer) Info(log lager.Logger, handle string) (gardener.ActualCo
 		return gardener.ActualContainerSpec{}, err
 	}
 
+	// the rest of the info is still useful when the processes can't be listed
+	processIDs, err := c.runtime.ProcessIDs(log, bundlePath, handle)
+	if err != nil {
+		log.Error("list-processes-failed", err)
+		processIDs = []string{}
+	}
+
 	privileged := true
 	for _, ns := range bundle.Namespaces() {
 		if ns.Type == specs.UserNamespace {
@@ -308,9 +439,16 @@ func (c *Containerizer) Info(log lager.Logger, handle string) (gardener.ActualCo
 	}
 
 	var cpuShares, limitInBytes uint64
-	if bundle.Resources() != nil {
-		cpuShares = *bundle.Resources().CPU.Shares
-		limitInBytes = uint64(*bundle.Resources().Memory.Limit)
+	if resources := bundle.Resources(); resources != nil {
+		if resources.CPU != nil && resources.CPU.Shares != nil {
+			cpuShares = *resources.CPU.Shares
+		}
+
+		if resources.Memory != nil && resources.Memory.Limit != nil {
+			limitInBytes = uint64(*resources.Memory.Limit)
+		} else if memoryMax, ok := resources.Unified["memory.max"]; ok && memoryMax != "max" {
+			limitInBytes, _ = strconv.ParseUint(memoryMax, 10, 64)
+		}
 	} else {
 		log.Debug("bundle-resources-is-nil", lager.Data{"bundle": bundle})
 	}
@@ -321,6 +459,7 @@ func (c *Containerizer) Info(log lager.Logger, handle string) (gardener.ActualCo
 		RootFSPath: bundle.RootFS(),
 		Events:     c.events.Events(handle),
 		Stopped:    c.states.IsStopped(handle),
+		ProcessIDs: processIDs,
 		Limits: garden.Limits{
 			CPU: garden.CPULimits{
 				LimitInShares: cpuShares,
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"os"
+	"syscall"
 	"time"
 
 	"code.cloudfoundry.org/garden"
@@ -31,6 +32,7 @@ var _ = Describe("Rundmc", func() {
 		fakeStateStore        *fakes.FakeStateStore
 		fakeRootfsFileCreator *fakes.FakeRootfsFileCreator
 		fakePeaCreator        *fakes.FakePeaCreator
+		fakeOOMCounter        *fakes.FakeOOMCounter
 
 		logger        lager.Logger
 		containerizer *rundmc.Containerizer
@@ -46,13 +48,14 @@ var _ = Describe("Rundmc", func() {
 		fakeStateStore = new(fakes.FakeStateStore)
 		fakeRootfsFileCreator = new(fakes.FakeRootfsFileCreator)
 		fakePeaCreator = new(fakes.FakePeaCreator)
+		fakeOOMCounter = new(fakes.FakeOOMCounter)
 		logger = lagertest.NewTestLogger("test")
 
 		fakeDepot.LookupStub = func(_ lager.Logger, handle string) (string, error) {
 			return "/path/to/" + handle, nil
 		}
 
-		containerizer = rundmc.New(fakeDepot, fakeOCIRuntime, fakeBundleLoader, fakeNstarRunner, fakeStopper, fakeEventStore, fakeStateStore, fakeRootfsFileCreator, fakePeaCreator)
+		containerizer = rundmc.New(fakeDepot, fakeOCIRuntime, fakeBundleLoader, fakeNstarRunner, fakeStopper, fakeEventStore, fakeStateStore, fakeRootfsFileCreator, fakePeaCreator, fakeOOMCounter)
 	})
 
 	Describe("Create", func() {
@@ -153,9 +156,159 @@ var _ = Describe("Rundmc", func() {
 
 			Eventually(fakeOCIRuntime.WatchEventsCallCount).Should(Equal(1))
 
-			_, handle, eventsNotifier := fakeOCIRuntime.WatchEventsArgsForCall(0)
+			_, handle, _ := fakeOCIRuntime.WatchEventsArgsForCall(0)
 			Expect(handle).To(Equal("some-container"))
-			Expect(eventsNotifier).To(Equal(fakeEventStore))
+		})
+
+		It("should record that the new container has no unrecorded OOM kills", func() {
+			Expect(containerizer.Create(logger, gardener.DesiredContainerSpec{
+				Handle:     "some-container",
+				BaseConfig: specs.Spec{Root: &specs.Root{}},
+			})).To(Succeed())
+
+			Expect(fakeStateStore.StoreOOMKillsCallCount()).To(Equal(1))
+			handle, kills := fakeStateStore.StoreOOMKillsArgsForCall(0)
+			Expect(handle).To(Equal("some-container"))
+			Expect(kills).To(BeZero())
+		})
+
+		It("should record the OOMs which the runtime notifies as events", func() {
+			fakeStateStore.OOMKillsReturns(0, true)
+			fakeOOMCounter.OOMKillsReturns(1, nil)
+
+			Expect(containerizer.Create(logger, gardener.DesiredContainerSpec{
+				Handle:     "some-container",
+				BaseConfig: specs.Spec{Root: &specs.Root{}},
+			})).To(Succeed())
+
+			Eventually(fakeOCIRuntime.WatchEventsCallCount).Should(Equal(1))
+			_, _, eventsNotifier := fakeOCIRuntime.WatchEventsArgsForCall(0)
+			Expect(eventsNotifier.OnEvent("some-container", runrunc.OutOfMemoryEvent)).To(Succeed())
+
+			Expect(fakeEventStore.OnEventCallCount()).To(Equal(1))
+			handle, event := fakeEventStore.OnEventArgsForCall(0)
+			Expect(handle).To(Equal("some-container"))
+			Expect(event).To(Equal("Out of memory"))
+		})
+	})
+
+	Describe("WatchEvents", func() {
+		var eventsNotifier runrunc.EventsNotifier
+
+		BeforeEach(func() {
+			fakeOCIRuntime.StateReturns(runrunc.State{Pid: 123}, nil)
+			fakeOOMCounter.OOMKillsReturns(3, nil)
+			fakeStateStore.OOMKillsReturns(1, true)
+		})
+
+		watchEvents := func() {
+			containerizer.WatchEvents(logger, "some-handle")
+
+			Eventually(fakeOCIRuntime.WatchEventsCallCount).Should(Equal(1))
+			_, _, eventsNotifier = fakeOCIRuntime.WatchEventsArgsForCall(0)
+		}
+
+		It("should watch for events in the container", func() {
+			watchEvents()
+
+			_, handle, _ := fakeOCIRuntime.WatchEventsArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+		})
+
+		It("should record an OOM event for each OOM kill which has not been recorded", func() {
+			watchEvents()
+
+			_, handle := fakeOCIRuntime.StateArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(fakeOOMCounter.OOMKillsArgsForCall(0)).To(Equal(123))
+
+			Expect(fakeEventStore.OnEventCallCount()).To(Equal(2))
+			for i := 0; i < 2; i++ {
+				handle, event := fakeEventStore.OnEventArgsForCall(i)
+				Expect(handle).To(Equal("some-handle"))
+				Expect(event).To(Equal("Out of memory"))
+			}
+
+			handle, kills := fakeStateStore.StoreOOMKillsArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(kills).To(Equal(uint64(3)))
+		})
+
+		Context("when no OOM kills have been recorded in the container", func() {
+			BeforeEach(func() {
+				fakeStateStore.OOMKillsReturns(0, false)
+			})
+
+			It("should record the OOM kills so far without recording any events", func() {
+				watchEvents()
+
+				Expect(fakeEventStore.OnEventCallCount()).To(BeZero())
+				_, kills := fakeStateStore.StoreOOMKillsArgsForCall(0)
+				Expect(kills).To(Equal(uint64(3)))
+			})
+		})
+
+		Context("when the OOM kills cannot be counted", func() {
+			BeforeEach(func() {
+				fakeOOMCounter.OOMKillsReturns(0, errors.New("no-cgroup"))
+			})
+
+			It("should log the error and watch the container anyway", func() {
+				watchEvents()
+				Expect(logger.(*lagertest.TestLogger)).To(gbytes.Say("record-missed-oom-kills-failed.*no-cgroup"))
+				Expect(fakeEventStore.OnEventCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when getting the state of the container fails", func() {
+			BeforeEach(func() {
+				fakeOCIRuntime.StateReturns(runrunc.State{}, errors.New("no-container"))
+			})
+
+			It("should log the error and watch the container anyway", func() {
+				watchEvents()
+				Expect(logger.(*lagertest.TestLogger)).To(gbytes.Say("record-missed-oom-kills-failed.*no-container"))
+			})
+		})
+
+		Context("when an OOM is notified", func() {
+			JustBeforeEach(func() {
+				watchEvents()
+				fakeStateStore.OOMKillsReturns(3, true)
+			})
+
+			It("should record an event for each OOM kill which has not been recorded", func() {
+				fakeOOMCounter.OOMKillsReturns(5, nil)
+				Expect(eventsNotifier.OnEvent("some-handle", runrunc.OutOfMemoryEvent)).To(Succeed())
+
+				Expect(fakeEventStore.OnEventCallCount()).To(Equal(2 + 2))
+				_, kills := fakeStateStore.StoreOOMKillsArgsForCall(1)
+				Expect(kills).To(Equal(uint64(5)))
+			})
+
+			It("should record an event even when the OOM kill has not been counted yet", func() {
+				Expect(eventsNotifier.OnEvent("some-handle", runrunc.OutOfMemoryEvent)).To(Succeed())
+
+				Expect(fakeEventStore.OnEventCallCount()).To(Equal(2 + 1))
+				_, kills := fakeStateStore.StoreOOMKillsArgsForCall(1)
+				Expect(kills).To(Equal(uint64(4)))
+			})
+
+			It("should record an event when the OOM kills cannot be counted", func() {
+				fakeOOMCounter.OOMKillsReturns(0, errors.New("no-cgroup"))
+				Expect(eventsNotifier.OnEvent("some-handle", runrunc.OutOfMemoryEvent)).To(Succeed())
+
+				Expect(fakeEventStore.OnEventCallCount()).To(Equal(2 + 1))
+				Expect(fakeStateStore.StoreOOMKillsCallCount()).To(Equal(1))
+			})
+		})
+
+		It("should record other events as they are notified", func() {
+			watchEvents()
+			Expect(eventsNotifier.OnEvent("some-handle", "some-event")).To(Succeed())
+
+			_, event := fakeEventStore.OnEventArgsForCall(2)
+			Expect(event).To(Equal("some-event"))
 		})
 	})
 
@@ -332,46 +485,69 @@ var _ = Describe("Rundmc", func() {
 
 	Describe("Stop", func() {
 		var (
-			cgroupPathArg string
-			exceptionsArg []int
-			killArg       bool
+			stopSpec       gardener.StopSpec
+			cgroupPathArg  string
+			exceptionsArg  []int
+			signalArg      syscall.Signal
+			gracePeriodArg time.Duration
 		)
 
 		Context("when the stop succeeds", func() {
 			BeforeEach(func() {
+				stopSpec = gardener.StopSpec{Kill: true}
+			})
+
+			JustBeforeEach(func() {
 				fakeOCIRuntime.StateReturns(runrunc.State{
 					Pid: 1234,
 				}, nil)
+				fakeStopper.StopAllReturns([]int{12, 34}, nil)
 
-				Expect(containerizer.Stop(logger, "some-handle", true)).To(Succeed())
+				Expect(containerizer.Stop(logger, "some-handle", stopSpec)).To(Succeed())
 				Expect(fakeStopper.StopAllCallCount()).To(Equal(1))
 
-				_, cgroupPathArg, exceptionsArg, killArg = fakeStopper.StopAllArgsForCall(0)
+				_, cgroupPathArg, exceptionsArg, signalArg, gracePeriodArg = fakeStopper.StopAllArgsForCall(0)
 			})
 
-			It("asks to stop all processes in the processes's cgroup", func() {
+			It("asks to kill all processes in the processes's cgroup", func() {
 				Expect(cgroupPathArg).To(Equal("some-handle"))
-				Expect(killArg).To(Equal(true))
+				Expect(signalArg).To(Equal(syscall.SIGKILL))
 			})
 
 			It("asks to not stop the pid of the init process", func() {
 				Expect(exceptionsArg).To(ConsistOf(1234))
 			})
 
+			It("logs the processes which were killed", func() {
+				Expect(logger.(*lagertest.TestLogger)).To(gbytes.Say("killed-processes"))
+			})
+
 			It("transitions the stored state", func() {
 				Expect(fakeStateStore.StoreStoppedCallCount()).To(Equal(1))
 				handle := fakeStateStore.StoreStoppedArgsForCall(0)
 				Expect(handle).To(Equal("some-handle"))
 			})
+
+			Context("when the processes should not be killed straight away", func() {
+				BeforeEach(func() {
+					stopSpec = gardener.StopSpec{GracePeriod: time.Minute}
+				})
+
+				It("sends them SIGTERM with the grace period", func() {
+					Expect(signalArg).To(Equal(syscall.SIGTERM))
+					Expect(gracePeriodArg).To(Equal(time.Minute))
+				})
+			})
 		})
 
 		Context("when the stop fails", func() {
 			BeforeEach(func() {
-				fakeStopper.StopAllReturns(errors.New("boom"))
+				fakeStopper.StopAllReturns(nil, errors.New("boom"))
 			})
 
 			It("does not transition to the stopped state", func() {
-				Expect(containerizer.Stop(logger, "some-handle", true)).To(MatchError(ContainSubstring("boom")))
+				err := containerizer.Stop(logger, "some-handle", gardener.StopSpec{Kill: true})
+				Expect(err).To(MatchError(ContainSubstring("boom")))
 				Expect(fakeStateStore.StoreStoppedCallCount()).To(Equal(0))
 			})
 		})
@@ -386,7 +562,8 @@ var _ = Describe("Rundmc", func() {
 			})
 
 			It("does not transition to the stopped state", func() {
-				Expect(containerizer.Stop(logger, "some-handle", true)).To(MatchError(ContainSubstring("boom")))
+				err := containerizer.Stop(logger, "some-handle", gardener.StopSpec{Kill: true})
+				Expect(err).To(MatchError(ContainSubstring("boom")))
 				Expect(fakeStateStore.StoreStoppedCallCount()).To(Equal(0))
 			})
 		})
@@ -552,6 +729,28 @@ var _ = Describe("Rundmc", func() {
 			Expect(actualSpec.Pid).To(Equal(42))
 		})
 
+		It("should return the IDs of the container's processes", func() {
+			fakeOCIRuntime.ProcessIDsReturns([]string{"process-1", "process-2"}, nil)
+
+			actualSpec, err := containerizer.Info(logger, "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actualSpec.ProcessIDs).To(Equal([]string{"process-1", "process-2"}))
+
+			_, bundlePath, id := fakeOCIRuntime.ProcessIDsArgsForCall(0)
+			Expect(bundlePath).To(Equal("/path/to/some-handle"))
+			Expect(id).To(Equal("some-handle"))
+		})
+
+		Context("when listing the processes fails", func() {
+			It("should return the rest of the info without any process IDs", func() {
+				fakeOCIRuntime.ProcessIDsReturns(nil, errors.New("batman-error"))
+				actualSpec, err := containerizer.Info(logger, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(actualSpec.Pid).To(Equal(42))
+				Expect(actualSpec.ProcessIDs).To(BeEmpty())
+			})
+		})
+
 		Context("when looking up the bundle path fails", func() {
 			It("should return the error", func() {
 				fakeDepot.LookupReturns("", errors.New("spiderman-error"))
@@ -573,6 +772,32 @@ var _ = Describe("Rundmc", func() {
 			})
 		})
 
+		Context("when the memory limit is a unified resource", func() {
+			BeforeEach(func() {
+				resources.Memory = nil
+				resources.Unified = map[string]string{"memory.max": "4096"}
+			})
+
+			It("should return the ActualContainerSpec with the correct memory limits", func() {
+				actualSpec, err := containerizer.Info(logger, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(actualSpec.Limits.Memory.LimitInBytes).To(BeEquivalentTo(4096))
+				Expect(actualSpec.Limits.CPU.LimitInShares).To(BeEquivalentTo(20))
+			})
+
+			Context("and is unlimited", func() {
+				BeforeEach(func() {
+					resources.Unified["memory.max"] = "max"
+				})
+
+				It("reports no memory limit", func() {
+					actualSpec, err := containerizer.Info(logger, "some-handle")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(actualSpec.Limits.Memory.LimitInBytes).To(BeEquivalentTo(0))
+				})
+			})
+		})
+
 		Context("when loading the bundle fails", func() {
 			It("should return the error", func() {
 				fakeBundleLoader.LoadReturns(goci.Bundle(), errors.New("aquaman-error"))
@@ -16,6 +16,7 @@ import (
 	"code.cloudfoundry.org/commandrunner"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/guardian/logging"
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
 	"code.cloudfoundry.org/guardian/rundmc/signals"
 	"code.cloudfoundry.org/lager"
 )
@@ -29,11 +30,12 @@ type ExecRunner struct {
 	processes                map[string]*process
 	processesMutex           *sync.Mutex
 	runMode                  string
+	cgroupsMountpoint        string
 }
 
 func NewExecRunner(
 	dadooPath, runcPath string, signallerFactory *signals.SignallerFactory,
-	commandRunner commandrunner.CommandRunner, shouldCleanup bool, runMode string,
+	commandRunner commandrunner.CommandRunner, shouldCleanup bool, runMode, cgroupsMountpoint string,
 ) *ExecRunner {
 	return &ExecRunner{
 		dadooPath:                dadooPath,
@@ -44,6 +46,7 @@ func NewExecRunner(
 		processes:                map[string]*process{},
 		processesMutex:           new(sync.Mutex),
 		runMode:                  runMode,
+		cgroupsMountpoint:        cgroupsMountpoint,
 	}
 }
 
@@ -81,7 +84,7 @@ func (d *ExecRunner) Run(
 	}
 
 	cmd := buildDadooCommand(
-		tty, d.dadooPath, d.runMode, d.runcPath, processID, processPath, sandboxHandle,
+		tty, d.dadooPath, d.runMode, d.runcPath, d.cgroupsMountpoint, processID, processPath, sandboxHandle,
 		[]*os.File{fd3w, logw, syncw}, procJSON,
 	)
 
@@ -155,11 +158,14 @@ func (d *ExecRunner) Run(
 	return process, nil
 }
 
-func buildDadooCommand(tty bool, dadooPath, dadooRunMode, runcPath, processID, processPath, sandboxHandle string, extraFiles []*os.File, stdin io.Reader) *exec.Cmd {
+func buildDadooCommand(tty bool, dadooPath, dadooRunMode, runcPath, cgroupsMountpoint, processID, processPath, sandboxHandle string, extraFiles []*os.File, stdin io.Reader) *exec.Cmd {
 	dadooArgs := []string{}
 	if tty {
 		dadooArgs = append(dadooArgs, "-tty")
 	}
+	if cgroupsMountpoint != "" {
+		dadooArgs = append(dadooArgs, "-cgroups-mountpoint", cgroupsMountpoint)
+	}
 	dadooArgs = append(dadooArgs, dadooRunMode, runcPath, processPath)
 	if dadooRunMode == "run" {
 		dadooArgs = append(dadooArgs, processID)
@@ -183,6 +189,16 @@ func (d *ExecRunner) Attach(log lager.Logger, processID string, io garden.Proces
 		return nil, err
 	}
 
+	// processes which exited while no gdn was waiting on them, e.g. during a
+	// restart, have nothing left to stream
+	exitStatus, err := runrunc.ReadExitStatus(processPath)
+	if err != nil {
+		return nil, err
+	}
+	if exitStatus != nil {
+		return &exitedProcess{id: processID, status: *exitStatus}, nil
+	}
+
 	process := d.getProcess(log, processID, processPath, filepath.Join(processPath, "pidfile"), nil)
 	if err := process.attach(io); err != nil {
 		return nil, err
@@ -191,6 +207,27 @@ func (d *ExecRunner) Attach(log lager.Logger, processID string, io garden.Proces
 	return process, nil
 }
 
+// ProcessIDs lists the processes in the given processes directory, including
+// those which have exited but whose directories have not been cleaned up
+func (d *ExecRunner) ProcessIDs(log lager.Logger, processesPath string) ([]string, error) {
+	entries, err := ioutil.ReadDir(processesPath)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	processIDs := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			processIDs = append(processIDs, entry.Name())
+		}
+	}
+
+	return processIDs, nil
+}
+
 type process struct {
 	logger                                       lager.Logger
 	id                                           string
@@ -380,6 +417,29 @@ func (p process) Wait() (int, error) {
 	return code, nil
 }
 
+// exitedProcess is returned by Attach for processes whose exit status was
+// recorded before anything attached to them
+type exitedProcess struct {
+	id     string
+	status runrunc.ExitStatus
+}
+
+func (p *exitedProcess) ID() string {
+	return p.id
+}
+
+func (p *exitedProcess) Wait() (int, error) {
+	return p.status.Code, nil
+}
+
+func (p *exitedProcess) SetTTY(garden.TTYSpec) error {
+	return nil
+}
+
+func (p *exitedProcess) Signal(garden.Signal) error {
+	return fmt.Errorf("process %s has already exited", p.id)
+}
+
 func (p process) SetTTY(spec garden.TTYSpec) error {
 	if spec.WindowSize == nil {
 		return nil
@@ -19,6 +19,7 @@ import (
 	"code.cloudfoundry.org/commandrunner/fake_command_runner"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/guardian/rundmc/execrunner/dadoo"
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
 	"code.cloudfoundry.org/guardian/rundmc/signals"
 	"code.cloudfoundry.org/guardian/rundmc/signals/signalsfakes"
 	"code.cloudfoundry.org/lager"
@@ -70,7 +71,7 @@ var _ = Describe("Dadoo ExecRunner", func() {
 		Expect(os.MkdirAll(processPath, 0700)).To(Succeed())
 
 		runner = dadoo.NewExecRunner("path-to-dadoo", "path-to-runc",
-			signallerFactory, fakeCommandRunner, false, "exec")
+			signallerFactory, fakeCommandRunner, false, "exec", "")
 		log = lagertest.NewTestLogger("test")
 
 		runcReturns = 0
@@ -238,7 +239,7 @@ var _ = Describe("Dadoo ExecRunner", func() {
 		Context("when the exec mode is 'run'", func() {
 			BeforeEach(func() {
 				runner = dadoo.NewExecRunner("path-to-dadoo", "path-to-runc",
-					signallerFactory, fakeCommandRunner, false, "run")
+					signallerFactory, fakeCommandRunner, false, "run", "")
 			})
 
 			It("executes the dadoo binary with the correct arguments", func() {
@@ -254,6 +255,25 @@ var _ = Describe("Dadoo ExecRunner", func() {
 			})
 		})
 
+		Context("when the cgroups are mounted somewhere other than the default", func() {
+			BeforeEach(func() {
+				runner = dadoo.NewExecRunner("path-to-dadoo", "path-to-runc",
+					signallerFactory, fakeCommandRunner, false, "exec", "/tmp/cgroups-some-tag")
+			})
+
+			It("passes the mountpoint to dadoo, so that it can count OOM kills", func() {
+				runner.Run(log, processID, processPath, "some-handle", bundlePath, 123, 456, defaultProcessIO(), false, nil, nil)
+
+				Expect(fakeCommandRunner.StartedCommands()[0].Args).To(
+					Equal([]string{
+						"path-to-dadoo",
+						"-cgroups-mountpoint", "/tmp/cgroups-some-tag",
+						"exec", "path-to-runc", processPath, "some-handle",
+					}),
+				)
+			})
+		})
+
 		Context("when TTY is requested", func() {
 			It("executed the dadoo binary with the correct arguments", func() {
 				runner.Run(log, processID, processPath, "some-handle", bundlePath, 123, 456, defaultProcessIO(), true, nil, nil)
@@ -325,7 +345,7 @@ var _ = Describe("Dadoo ExecRunner", func() {
 		Context("when cleanupProcessDirsOnWait is true", func() {
 			BeforeEach(func() {
 				runner = dadoo.NewExecRunner("path-to-dadoo", "path-to-runc",
-					signallerFactory, fakeCommandRunner, true, "exec")
+					signallerFactory, fakeCommandRunner, true, "exec", "")
 			})
 
 			It("cleans up the processes dir after Wait returns", func() {
@@ -344,7 +364,7 @@ var _ = Describe("Dadoo ExecRunner", func() {
 		Context("when cleanupProcessDirsOnWait is false", func() {
 			BeforeEach(func() {
 				runner = dadoo.NewExecRunner("path-to-dadoo", "path-to-runc",
-					signallerFactory, fakeCommandRunner, false, "exec")
+					signallerFactory, fakeCommandRunner, false, "exec", "")
 			})
 
 			It("does not clean up the processes dir after Wait returns", func() {
@@ -724,7 +744,7 @@ var _ = Describe("Dadoo ExecRunner", func() {
 			Context("when cleanupProcessDirsOnWait is true", func() {
 				JustBeforeEach(func() {
 					runner = dadoo.NewExecRunner("path-to-dadoo", "path-to-runc",
-						signallerFactory, fakeCommandRunner, true, "exec")
+						signallerFactory, fakeCommandRunner, true, "exec", "")
 				})
 
 				It("cleans up the map entry and the process path", func() {
@@ -787,7 +807,7 @@ var _ = Describe("Dadoo ExecRunner", func() {
 	Describe("Attach after Run", func() {
 		Context("when cleanupProcessDirsOnWait is true", func() {
 			BeforeEach(func() {
-				runner = dadoo.NewExecRunner("path-to-dadoo", "path-to-runc", signallerFactory, fakeCommandRunner, true, "exec")
+				runner = dadoo.NewExecRunner("path-to-dadoo", "path-to-runc", signallerFactory, fakeCommandRunner, true, "exec", "")
 			})
 
 			It("cleans up the processes dir after Wait returns", func() {
@@ -807,7 +827,7 @@ var _ = Describe("Dadoo ExecRunner", func() {
 
 		Context("when cleanupProcessDirsOnWait is false", func() {
 			BeforeEach(func() {
-				runner = dadoo.NewExecRunner("path-to-dadoo", "path-to-runc", signallerFactory, fakeCommandRunner, false, "exec")
+				runner = dadoo.NewExecRunner("path-to-dadoo", "path-to-runc", signallerFactory, fakeCommandRunner, false, "exec", "")
 			})
 
 			It("does not clean up the processes dir after Wait returns", func() {
@@ -835,6 +855,29 @@ var _ = Describe("Dadoo ExecRunner", func() {
 			Expect(syscall.Mkfifo(filepath.Join(processPath, "exit"), 0)).To(Succeed())
 		})
 
+		Context("when the exit status of the process was recorded before anything attached", func() {
+			BeforeEach(func() {
+				Expect(runrunc.WriteExitStatus(processPath, runrunc.ExitStatus{
+					Code:   42,
+					Time:   time.Now(),
+					Reason: runrunc.ExitReasonExited,
+				})).To(Succeed())
+			})
+
+			It("returns a process which reports the recorded exit status", func() {
+				process, err := runner.Attach(log, processID, garden.ProcessIO{}, filepath.Dir(processPath))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(process.ID()).To(Equal(processID))
+				Expect(process.Wait()).To(Equal(42))
+			})
+
+			It("cannot signal the process", func() {
+				process, err := runner.Attach(log, processID, garden.ProcessIO{}, filepath.Dir(processPath))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(process.Signal(garden.SignalTerminate)).To(MatchError(ContainSubstring("has already exited")))
+			})
+		})
+
 		Context("when dadoo has already exited", func() {
 			It("returns the process", func() {
 				out := gbytes.NewBuffer()
@@ -971,7 +1014,7 @@ var _ = Describe("Dadoo ExecRunner", func() {
 
 		Context("when no process with the specified ID exists", func() {
 			BeforeEach(func() {
-				runner = dadoo.NewExecRunner("path-to-dadoo", "path-to-runc", signallerFactory, fakeCommandRunner, true, "exec")
+				runner = dadoo.NewExecRunner("path-to-dadoo", "path-to-runc", signallerFactory, fakeCommandRunner, true, "exec", "")
 			})
 
 			It("returns ProcessNotFoundError", func() {
@@ -980,6 +1023,27 @@ var _ = Describe("Dadoo ExecRunner", func() {
 			})
 		})
 	})
+
+	Describe("ProcessIDs", func() {
+		var processesPath string
+
+		BeforeEach(func() {
+			processesPath = filepath.Dir(processPath)
+			Expect(ioutil.WriteFile(filepath.Join(processesPath, "not-a-process"), nil, 0600)).To(Succeed())
+		})
+
+		It("lists the IDs of the process directories", func() {
+			Expect(runner.ProcessIDs(log, processesPath)).To(ConsistOf(processID))
+		})
+
+		Context("when the processes directory does not exist", func() {
+			It("lists no processes", func() {
+				processIDs, err := runner.ProcessIDs(log, filepath.Join(bundlePath, "not-there"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(processIDs).To(BeEmpty())
+			})
+		})
+	})
 })
 
 type fakeExitError int
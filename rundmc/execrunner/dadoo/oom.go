@@ -0,0 +1,19 @@
+package dadoo
+
+import "code.cloudfoundry.org/guardian/rundmc/cgroups"
+
+// WatchOOMKills reads the number of OOM kills so far in the memory cgroup of
+// the process, and returns a func which reports whether there have been any
+// more since. Processes killed with SIGKILL when it reports true were most
+// likely killed by the kernel's OOM killer.
+func WatchOOMKills(cgroupsMountpoint string, pid int) func() bool {
+	before, err := cgroups.OOMKills(cgroupsMountpoint, pid)
+	if err != nil {
+		return func() bool { return false }
+	}
+
+	return func() bool {
+		after, err := cgroups.OOMKills(cgroupsMountpoint, pid)
+		return err == nil && after > before
+	}
+}
@@ -0,0 +1,125 @@
+package dadoo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
+)
+
+// The captured output of each stream is split into segments named after the
+// offset in the stream of their first byte, e.g. stdout-00000000000000001024.log,
+// so that they sort in the order they were written
+const logSegmentSuffix = ".log"
+
+// LogWriter captures one of the output streams of a process in size-rotated
+// segments in its process directory
+type LogWriter struct {
+	processPath string
+	stream      string
+	config      runrunc.ProcessLogConfig
+
+	segment      *os.File
+	segmentStart int64
+	offset       int64
+}
+
+func NewLogWriter(processPath, stream string, config runrunc.ProcessLogConfig) *LogWriter {
+	return &LogWriter{
+		processPath: processPath,
+		stream:      stream,
+		config:      config,
+	}
+}
+
+func (w *LogWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if w.segment == nil || w.offset-w.segmentStart >= w.config.MaxSize {
+			if err := w.rotate(); err != nil {
+				return written, err
+			}
+		}
+
+		chunk := p
+		if remaining := w.config.MaxSize - (w.offset - w.segmentStart); int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+
+		n, err := w.segment.Write(chunk)
+		written += n
+		w.offset += int64(n)
+		if err != nil {
+			return written, err
+		}
+
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+func (w *LogWriter) Close() error {
+	if w.segment == nil {
+		return nil
+	}
+
+	return w.segment.Close()
+}
+
+// rotate starts a new segment, removing the oldest segments beyond the
+// number which are kept
+func (w *LogWriter) rotate() error {
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	var err error
+	w.segmentStart = w.offset
+	w.segment, err = os.OpenFile(segmentPath(w.processPath, w.stream, w.offset), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	starts, err := segmentStarts(w.processPath, w.stream)
+	if err != nil {
+		return err
+	}
+
+	for len(starts) > w.config.MaxFiles {
+		os.Remove(segmentPath(w.processPath, w.stream, starts[0]))
+		starts = starts[1:]
+	}
+
+	return nil
+}
+
+func segmentPath(processPath, stream string, start int64) string {
+	return filepath.Join(processPath, fmt.Sprintf("%s-%020d%s", stream, start, logSegmentSuffix))
+}
+
+// segmentStarts returns the offsets at which the retained segments of the
+// stream start, in order
+func segmentStarts(processPath, stream string) ([]int64, error) {
+	paths, err := filepath.Glob(filepath.Join(processPath, stream+"-*"+logSegmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	var starts []int64
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), stream+"-"), logSegmentSuffix)
+		start, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		starts = append(starts, start)
+	}
+
+	// the zero-padded offsets sort as the paths do
+	return starts, nil
+}
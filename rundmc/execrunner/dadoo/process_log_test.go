@@ -0,0 +1,85 @@
+package dadoo_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/guardian/rundmc/execrunner/dadoo"
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LogWriter", func() {
+	var (
+		processPath string
+		config      runrunc.ProcessLogConfig
+		writer      *dadoo.LogWriter
+	)
+
+	BeforeEach(func() {
+		var err error
+		processPath, err = ioutil.TempDir("", "process")
+		Expect(err).NotTo(HaveOccurred())
+
+		config = runrunc.ProcessLogConfig{MaxSize: 10, MaxFiles: 3}
+	})
+
+	JustBeforeEach(func() {
+		writer = dadoo.NewLogWriter(processPath, "stdout", config)
+	})
+
+	AfterEach(func() {
+		Expect(writer.Close()).To(Succeed())
+		Expect(os.RemoveAll(processPath)).To(Succeed())
+	})
+
+	write := func(output string) {
+		_, err := writer.Write([]byte(output))
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	readSegment := func(start string) string {
+		contents, err := ioutil.ReadFile(filepath.Join(processPath, "stdout-"+start+".log"))
+		Expect(err).NotTo(HaveOccurred())
+		return string(contents)
+	}
+
+	It("captures the output in the process directory", func() {
+		write("hello ")
+		write("world")
+
+		Expect(readSegment("00000000000000000000")).To(Equal("hello world"))
+	})
+
+	It("splits the output into files of at most the max size, named after their offset", func() {
+		write("0123456789abcdefghij0123")
+
+		Expect(readSegment("00000000000000000000")).To(Equal("0123456789"))
+		Expect(readSegment("00000000000000000010")).To(Equal("abcdefghij"))
+		Expect(readSegment("00000000000000000020")).To(Equal("0123"))
+	})
+
+	Context("when there are more files than the max", func() {
+		JustBeforeEach(func() {
+			write("0123456789abcdefghijABCDEFGHIJklmnopqrst")
+		})
+
+		It("removes the oldest", func() {
+			segments, err := filepath.Glob(filepath.Join(processPath, "stdout-*"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(segments).To(ConsistOf(
+				filepath.Join(processPath, "stdout-00000000000000000010.log"),
+				filepath.Join(processPath, "stdout-00000000000000000020.log"),
+				filepath.Join(processPath, "stdout-00000000000000000030.log"),
+			))
+		})
+	})
+
+	It("does not create a file until there is output", func() {
+		segments, err := filepath.Glob(filepath.Join(processPath, "stdout-*"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(segments).To(BeEmpty())
+	})
+})
@@ -95,6 +95,12 @@ func (e *DirectExecRunner) Attach(log lager.Logger, processID string, io garden.
 	panic("not supported on this platform")
 }
 
+// ProcessIDs lists no processes, since listing is not supported on this
+// platform
+func (e *DirectExecRunner) ProcessIDs(log lager.Logger, processesPath string) ([]string, error) {
+	return []string{}, nil
+}
+
 type process struct {
 	id       string
 	exitCode int
@@ -58,6 +58,26 @@ func (b Bndl) WithHostname(hostname string) Bndl {
 	return b
 }
 
+// RuntimeClassAnnotation names the runtime class with which a bundle is run
+const RuntimeClassAnnotation = "org.cloudfoundry.guardian.runtime-class"
+
+func (b Bndl) RuntimeClass() string {
+	return b.Spec.Annotations[RuntimeClassAnnotation]
+}
+
+// WithRuntimeClass returns a bundle annotated with the name of the runtime
+// class with which it should be run. The original bundle is not modified.
+func (b Bndl) WithRuntimeClass(name string) Bndl {
+	annotations := map[string]string{}
+	for key, value := range b.Spec.Annotations {
+		annotations[key] = value
+	}
+
+	annotations[RuntimeClassAnnotation] = name
+	b.Spec.Annotations = annotations
+	return b
+}
+
 func (b Bndl) Process() specs.Process {
 	return *(b.Spec.Process)
 }
@@ -171,6 +191,21 @@ func (b Bndl) WithPidLimit(limit specs.LinuxPids) Bndl {
 	return b
 }
 
+// WithUnifiedResources returns a bundle with the given cgroup v2 interface
+// files set in the runtime spec, e.g. "memory.max". These are applied as-is by
+// the runtime and take precedence over any equivalent v1-shaped resources.
+func (b Bndl) WithUnifiedResources(unified map[string]string) Bndl {
+	resources := b.Resources()
+	if resources == nil {
+		resources = &specs.LinuxResources{}
+	}
+
+	resources.Unified = unified
+	b.CloneLinux().Spec.Linux.Resources = resources
+
+	return b
+}
+
 func (b Bndl) WithDeviceRestrictions(deviceRestrictions []specs.LinuxDeviceCgroup) Bndl {
 	resources := b.Resources()
 	if resources == nil {
@@ -191,6 +226,14 @@ func (b Bndl) WithNamespace(ns specs.LinuxNamespace) Bndl {
 	return b
 }
 
+// WithoutNamespace returns a bundle without any namespace of the given type, so
+// that the container shares the namespace of the runtime. The bundle is not modified.
+func (b Bndl) WithoutNamespace(nsType specs.LinuxNamespaceType) Bndl {
+	slice := NamespaceSlice(b.Spec.Linux.Namespaces)
+	b.CloneLinux().Spec.Linux.Namespaces = []specs.LinuxNamespace(slice.Remove(nsType))
+	return b
+}
+
 func (b Bndl) Namespaces() []specs.LinuxNamespace {
 	return b.Spec.Linux.Namespaces
 }
@@ -305,6 +348,17 @@ func (slice NamespaceSlice) Set(ns specs.LinuxNamespace) NamespaceSlice {
 	return append(slice, ns)
 }
 
+func (slice NamespaceSlice) Remove(nsType specs.LinuxNamespaceType) NamespaceSlice {
+	var result NamespaceSlice
+	for _, namespace := range slice {
+		if namespace.Type != nsType {
+			result = append(result, namespace)
+		}
+	}
+
+	return result
+}
+
 // Process returns an OCI Process struct with the given args.
 func Process(args ...string) specs.Process {
 	return specs.Process{Args: args}
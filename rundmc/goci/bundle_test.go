@@ -26,6 +26,18 @@ var _ = Describe("Bundle", func() {
 		})
 	})
 
+	Describe("WithRuntimeClass", func() {
+		It("sets the RuntimeClass in the bundle", func() {
+			returnedBundle := initialBundle.WithRuntimeClass("sandboxed")
+			Expect(returnedBundle.RuntimeClass()).To(Equal("sandboxed"))
+		})
+
+		It("does not modify the original bundle", func() {
+			initialBundle.WithRuntimeClass("sandboxed")
+			Expect(initialBundle.RuntimeClass()).To(BeEmpty())
+		})
+	})
+
 	Describe("WithRootFSPropagation", func() {
 		It("sets the RootFSPropagation in the bundle", func() {
 			returnedBundle := initialBundle.WithRootFSPropagation("rshared")
@@ -301,6 +313,18 @@ var _ = Describe("Bundle", func() {
 		})
 	})
 
+	Describe("WithUnifiedResources", func() {
+		unified := map[string]string{"memory.max": "1024"}
+
+		BeforeEach(func() {
+			returnedBundle = initialBundle.WithUnifiedResources(unified)
+		})
+
+		It("returns a bundle with the unified resources added to the runtime spec", func() {
+			Expect(returnedBundle.Resources().Unified).To(Equal(unified))
+		})
+	})
+
 	Describe("WithDeviceRestrictions", func() {
 		restrictions := []specs.LinuxDeviceCgroup{{Type: "some-type"}}
 
@@ -341,6 +365,25 @@ var _ = Describe("Bundle", func() {
 		})
 	})
 
+	Describe("WithoutNamespace", func() {
+		It("removes namespaces of the given type", func() {
+			initialBundle = initialBundle.WithNamespaces(
+				specs.LinuxNamespace{Type: specs.NetworkNamespace},
+				specs.LinuxNamespace{Type: specs.PIDNamespace},
+			)
+
+			returnedBundle = initialBundle.WithoutNamespace(specs.NetworkNamespace)
+			Expect(returnedBundle.Namespaces()).To(ConsistOf(specs.LinuxNamespace{Type: specs.PIDNamespace}))
+		})
+
+		It("does not modify the original bundle", func() {
+			initialBundle = initialBundle.WithNamespaces(specs.LinuxNamespace{Type: specs.NetworkNamespace})
+
+			initialBundle.WithoutNamespace(specs.NetworkNamespace)
+			Expect(initialBundle.Namespaces()).To(ConsistOf(specs.LinuxNamespace{Type: specs.NetworkNamespace}))
+		})
+	})
+
 	Describe("WithNamespaces", func() {
 		BeforeEach(func() {
 			returnedBundle = initialBundle.WithNamespaces(specs.LinuxNamespace{Type: specs.NetworkNamespace})
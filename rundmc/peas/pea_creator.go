@@ -49,8 +49,13 @@ type PeaCreator struct {
 	BundleGenerator        depot.BundleGenerator
 	BundleSaver            depot.BundleSaver
 	ProcessBuilder         runrunc.ProcessBuilder
-	ExecRunner             runrunc.ExecRunner
 	RuncDeleter            RuncDeleter
+
+	// a pea is run by the runtime of its sandbox's runtime class: ExecRunner
+	// for sandboxes without one, otherwise the class's in ClassExecRunners
+	BundleLoader     depot.BundleLoader
+	ExecRunner       runrunc.ExecRunner
+	ClassExecRunners map[string]runrunc.ExecRunner
 }
 
 func (p *PeaCreator) CreatePea(log lager.Logger, spec garden.ProcessSpec, procIO garden.ProcessIO, sandboxHandle, sandboxBundlePath string) (garden.Process, error) {
@@ -80,6 +85,11 @@ func (p *PeaCreator) CreatePea(log lager.Logger, spec garden.ProcessSpec, procIO
 		return errs("determining-privileged", err)
 	}
 
+	execRunner, err := p.execRunnerFor(sandboxBundlePath)
+	if err != nil {
+		return errs("determining-runtime-class", err)
+	}
+
 	defaultBindMounts, err := p.BindMountSourceCreator.Create(sandboxBundlePath, !privileged)
 	if err != nil {
 		return errs("creating-bind-mount-sources", err)
@@ -88,6 +98,29 @@ func (p *PeaCreator) CreatePea(log lager.Logger, spec garden.ProcessSpec, procIO
 	if spec.Dir == "" {
 		spec.Dir = "/"
 	}
+
+	var timeout *runrunc.ProcessTimeout
+	spec.Env, timeout, err = runrunc.ExtractProcessTimeout(spec.Env)
+	if err != nil {
+		return errs("parse-process-timeout", err)
+	}
+	if timeout != nil {
+		if err := runrunc.WriteProcessTimeout(peaBundlePath, *timeout); err != nil {
+			return errs("write-process-timeout", err)
+		}
+	}
+
+	var logConfig *runrunc.ProcessLogConfig
+	spec.Env, logConfig, err = runrunc.ExtractProcessLogConfig(spec.Env)
+	if err != nil {
+		return errs("parse-process-log-config", err)
+	}
+	if logConfig != nil {
+		if err := runrunc.WriteProcessLogConfig(peaBundlePath, *logConfig); err != nil {
+			return errs("write-process-log-config", err)
+		}
+	}
+
 	uid, gid, err := parseUser(spec.User)
 	if err != nil {
 		return errs("parse-user", err)
@@ -158,7 +191,7 @@ func (p *PeaCreator) CreatePea(log lager.Logger, spec garden.ProcessSpec, procIO
 
 		return result.ErrorOrNil()
 	}
-	proc, runErr := p.ExecRunner.Run(
+	proc, runErr := execRunner.Run(
 		log, processID, peaBundlePath, sandboxHandle, sandboxBundlePath,
 		preparedProcess.ContainerRootHostUID, preparedProcess.ContainerRootHostGID,
 		procIO, preparedProcess.Process.Terminal, nil, extraCleanup,
@@ -171,6 +204,25 @@ func (p *PeaCreator) CreatePea(log lager.Logger, spec garden.ProcessSpec, procIO
 	return proc, nil
 }
 
+func (p *PeaCreator) execRunnerFor(sandboxBundlePath string) (runrunc.ExecRunner, error) {
+	sandboxBundle, err := p.BundleLoader.Load(sandboxBundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	class := sandboxBundle.RuntimeClass()
+	if class == "" {
+		return p.ExecRunner, nil
+	}
+
+	execRunner, ok := p.ClassExecRunners[class]
+	if !ok {
+		return nil, fmt.Errorf("unknown runtime class: %s", class)
+	}
+
+	return execRunner, nil
+}
+
 func (p *PeaCreator) linuxNamespaces(sandboxBundlePath string, privileged bool) (map[string]string, error) {
 	originalCtrInitPid, err := p.PidGetter.Pid(filepath.Join(sandboxBundlePath, "pidfile"))
 	if err != nil {
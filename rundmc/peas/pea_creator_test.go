@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/guardian/rundmc/depot/depotfakes"
@@ -32,6 +33,8 @@ var _ = Describe("PeaCreator", func() {
 		bundleSaver            *depotfakes.FakeBundleSaver
 		processBuilder         *runruncfakes.FakeProcessBuilder
 		execRunner             *runruncfakes.FakeExecRunner
+		classExecRunner        *runruncfakes.FakeExecRunner
+		bundleLoader           *depotfakes.FakeBundleLoader
 		privilegedGetter       *peasfakes.FakePrivilegedGetter
 
 		peaCreator *peas.PeaCreator
@@ -78,6 +81,9 @@ var _ = Describe("PeaCreator", func() {
 		processBuilder.BuildProcessReturns(builtProcess)
 
 		execRunner = new(runruncfakes.FakeExecRunner)
+		classExecRunner = new(runruncfakes.FakeExecRunner)
+		bundleLoader = new(depotfakes.FakeBundleLoader)
+		bundleLoader.LoadReturns(goci.Bndl{}, nil)
 
 		privilegedGetter = new(peasfakes.FakePrivilegedGetter)
 		privilegedGetter.PrivilegedReturns(false, nil)
@@ -89,9 +95,11 @@ var _ = Describe("PeaCreator", func() {
 			BundleGenerator:        bundleGenerator,
 			BundleSaver:            bundleSaver,
 			ProcessBuilder:         processBuilder,
-			ExecRunner:             execRunner,
 			PrivilegedGetter:       privilegedGetter,
 			RuncDeleter:            runcDeleter,
+			BundleLoader:           bundleLoader,
+			ExecRunner:             execRunner,
+			ClassExecRunners:       map[string]runrunc.ExecRunner{"kata": classExecRunner},
 		}
 
 		var err error
@@ -197,6 +205,40 @@ var _ = Describe("PeaCreator", func() {
 			Expect(actualCtrSpec.Privileged).To(Equal(false))
 		})
 
+		Context("when the process has a timeout", func() {
+			BeforeEach(func() {
+				processSpec.Env = []string{"GARDEN_PROCESS_TIMEOUT=1h", "FOO=bar"}
+			})
+
+			It("does not pass the timeout to the process", func() {
+				_, actualProcessSpec := processBuilder.BuildProcessArgsForCall(0)
+				Expect(actualProcessSpec.Env).To(Equal([]string{"FOO=bar"}))
+			})
+
+			It("records the timeout in the pea's bundle directory", func() {
+				timeout, err := runrunc.ReadProcessTimeout(filepath.Join(ctrBundleDir, "processes", processSpec.ID))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(timeout.Timeout).To(Equal(time.Hour))
+			})
+		})
+
+		Context("when the output of the process is captured", func() {
+			BeforeEach(func() {
+				processSpec.Env = []string{"GARDEN_PROCESS_LOG=true", "FOO=bar"}
+			})
+
+			It("does not pass the log config to the process", func() {
+				_, actualProcessSpec := processBuilder.BuildProcessArgsForCall(0)
+				Expect(actualProcessSpec.Env).To(Equal([]string{"FOO=bar"}))
+			})
+
+			It("records the log config in the pea's bundle directory", func() {
+				config, err := runrunc.ReadProcessLogConfig(filepath.Join(ctrBundleDir, "processes", processSpec.ID))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config).NotTo(BeNil())
+			})
+		})
+
 		Describe("sharing namespaces", func() {
 			It("shares all namespaces apart from mnt with the container", func() {
 				Expect(bundleGenerator.GenerateCallCount()).To(Equal(1))
@@ -271,6 +313,22 @@ var _ = Describe("PeaCreator", func() {
 			Expect(actualProcJSON).To(BeNil())
 		})
 
+		It("loads the sandbox container's bundle to determine its runtime class", func() {
+			Expect(bundleLoader.LoadCallCount()).To(Equal(1))
+			Expect(bundleLoader.LoadArgsForCall(0)).To(Equal(ctrBundleDir))
+		})
+
+		Context("when the sandbox container has a runtime class", func() {
+			BeforeEach(func() {
+				bundleLoader.LoadReturns(goci.Bndl{}.WithRuntimeClass("kata"), nil)
+			})
+
+			It("runs the pea with the runtime of the class", func() {
+				Eventually(classExecRunner.RunCallCount()).Should(Equal(1))
+				Expect(execRunner.RunCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when the runtime spec uses a TTY", func() {
 			BeforeEach(func() {
 				builtProcess.Terminal = true
@@ -373,6 +431,26 @@ var _ = Describe("PeaCreator", func() {
 			})
 		})
 
+		Context("when the process timeout is invalid", func() {
+			BeforeEach(func() {
+				processSpec.Env = []string{"GARDEN_PROCESS_TIMEOUT=-1m"}
+			})
+
+			It("returns a wrapped error", func() {
+				Expect(createErr).To(MatchError(ContainSubstring("invalid GARDEN_PROCESS_TIMEOUT")))
+			})
+		})
+
+		Context("when the process log config is invalid", func() {
+			BeforeEach(func() {
+				processSpec.Env = []string{"GARDEN_PROCESS_LOG=yes please"}
+			})
+
+			It("returns a wrapped error", func() {
+				Expect(createErr).To(MatchError(ContainSubstring("invalid GARDEN_PROCESS_LOG")))
+			})
+		})
+
 		Context("when the pid getter returns an error", func() {
 			BeforeEach(func() {
 				pidGetter.PidReturns(-1, errors.New("pickle"))
@@ -453,6 +531,30 @@ var _ = Describe("PeaCreator", func() {
 			})
 		})
 
+		Context("when the sandbox container's bundle cannot be loaded", func() {
+			BeforeEach(func() {
+				bundleLoader.LoadReturns(goci.Bndl{}, errors.New("load-error"))
+			})
+
+			It("returns an error", func() {
+				Expect(createErr).To(MatchError(ContainSubstring("load-error")))
+			})
+		})
+
+		Context("when the sandbox container's runtime class is unknown", func() {
+			BeforeEach(func() {
+				bundleLoader.LoadReturns(goci.Bndl{}.WithRuntimeClass("gvisor"), nil)
+			})
+
+			It("returns an error", func() {
+				Expect(createErr).To(MatchError(ContainSubstring("unknown runtime class: gvisor")))
+			})
+
+			It("does not create a volume", func() {
+				Expect(volumizer.CreateCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when the exec runner returns an error", func() {
 			BeforeEach(func() {
 				execRunner.RunReturns(nil, errors.New("execrunner-error"))
@@ -126,6 +126,21 @@ type FakeOCIRuntime struct {
 	watchEventsReturnsOnCall map[int]struct {
 		result1 error
 	}
+	ProcessIDsStub        func(log lager.Logger, bundlePath string, id string) ([]string, error)
+	processIDsMutex       sync.RWMutex
+	processIDsArgsForCall []struct {
+		log        lager.Logger
+		bundlePath string
+		id         string
+	}
+	processIDsReturns struct {
+		result1 []string
+		result2 error
+	}
+	processIDsReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -544,6 +559,59 @@ func (fake *FakeOCIRuntime) WatchEventsReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeOCIRuntime) ProcessIDs(log lager.Logger, bundlePath string, id string) ([]string, error) {
+	fake.processIDsMutex.Lock()
+	ret, specificReturn := fake.processIDsReturnsOnCall[len(fake.processIDsArgsForCall)]
+	fake.processIDsArgsForCall = append(fake.processIDsArgsForCall, struct {
+		log        lager.Logger
+		bundlePath string
+		id         string
+	}{log, bundlePath, id})
+	fake.recordInvocation("ProcessIDs", []interface{}{log, bundlePath, id})
+	fake.processIDsMutex.Unlock()
+	if fake.ProcessIDsStub != nil {
+		return fake.ProcessIDsStub(log, bundlePath, id)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.processIDsReturns.result1, fake.processIDsReturns.result2
+}
+
+func (fake *FakeOCIRuntime) ProcessIDsCallCount() int {
+	fake.processIDsMutex.RLock()
+	defer fake.processIDsMutex.RUnlock()
+	return len(fake.processIDsArgsForCall)
+}
+
+func (fake *FakeOCIRuntime) ProcessIDsArgsForCall(i int) (lager.Logger, string, string) {
+	fake.processIDsMutex.RLock()
+	defer fake.processIDsMutex.RUnlock()
+	return fake.processIDsArgsForCall[i].log, fake.processIDsArgsForCall[i].bundlePath, fake.processIDsArgsForCall[i].id
+}
+
+func (fake *FakeOCIRuntime) ProcessIDsReturns(result1 []string, result2 error) {
+	fake.ProcessIDsStub = nil
+	fake.processIDsReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeOCIRuntime) ProcessIDsReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.ProcessIDsStub = nil
+	if fake.processIDsReturnsOnCall == nil {
+		fake.processIDsReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.processIDsReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeOCIRuntime) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -563,6 +631,8 @@ func (fake *FakeOCIRuntime) Invocations() map[string][][]interface{} {
 	defer fake.statsMutex.RUnlock()
 	fake.watchEventsMutex.RLock()
 	defer fake.watchEventsMutex.RUnlock()
+	fake.processIDsMutex.RLock()
+	defer fake.processIDsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -24,6 +24,25 @@ type FakeStateStore struct {
 	isStoppedReturnsOnCall map[int]struct {
 		result1 bool
 	}
+	StoreOOMKillsStub        func(handle string, kills uint64)
+	storeOOMKillsMutex       sync.RWMutex
+	storeOOMKillsArgsForCall []struct {
+		handle string
+		kills  uint64
+	}
+	OOMKillsStub        func(handle string) (uint64, bool)
+	oOMKillsMutex       sync.RWMutex
+	oOMKillsArgsForCall []struct {
+		handle string
+	}
+	oOMKillsReturns struct {
+		result1 uint64
+		result2 bool
+	}
+	oOMKillsReturnsOnCall map[int]struct {
+		result1 uint64
+		result2 bool
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -100,6 +119,82 @@ func (fake *FakeStateStore) IsStoppedReturnsOnCall(i int, result1 bool) {
 	}{result1}
 }
 
+func (fake *FakeStateStore) StoreOOMKills(handle string, kills uint64) {
+	fake.storeOOMKillsMutex.Lock()
+	fake.storeOOMKillsArgsForCall = append(fake.storeOOMKillsArgsForCall, struct {
+		handle string
+		kills  uint64
+	}{handle, kills})
+	fake.recordInvocation("StoreOOMKills", []interface{}{handle, kills})
+	fake.storeOOMKillsMutex.Unlock()
+	if fake.StoreOOMKillsStub != nil {
+		fake.StoreOOMKillsStub(handle, kills)
+	}
+}
+
+func (fake *FakeStateStore) StoreOOMKillsCallCount() int {
+	fake.storeOOMKillsMutex.RLock()
+	defer fake.storeOOMKillsMutex.RUnlock()
+	return len(fake.storeOOMKillsArgsForCall)
+}
+
+func (fake *FakeStateStore) StoreOOMKillsArgsForCall(i int) (string, uint64) {
+	fake.storeOOMKillsMutex.RLock()
+	defer fake.storeOOMKillsMutex.RUnlock()
+	return fake.storeOOMKillsArgsForCall[i].handle, fake.storeOOMKillsArgsForCall[i].kills
+}
+
+func (fake *FakeStateStore) OOMKills(handle string) (uint64, bool) {
+	fake.oOMKillsMutex.Lock()
+	ret, specificReturn := fake.oOMKillsReturnsOnCall[len(fake.oOMKillsArgsForCall)]
+	fake.oOMKillsArgsForCall = append(fake.oOMKillsArgsForCall, struct {
+		handle string
+	}{handle})
+	fake.recordInvocation("OOMKills", []interface{}{handle})
+	fake.oOMKillsMutex.Unlock()
+	if fake.OOMKillsStub != nil {
+		return fake.OOMKillsStub(handle)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.oOMKillsReturns.result1, fake.oOMKillsReturns.result2
+}
+
+func (fake *FakeStateStore) OOMKillsCallCount() int {
+	fake.oOMKillsMutex.RLock()
+	defer fake.oOMKillsMutex.RUnlock()
+	return len(fake.oOMKillsArgsForCall)
+}
+
+func (fake *FakeStateStore) OOMKillsArgsForCall(i int) string {
+	fake.oOMKillsMutex.RLock()
+	defer fake.oOMKillsMutex.RUnlock()
+	return fake.oOMKillsArgsForCall[i].handle
+}
+
+func (fake *FakeStateStore) OOMKillsReturns(result1 uint64, result2 bool) {
+	fake.OOMKillsStub = nil
+	fake.oOMKillsReturns = struct {
+		result1 uint64
+		result2 bool
+	}{result1, result2}
+}
+
+func (fake *FakeStateStore) OOMKillsReturnsOnCall(i int, result1 uint64, result2 bool) {
+	fake.OOMKillsStub = nil
+	if fake.oOMKillsReturnsOnCall == nil {
+		fake.oOMKillsReturnsOnCall = make(map[int]struct {
+			result1 uint64
+			result2 bool
+		})
+	}
+	fake.oOMKillsReturnsOnCall[i] = struct {
+		result1 uint64
+		result2 bool
+	}{result1, result2}
+}
+
 func (fake *FakeStateStore) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -107,6 +202,10 @@ func (fake *FakeStateStore) Invocations() map[string][][]interface{} {
 	defer fake.storeStoppedMutex.RUnlock()
 	fake.isStoppedMutex.RLock()
 	defer fake.isStoppedMutex.RUnlock()
+	fake.storeOOMKillsMutex.RLock()
+	defer fake.storeOOMKillsMutex.RUnlock()
+	fake.oOMKillsMutex.RLock()
+	defer fake.oOMKillsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
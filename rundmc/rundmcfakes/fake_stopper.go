@@ -3,31 +3,36 @@ package rundmcfakes
 
 import (
 	"sync"
+	"syscall"
+	"time"
 
 	"code.cloudfoundry.org/guardian/rundmc"
 	"code.cloudfoundry.org/lager"
 )
 
 type FakeStopper struct {
-	StopAllStub        func(log lager.Logger, cgroupName string, save []int, kill bool) error
+	StopAllStub        func(log lager.Logger, cgroupName string, save []int, signal syscall.Signal, gracePeriod time.Duration) ([]int, error)
 	stopAllMutex       sync.RWMutex
 	stopAllArgsForCall []struct {
-		log        lager.Logger
-		cgroupName string
-		save       []int
-		kill       bool
+		log         lager.Logger
+		cgroupName  string
+		save        []int
+		signal      syscall.Signal
+		gracePeriod time.Duration
 	}
 	stopAllReturns struct {
-		result1 error
+		result1 []int
+		result2 error
 	}
 	stopAllReturnsOnCall map[int]struct {
-		result1 error
+		result1 []int
+		result2 error
 	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeStopper) StopAll(log lager.Logger, cgroupName string, save []int, kill bool) error {
+func (fake *FakeStopper) StopAll(log lager.Logger, cgroupName string, save []int, signal syscall.Signal, gracePeriod time.Duration) ([]int, error) {
 	var saveCopy []int
 	if save != nil {
 		saveCopy = make([]int, len(save))
@@ -36,20 +41,21 @@ func (fake *FakeStopper) StopAll(log lager.Logger, cgroupName string, save []int
 	fake.stopAllMutex.Lock()
 	ret, specificReturn := fake.stopAllReturnsOnCall[len(fake.stopAllArgsForCall)]
 	fake.stopAllArgsForCall = append(fake.stopAllArgsForCall, struct {
-		log        lager.Logger
-		cgroupName string
-		save       []int
-		kill       bool
-	}{log, cgroupName, saveCopy, kill})
-	fake.recordInvocation("StopAll", []interface{}{log, cgroupName, saveCopy, kill})
+		log         lager.Logger
+		cgroupName  string
+		save        []int
+		signal      syscall.Signal
+		gracePeriod time.Duration
+	}{log, cgroupName, saveCopy, signal, gracePeriod})
+	fake.recordInvocation("StopAll", []interface{}{log, cgroupName, saveCopy, signal, gracePeriod})
 	fake.stopAllMutex.Unlock()
 	if fake.StopAllStub != nil {
-		return fake.StopAllStub(log, cgroupName, save, kill)
+		return fake.StopAllStub(log, cgroupName, save, signal, gracePeriod)
 	}
 	if specificReturn {
-		return ret.result1
+		return ret.result1, ret.result2
 	}
-	return fake.stopAllReturns.result1
+	return fake.stopAllReturns.result1, fake.stopAllReturns.result2
 }
 
 func (fake *FakeStopper) StopAllCallCount() int {
@@ -58,29 +64,32 @@ func (fake *FakeStopper) StopAllCallCount() int {
 	return len(fake.stopAllArgsForCall)
 }
 
-func (fake *FakeStopper) StopAllArgsForCall(i int) (lager.Logger, string, []int, bool) {
+func (fake *FakeStopper) StopAllArgsForCall(i int) (lager.Logger, string, []int, syscall.Signal, time.Duration) {
 	fake.stopAllMutex.RLock()
 	defer fake.stopAllMutex.RUnlock()
-	return fake.stopAllArgsForCall[i].log, fake.stopAllArgsForCall[i].cgroupName, fake.stopAllArgsForCall[i].save, fake.stopAllArgsForCall[i].kill
+	return fake.stopAllArgsForCall[i].log, fake.stopAllArgsForCall[i].cgroupName, fake.stopAllArgsForCall[i].save, fake.stopAllArgsForCall[i].signal, fake.stopAllArgsForCall[i].gracePeriod
 }
 
-func (fake *FakeStopper) StopAllReturns(result1 error) {
+func (fake *FakeStopper) StopAllReturns(result1 []int, result2 error) {
 	fake.StopAllStub = nil
 	fake.stopAllReturns = struct {
-		result1 error
-	}{result1}
+		result1 []int
+		result2 error
+	}{result1, result2}
 }
 
-func (fake *FakeStopper) StopAllReturnsOnCall(i int, result1 error) {
+func (fake *FakeStopper) StopAllReturnsOnCall(i int, result1 []int, result2 error) {
 	fake.StopAllStub = nil
 	if fake.stopAllReturnsOnCall == nil {
 		fake.stopAllReturnsOnCall = make(map[int]struct {
-			result1 error
+			result1 []int
+			result2 error
 		})
 	}
 	fake.stopAllReturnsOnCall[i] = struct {
-		result1 error
-	}{result1}
+		result1 []int
+		result2 error
+	}{result1, result2}
 }
 
 func (fake *FakeStopper) Invocations() map[string][][]interface{} {
@@ -63,6 +63,7 @@ type ExecRunner interface {
 		procJSON io.Reader, extraCleanup func() error,
 	) (garden.Process, error)
 	Attach(log lager.Logger, processID string, io garden.ProcessIO, processesPath string) (garden.Process, error)
+	ProcessIDs(log lager.Logger, processesPath string) ([]string, error)
 }
 
 type PreparedSpec struct {
@@ -42,6 +42,19 @@ func (e *Execer) Exec(log lager.Logger, bundlePath, sandboxHandle string, spec g
 	log.Info("start")
 	defer log.Info("finished")
 
+	env, timeout, err := ExtractProcessTimeout(spec.Env)
+	if err != nil {
+		log.Error("parse-process-timeout-failed", err)
+		return nil, err
+	}
+
+	env, logConfig, err := ExtractProcessLogConfig(env)
+	if err != nil {
+		log.Error("parse-process-log-config-failed", err)
+		return nil, err
+	}
+	spec.Env = env
+
 	ctrInitPid, err := ioutil.ReadFile(filepath.Join(bundlePath, "pidfile"))
 	if err != nil {
 		log.Error("read-pidfile-failed", err)
@@ -94,6 +107,18 @@ func (e *Execer) Exec(log lager.Logger, bundlePath, sandboxHandle string, spec g
 		return nil, err
 	}
 
+	if timeout != nil {
+		if err := WriteProcessTimeout(processPath, *timeout); err != nil {
+			return nil, err
+		}
+	}
+
+	if logConfig != nil {
+		if err := WriteProcessLogConfig(processPath, *logConfig); err != nil {
+			return nil, err
+		}
+	}
+
 	encodedSpec, err := json.Marshal(preparedSpec.Process)
 	if err != nil {
 		return nil, err // this could *almost* be a panic: a valid spec should always encode (but out of caution we'll error)
@@ -105,6 +130,11 @@ func (e *Execer) Exec(log lager.Logger, bundlePath, sandboxHandle string, spec g
 	)
 }
 
+// ProcessIDs lists the processes in the bundle's processes directory
+func (e *Execer) ProcessIDs(log lager.Logger, bundlePath, id string) ([]string, error) {
+	return e.runner.ProcessIDs(log, filepath.Join(bundlePath, "processes"))
+}
+
 // Attach attaches to an already running process by guid
 func (e *Execer) Attach(log lager.Logger, bundlePath, id, processID string, io garden.ProcessIO) (garden.Process, error) {
 	processesPath := path.Join(bundlePath, "processes")
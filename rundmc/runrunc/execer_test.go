@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/guardian/rundmc/goci"
@@ -178,6 +179,46 @@ var _ = Describe("Execer", func() {
 			Expect(string(actualProcJSONBytes)).To(Equal(string(procJSONBytes)))
 		})
 
+		Context("when the process has a timeout", func() {
+			BeforeEach(func() {
+				spec.Env = []string{"FOO=bar", "GARDEN_PROCESS_TIMEOUT=5m"}
+			})
+
+			It("does not pass the timeout to the process", func() {
+				_, actualProcessSpec := processBuilder.BuildProcessArgsForCall(0)
+				Expect(actualProcessSpec.Env).To(Equal([]string{"FOO=bar"}))
+			})
+
+			It("records the timeout in the process directory", func() {
+				timeout, err := runrunc.ReadProcessTimeout(filepath.Join(bundlePath, "processes", spec.ID))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(timeout).To(Equal(&runrunc.ProcessTimeout{
+					Timeout:     5 * time.Minute,
+					GracePeriod: runrunc.DefaultProcessTimeoutGracePeriod,
+				}))
+			})
+		})
+
+		Context("when the output of the process is captured", func() {
+			BeforeEach(func() {
+				spec.Env = []string{"FOO=bar", "GARDEN_PROCESS_LOG=true", "GARDEN_PROCESS_LOG_MAX_FILES=2"}
+			})
+
+			It("does not pass the log config to the process", func() {
+				_, actualProcessSpec := processBuilder.BuildProcessArgsForCall(0)
+				Expect(actualProcessSpec.Env).To(Equal([]string{"FOO=bar"}))
+			})
+
+			It("records the log config in the process directory", func() {
+				config, err := runrunc.ReadProcessLogConfig(filepath.Join(bundlePath, "processes", spec.ID))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config).To(Equal(&runrunc.ProcessLogConfig{
+					MaxSize:  runrunc.DefaultProcessLogMaxSize,
+					MaxFiles: 2,
+				}))
+			})
+		})
+
 		Context("when the process has a terminal", func() {
 			BeforeEach(func() {
 				preparedProc.Process.Terminal = true
@@ -246,6 +287,28 @@ var _ = Describe("Execer", func() {
 			})
 		})
 
+		Context("when the process timeout is invalid", func() {
+			BeforeEach(func() {
+				spec.Env = []string{"GARDEN_PROCESS_TIMEOUT=forever"}
+			})
+
+			It("returns an error", func() {
+				Expect(execErr).To(MatchError(ContainSubstring("invalid GARDEN_PROCESS_TIMEOUT")))
+				Expect(execRunner.RunCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the process log config is invalid", func() {
+			BeforeEach(func() {
+				spec.Env = []string{"GARDEN_PROCESS_LOG=true", "GARDEN_PROCESS_LOG_MAX_SIZE=big"}
+			})
+
+			It("returns an error", func() {
+				Expect(execErr).To(MatchError(ContainSubstring("invalid GARDEN_PROCESS_LOG_MAX_SIZE")))
+				Expect(execRunner.RunCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when the process ID is already in use", func() {
 			BeforeEach(func() {
 				Expect(os.MkdirAll(filepath.Join(bundlePath, "processes", spec.ID), 0700)).To(Succeed())
@@ -0,0 +1,91 @@
+package runrunc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// ExitReasonExited is recorded for processes which exited of their own
+	// accord
+	ExitReasonExited = "exited"
+
+	// ExitReasonSignaled is recorded for processes which were terminated by a
+	// signal
+	ExitReasonSignaled = "signaled"
+
+	// ExitReasonOOMKilled is recorded for processes which were killed by the
+	// kernel because their memory cgroup ran out of memory
+	ExitReasonOOMKilled = "oom-killed"
+
+	// ExitReasonTimedOut is recorded for processes which were stopped because
+	// they exceeded their ProcessTimeout
+	ExitReasonTimedOut = "timed-out"
+
+	exitStatusFile = "exitstatus.json"
+)
+
+// ExitStatus is recorded in the directory of a process by dadoo once the
+// process has exited, so that it outlives the server which ran the process
+type ExitStatus struct {
+	Code   int       `json:"code"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+
+	// The signal which terminated the process, if any
+	Signal string `json:"signal,omitempty"`
+}
+
+// WriteExitStatus records the exit status in the process directory, replacing
+// it atomically so that it is never read partially written
+func WriteExitStatus(processPath string, status ExitStatus) error {
+	contents, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(processPath, exitStatusFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(contents); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), filepath.Join(processPath, exitStatusFile))
+}
+
+// ReadExitStatus returns the exit status recorded in the process directory,
+// or nil if none has been recorded
+func ReadExitStatus(processPath string) (*ExitStatus, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(processPath, exitStatusFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var status ExitStatus
+	if err := json.Unmarshal(contents, &status); err != nil {
+		return nil, fmt.Errorf("parsing exit status: %s", err)
+	}
+
+	return &status, nil
+}
@@ -0,0 +1,60 @@
+package runrunc_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ExitStatus", func() {
+	var processPath string
+
+	BeforeEach(func() {
+		var err error
+		processPath, err = ioutil.TempDir("", "process")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(processPath)).To(Succeed())
+	})
+
+	It("reads back the exit status written to the process directory", func() {
+		status := runrunc.ExitStatus{
+			Code:   137,
+			Time:   time.Unix(1500000000, 0).UTC(),
+			Reason: runrunc.ExitReasonOOMKilled,
+			Signal: "killed",
+		}
+		Expect(runrunc.WriteExitStatus(processPath, status)).To(Succeed())
+
+		Expect(runrunc.ReadExitStatus(processPath)).To(Equal(&status))
+	})
+
+	It("does not leave any temporary files in the process directory", func() {
+		Expect(runrunc.WriteExitStatus(processPath, runrunc.ExitStatus{Code: 1})).To(Succeed())
+
+		entries, err := ioutil.ReadDir(processPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Name()).To(Equal("exitstatus.json"))
+	})
+
+	It("reads no exit status when none was written", func() {
+		Expect(runrunc.ReadExitStatus(processPath)).To(BeNil())
+	})
+
+	Context("when the exit status is not valid JSON", func() {
+		It("returns an error", func() {
+			Expect(ioutil.WriteFile(filepath.Join(processPath, "exitstatus.json"), []byte("{"), 0600)).To(Succeed())
+
+			_, err := runrunc.ReadExitStatus(processPath)
+			Expect(err).To(MatchError(ContainSubstring("parsing exit status")))
+		})
+	})
+})
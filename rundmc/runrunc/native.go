@@ -0,0 +1,299 @@
+package runrunc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/gardener"
+	"code.cloudfoundry.org/lager"
+)
+
+// the kernel reports cpuacct.stat in USER_HZ, which is 100 on all supported
+// architectures
+const clockTicksPerSecond = 100
+
+//go:generate counterfeiter . StateGetter
+type StateGetter interface {
+	State(log lager.Logger, id string) (State, error)
+}
+
+//go:generate counterfeiter . StatsGetter
+type StatsGetter interface {
+	Stats(log lager.Logger, id string) (gardener.ActualContainerMetrics, error)
+}
+
+// runcState is the part of the state.json which runc keeps for each container
+// in its state directory that garden needs
+type runcState struct {
+	InitProcessPid   int               `json:"init_process_pid"`
+	InitProcessStart startTime         `json:"init_process_start"`
+	CgroupPaths      map[string]string `json:"cgroup_paths"`
+}
+
+// startTime is recorded as a string by older versions of runc
+type startTime uint64
+
+func (t *startTime) UnmarshalJSON(data []byte) error {
+	n, err := strconv.ParseUint(strings.Trim(string(data), `"`), 10, 64)
+	if err != nil {
+		return fmt.Errorf("parse init process start time: %s", err)
+	}
+
+	*t = startTime(n)
+	return nil
+}
+
+func readRuncState(stateDir, id string) (runcState, error) {
+	var state runcState
+
+	stateJson, err := os.Open(filepath.Join(stateDir, id, "state.json"))
+	if err != nil {
+		return state, err
+	}
+	defer stateJson.Close()
+
+	err = json.NewDecoder(stateJson).Decode(&state)
+	return state, err
+}
+
+// NativeStater works out the state of a container from runc's state directory
+// and /proc in the same way as 'runc state', without forking runc. If the
+// state cannot be read, e.g. because the container was created by a runtime
+// which keeps its state elsewhere, it falls back to the given StateGetter.
+type NativeStater struct {
+	stateDir string
+	fallback StateGetter
+}
+
+func NewNativeStater(stateDir string, fallback StateGetter) *NativeStater {
+	return &NativeStater{
+		stateDir: stateDir,
+		fallback: fallback,
+	}
+}
+
+func (s *NativeStater) State(log lager.Logger, id string) (State, error) {
+	state, err := s.state(id)
+	if err != nil {
+		log.Debug("native-state-failed-falling-back", lager.Data{"handle": id, "error": err.Error()})
+		return s.fallback.State(log, id)
+	}
+
+	return state, nil
+}
+
+func (s *NativeStater) state(id string) (State, error) {
+	runcState, err := readRuncState(s.stateDir, id)
+	if err != nil {
+		return State{}, err
+	}
+
+	alive, err := isAlive(runcState.InitProcessPid, uint64(runcState.InitProcessStart))
+	if err != nil {
+		return State{}, err
+	}
+
+	if !alive {
+		return State{Status: StoppedStatus}, nil
+	}
+
+	// runc removes the exec fifo once the container's process is started
+	if _, err := os.Stat(filepath.Join(s.stateDir, id, "exec.fifo")); err == nil {
+		return State{Pid: runcState.InitProcessPid, Status: CreatedStatus}, nil
+	}
+
+	return State{Pid: runcState.InitProcessPid, Status: RunningStatus}, nil
+}
+
+// isAlive returns whether the process with the given pid is still the one
+// started at the given time, rather than a zombie or a process which has
+// since been given the same pid
+func isAlive(pid int, start uint64) (bool, error) {
+	if pid <= 0 {
+		return false, nil
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// the command name may contain spaces and parentheses, so the remaining
+	// fields are found after the last parenthesis
+	stat := string(contents)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 20 {
+		return false, fmt.Errorf("unknown format of /proc/%d/stat", pid)
+	}
+
+	processState, processStart := fields[0], fields[19]
+	if processState == "Z" || processState == "X" {
+		return false, nil
+	}
+
+	return processStart == strconv.FormatUint(start, 10), nil
+}
+
+// NativeStatser reads the metrics of a container from its cgroups, which it
+// finds from runc's state directory, without forking runc. If they cannot be
+// read, it falls back to the given StatsGetter.
+type NativeStatser struct {
+	stateDir string
+	fallback StatsGetter
+}
+
+func NewNativeStatser(stateDir string, fallback StatsGetter) *NativeStatser {
+	return &NativeStatser{
+		stateDir: stateDir,
+		fallback: fallback,
+	}
+}
+
+func (s *NativeStatser) Stats(log lager.Logger, id string) (gardener.ActualContainerMetrics, error) {
+	metrics, err := s.stats(id)
+	if err != nil {
+		log.Debug("native-stats-failed-falling-back", lager.Data{"handle": id, "error": err.Error()})
+		return s.fallback.Stats(log, id)
+	}
+
+	return metrics, nil
+}
+
+func (s *NativeStatser) stats(id string) (gardener.ActualContainerMetrics, error) {
+	state, err := readRuncState(s.stateDir, id)
+	if err != nil {
+		return gardener.ActualContainerMetrics{}, err
+	}
+
+	if cgroupPath, ok := state.CgroupPaths[""]; ok {
+		return unifiedMetrics(cgroupPath)
+	}
+
+	cpuacctPath, memoryPath := state.CgroupPaths["cpuacct"], state.CgroupPaths["memory"]
+	if cpuacctPath == "" || memoryPath == "" {
+		return gardener.ActualContainerMetrics{}, errors.New("no cpuacct or memory cgroup in runc state")
+	}
+
+	return v1Metrics(cpuacctPath, memoryPath)
+}
+
+func v1Metrics(cpuacctPath, memoryPath string) (gardener.ActualContainerMetrics, error) {
+	usage, err := readUint(filepath.Join(cpuacctPath, "cpuacct.usage"))
+	if err != nil {
+		return gardener.ActualContainerMetrics{}, err
+	}
+
+	cpuStat, err := readKeyValues(filepath.Join(cpuacctPath, "cpuacct.stat"))
+	if err != nil {
+		return gardener.ActualContainerMetrics{}, err
+	}
+
+	memory, err := readMemoryStat(memoryPath, 0)
+	if err != nil {
+		return gardener.ActualContainerMetrics{}, err
+	}
+
+	return newMetrics(garden.ContainerCPUStat{
+		Usage:  usage,
+		System: cpuStat["system"] * 1e9 / clockTicksPerSecond,
+		User:   cpuStat["user"] * 1e9 / clockTicksPerSecond,
+	}, memory), nil
+}
+
+func unifiedMetrics(cgroupPath string) (gardener.ActualContainerMetrics, error) {
+	cpuStat, err := readKeyValues(filepath.Join(cgroupPath, "cpu.stat"))
+	if err != nil {
+		return gardener.ActualContainerMetrics{}, err
+	}
+
+	memoryMax, err := ioutil.ReadFile(filepath.Join(cgroupPath, "memory.max"))
+	if err != nil {
+		return gardener.ActualContainerMetrics{}, err
+	}
+
+	limit := uint64(math.MaxUint64)
+	if value := strings.TrimSpace(string(memoryMax)); value != "max" {
+		if limit, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return gardener.ActualContainerMetrics{}, fmt.Errorf("parse memory.max: %s", err)
+		}
+	}
+
+	memory, err := readMemoryStat(cgroupPath, limit)
+	if err != nil {
+		return gardener.ActualContainerMetrics{}, err
+	}
+
+	return newMetrics(garden.ContainerCPUStat{
+		Usage:  cpuStat["usage_usec"] * 1000,
+		System: cpuStat["system_usec"] * 1000,
+		User:   cpuStat["user_usec"] * 1000,
+	}, memory), nil
+}
+
+// readMemoryStat reads memory.stat as runc reports it, as the raw stats
+func readMemoryStat(memoryPath string, limit uint64) (garden.ContainerMemoryStat, error) {
+	raw, err := readKeyValues(filepath.Join(memoryPath, "memory.stat"))
+	if err != nil {
+		return garden.ContainerMemoryStat{}, err
+	}
+
+	rawJson, err := json.Marshal(raw)
+	if err != nil {
+		return garden.ContainerMemoryStat{}, err
+	}
+
+	return memoryStat(rawJson, limit)
+}
+
+func readUint(path string) (uint64, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := strconv.ParseUint(strings.TrimSpace(string(contents)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %s", filepath.Base(path), err)
+	}
+
+	return value, nil
+}
+
+// readKeyValues reads a cgroup file of lines of the form '<key> <value>'
+func readKeyValues(path string) (map[string]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("parse %s: unexpected line '%s'", filepath.Base(path), scanner.Text())
+		}
+
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %s", filepath.Base(path), err)
+		}
+
+		values[fields[0]] = value
+	}
+
+	return values, scanner.Err()
+}
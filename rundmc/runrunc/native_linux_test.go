@@ -0,0 +1,290 @@
+package runrunc_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/gardener"
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
+	fakes "code.cloudfoundry.org/guardian/rundmc/runrunc/runruncfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Native state and stats", func() {
+	var (
+		stateDir string
+		logger   *lagertest.TestLogger
+	)
+
+	writeState := func(state map[string]interface{}) {
+		Expect(os.MkdirAll(filepath.Join(stateDir, "some-handle"), 0700)).To(Succeed())
+		stateJson, err := json.Marshal(state)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(stateDir, "some-handle", "state.json"), stateJson, 0600)).To(Succeed())
+	}
+
+	writeFile := func(path, contents string) {
+		Expect(os.MkdirAll(filepath.Dir(path), 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		stateDir, err = ioutil.TempDir("", "runc-state")
+		Expect(err).NotTo(HaveOccurred())
+
+		logger = lagertest.NewTestLogger("test")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(stateDir)).To(Succeed())
+	})
+
+	Describe("NativeStater", func() {
+		var (
+			fallback *fakes.FakeStateGetter
+			stater   *runrunc.NativeStater
+
+			pid   int
+			start string
+		)
+
+		BeforeEach(func() {
+			fallback = new(fakes.FakeStateGetter)
+			fallback.StateReturns(runrunc.State{Pid: 99, Status: "from-runc"}, nil)
+			stater = runrunc.NewNativeStater(stateDir, fallback)
+
+			pid = os.Getpid()
+			stat, err := ioutil.ReadFile("/proc/self/stat")
+			Expect(err).NotTo(HaveOccurred())
+			fields := strings.Fields(string(stat)[strings.LastIndex(string(stat), ")")+1:])
+			start = fields[19]
+		})
+
+		Context("when the init process is running", func() {
+			BeforeEach(func() {
+				writeState(map[string]interface{}{
+					"init_process_pid":   pid,
+					"init_process_start": json.Number(start),
+				})
+			})
+
+			It("reports the container as running", func() {
+				state, err := stater.State(logger, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(state).To(Equal(runrunc.State{Pid: pid, Status: runrunc.RunningStatus}))
+				Expect(fallback.StateCallCount()).To(Equal(0))
+			})
+
+			Context("and the container has not been started", func() {
+				BeforeEach(func() {
+					writeFile(filepath.Join(stateDir, "some-handle", "exec.fifo"), "")
+				})
+
+				It("reports the container as created", func() {
+					state, err := stater.State(logger, "some-handle")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(state).To(Equal(runrunc.State{Pid: pid, Status: runrunc.CreatedStatus}))
+				})
+			})
+		})
+
+		Context("when the start time was recorded as a string by an older runc", func() {
+			BeforeEach(func() {
+				writeState(map[string]interface{}{
+					"init_process_pid":   pid,
+					"init_process_start": start,
+				})
+			})
+
+			It("reports the container as running", func() {
+				state, err := stater.State(logger, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(state.Status).To(Equal(runrunc.RunningStatus))
+			})
+		})
+
+		Context("when the init process's pid belongs to a process started at another time", func() {
+			BeforeEach(func() {
+				writeState(map[string]interface{}{
+					"init_process_pid":   pid,
+					"init_process_start": 1,
+				})
+			})
+
+			It("reports the container as stopped", func() {
+				state, err := stater.State(logger, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(state).To(Equal(runrunc.State{Status: runrunc.StoppedStatus}))
+			})
+		})
+
+		Context("when there is no init process", func() {
+			BeforeEach(func() {
+				writeState(map[string]interface{}{
+					"init_process_pid":   0,
+					"init_process_start": 0,
+				})
+			})
+
+			It("reports the container as stopped", func() {
+				state, err := stater.State(logger, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(state.Status).To(Equal(runrunc.StoppedStatus))
+			})
+		})
+
+		Context("when the state cannot be read", func() {
+			It("falls back to the runtime", func() {
+				state, err := stater.State(logger, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(state).To(Equal(runrunc.State{Pid: 99, Status: "from-runc"}))
+
+				Expect(fallback.StateCallCount()).To(Equal(1))
+				_, id := fallback.StateArgsForCall(0)
+				Expect(id).To(Equal("some-handle"))
+			})
+
+			It("returns any error from the runtime", func() {
+				fallback.StateReturns(runrunc.State{}, errors.New("no such container"))
+
+				_, err := stater.State(logger, "some-handle")
+				Expect(err).To(MatchError("no such container"))
+			})
+		})
+	})
+
+	Describe("NativeStatser", func() {
+		var (
+			fallback *fakes.FakeStatsGetter
+			statser  *runrunc.NativeStatser
+
+			cgroupDir string
+		)
+
+		BeforeEach(func() {
+			fallback = new(fakes.FakeStatsGetter)
+			fallback.StatsReturns(gardener.ActualContainerMetrics{CPU: garden.ContainerCPUStat{Usage: 99}}, nil)
+			statser = runrunc.NewNativeStatser(stateDir, fallback)
+
+			var err error
+			cgroupDir, err = ioutil.TempDir("", "cgroups")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(cgroupDir)).To(Succeed())
+		})
+
+		Context("when the container is in v1 cgroups", func() {
+			BeforeEach(func() {
+				cpuacctPath := filepath.Join(cgroupDir, "cpuacct", "some-handle")
+				memoryPath := filepath.Join(cgroupDir, "memory", "some-handle")
+				writeState(map[string]interface{}{
+					"cgroup_paths": map[string]string{
+						"cpuacct": cpuacctPath,
+						"memory":  memoryPath,
+					},
+				})
+
+				writeFile(filepath.Join(cpuacctPath, "cpuacct.usage"), "123\n")
+				writeFile(filepath.Join(cpuacctPath, "cpuacct.stat"), "user 2\nsystem 3\n")
+				writeFile(filepath.Join(memoryPath, "memory.stat"), "cache 4\nrss 6\ntotal_cache 5\ntotal_rss 10\ntotal_inactive_file 2\nhierarchical_memory_limit 100\n")
+			})
+
+			It("reads the cpu usage from the cpuacct cgroup", func() {
+				metrics, err := statser.Stats(logger, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(metrics.CPU).To(Equal(garden.ContainerCPUStat{
+					Usage:  123,
+					User:   20000000,
+					System: 30000000,
+				}))
+				Expect(fallback.StatsCallCount()).To(Equal(0))
+			})
+
+			It("reads the memory stats from the memory cgroup", func() {
+				metrics, err := statser.Stats(logger, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(metrics.Memory).To(Equal(garden.ContainerMemoryStat{
+					Cache:                   4,
+					Rss:                     6,
+					TotalCache:              5,
+					TotalRss:                10,
+					TotalInactiveFile:       2,
+					HierarchicalMemoryLimit: 100,
+					TotalUsageTowardLimit:   13,
+				}))
+			})
+
+			Context("and a cgroup file cannot be read", func() {
+				BeforeEach(func() {
+					Expect(os.Remove(filepath.Join(cgroupDir, "cpuacct", "some-handle", "cpuacct.usage"))).To(Succeed())
+				})
+
+				It("falls back to the runtime", func() {
+					metrics, err := statser.Stats(logger, "some-handle")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(metrics.CPU.Usage).To(BeEquivalentTo(99))
+				})
+			})
+		})
+
+		Context("when the container is in the unified cgroup hierarchy", func() {
+			BeforeEach(func() {
+				cgroupPath := filepath.Join(cgroupDir, "some-handle")
+				writeState(map[string]interface{}{
+					"cgroup_paths": map[string]string{"": cgroupPath},
+				})
+
+				writeFile(filepath.Join(cgroupPath, "cpu.stat"), "usage_usec 5\nuser_usec 3\nsystem_usec 2\n")
+				writeFile(filepath.Join(cgroupPath, "memory.max"), "4096\n")
+				writeFile(filepath.Join(cgroupPath, "memory.stat"), "anon 1\nfile 2\ninactive_file 1\n")
+			})
+
+			It("reads the cpu and memory stats from the container's cgroup", func() {
+				metrics, err := statser.Stats(logger, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(metrics.CPU).To(Equal(garden.ContainerCPUStat{
+					Usage:  5000,
+					User:   3000,
+					System: 2000,
+				}))
+				Expect(metrics.Memory.Rss).To(BeEquivalentTo(1))
+				Expect(metrics.Memory.TotalCache).To(BeEquivalentTo(2))
+				Expect(metrics.Memory.HierarchicalMemoryLimit).To(BeEquivalentTo(4096))
+				Expect(metrics.Memory.TotalUsageTowardLimit).To(BeEquivalentTo(2))
+			})
+
+			Context("and there is no memory limit", func() {
+				BeforeEach(func() {
+					writeFile(filepath.Join(cgroupDir, "some-handle", "memory.max"), "max\n")
+				})
+
+				It("reports the limit as runc does", func() {
+					metrics, err := statser.Stats(logger, "some-handle")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(metrics.Memory.HierarchicalMemoryLimit).To(BeEquivalentTo(uint64(math.MaxUint64)))
+				})
+			})
+		})
+
+		Context("when the state cannot be read", func() {
+			It("falls back to the runtime", func() {
+				metrics, err := statser.Stats(logger, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(metrics.CPU.Usage).To(BeEquivalentTo(99))
+
+				Expect(fallback.StatsCallCount()).To(Equal(1))
+			})
+		})
+	})
+})
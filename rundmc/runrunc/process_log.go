@@ -0,0 +1,104 @@
+package runrunc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	// ProcessLogEnv may be set to true in the environment of a process to
+	// capture its stdout and stderr in files in its process directory, from
+	// which they can be read whether or not a client was attached. The
+	// variables are not passed on to the process.
+	ProcessLogEnv = "GARDEN_PROCESS_LOG"
+
+	// ProcessLogMaxSizeEnv overrides the size in bytes at which each captured
+	// stream is rotated to a new file
+	ProcessLogMaxSizeEnv = "GARDEN_PROCESS_LOG_MAX_SIZE"
+
+	// ProcessLogMaxFilesEnv overrides how many files of each captured stream
+	// are kept, the oldest being removed on rotation
+	ProcessLogMaxFilesEnv = "GARDEN_PROCESS_LOG_MAX_FILES"
+
+	DefaultProcessLogMaxSize  = 10 * 1024 * 1024
+	DefaultProcessLogMaxFiles = 5
+
+	processLogConfigFile = "log.json"
+)
+
+// ProcessLogConfig is recorded in the directory of a process whose output is
+// captured, where dadoo, which copies the output, reads it
+type ProcessLogConfig struct {
+	MaxSize  int64 `json:"max_size"`
+	MaxFiles int   `json:"max_files"`
+}
+
+// ExtractProcessLogConfig returns the environment without the log capture
+// variables, and the configuration they specify, or nil if the output of the
+// process should not be captured
+func ExtractProcessLogConfig(env []string) ([]string, *ProcessLogConfig, error) {
+	remaining, values := extractEnv(env, ProcessLogEnv, ProcessLogMaxSizeEnv, ProcessLogMaxFilesEnv)
+
+	if values[ProcessLogEnv] == "" {
+		return remaining, nil, nil
+	}
+
+	capture, err := strconv.ParseBool(values[ProcessLogEnv])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %s", ProcessLogEnv, err)
+	}
+	if !capture {
+		return remaining, nil, nil
+	}
+
+	config := &ProcessLogConfig{
+		MaxSize:  DefaultProcessLogMaxSize,
+		MaxFiles: DefaultProcessLogMaxFiles,
+	}
+
+	if maxSize := values[ProcessLogMaxSizeEnv]; maxSize != "" {
+		if config.MaxSize, err = strconv.ParseInt(maxSize, 10, 64); err != nil || config.MaxSize <= 0 {
+			return nil, nil, fmt.Errorf("invalid %s: '%s' is not a positive number of bytes", ProcessLogMaxSizeEnv, maxSize)
+		}
+	}
+
+	if maxFiles := values[ProcessLogMaxFilesEnv]; maxFiles != "" {
+		if config.MaxFiles, err = strconv.Atoi(maxFiles); err != nil || config.MaxFiles <= 0 {
+			return nil, nil, fmt.Errorf("invalid %s: '%s' is not a positive number of files", ProcessLogMaxFilesEnv, maxFiles)
+		}
+	}
+
+	return remaining, config, nil
+}
+
+func WriteProcessLogConfig(processPath string, config ProcessLogConfig) error {
+	contents, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(processPath, processLogConfigFile), contents, 0600)
+}
+
+// ReadProcessLogConfig returns the log capture configuration recorded in the
+// process directory, or nil if the output of the process is not captured
+func ReadProcessLogConfig(processPath string) (*ProcessLogConfig, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(processPath, processLogConfigFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var config ProcessLogConfig
+	if err := json.Unmarshal(contents, &config); err != nil {
+		return nil, fmt.Errorf("parsing process log config: %s", err)
+	}
+
+	return &config, nil
+}
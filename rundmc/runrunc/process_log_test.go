@@ -0,0 +1,99 @@
+package runrunc_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ProcessLogConfig", func() {
+	Describe("ExtractProcessLogConfig", func() {
+		It("returns no config when capture is not requested", func() {
+			env, config, err := runrunc.ExtractProcessLogConfig([]string{"FOO=bar"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(Equal([]string{"FOO=bar"}))
+			Expect(config).To(BeNil())
+		})
+
+		It("returns the default config, removing it from the environment", func() {
+			env, config, err := runrunc.ExtractProcessLogConfig([]string{"FOO=bar", "GARDEN_PROCESS_LOG=true"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(Equal([]string{"FOO=bar"}))
+			Expect(config).To(Equal(&runrunc.ProcessLogConfig{
+				MaxSize:  runrunc.DefaultProcessLogMaxSize,
+				MaxFiles: runrunc.DefaultProcessLogMaxFiles,
+			}))
+		})
+
+		It("returns the max size and number of files when they are specified", func() {
+			env, config, err := runrunc.ExtractProcessLogConfig([]string{
+				"GARDEN_PROCESS_LOG=1",
+				"GARDEN_PROCESS_LOG_MAX_SIZE=1024",
+				"GARDEN_PROCESS_LOG_MAX_FILES=2",
+				"FOO=bar",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(Equal([]string{"FOO=bar"}))
+			Expect(config).To(Equal(&runrunc.ProcessLogConfig{MaxSize: 1024, MaxFiles: 2}))
+		})
+
+		It("returns no config, removing the variables, when capture is disabled", func() {
+			env, config, err := runrunc.ExtractProcessLogConfig([]string{
+				"GARDEN_PROCESS_LOG=false",
+				"GARDEN_PROCESS_LOG_MAX_SIZE=1024",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(BeEmpty())
+			Expect(config).To(BeNil())
+		})
+
+		It("returns an error when capture is not a boolean", func() {
+			_, _, err := runrunc.ExtractProcessLogConfig([]string{"GARDEN_PROCESS_LOG=please"})
+			Expect(err).To(MatchError(ContainSubstring("invalid GARDEN_PROCESS_LOG")))
+		})
+
+		It("returns an error when the max size is not positive", func() {
+			_, _, err := runrunc.ExtractProcessLogConfig([]string{
+				"GARDEN_PROCESS_LOG=true",
+				"GARDEN_PROCESS_LOG_MAX_SIZE=0",
+			})
+			Expect(err).To(MatchError("invalid GARDEN_PROCESS_LOG_MAX_SIZE: '0' is not a positive number of bytes"))
+		})
+
+		It("returns an error when the max number of files is not a number", func() {
+			_, _, err := runrunc.ExtractProcessLogConfig([]string{
+				"GARDEN_PROCESS_LOG=true",
+				"GARDEN_PROCESS_LOG_MAX_FILES=lots",
+			})
+			Expect(err).To(MatchError("invalid GARDEN_PROCESS_LOG_MAX_FILES: 'lots' is not a positive number of files"))
+		})
+	})
+
+	Describe("WriteProcessLogConfig and ReadProcessLogConfig", func() {
+		var processPath string
+
+		BeforeEach(func() {
+			var err error
+			processPath, err = ioutil.TempDir("", "process")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(processPath)).To(Succeed())
+		})
+
+		It("reads back the config written to the process directory", func() {
+			config := runrunc.ProcessLogConfig{MaxSize: 100, MaxFiles: 3}
+			Expect(runrunc.WriteProcessLogConfig(processPath, config)).To(Succeed())
+
+			Expect(runrunc.ReadProcessLogConfig(processPath)).To(Equal(&config))
+		})
+
+		It("reads no config when none was written", func() {
+			Expect(runrunc.ReadProcessLogConfig(processPath)).To(BeNil())
+		})
+	})
+})
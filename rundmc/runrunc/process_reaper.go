@@ -0,0 +1,93 @@
+package runrunc
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-golang/clock"
+)
+
+// ProcessReaper removes the directories of processes in the containers of a
+// depot once they have been exited for longer than the retention period, so
+// that clients have that long to attach and collect their exit status
+type ProcessReaper struct {
+	depotDir  string
+	retention time.Duration
+	clock     clock.Clock
+}
+
+func NewProcessReaper(depotDir string, retention time.Duration, clk clock.Clock) *ProcessReaper {
+	return &ProcessReaper{
+		depotDir:  depotDir,
+		retention: retention,
+		clock:     clk,
+	}
+}
+
+// Run reaps on the interval until stop is closed
+func (r *ProcessReaper) Run(log lager.Logger, interval time.Duration, stop <-chan struct{}) {
+	ticker := r.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			r.Reap(log)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Reap removes the directories of processes which exited longer ago than the
+// retention period. Processes which are still running are left alone.
+func (r *ProcessReaper) Reap(log lager.Logger) {
+	log = log.Session("reap-processes")
+
+	processPaths, err := filepath.Glob(filepath.Join(r.depotDir, "*", "processes", "*"))
+	if err != nil {
+		log.Error("listing-processes-failed", err)
+		return
+	}
+
+	for _, processPath := range processPaths {
+		exitTime, exited, err := processExitTime(processPath)
+		if err != nil {
+			log.Error("reading-exit-time-failed", err, lager.Data{"path": processPath})
+			continue
+		}
+
+		if !exited || r.clock.Now().Sub(exitTime) < r.retention {
+			continue
+		}
+
+		log.Debug("removing", lager.Data{"path": processPath, "exit-time": exitTime})
+		if err := os.RemoveAll(processPath); err != nil {
+			log.Error("removing-failed", err, lager.Data{"path": processPath})
+		}
+	}
+}
+
+// processExitTime returns when the process exited, from its recorded exit
+// status or, for processes run by an older dadoo, its exitcode file
+func processExitTime(processPath string) (time.Time, bool, error) {
+	status, err := ReadExitStatus(processPath)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if status != nil {
+		return status.Time, true, nil
+	}
+
+	exitcode, err := os.Stat(filepath.Join(processPath, "exitcode"))
+	if os.IsNotExist(err) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return exitcode.ModTime(), true, nil
+}
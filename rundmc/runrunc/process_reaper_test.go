@@ -0,0 +1,127 @@
+package runrunc_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+)
+
+var _ = Describe("ProcessReaper", func() {
+	var (
+		depotDir  string
+		fakeClock *fakeclock.FakeClock
+		logger    *lagertest.TestLogger
+
+		reaper *runrunc.ProcessReaper
+	)
+
+	processPath := func(handle, processID string) string {
+		return filepath.Join(depotDir, handle, "processes", processID)
+	}
+
+	createProcess := func(handle, processID string) string {
+		path := processPath(handle, processID)
+		Expect(os.MkdirAll(path, 0700)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		depotDir, err = ioutil.TempDir("", "depot")
+		Expect(err).NotTo(HaveOccurred())
+
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		logger = lagertest.NewTestLogger("test")
+
+		reaper = runrunc.NewProcessReaper(depotDir, time.Hour, fakeClock)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(depotDir)).To(Succeed())
+	})
+
+	It("removes processes which exited longer ago than the retention period", func() {
+		path := createProcess("some-handle", "old-process")
+		Expect(runrunc.WriteExitStatus(path, runrunc.ExitStatus{Time: fakeClock.Now().Add(-2 * time.Hour)})).To(Succeed())
+
+		reaper.Reap(logger)
+
+		Expect(path).NotTo(BeADirectory())
+	})
+
+	It("keeps processes which exited within the retention period", func() {
+		path := createProcess("some-handle", "recent-process")
+		Expect(runrunc.WriteExitStatus(path, runrunc.ExitStatus{Time: fakeClock.Now().Add(-time.Minute)})).To(Succeed())
+
+		reaper.Reap(logger)
+
+		Expect(path).To(BeADirectory())
+	})
+
+	It("keeps processes which are still running", func() {
+		path := createProcess("some-handle", "running-process")
+		Expect(ioutil.WriteFile(filepath.Join(path, "pidfile"), []byte("123"), 0600)).To(Succeed())
+
+		reaper.Reap(logger)
+
+		Expect(path).To(BeADirectory())
+	})
+
+	It("reaps processes in every container", func() {
+		path := createProcess("some-other-handle", "old-process")
+		Expect(runrunc.WriteExitStatus(path, runrunc.ExitStatus{Time: fakeClock.Now().Add(-2 * time.Hour)})).To(Succeed())
+
+		reaper.Reap(logger)
+
+		Expect(path).NotTo(BeADirectory())
+		Expect(filepath.Dir(path)).To(BeADirectory())
+	})
+
+	Context("when only an exitcode was recorded", func() {
+		var path string
+
+		BeforeEach(func() {
+			path = createProcess("some-handle", "old-dadoo-process")
+			exitcodePath := filepath.Join(path, "exitcode")
+			Expect(ioutil.WriteFile(exitcodePath, []byte("0"), 0600)).To(Succeed())
+
+			exitTime := fakeClock.Now().Add(-2 * time.Hour)
+			Expect(os.Chtimes(exitcodePath, exitTime, exitTime)).To(Succeed())
+		})
+
+		It("treats the modification time of the exitcode as the exit time", func() {
+			reaper.Reap(logger)
+
+			Expect(path).NotTo(BeADirectory())
+		})
+	})
+
+	Describe("Run", func() {
+		It("reaps on the interval until stopped", func() {
+			path := createProcess("some-handle", "old-process")
+			Expect(runrunc.WriteExitStatus(path, runrunc.ExitStatus{Time: fakeClock.Now().Add(-2 * time.Hour)})).To(Succeed())
+
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				reaper.Run(logger, time.Minute, stop)
+				close(done)
+			}()
+
+			Consistently(path).Should(BeADirectory())
+
+			fakeClock.WaitForWatcherAndIncrement(time.Minute)
+			Eventually(path).ShouldNot(BeADirectory())
+
+			close(stop)
+			Eventually(done).Should(BeClosed())
+		})
+	})
+})
@@ -0,0 +1,126 @@
+package runrunc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// ProcessTimeoutEnv may be set in the environment of a process to a
+	// duration after which the process is sent SIGTERM. If it has not exited
+	// after its grace period, it and its descendants are sent SIGKILL. The
+	// variable is not passed on to the process.
+	ProcessTimeoutEnv = "GARDEN_PROCESS_TIMEOUT"
+
+	// ProcessTimeoutGracePeriodEnv overrides the grace period of a process
+	// with a timeout
+	ProcessTimeoutGracePeriodEnv = "GARDEN_PROCESS_TIMEOUT_GRACE_PERIOD"
+
+	DefaultProcessTimeoutGracePeriod = 10 * time.Second
+
+	// TimedOutExitCode is the exit status of processes which were stopped
+	// because they timed out, as for timeout(1)
+	TimedOutExitCode = 124
+
+	processTimeoutFile = "timeout.json"
+)
+
+// ProcessTimeout is recorded in the directory of a process with a timeout,
+// where dadoo, which waits on the process, enforces it
+type ProcessTimeout struct {
+	Timeout     time.Duration `json:"timeout"`
+	GracePeriod time.Duration `json:"grace_period"`
+}
+
+// ExtractProcessTimeout returns the environment without the timeout
+// variables, and the timeout they specify, or nil if there is none
+func ExtractProcessTimeout(env []string) ([]string, *ProcessTimeout, error) {
+	remaining, values := extractEnv(env, ProcessTimeoutEnv, ProcessTimeoutGracePeriodEnv)
+	timeout, gracePeriod := values[ProcessTimeoutEnv], values[ProcessTimeoutGracePeriodEnv]
+
+	if timeout == "" {
+		return remaining, nil, nil
+	}
+
+	processTimeout := &ProcessTimeout{GracePeriod: DefaultProcessTimeoutGracePeriod}
+
+	var err error
+	if processTimeout.Timeout, err = parsePositiveDuration(timeout); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %s", ProcessTimeoutEnv, err)
+	}
+
+	if gracePeriod != "" {
+		if processTimeout.GracePeriod, err = parsePositiveDuration(gracePeriod); err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: %s", ProcessTimeoutGracePeriodEnv, err)
+		}
+	}
+
+	return remaining, processTimeout, nil
+}
+
+// extractEnv returns the environment without the named variables, and their
+// values
+func extractEnv(env []string, names ...string) ([]string, map[string]string) {
+	var remaining []string
+	values := map[string]string{}
+
+nextVariable:
+	for _, variable := range env {
+		for _, name := range names {
+			if strings.HasPrefix(variable, name+"=") {
+				values[name] = strings.TrimPrefix(variable, name+"=")
+				continue nextVariable
+			}
+		}
+
+		remaining = append(remaining, variable)
+	}
+
+	return remaining, values
+}
+
+func parsePositiveDuration(value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if duration <= 0 {
+		return 0, fmt.Errorf("'%s' is not positive", value)
+	}
+
+	return duration, nil
+}
+
+func WriteProcessTimeout(processPath string, timeout ProcessTimeout) error {
+	contents, err := json.Marshal(timeout)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(processPath, processTimeoutFile), contents, 0600)
+}
+
+// ReadProcessTimeout returns the timeout recorded in the process directory,
+// or nil if the process has none
+func ReadProcessTimeout(processPath string) (*ProcessTimeout, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(processPath, processTimeoutFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var timeout ProcessTimeout
+	if err := json.Unmarshal(contents, &timeout); err != nil {
+		return nil, fmt.Errorf("parsing process timeout: %s", err)
+	}
+
+	return &timeout, nil
+}
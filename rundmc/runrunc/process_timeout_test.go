@@ -0,0 +1,93 @@
+package runrunc_test
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ProcessTimeout", func() {
+	Describe("ExtractProcessTimeout", func() {
+		It("returns no timeout when none is specified", func() {
+			env, timeout, err := runrunc.ExtractProcessTimeout([]string{"FOO=bar"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(Equal([]string{"FOO=bar"}))
+			Expect(timeout).To(BeNil())
+		})
+
+		It("returns the timeout with the default grace period, removing it from the environment", func() {
+			env, timeout, err := runrunc.ExtractProcessTimeout([]string{"FOO=bar", "GARDEN_PROCESS_TIMEOUT=1m30s"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(Equal([]string{"FOO=bar"}))
+			Expect(timeout).To(Equal(&runrunc.ProcessTimeout{
+				Timeout:     90 * time.Second,
+				GracePeriod: runrunc.DefaultProcessTimeoutGracePeriod,
+			}))
+		})
+
+		It("returns the grace period when one is specified", func() {
+			env, timeout, err := runrunc.ExtractProcessTimeout([]string{
+				"GARDEN_PROCESS_TIMEOUT=10s",
+				"GARDEN_PROCESS_TIMEOUT_GRACE_PERIOD=2s",
+				"FOO=bar",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(Equal([]string{"FOO=bar"}))
+			Expect(timeout).To(Equal(&runrunc.ProcessTimeout{Timeout: 10 * time.Second, GracePeriod: 2 * time.Second}))
+		})
+
+		It("ignores a grace period without a timeout", func() {
+			env, timeout, err := runrunc.ExtractProcessTimeout([]string{"GARDEN_PROCESS_TIMEOUT_GRACE_PERIOD=2s"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(BeEmpty())
+			Expect(timeout).To(BeNil())
+		})
+
+		It("returns an error when the timeout is not a duration", func() {
+			_, _, err := runrunc.ExtractProcessTimeout([]string{"GARDEN_PROCESS_TIMEOUT=soon"})
+			Expect(err).To(MatchError(ContainSubstring("invalid GARDEN_PROCESS_TIMEOUT")))
+		})
+
+		It("returns an error when the timeout is not positive", func() {
+			_, _, err := runrunc.ExtractProcessTimeout([]string{"GARDEN_PROCESS_TIMEOUT=0s"})
+			Expect(err).To(MatchError("invalid GARDEN_PROCESS_TIMEOUT: '0s' is not positive"))
+		})
+
+		It("returns an error when the grace period is invalid", func() {
+			_, _, err := runrunc.ExtractProcessTimeout([]string{
+				"GARDEN_PROCESS_TIMEOUT=10s",
+				"GARDEN_PROCESS_TIMEOUT_GRACE_PERIOD=-1s",
+			})
+			Expect(err).To(MatchError("invalid GARDEN_PROCESS_TIMEOUT_GRACE_PERIOD: '-1s' is not positive"))
+		})
+	})
+
+	Describe("WriteProcessTimeout and ReadProcessTimeout", func() {
+		var processPath string
+
+		BeforeEach(func() {
+			var err error
+			processPath, err = ioutil.TempDir("", "process")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(processPath)).To(Succeed())
+		})
+
+		It("reads back the timeout written to the process directory", func() {
+			timeout := runrunc.ProcessTimeout{Timeout: time.Minute, GracePeriod: time.Second}
+			Expect(runrunc.WriteProcessTimeout(processPath, timeout)).To(Succeed())
+
+			Expect(runrunc.ReadProcessTimeout(processPath)).To(Equal(&timeout))
+		})
+
+		It("reads no timeout when none was written", func() {
+			Expect(runrunc.ReadProcessTimeout(processPath)).To(BeNil())
+		})
+	})
+})
@@ -17,8 +17,8 @@ type RunRunc struct {
 	*Execer
 	*Creator
 	*OomWatcher
-	*Statser
-	*Stater
+	StatsGetter
+	StateGetter
 	*Killer
 	*Deleter
 }
@@ -36,16 +36,23 @@ type RuncBinary interface {
 func New(
 	runner commandrunner.CommandRunner, runcCmdRunner RuncCmdRunner,
 	runc RuncBinary, dadooPath, runcPath, runcSubCmd string, runcSubcmdExtraArgs, runcExtraArgs []string, bundleLoader BundleLoader, processBuilder ProcessBuilder,
-	mkdirer Mkdirer, userLookuper UserLookupper, execRunner ExecRunner, uidGenerator UidGenerator,
+	mkdirer Mkdirer, userLookuper UserLookupper, execRunner ExecRunner, uidGenerator UidGenerator, stateDir string,
 ) *RunRunc {
+	var stater StateGetter = NewStater(runcCmdRunner, runc)
+	var statser StatsGetter = NewStatser(runcCmdRunner, runc)
+	if stateDir != "" {
+		stater = NewNativeStater(stateDir, stater)
+		statser = NewNativeStatser(stateDir, statser)
+	}
+
 	return &RunRunc{
 		Creator: NewCreator(runcPath, runcSubCmd, runcSubcmdExtraArgs, runcExtraArgs, runner),
 		Execer:  NewExecer(bundleLoader, processBuilder, mkdirer, userLookuper, execRunner, uidGenerator),
 
-		OomWatcher: NewOomWatcher(runner, runc),
-		Statser:    NewStatser(runcCmdRunner, runc),
-		Stater:     NewStater(runcCmdRunner, runc),
-		Killer:     NewKiller(runcCmdRunner, runc),
-		Deleter:    NewDeleter(runcCmdRunner, runc),
+		OomWatcher:  NewOomWatcher(runner, runc),
+		StatsGetter: statser,
+		StateGetter: stater,
+		Killer:      NewKiller(runcCmdRunner, runc),
+		Deleter:     NewDeleter(runcCmdRunner, runc),
 	}
 }
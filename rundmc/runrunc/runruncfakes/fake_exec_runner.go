@@ -50,6 +50,20 @@ type FakeExecRunner struct {
 		result1 garden.Process
 		result2 error
 	}
+	ProcessIDsStub        func(log lager.Logger, processesPath string) ([]string, error)
+	processIDsMutex       sync.RWMutex
+	processIDsArgsForCall []struct {
+		log           lager.Logger
+		processesPath string
+	}
+	processIDsReturns struct {
+		result1 []string
+		result2 error
+	}
+	processIDsReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -169,6 +183,58 @@ func (fake *FakeExecRunner) AttachReturnsOnCall(i int, result1 garden.Process, r
 	}{result1, result2}
 }
 
+func (fake *FakeExecRunner) ProcessIDs(log lager.Logger, processesPath string) ([]string, error) {
+	fake.processIDsMutex.Lock()
+	ret, specificReturn := fake.processIDsReturnsOnCall[len(fake.processIDsArgsForCall)]
+	fake.processIDsArgsForCall = append(fake.processIDsArgsForCall, struct {
+		log           lager.Logger
+		processesPath string
+	}{log, processesPath})
+	fake.recordInvocation("ProcessIDs", []interface{}{log, processesPath})
+	fake.processIDsMutex.Unlock()
+	if fake.ProcessIDsStub != nil {
+		return fake.ProcessIDsStub(log, processesPath)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.processIDsReturns.result1, fake.processIDsReturns.result2
+}
+
+func (fake *FakeExecRunner) ProcessIDsCallCount() int {
+	fake.processIDsMutex.RLock()
+	defer fake.processIDsMutex.RUnlock()
+	return len(fake.processIDsArgsForCall)
+}
+
+func (fake *FakeExecRunner) ProcessIDsArgsForCall(i int) (lager.Logger, string) {
+	fake.processIDsMutex.RLock()
+	defer fake.processIDsMutex.RUnlock()
+	return fake.processIDsArgsForCall[i].log, fake.processIDsArgsForCall[i].processesPath
+}
+
+func (fake *FakeExecRunner) ProcessIDsReturns(result1 []string, result2 error) {
+	fake.ProcessIDsStub = nil
+	fake.processIDsReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeExecRunner) ProcessIDsReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.ProcessIDsStub = nil
+	if fake.processIDsReturnsOnCall == nil {
+		fake.processIDsReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.processIDsReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeExecRunner) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -176,6 +242,8 @@ func (fake *FakeExecRunner) Invocations() map[string][][]interface{} {
 	defer fake.runMutex.RUnlock()
 	fake.attachMutex.RLock()
 	defer fake.attachMutex.RUnlock()
+	fake.processIDsMutex.RLock()
+	defer fake.processIDsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -26,7 +26,10 @@ type runcStats struct {
 			} `json:"usage"`
 		} `json:"cpu"`
 		MemoryStats struct {
-			Stats garden.ContainerMemoryStat `json:"raw"`
+			Raw   json.RawMessage `json:"raw"`
+			Usage struct {
+				Limit uint64 `json:"limit"`
+			} `json:"usage"`
 		} `json:"memory"`
 	}
 }
@@ -58,16 +61,67 @@ func (r *Statser) Stats(log lager.Logger, id string) (gardener.ActualContainerMe
 		return gardener.ActualContainerMetrics{}, fmt.Errorf("decode stats: %s", err)
 	}
 
-	stats := gardener.ActualContainerMetrics{
-		Memory: data.Data.MemoryStats.Stats,
-		CPU: garden.ContainerCPUStat{
-			Usage:  data.Data.CPUStats.CPUUsage.Usage,
-			System: data.Data.CPUStats.CPUUsage.System,
-			User:   data.Data.CPUStats.CPUUsage.User,
-		},
+	memory, err := memoryStat(data.Data.MemoryStats.Raw, data.Data.MemoryStats.Usage.Limit)
+	if err != nil {
+		return gardener.ActualContainerMetrics{}, fmt.Errorf("decode stats: %s", err)
 	}
 
-	stats.Memory.TotalUsageTowardLimit = stats.Memory.TotalRss + (stats.Memory.TotalCache - stats.Memory.TotalInactiveFile)
+	return newMetrics(garden.ContainerCPUStat{
+		Usage:  data.Data.CPUStats.CPUUsage.Usage,
+		System: data.Data.CPUStats.CPUUsage.System,
+		User:   data.Data.CPUStats.CPUUsage.User,
+	}, memory), nil
+}
+
+func newMetrics(cpu garden.ContainerCPUStat, memory garden.ContainerMemoryStat) gardener.ActualContainerMetrics {
+	memory.TotalUsageTowardLimit = memory.TotalRss + (memory.TotalCache - memory.TotalInactiveFile)
+
+	return gardener.ActualContainerMetrics{
+		Memory: memory,
+		CPU:    cpu,
+	}
+}
+
+// memoryStat reads the raw memory.stat of the container's memory cgroup. On
+// the unified hierarchy the keys differ from v1, and are always hierarchical,
+// so they're mapped onto both the local and total v1 stats.
+func memoryStat(raw json.RawMessage, limit uint64) (garden.ContainerMemoryStat, error) {
+	var stat garden.ContainerMemoryStat
+	if len(raw) == 0 {
+		return stat, nil
+	}
+
+	var keys map[string]uint64
+	if err := json.Unmarshal(raw, &keys); err != nil {
+		return stat, err
+	}
+
+	if _, unified := keys["anon"]; !unified {
+		err := json.Unmarshal(raw, &stat)
+		return stat, err
+	}
 
-	return stats, nil
+	return garden.ContainerMemoryStat{
+		ActiveAnon:              keys["active_anon"],
+		ActiveFile:              keys["active_file"],
+		Cache:                   keys["file"],
+		HierarchicalMemoryLimit: limit,
+		InactiveAnon:            keys["inactive_anon"],
+		InactiveFile:            keys["inactive_file"],
+		MappedFile:              keys["file_mapped"],
+		Pgfault:                 keys["pgfault"],
+		Pgmajfault:              keys["pgmajfault"],
+		Rss:                     keys["anon"],
+		TotalActiveAnon:         keys["active_anon"],
+		TotalActiveFile:         keys["active_file"],
+		TotalCache:              keys["file"],
+		TotalInactiveAnon:       keys["inactive_anon"],
+		TotalInactiveFile:       keys["inactive_file"],
+		TotalMappedFile:         keys["file_mapped"],
+		TotalPgfault:            keys["pgfault"],
+		TotalPgmajfault:         keys["pgmajfault"],
+		TotalRss:                keys["anon"],
+		TotalUnevictable:        keys["unevictable"],
+		Unevictable:             keys["unevictable"],
+	}, nil
 }
@@ -160,6 +160,70 @@ var _ = Describe("Stats", func() {
 
 	})
 
+	Context("when runC reports stats from the unified cgroup hierarchy", func() {
+		BeforeEach(func() {
+			commandRunner.WhenRunning(fake_command_runner.CommandSpec{
+				Path: "funC-stats",
+			}, func(cmd *exec.Cmd) error {
+				cmd.Stdout.Write([]byte(`{
+					"type": "stats",
+					"data": {
+						"memory": {
+							"usage": {
+								"usage": 100,
+								"limit": 4096
+							},
+							"raw": {
+								"anon": 1,
+								"file": 2,
+								"file_mapped": 3,
+								"active_anon": 4,
+								"inactive_anon": 5,
+								"active_file": 6,
+								"inactive_file": 1,
+								"unevictable": 7,
+								"pgfault": 8,
+								"pgmajfault": 9
+							}
+						}
+					}
+				}`))
+
+				return nil
+			})
+		})
+
+		It("maps the memory stats onto their v1 equivalents", func() {
+			stats, err := statser.Stats(logger, "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stats.Memory).To(Equal(garden.ContainerMemoryStat{
+				ActiveAnon:              4,
+				ActiveFile:              6,
+				Cache:                   2,
+				HierarchicalMemoryLimit: 4096,
+				InactiveAnon:            5,
+				InactiveFile:            1,
+				MappedFile:              3,
+				Pgfault:                 8,
+				Pgmajfault:              9,
+				Rss:                     1,
+				TotalActiveAnon:         4,
+				TotalActiveFile:         6,
+				TotalCache:              2,
+				TotalInactiveAnon:       5,
+				TotalInactiveFile:       1,
+				TotalMappedFile:         3,
+				TotalPgfault:            8,
+				TotalPgmajfault:         9,
+				TotalRss:                1,
+				TotalUnevictable:        7,
+				Unevictable:             7,
+				TotalUsageTowardLimit:   2,
+			}))
+		})
+	})
+
 	Context("when runC reports invalid JSON", func() {
 		BeforeEach(func() {
 			commandRunner.WhenRunning(fake_command_runner.CommandSpec{
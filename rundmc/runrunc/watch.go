@@ -9,6 +9,10 @@ import (
 	"code.cloudfoundry.org/lager"
 )
 
+// OutOfMemoryEvent is the event notified when runc reports an OOM in a
+// container
+const OutOfMemoryEvent = "Out of memory"
+
 //go:generate counterfeiter . EventsNotifier
 type EventsNotifier interface {
 	OnEvent(handle string, event string) error
@@ -71,7 +75,7 @@ func (r *OomWatcher) WatchEvents(log lager.Logger, handle string, eventsNotifier
 			"type": event.Type,
 		})
 		if event.Type == "oom" {
-			err := eventsNotifier.OnEvent(handle, "Out of memory")
+			err := eventsNotifier.OnEvent(handle, OutOfMemoryEvent)
 			if err != nil {
 				log.Debug("failed-to-notify-oom-event", lager.Data{"event": event.Data})
 			}
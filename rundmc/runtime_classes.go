@@ -0,0 +1,195 @@
+package rundmc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/gardener"
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
+	"code.cloudfoundry.org/lager"
+)
+
+// DefaultRuntimeClass is the name of the runtime class used by containers
+// which do not select one
+const DefaultRuntimeClass = "default"
+
+// RuntimeClassSpec defines a named OCI runtime which containers may select.
+// ExtraArgs are passed to the runtime when creating a container. StateDir is
+// the runtime's state directory; if it holds state in runc's format, the state
+// and stats of the class's containers are read from it rather than by forking
+// the runtime.
+type RuntimeClassSpec struct {
+	Name      string   `json:"name"`
+	Path      string   `json:"path"`
+	ExtraArgs []string `json:"extra_args,omitempty"`
+	StateDir  string   `json:"state_dir,omitempty"`
+}
+
+type UnknownRuntimeClassError struct {
+	Name string
+}
+
+func (e UnknownRuntimeClassError) Error() string {
+	return fmt.Sprintf("unknown runtime class: %s", e.Name)
+}
+
+func LoadRuntimeClasses(path string) ([]RuntimeClassSpec, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening runtime classes config: %s", err)
+	}
+	defer file.Close()
+
+	var specs []RuntimeClassSpec
+	if err := json.NewDecoder(file).Decode(&specs); err != nil {
+		return nil, fmt.Errorf("parsing runtime classes config: %s", err)
+	}
+
+	seen := map[string]bool{DefaultRuntimeClass: true}
+	for _, spec := range specs {
+		if spec.Name == "" || seen[spec.Name] {
+			return nil, fmt.Errorf("invalid runtime class name: '%s'", spec.Name)
+		}
+
+		if spec.Path == "" {
+			return nil, fmt.Errorf("runtime class '%s' has no path", spec.Name)
+		}
+
+		seen[spec.Name] = true
+	}
+
+	return specs, nil
+}
+
+// RuntimeClasses routes each call to the runtime of the class recorded in the
+// container's bundle, so that a container is always managed by the runtime
+// which created it. Bundles without a class use the default runtime.
+type RuntimeClasses struct {
+	depot    Depot
+	loader   BundleLoader
+	runtimes map[string]OCIRuntime
+}
+
+func NewRuntimeClasses(depot Depot, loader BundleLoader, defaultRuntime OCIRuntime, runtimes map[string]OCIRuntime) *RuntimeClasses {
+	classes := map[string]OCIRuntime{DefaultRuntimeClass: defaultRuntime}
+	for name, runtime := range runtimes {
+		classes[name] = runtime
+	}
+
+	return &RuntimeClasses{
+		depot:    depot,
+		loader:   loader,
+		runtimes: classes,
+	}
+}
+
+func (r *RuntimeClasses) Create(log lager.Logger, bundlePath, id string, io garden.ProcessIO) error {
+	runtime, err := r.runtimeFor(bundlePath)
+	if err != nil {
+		return err
+	}
+
+	return runtime.Create(log, bundlePath, id, io)
+}
+
+func (r *RuntimeClasses) Exec(log lager.Logger, bundlePath, id string, spec garden.ProcessSpec, io garden.ProcessIO) (garden.Process, error) {
+	runtime, err := r.runtimeFor(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return runtime.Exec(log, bundlePath, id, spec, io)
+}
+
+func (r *RuntimeClasses) Attach(log lager.Logger, bundlePath, id, processId string, io garden.ProcessIO) (garden.Process, error) {
+	runtime, err := r.runtimeFor(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return runtime.Attach(log, bundlePath, id, processId, io)
+}
+
+func (r *RuntimeClasses) ProcessIDs(log lager.Logger, bundlePath, id string) ([]string, error) {
+	runtime, err := r.runtimeFor(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return runtime.ProcessIDs(log, bundlePath, id)
+}
+
+func (r *RuntimeClasses) Kill(log lager.Logger, id string) error {
+	runtime, err := r.runtimeForContainer(log, id)
+	if err != nil {
+		return err
+	}
+
+	return runtime.Kill(log, id)
+}
+
+func (r *RuntimeClasses) Delete(log lager.Logger, force bool, id string) error {
+	runtime, err := r.runtimeForContainer(log, id)
+	if err != nil {
+		return err
+	}
+
+	return runtime.Delete(log, force, id)
+}
+
+func (r *RuntimeClasses) State(log lager.Logger, id string) (runrunc.State, error) {
+	runtime, err := r.runtimeForContainer(log, id)
+	if err != nil {
+		return runrunc.State{}, err
+	}
+
+	return runtime.State(log, id)
+}
+
+func (r *RuntimeClasses) Stats(log lager.Logger, id string) (gardener.ActualContainerMetrics, error) {
+	runtime, err := r.runtimeForContainer(log, id)
+	if err != nil {
+		return gardener.ActualContainerMetrics{}, err
+	}
+
+	return runtime.Stats(log, id)
+}
+
+func (r *RuntimeClasses) WatchEvents(log lager.Logger, id string, eventsNotifier runrunc.EventsNotifier) error {
+	runtime, err := r.runtimeForContainer(log, id)
+	if err != nil {
+		return err
+	}
+
+	return runtime.WatchEvents(log, id, eventsNotifier)
+}
+
+func (r *RuntimeClasses) runtimeForContainer(log lager.Logger, id string) (OCIRuntime, error) {
+	bundlePath, err := r.depot.Lookup(log, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.runtimeFor(bundlePath)
+}
+
+func (r *RuntimeClasses) runtimeFor(bundlePath string) (OCIRuntime, error) {
+	bundle, err := r.loader.Load(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	name := bundle.RuntimeClass()
+	if name == "" {
+		name = DefaultRuntimeClass
+	}
+
+	runtime, ok := r.runtimes[name]
+	if !ok {
+		return nil, UnknownRuntimeClassError{Name: name}
+	}
+
+	return runtime, nil
+}
@@ -0,0 +1,267 @@
+package rundmc_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/guardian/rundmc"
+	"code.cloudfoundry.org/guardian/rundmc/goci"
+	fakes "code.cloudfoundry.org/guardian/rundmc/rundmcfakes"
+	"code.cloudfoundry.org/guardian/rundmc/runrunc"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RuntimeClasses", func() {
+	var (
+		fakeDepot        *fakes.FakeDepot
+		fakeBundleLoader *fakes.FakeBundleLoader
+		defaultRuntime   *fakes.FakeOCIRuntime
+		sandboxed        *fakes.FakeOCIRuntime
+
+		bundles map[string]goci.Bndl
+
+		logger  lager.Logger
+		classes *rundmc.RuntimeClasses
+	)
+
+	BeforeEach(func() {
+		fakeDepot = new(fakes.FakeDepot)
+		fakeBundleLoader = new(fakes.FakeBundleLoader)
+		defaultRuntime = new(fakes.FakeOCIRuntime)
+		sandboxed = new(fakes.FakeOCIRuntime)
+		logger = lagertest.NewTestLogger("test")
+
+		bundles = map[string]goci.Bndl{
+			"/path/to/trusted":   goci.Bundle(),
+			"/path/to/untrusted": goci.Bundle().WithRuntimeClass("sandboxed"),
+			"/path/to/unknown":   goci.Bundle().WithRuntimeClass("banana"),
+		}
+
+		fakeDepot.LookupStub = func(_ lager.Logger, handle string) (string, error) {
+			return "/path/to/" + handle, nil
+		}
+
+		fakeBundleLoader.LoadStub = func(bundlePath string) (goci.Bndl, error) {
+			bundle, ok := bundles[bundlePath]
+			if !ok {
+				return goci.Bndl{}, errors.New("no such bundle")
+			}
+
+			return bundle, nil
+		}
+
+		classes = rundmc.NewRuntimeClasses(fakeDepot, fakeBundleLoader, defaultRuntime, map[string]rundmc.OCIRuntime{
+			"sandboxed": sandboxed,
+		})
+	})
+
+	Describe("Create", func() {
+		It("creates the container with the runtime of the bundle's class", func() {
+			Expect(classes.Create(logger, "/path/to/untrusted", "untrusted", garden.ProcessIO{})).To(Succeed())
+
+			Expect(sandboxed.CreateCallCount()).To(Equal(1))
+			_, bundlePath, id, _ := sandboxed.CreateArgsForCall(0)
+			Expect(bundlePath).To(Equal("/path/to/untrusted"))
+			Expect(id).To(Equal("untrusted"))
+			Expect(defaultRuntime.CreateCallCount()).To(Equal(0))
+		})
+
+		It("creates the container with the default runtime when the bundle has no class", func() {
+			Expect(classes.Create(logger, "/path/to/trusted", "trusted", garden.ProcessIO{})).To(Succeed())
+
+			Expect(defaultRuntime.CreateCallCount()).To(Equal(1))
+			Expect(sandboxed.CreateCallCount()).To(Equal(0))
+		})
+
+		Context("when the bundle's class is not defined", func() {
+			It("returns an error without creating the container", func() {
+				err := classes.Create(logger, "/path/to/unknown", "unknown", garden.ProcessIO{})
+				Expect(err).To(MatchError(rundmc.UnknownRuntimeClassError{Name: "banana"}))
+
+				Expect(defaultRuntime.CreateCallCount()).To(Equal(0))
+				Expect(sandboxed.CreateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the bundle cannot be loaded", func() {
+			It("returns the error", func() {
+				err := classes.Create(logger, "/path/to/nowhere", "nowhere", garden.ProcessIO{})
+				Expect(err).To(MatchError("no such bundle"))
+			})
+		})
+	})
+
+	Describe("Exec", func() {
+		It("runs the process with the runtime of the bundle's class", func() {
+			_, err := classes.Exec(logger, "/path/to/untrusted", "untrusted", garden.ProcessSpec{}, garden.ProcessIO{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sandboxed.ExecCallCount()).To(Equal(1))
+			Expect(defaultRuntime.ExecCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("Attach", func() {
+		It("attaches with the runtime of the bundle's class", func() {
+			_, err := classes.Attach(logger, "/path/to/untrusted", "untrusted", "some-process", garden.ProcessIO{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sandboxed.AttachCallCount()).To(Equal(1))
+			Expect(defaultRuntime.AttachCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("ProcessIDs", func() {
+		It("lists the processes with the runtime of the bundle's class", func() {
+			_, err := classes.ProcessIDs(logger, "/path/to/untrusted", "untrusted")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sandboxed.ProcessIDsCallCount()).To(Equal(1))
+			Expect(defaultRuntime.ProcessIDsCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("State", func() {
+		It("gets the state from the runtime which created the container", func() {
+			sandboxed.StateReturns(runrunc.State{Pid: 42}, nil)
+
+			state, err := classes.State(logger, "untrusted")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(state.Pid).To(Equal(42))
+
+			_, id := sandboxed.StateArgsForCall(0)
+			Expect(id).To(Equal("untrusted"))
+		})
+
+		Context("when looking up the bundle fails", func() {
+			It("returns the error", func() {
+				fakeDepot.LookupReturns("", errors.New("no bundle here"))
+
+				_, err := classes.State(logger, "untrusted")
+				Expect(err).To(MatchError("no bundle here"))
+			})
+		})
+	})
+
+	Describe("Kill", func() {
+		It("kills the container with the runtime which created it", func() {
+			Expect(classes.Kill(logger, "untrusted")).To(Succeed())
+
+			Expect(sandboxed.KillCallCount()).To(Equal(1))
+			Expect(defaultRuntime.KillCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("Delete", func() {
+		It("deletes the container with the runtime which created it", func() {
+			Expect(classes.Delete(logger, true, "trusted")).To(Succeed())
+
+			Expect(defaultRuntime.DeleteCallCount()).To(Equal(1))
+			_, force, id := defaultRuntime.DeleteArgsForCall(0)
+			Expect(force).To(BeTrue())
+			Expect(id).To(Equal("trusted"))
+			Expect(sandboxed.DeleteCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("Stats", func() {
+		It("gets the stats from the runtime which created the container", func() {
+			_, err := classes.Stats(logger, "untrusted")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sandboxed.StatsCallCount()).To(Equal(1))
+			Expect(defaultRuntime.StatsCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("WatchEvents", func() {
+		It("watches the events of the runtime which created the container", func() {
+			Expect(classes.WatchEvents(logger, "untrusted", nil)).To(Succeed())
+
+			Expect(sandboxed.WatchEventsCallCount()).To(Equal(1))
+			Expect(defaultRuntime.WatchEventsCallCount()).To(Equal(0))
+		})
+	})
+})
+
+var _ = Describe("LoadRuntimeClasses", func() {
+	var configPath string
+
+	BeforeEach(func() {
+		dir, err := ioutil.TempDir("", "runtime-classes")
+		Expect(err).NotTo(HaveOccurred())
+		configPath = filepath.Join(dir, "classes.json")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(filepath.Dir(configPath))).To(Succeed())
+	})
+
+	writeConfig := func(config string) {
+		Expect(ioutil.WriteFile(configPath, []byte(config), 0600)).To(Succeed())
+	}
+
+	It("loads the runtime classes", func() {
+		writeConfig(`[
+			{"name": "crun", "path": "/usr/bin/crun"},
+			{"name": "sandboxed", "path": "/usr/bin/runsc", "extra_args": ["--network=host"], "state_dir": "/run/runsc"}
+		]`)
+
+		classes, err := rundmc.LoadRuntimeClasses(configPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(classes).To(Equal([]rundmc.RuntimeClassSpec{
+			{Name: "crun", Path: "/usr/bin/crun"},
+			{Name: "sandboxed", Path: "/usr/bin/runsc", ExtraArgs: []string{"--network=host"}, StateDir: "/run/runsc"},
+		}))
+	})
+
+	It("rejects a class without a path", func() {
+		writeConfig(`[{"name": "crun"}]`)
+
+		_, err := rundmc.LoadRuntimeClasses(configPath)
+		Expect(err).To(MatchError("runtime class 'crun' has no path"))
+	})
+
+	It("rejects a class without a name", func() {
+		writeConfig(`[{"path": "/usr/bin/crun"}]`)
+
+		_, err := rundmc.LoadRuntimeClasses(configPath)
+		Expect(err).To(MatchError("invalid runtime class name: ''"))
+	})
+
+	It("rejects classes defined more than once", func() {
+		writeConfig(`[{"name": "crun", "path": "/usr/bin/crun"}, {"name": "crun", "path": "/usr/local/bin/crun"}]`)
+
+		_, err := rundmc.LoadRuntimeClasses(configPath)
+		Expect(err).To(MatchError("invalid runtime class name: 'crun'"))
+	})
+
+	It("rejects a class named after the default class", func() {
+		writeConfig(`[{"name": "default", "path": "/usr/bin/crun"}]`)
+
+		_, err := rundmc.LoadRuntimeClasses(configPath)
+		Expect(err).To(MatchError("invalid runtime class name: 'default'"))
+	})
+
+	Context("when the config cannot be parsed", func() {
+		It("returns an error", func() {
+			writeConfig(`{banana`)
+
+			_, err := rundmc.LoadRuntimeClasses(configPath)
+			Expect(err).To(MatchError(ContainSubstring("parsing runtime classes config")))
+		})
+	})
+
+	Context("when the config does not exist", func() {
+		It("returns an error", func() {
+			_, err := rundmc.LoadRuntimeClasses("/does/not/exist")
+			Expect(err).To(MatchError(ContainSubstring("opening runtime classes config")))
+		})
+	})
+})
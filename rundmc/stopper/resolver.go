@@ -11,17 +11,20 @@ type state struct {
 }
 
 type resolver struct {
-	stateStore string
+	stateStores []string
 }
 
-func NewRuncStateCgroupPathResolver(stateStorePath string) *resolver {
+// NewRuncStateCgroupPathResolver resolves the cgroups of containers from the
+// state kept by their runtime in the first of the given state stores to hold
+// the container
+func NewRuncStateCgroupPathResolver(stateStorePaths ...string) *resolver {
 	return &resolver{
-		stateStore: stateStorePath,
+		stateStores: stateStorePaths,
 	}
 }
 
 func (r resolver) Resolve(name, subsystem string) (string, error) {
-	stateJson, err := os.Open(filepath.Join(r.stateStore, name, "state.json"))
+	stateJson, err := r.openState(name)
 	if err != nil {
 		return "", err
 	}
@@ -32,5 +35,24 @@ func (r resolver) Resolve(name, subsystem string) (string, error) {
 		return "", err
 	}
 
-	return s.CgroupPaths["devices"], nil
+	if path, ok := s.CgroupPaths[subsystem]; ok {
+		return path, nil
+	}
+
+	// on the unified hierarchy, runc records the container's single cgroup
+	// without a subsystem
+	return s.CgroupPaths[""], nil
+}
+
+func (r resolver) openState(name string) (*os.File, error) {
+	err := os.ErrNotExist
+	for _, stateStore := range r.stateStores {
+		var stateJson *os.File
+		stateJson, err = os.Open(filepath.Join(stateStore, name, "state.json"))
+		if err == nil {
+			return stateJson, nil
+		}
+	}
+
+	return nil, err
 }
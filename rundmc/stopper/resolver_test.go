@@ -55,6 +55,25 @@ var _ = Describe("Resolver", func() {
 		})
 	})
 
+	Context("when the container is on the unified cgroup hierarchy", func() {
+		BeforeEach(func() {
+			stateJson, err := os.Create(filepath.Join(fakeStateDir, "some-handle", "state.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(stateJson).Encode(map[string]interface{}{
+				"cgroup_paths": map[string]string{
+					"": "i-am-the-unified-cgroup-path",
+				},
+			})).To(Succeed())
+			Expect(stateJson.Close()).To(Succeed())
+		})
+
+		It("resolves the container's single cgroup", func() {
+			path, err := stopper.NewRuncStateCgroupPathResolver(fakeStateDir).Resolve("some-handle", "devices")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(Equal("i-am-the-unified-cgroup-path"))
+		})
+	})
+
 	Context("with invalid state.json", func() {
 		BeforeEach(func() {
 			stateJson, err := os.Create(filepath.Join(fakeStateDir, "some-handle", "state.json"))
@@ -70,6 +89,36 @@ var _ = Describe("Resolver", func() {
 		})
 	})
 
+	Context("when the container is in another of the state stores", func() {
+		var otherStateDir string
+
+		BeforeEach(func() {
+			var err error
+			otherStateDir, err = ioutil.TempDir("", "otherfakestate")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.MkdirAll(filepath.Join(otherStateDir, "other-handle"), 0700)).To(Succeed())
+			stateJson, err := os.Create(filepath.Join(otherStateDir, "other-handle", "state.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.NewEncoder(stateJson).Encode(map[string]interface{}{
+				"cgroup_paths": map[string]string{
+					"devices": "i-am-the-other-devices-cgroup-path",
+				},
+			})).To(Succeed())
+			Expect(stateJson.Close()).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(otherStateDir)).To(Succeed())
+		})
+
+		It("resolves the cgroup from that store", func() {
+			path, err := stopper.NewRuncStateCgroupPathResolver(fakeStateDir, otherStateDir).Resolve("other-handle", "devices")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(Equal("i-am-the-other-devices-cgroup-path"))
+		})
+	})
+
 	It("returns an error if the state.json doesn't exist", func() {
 		_, err := stopper.NewRuncStateCgroupPathResolver(fakeStateDir).Resolve("some-handle", "devices")
 		Expect(err).To(MatchError(ContainSubstring(notFoundRuntimeError[runtime.GOOS])))
@@ -1,6 +1,19 @@
 package stopper
 
-import "syscall"
+import (
+	"syscall"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+)
+
+// DefaultGracePeriod is how long processes are given to exit after being
+// signalled, when no grace period is specified, before they are killed
+const DefaultGracePeriod = 10 * time.Second
+
+// pollInterval is how often the cgroup is checked for processes which have
+// not yet exited during the grace period
+const pollInterval = 100 * time.Millisecond
 
 //go:generate counterfeiter . Killer
 //go:generate counterfeiter . CgroupPathResolver
@@ -22,16 +35,22 @@ type CgroupStopper struct {
 	killer             Killer
 	retrier            Retrier
 	cgroupPathResolver CgroupPathResolver
+	clock              clock.Clock
 }
 
-func New(cgroupPathResolver CgroupPathResolver, killer Killer, retrier Retrier) *CgroupStopper {
+func New(cgroupPathResolver CgroupPathResolver, killer Killer, retrier Retrier, clk clock.Clock) *CgroupStopper {
 	if killer == nil {
 		killer = DefaultKiller{}
 	}
 
+	if clk == nil {
+		clk = clock.NewClock()
+	}
+
 	return &CgroupStopper{
 		killer:             killer,
 		cgroupPathResolver: cgroupPathResolver,
 		retrier:            retrier,
+		clock:              clk,
 	}
 }
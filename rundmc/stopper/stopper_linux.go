@@ -2,15 +2,29 @@ package stopper
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"syscall"
+	"time"
 
 	"code.cloudfoundry.org/lager"
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 )
 
-func (stopper *CgroupStopper) StopAll(log lager.Logger, cgroupName string, exceptions []int, kill bool) error {
+// StopAll sends the signal to every process in the cgroup other than the
+// exceptions, and waits up to the grace period for them to exit before sending
+// SIGKILL to any which remain. It returns the pids of the processes which had
+// to be killed. When the signal is SIGKILL there is no grace period.
+func (stopper *CgroupStopper) StopAll(log lager.Logger, cgroupName string, exceptions []int, signal syscall.Signal, gracePeriod time.Duration) ([]int, error) {
+	if gracePeriod == 0 {
+		gracePeriod = DefaultGracePeriod
+	}
+
 	log = log.Session("stop-all", lager.Data{
-		"name": cgroupName,
+		"name":         cgroupName,
+		"signal":       signal.String(),
+		"grace-period": gracePeriod.String(),
 	})
 
 	log.Debug("start")
@@ -18,45 +32,116 @@ func (stopper *CgroupStopper) StopAll(log lager.Logger, cgroupName string, excep
 
 	devicesSubsystemPath, err := stopper.cgroupPathResolver.Resolve(cgroupName, "devices")
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if signal != syscall.SIGKILL && stopper.signalAndWait(signal, devicesSubsystemPath, exceptions, gracePeriod) {
+		return nil, nil
+	}
+
+	killed, err := remainingPids(devicesSubsystemPath, exceptions)
+	if err != nil {
+		log.Error("listing-remaining-processes-failed", err)
 	}
 
-	if !kill {
-		stopper.retrier.Run(func() error {
-			return stopper.killAllRemaining(syscall.SIGTERM, devicesSubsystemPath, exceptions)
-		})
+	if len(killed) > 0 {
+		log.Info("killing-remaining-processes", lager.Data{"pids": killed})
 	}
 
 	stopper.retrier.Run(func() error {
 		return stopper.killAllRemaining(syscall.SIGKILL, devicesSubsystemPath, exceptions)
 	})
 
-	return nil // we killed, so everything must die
+	return killed, nil // we killed, so everything must die
 }
 
-func (stopper *CgroupStopper) killAllRemaining(signal syscall.Signal, cgroupPath string, exceptions []int) error {
-	pidsInCgroup, err := cgroups.GetAllPids(cgroupPath)
+// signalAndWait signals the processes in the cgroup and returns whether they
+// all exited within the grace period
+func (stopper *CgroupStopper) signalAndWait(signal syscall.Signal, cgroupPath string, exceptions []int, gracePeriod time.Duration) bool {
+	pids, err := remainingPids(cgroupPath, exceptions)
 	if err != nil {
-		return err
+		return false
 	}
 
-	var pidsToKill []int
-	for _, pid := range pidsInCgroup {
-		if contains(exceptions, pid) {
-			continue
+	if len(pids) == 0 {
+		return true
+	}
+
+	stopper.killer.Kill(signal, pids...)
+
+	deadline := stopper.clock.Now().Add(gracePeriod)
+	for {
+		if pids, err := remainingPids(cgroupPath, exceptions); err == nil && len(pids) == 0 {
+			return true
+		}
+
+		remaining := deadline.Sub(stopper.clock.Now())
+		if remaining <= 0 {
+			return false
+		}
+
+		if remaining > pollInterval {
+			remaining = pollInterval
 		}
 
-		pidsToKill = append(pidsToKill, pid)
+		stopper.clock.Sleep(remaining)
+	}
+}
+
+func (stopper *CgroupStopper) killAllRemaining(signal syscall.Signal, cgroupPath string, exceptions []int) error {
+	pidsInCgroup, err := cgroups.GetAllPids(cgroupPath)
+	if err != nil {
+		return err
 	}
 
+	pidsToKill := withoutExceptions(pidsInCgroup, exceptions)
 	if len(pidsToKill) == 0 {
 		return nil
 	}
 
+	if signal == syscall.SIGKILL && len(pidsToKill) == len(pidsInCgroup) && killCgroup(cgroupPath) {
+		return fmt.Errorf("still running after writing to cgroup.kill, %v", pidsToKill)
+	}
+
 	stopper.killer.Kill(signal, pidsToKill...)
 	return fmt.Errorf("still running after signal %s, %v", signal, pidsToKill)
 }
 
+// killCgroup kills every process in the cgroup and its descendants at once
+// through cgroup.kill, which is only present on the unified hierarchy of
+// kernels 5.14 and above. Unlike signalling each pid in turn, this cannot
+// race with processes forking.
+func killCgroup(cgroupPath string) bool {
+	killFile := filepath.Join(cgroupPath, "cgroup.kill")
+	if _, err := os.Stat(killFile); err != nil {
+		return false
+	}
+
+	return ioutil.WriteFile(killFile, []byte("1"), 0) == nil
+}
+
+func remainingPids(cgroupPath string, exceptions []int) ([]int, error) {
+	pidsInCgroup, err := cgroups.GetAllPids(cgroupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return withoutExceptions(pidsInCgroup, exceptions), nil
+}
+
+func withoutExceptions(pids, exceptions []int) []int {
+	var remaining []int
+	for _, pid := range pids {
+		if contains(exceptions, pid) {
+			continue
+		}
+
+		remaining = append(remaining, pid)
+	}
+
+	return remaining
+}
+
 func contains(a []int, b int) bool {
 	for _, i := range a {
 		if i == b {
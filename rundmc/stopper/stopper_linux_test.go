@@ -1,17 +1,20 @@
 package stopper_test
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"syscall"
+	"time"
 
 	"code.cloudfoundry.org/guardian/rundmc/stopper"
 	fakes "code.cloudfoundry.org/guardian/rundmc/stopper/stopperfakes"
 	"code.cloudfoundry.org/lager/lagertest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
 )
 
 var _ = Describe("CgroupStopper", func() {
@@ -19,15 +22,38 @@ var _ = Describe("CgroupStopper", func() {
 		fakeCgroupResolver *fakes.FakeCgroupPathResolver
 		fakeKiller         *fakes.FakeKiller
 		fakeRetrier        *fakes.FakeRetrier
+		fakeClock          *fakeclock.FakeClock
+		logger             *lagertest.TestLogger
 
 		subject                          *stopper.CgroupStopper
 		devicesCgroupPath, fakeCgroupDir string
 	)
 
+	writeProcs := func(procs string) {
+		Expect(ioutil.WriteFile(filepath.Join(devicesCgroupPath, "cgroup.procs"), []byte(procs), 0700)).To(Succeed())
+	}
+
+	// stopAllInBackground runs StopAll, which blocks on the fake clock while
+	// it waits for processes to exit, and sends the pids it killed on the
+	// returned channel
+	stopAllInBackground := func(exceptions []int, signal syscall.Signal, gracePeriod time.Duration) <-chan []int {
+		killed := make(chan []int, 1)
+		go func() {
+			defer GinkgoRecover()
+			pids, err := subject.StopAll(logger, "foo", exceptions, signal, gracePeriod)
+			Expect(err).NotTo(HaveOccurred())
+			killed <- pids
+		}()
+
+		return killed
+	}
+
 	BeforeEach(func() {
 		fakeCgroupResolver = new(fakes.FakeCgroupPathResolver)
 		fakeKiller = new(fakes.FakeKiller)
 		fakeRetrier = new(fakes.FakeRetrier)
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		logger = lagertest.NewTestLogger("test")
 
 		var err error
 		fakeCgroupDir, err = ioutil.TempDir("", "fakecgroupdir")
@@ -35,10 +61,10 @@ var _ = Describe("CgroupStopper", func() {
 
 		devicesCgroupPath = filepath.Join(fakeCgroupDir, "foo", "devices")
 		Expect(os.MkdirAll(devicesCgroupPath, 0700)).To(Succeed())
-		Expect(ioutil.WriteFile(filepath.Join(devicesCgroupPath, "cgroup.procs"), []byte(`1
+		writeProcs(`1
 3
 5
-9`), 0700)).To(Succeed())
+9`)
 
 		fakeCgroupResolver.ResolveStub = func(name string, subsystem string) (string, error) {
 			return filepath.Join(fakeCgroupDir, name, subsystem), nil
@@ -48,7 +74,7 @@ var _ = Describe("CgroupStopper", func() {
 			return fn()
 		}
 
-		subject = stopper.New(fakeCgroupResolver, fakeKiller, fakeRetrier)
+		subject = stopper.New(fakeCgroupResolver, fakeKiller, fakeRetrier, fakeClock)
 	})
 
 	AfterEach(func() {
@@ -56,84 +82,169 @@ var _ = Describe("CgroupStopper", func() {
 	})
 
 	It("does not send any signal to processes in the exceptions list", func() {
-		Expect(subject.StopAll(lagertest.NewTestLogger("test"), "foo", []int{3, 5}, false)).To(Succeed())
+		killed := stopAllInBackground([]int{3, 5}, syscall.SIGTERM, time.Second)
+		fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+		Eventually(killed).Should(Receive(ConsistOf(1, 9)))
 		Expect(fakeKiller).To(HaveKilled(0, syscall.SIGTERM, 1, 9))
 		Expect(fakeKiller).To(HaveKilled(1, syscall.SIGKILL, 1, 9))
 	})
 
-	Context("when the kill flag is true", func() {
+	Context("when the signal is SIGKILL", func() {
 		It("sends a KILL to all processes found in the cgroup", func() {
-			Expect(subject.StopAll(lagertest.NewTestLogger("test"), "foo", nil, true)).To(Succeed())
+			Expect(subject.StopAll(logger, "foo", nil, syscall.SIGKILL, 0)).To(ConsistOf(1, 3, 5, 9))
 			Expect(fakeKiller).To(HaveKilled(0, syscall.SIGKILL, 1, 3, 5, 9))
 		})
 
 		It("does not send TERM to processes found in the cgroup", func() {
-			Expect(subject.StopAll(lagertest.NewTestLogger("test"), "foo", nil, true)).To(Succeed())
+			Expect(subject.StopAll(logger, "foo", nil, syscall.SIGKILL, 0)).To(ConsistOf(1, 3, 5, 9))
 			Expect(fakeKiller).NotTo(HaveKilled(0, syscall.SIGTERM, 1, 3, 5, 9))
 		})
+
+		Describe("telling the retrier whether it should continue", func() {
+			It("tells the retrier that it is done when there are no processes left", func() {
+				fakeRetrier.RunStub = func(fn func() error) error {
+					writeProcs("9\n")
+					Expect(fn()).To(Succeed()) // should stop retrying when everything's gone
+					return nil
+				}
+
+				Expect(subject.StopAll(logger, "foo", []int{9}, syscall.SIGKILL, 0)).To(ConsistOf(1, 3, 5))
+				Expect(fakeRetrier.RunCallCount()).To(Equal(1))
+			})
+
+			It("tells the retrier that it is not yet done if there are processes left", func() {
+				fakeRetrier.RunStub = func(fn func() error) error {
+					Expect(fn()).NotTo(Succeed()) // should not stop retrying until everything's gone
+					return nil
+				}
+
+				Expect(subject.StopAll(logger, "foo", []int{9}, syscall.SIGKILL, 0)).To(ConsistOf(1, 3, 5))
+				Expect(fakeKiller).To(HaveKilled(0, syscall.SIGKILL, 1, 3, 5))
+			})
+		})
 	})
 
-	Context("when the kill flag is false", func() {
-		It("eventually returns successfully even if the cgroup.procs is unchanged (because it eventually gives up and SIGKILLs)", func() {
-			Expect(subject.StopAll(lagertest.NewTestLogger("test"), "foo", []int{3, 5}, false)).To(Succeed())
+	Context("when the cgroup can be killed through cgroup.kill", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(filepath.Join(devicesCgroupPath, "cgroup.kill"), []byte{}, 0700)).To(Succeed())
 		})
 
-		It("sends TERM to all the processes found in the cgroup", func() {
-			Expect(subject.StopAll(lagertest.NewTestLogger("test"), "foo", nil, false)).To(Succeed())
-			Expect(fakeKiller).To(HaveKilled(0, syscall.SIGTERM, 1, 3, 5, 9))
+		It("kills the whole cgroup rather than each process", func() {
+			Expect(subject.StopAll(logger, "foo", nil, syscall.SIGKILL, 0)).To(ConsistOf(1, 3, 5, 9))
+
+			Expect(ioutil.ReadFile(filepath.Join(devicesCgroupPath, "cgroup.kill"))).To(Equal([]byte("1")))
+			Expect(fakeKiller.KillCallCount()).To(Equal(0))
 		})
 
-		It("repeatedly sends TERM and KILL to the remaining processes until the retrier gives up", func() {
+		It("tells the retrier that it is not yet done until the processes are gone", func() {
 			fakeRetrier.RunStub = func(fn func() error) error {
-				Expect(ioutil.WriteFile(filepath.Join(devicesCgroupPath, "cgroup.procs"), []byte(`3
-9`), 0700)).To(Succeed())
-				err := fn()
-				return err
+				Expect(fn()).NotTo(Succeed())
+				return nil
 			}
 
-			Expect(subject.StopAll(lagertest.NewTestLogger("test"), "foo", []int{9}, false)).To(Succeed())
+			Expect(subject.StopAll(logger, "foo", nil, syscall.SIGKILL, 0)).To(ConsistOf(1, 3, 5, 9))
+		})
+
+		It("still sends TERM to each process", func() {
+			fakeKiller.KillStub = func(syscall.Signal, ...int) {
+				writeProcs("")
+			}
 
-			Expect(fakeKiller).To(HaveKilled(0, syscall.SIGTERM, 3))
-			Expect(fakeKiller).To(HaveKilled(1, syscall.SIGKILL, 3))
-			Expect(fakeRetrier.RunCallCount()).To(Equal(2))
+			Expect(subject.StopAll(logger, "foo", nil, syscall.SIGTERM, time.Second)).To(BeEmpty())
+			Expect(fakeKiller).To(HaveKilled(0, syscall.SIGTERM, 1, 3, 5, 9))
+			Expect(ioutil.ReadFile(filepath.Join(devicesCgroupPath, "cgroup.kill"))).To(BeEmpty())
 		})
 
-		Describe("telling the retrier whether it should continue", func() {
-			It("tells the retrier that it is done when there are no processes left", func() {
-				fakeRetrier.RunStub = func(fn func() error) error {
-					Expect(ioutil.WriteFile(filepath.Join(devicesCgroupPath, "cgroup.procs"), []byte(`9
-`), 0700)).To(Succeed())
-					Expect(fn()).To(Succeed()) // should stop retrying when everything's gone
-					return nil
+		Context("when there are exceptions", func() {
+			It("sends KILL to each of the other processes", func() {
+				Expect(subject.StopAll(logger, "foo", []int{3, 5}, syscall.SIGKILL, 0)).To(ConsistOf(1, 9))
+
+				Expect(ioutil.ReadFile(filepath.Join(devicesCgroupPath, "cgroup.kill"))).To(BeEmpty())
+				Expect(fakeKiller).To(HaveKilled(0, syscall.SIGKILL, 1, 9))
+			})
+		})
+	})
+
+	Context("when the signal is not SIGKILL", func() {
+		Context("and the processes exit within the grace period", func() {
+			BeforeEach(func() {
+				fakeKiller.KillStub = func(syscall.Signal, ...int) {
+					writeProcs("9\n")
 				}
+			})
 
-				Expect(subject.StopAll(lagertest.NewTestLogger("test"), "foo", []int{9}, false)).To(Succeed())
+			It("sends the signal once to all the processes found in the cgroup", func() {
+				Expect(subject.StopAll(logger, "foo", []int{9}, syscall.SIGTERM, time.Minute)).To(BeEmpty())
+				Expect(fakeKiller.KillCallCount()).To(Equal(1))
+				Expect(fakeKiller).To(HaveKilled(0, syscall.SIGTERM, 1, 3, 5))
+			})
 
-				Expect(fakeRetrier.RunCallCount()).To(Equal(2))
+			It("sends the chosen signal", func() {
+				Expect(subject.StopAll(logger, "foo", []int{9}, syscall.SIGINT, time.Minute)).To(BeEmpty())
+				Expect(fakeKiller).To(HaveKilled(0, syscall.SIGINT, 1, 3, 5))
 			})
 
-			It("tells the retrier that it is not yet done if there are processes left", func() {
-				fakeRetrier.RunStub = func(fn func() error) error {
-					Expect(fn()).NotTo(Succeed()) // should not stop retrying until everything's gone
-					return nil
+			It("does not kill anything", func() {
+				Expect(subject.StopAll(logger, "foo", []int{9}, syscall.SIGTERM, time.Minute)).To(BeEmpty())
+				Expect(fakeRetrier.RunCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("and there are no processes to signal", func() {
+			It("does not send any signal", func() {
+				Expect(subject.StopAll(logger, "foo", []int{1, 3, 5, 9}, syscall.SIGTERM, time.Minute)).To(BeEmpty())
+				Expect(fakeKiller.KillCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("and processes are still in cgroup.procs once the grace period has expired", func() {
+			BeforeEach(func() {
+				fakeKiller.KillStub = func(signal syscall.Signal, pids ...int) {
+					if signal == syscall.SIGTERM {
+						writeProcs("3\n9")
+					}
 				}
+			})
 
-				Expect(subject.StopAll(lagertest.NewTestLogger("test"), "foo", []int{9}, false)).To(Succeed())
+			It("waits for the grace period before sending KILL", func() {
+				killed := stopAllInBackground([]int{9}, syscall.SIGTERM, time.Second)
 
+				fakeClock.WaitForWatcherAndIncrement(500 * time.Millisecond)
+				Consistently(killed).ShouldNot(Receive())
+				Expect(fakeKiller.KillCallCount()).To(Equal(1))
+
+				fakeClock.WaitForWatcherAndIncrement(500 * time.Millisecond)
+				Eventually(killed).Should(Receive())
+			})
+
+			It("sends KILL to the remaining processes and reports them", func() {
+				killed := stopAllInBackground([]int{9}, syscall.SIGTERM, time.Second)
+				fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+				Eventually(killed).Should(Receive(Equal([]int{3})))
 				Expect(fakeKiller).To(HaveKilled(0, syscall.SIGTERM, 1, 3, 5))
-				Expect(fakeKiller).To(HaveKilled(1, syscall.SIGKILL, 1, 3, 5))
-				Expect(fakeRetrier.RunCallCount()).To(Equal(2))
+				Expect(fakeKiller).To(HaveKilled(1, syscall.SIGKILL, 3))
 			})
-		})
 
-		Context("and processes are still in cgroup.procs after the TERM was sent as often as the retrier is willing", func() {
-			It("eventually sends KILL to processes", func() {
-				Expect(subject.StopAll(lagertest.NewTestLogger("test"), "foo", []int{3, 5}, false)).To(Succeed())
-				Expect(fakeKiller).To(HaveKilled(1, syscall.SIGKILL, 1, 9))
+			It("waits for the default grace period when none is specified", func() {
+				killed := stopAllInBackground([]int{9}, syscall.SIGTERM, 0)
+
+				fakeClock.WaitForWatcherAndIncrement(stopper.DefaultGracePeriod - time.Millisecond)
+				Consistently(killed).ShouldNot(Receive())
+
+				fakeClock.WaitForWatcherAndIncrement(time.Millisecond)
+				Eventually(killed).Should(Receive(Equal([]int{3})))
 			})
 
 			It("always returns success if it killed, because kill always works", func() {
-				Expect(subject.StopAll(lagertest.NewTestLogger("test"), "foo", []int{3, 5}, false)).To(Succeed())
+				fakeRetrier.RunReturns(errors.New("still running"))
+				fakeRetrier.RunStub = nil
+
+				killed := stopAllInBackground([]int{9}, syscall.SIGTERM, time.Second)
+				fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+				Eventually(killed).Should(Receive())
 			})
 		})
 	})
@@ -2,12 +2,13 @@ package stopper
 
 import (
 	"syscall"
+	"time"
 
 	"code.cloudfoundry.org/lager"
 )
 
-func (stopper *CgroupStopper) StopAll(log lager.Logger, cgroupName string, exceptions []int, kill bool) error {
-	return nil
+func (stopper *CgroupStopper) StopAll(log lager.Logger, cgroupName string, exceptions []int, signal syscall.Signal, gracePeriod time.Duration) ([]int, error) {
+	return nil, nil
 }
 
 func (stopper *CgroupStopper) killAllRemaining(signal syscall.Signal, cgroupPath string, exceptions []int) error {
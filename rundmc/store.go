@@ -1,6 +1,7 @@
 package rundmc
 
 import (
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -62,3 +63,23 @@ func (s *states) IsStopped(handle string) bool {
 
 	return value == "stopped"
 }
+
+// StoreOOMKills records how many of the OOM kills counted in the memory
+// cgroup of the container have been recorded as events
+func (s *states) StoreOOMKills(handle string, kills uint64) {
+	s.props.Set(handle, "rundmc.oom_kills", strconv.FormatUint(kills, 10))
+}
+
+func (s *states) OOMKills(handle string) (uint64, bool) {
+	value, ok := s.props.Get(handle, "rundmc.oom_kills")
+	if !ok {
+		return 0, false
+	}
+
+	kills, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return kills, true
+}
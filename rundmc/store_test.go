@@ -132,4 +132,49 @@ var _ = Describe("States Store", func() {
 			})
 		})
 	})
+
+	It("stashes the recorded OOM kills on the property manager under the 'rundmc.oom_kills' key", func() {
+		states := rundmc.NewStateStore(props)
+		states.StoreOOMKills("foo", 3)
+
+		Expect(props.SetCallCount()).To(Equal(1))
+
+		handle, key, value := props.SetArgsForCall(0)
+		Expect(handle).To(Equal("foo"))
+		Expect(key).To(Equal("rundmc.oom_kills"))
+		Expect(value).To(Equal("3"))
+	})
+
+	Describe("OOMKills", func() {
+		It("returns the recorded OOM kills", func() {
+			props.GetReturns("3", true)
+
+			states := rundmc.NewStateStore(props)
+			kills, ok := states.OOMKills("some-handle")
+			Expect(ok).To(BeTrue())
+			Expect(kills).To(Equal(uint64(3)))
+
+			handle, key := props.GetArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(key).To(Equal("rundmc.oom_kills"))
+		})
+
+		Context("when no OOM kills have been recorded", func() {
+			It("returns false", func() {
+				states := rundmc.NewStateStore(props)
+				_, ok := states.OOMKills("some-handle")
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		Context("when the recorded OOM kills are not a number", func() {
+			It("returns false", func() {
+				props.GetReturns("lots", true)
+
+				states := rundmc.NewStateStore(props)
+				_, ok := states.OOMKills("some-handle")
+				Expect(ok).To(BeFalse())
+			})
+		})
+	})
 })